@@ -0,0 +1,245 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// AgentRunner runs the agent under evaluation on a task.
+type AgentRunner interface {
+	// Run runs the agent until its output ends. It sends the agent each prompt received
+	// from spec.Prompts, until the channel is closed.
+	Run(ctx context.Context, spec AgentRunSpec) (AgentRunResult, error)
+}
+
+// AgentRunSpec describes a run of the agent on a task.
+type AgentRunSpec struct {
+	// KubeConfig is the path to the kubeconfig of the cluster the agent works on.
+	KubeConfig string
+	// ClusterKubeConfigs are the paths to the kubeconfigs of the task's named clusters, by their
+	// KUBECONFIG_<NAME> variable, which TaskEnv also sets.
+	ClusterKubeConfigs map[string]string
+	// LLMConfig selects the model the agent uses.
+	LLMConfig model.LLMConfig
+	// TracePath is where the agent should write its trace.
+	TracePath string
+	// Env is the environment of the task, and TaskEnv the variables that the task and the
+	// LLM config set on top of the environment k8s-ai-bench runs in.
+	Env     []string
+	TaskEnv []string
+	// Prompts receives the prompts of the script steps, and is closed after the last one.
+	Prompts <-chan string
+	// Output receives the agent's output, which expectations are checked against,
+	// and Stderr its diagnostics.
+	Output io.Writer
+	Stderr io.Writer
+}
+
+// AgentRunResult describes how a run of the agent ended.
+type AgentRunResult struct {
+	// Exit describes how the agent process ended, if it was a process that did not exit successfully.
+	Exit *model.AgentExit
+}
+
+// Agent runners, selected with --agent-runner.
+const (
+	AgentRunnerExec = "exec"
+	AgentRunnerHTTP = "http"
+)
+
+// AgentConfig is an agent to evaluate.
+type AgentConfig struct {
+	// ID identifies the agent in results and output paths; it is empty when only one agent is evaluated.
+	ID string
+	// Bin is the path to the agent binary, and Args are passed to it after the standard flags.
+	Bin  string
+	Args []string
+}
+
+// agentFlag parses repeated --agent flags of the form "ID=PATH [ARGS...]".
+type agentFlag []AgentConfig
+
+func (f *agentFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var agents []string
+	for _, agent := range *f {
+		agents = append(agents, agent.ID+"="+strings.Join(append([]string{agent.Bin}, agent.Args...), " "))
+	}
+	return strings.Join(agents, ", ")
+}
+
+func (f *agentFlag) reset() {
+	*f = nil
+}
+
+func (f *agentFlag) Set(value string) error {
+	id, command, ok := strings.Cut(value, "=")
+	fields := strings.Fields(command)
+	if !ok || len(fields) == 0 {
+		return fmt.Errorf("must be ID=PATH [ARGS...]")
+	}
+	if !agentIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid agent ID %q, must match %s", id, agentIDPattern)
+	}
+	for _, agent := range *f {
+		if agent.ID == id {
+			return fmt.Errorf("duplicate agent ID %q", id)
+		}
+	}
+	*f = append(*f, AgentConfig{ID: id, Bin: fields[0], Args: fields[1:]})
+	return nil
+}
+
+// agentIDPattern matches agent IDs, which are used as directory names.
+var agentIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// newAgentRunner returns the runner of the agent, as selected in the config.
+func newAgentRunner(config EvalConfig, agent AgentConfig) (AgentRunner, error) {
+	switch config.AgentRunner {
+	case "", AgentRunnerExec:
+		if config.AgentImage != "" {
+			return newContainerAgentRunner(config.AgentImage, config.AgentContainer, agent)
+		}
+		return &execAgentRunner{agentBin: agent.Bin, args: agent.Args}, nil
+	case AgentRunnerHTTP:
+		return newHTTPAgentRunner(config.AgentHTTP)
+	default:
+		return nil, fmt.Errorf("unknown agent runner %q, must be %q or %q", config.AgentRunner, AgentRunnerExec, AgentRunnerHTTP)
+	}
+}
+
+// execAgentRunner runs the agent as a local kubectl-ai compatible binary,
+// sending the prompts on its stdin.
+type execAgentRunner struct {
+	agentBin string
+	args     []string
+}
+
+func (r *execAgentRunner) Run(ctx context.Context, spec AgentRunSpec) (AgentRunResult, error) {
+	cmd := exec.CommandContext(ctx, r.agentBin, agentArgs(spec, spec.KubeConfig, spec.TracePath, r.args)...)
+	// The agent runs in its own process group, so stopping it also stops the processes it
+	// started (e.g. kubectl port-forward).
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.Env = spec.Env
+	return runAgentProcess(ctx, cmd, spec)
+}
+
+// agentArgs returns the flags of a kubectl-ai compatible agent for the run, with the paths of the
+// kubeconfig and the trace as the agent sees them, followed by the agent's own args.
+func agentArgs(spec AgentRunSpec, kubeconfig, tracePath string, extraArgs []string) []string {
+	args := []string{
+		"--kubeconfig", kubeconfig,
+		"--llm-provider", spec.LLMConfig.ProviderID,
+		fmt.Sprintf("--enable-tool-use-shim=%t", spec.LLMConfig.EnableToolUseShim),
+		fmt.Sprintf("--quiet=%t", spec.LLMConfig.Quiet),
+		"--model", spec.LLMConfig.ModelID,
+		"--trace-path", tracePath,
+		"--skip-permissions",
+		"--show-tool-output",
+	}
+	if spec.LLMConfig.McpClient {
+		args = append(args, "--mcp-client")
+	}
+	args = append(args, generationArgs(spec.LLMConfig)...)
+	return append(args, extraArgs...)
+}
+
+// runAgentProcess runs the agent command, sending the prompts on its stdin until its output ends.
+func runAgentProcess(ctx context.Context, cmd *exec.Cmd, spec AgentRunSpec) (AgentRunResult, error) {
+	stdinReader, stdinWriter := io.Pipe()
+	cmd.Stdin = stdinReader
+	cmd.Stderr = spec.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return AgentRunResult{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return AgentRunResult{}, err
+	}
+
+	go func() {
+		defer stdinWriter.Close()
+		for prompt := range spec.Prompts {
+			// Writes to the pipe return once the prompt has been passed on to the agent.
+			if _, err := fmt.Fprintf(stdinWriter, "%s\n", prompt); err != nil {
+				// The agent has exited.
+				return
+			}
+		}
+	}()
+
+	_, copyErr := io.Copy(spec.Output, stdout)
+	// The agent has closed its output, stop sending prompts.
+	stdinWriter.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return AgentRunResult{Exit: agentExit(ctx, err)}, err
+	}
+	if copyErr != nil {
+		return AgentRunResult{}, fmt.Errorf("reading agent output: %w", copyErr)
+	}
+	return AgentRunResult{}, nil
+}
+
+// generationArgs returns the agent flags for the generation parameters set in the LLM config,
+// followed by its extra args.
+func generationArgs(llmConfig model.LLMConfig) []string {
+	var args []string
+	if llmConfig.Temperature != nil {
+		args = append(args, fmt.Sprintf("--temperature=%g", *llmConfig.Temperature))
+	}
+	if llmConfig.MaxTokens > 0 {
+		args = append(args, fmt.Sprintf("--max-tokens=%d", llmConfig.MaxTokens))
+	}
+	return append(args, llmConfig.ExtraArgs...)
+}
+
+// unknownFlagPattern matches the errors of common flag parsers for a flag they do not define.
+var unknownFlagPattern = regexp.MustCompile(`(?i)unknown (shorthand )?flag|flag provided but not defined|unrecognized (option|arguments)`)
+
+// rejectedGenerationArgs returns the generation args of the LLM config that the agent rejected
+// as unknown flags in its stderr, or all of them if the agent did not name the flag, or nil
+// if the stderr has no unknown flag error.
+func rejectedGenerationArgs(llmConfig model.LLMConfig, stderr string) []string {
+	args := generationArgs(llmConfig)
+	if len(args) == 0 || !unknownFlagPattern.MatchString(stderr) {
+		return nil
+	}
+	var rejected []string
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if strings.HasPrefix(name, "-") && strings.Contains(stderr, strings.TrimLeft(name, "-")) {
+			rejected = append(rejected, arg)
+		}
+	}
+	if len(rejected) == 0 {
+		return args
+	}
+	return rejected
+}
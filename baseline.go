@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// passRateGroup accumulates the pass rates of a group of pairs in the baseline and the current run.
+type passRateGroup struct {
+	total       int
+	oldPassRate float64
+	newPassRate float64
+	description string
+}
+
+func (g *passRateGroup) drop() float64 {
+	return (g.oldPassRate - g.newPassRate) / float64(g.total)
+}
+
+// pairSamples groups the samples of the results by task and LLM config pair.
+// Results with an error, and skipped results, are excluded from the samples.
+func pairSamples(results []model.TaskResult) map[resultPair]*samples {
+	grouped := make(map[resultPair]*samples)
+	for _, result := range evaluatedResults(results) {
+		pair := resultPair{Task: result.Task, LLMConfig: result.ConfigID()}
+		if grouped[pair] == nil {
+			grouped[pair] = &samples{}
+		}
+		grouped[pair].add(result)
+	}
+	return grouped
+}
+
+// checkBaseline compares the pass rates of the results with those in the baseline, for each
+// LLM config (and each category, if config.BaselineByCategory is set). Only the task and
+// LLM config pairs that ran in both are compared, so new tasks do not count.
+// The pass rate of a group is the mean over its pairs of the fraction of their runs that passed.
+// As in the pass@k tables, runs with an error are not samples, and pairs with only errors
+// in either run are not compared.
+// It returns an error if any pass rate dropped by more than config.MaxRegression.
+func checkBaseline(config EvalConfig, results []model.TaskResult) error {
+	baseline, err := loadRunResults(config.Baseline)
+	if err != nil {
+		return fmt.Errorf("loading baseline: %w", err)
+	}
+
+	oldSamples := pairSamples(baseline.Results)
+	newSamples := pairSamples(results)
+	categories := make(map[resultPair]string)
+	for _, result := range results {
+		categories[resultPair{Task: result.Task, LLMConfig: result.ConfigID()}] = categoryOf(result)
+	}
+
+	groups := make(map[string]*passRateGroup)
+	add := func(key, description string, oldPassRate, newPassRate float64) {
+		g := groups[key]
+		if g == nil {
+			g = &passRateGroup{description: description}
+			groups[key] = g
+		}
+		g.total++
+		g.oldPassRate += oldPassRate
+		g.newPassRate += newPassRate
+	}
+	for pair, newPair := range newSamples {
+		oldPair, ok := oldSamples[pair]
+		if !ok || oldPair.total == 0 || newPair.total == 0 {
+			continue
+		}
+		oldPassRate := float64(oldPair.passed) / float64(oldPair.total)
+		newPassRate := float64(newPair.passed) / float64(newPair.total)
+		add(pair.LLMConfig, pair.LLMConfig, oldPassRate, newPassRate)
+		if config.BaselineByCategory {
+			category := categories[pair]
+			add(pair.LLMConfig+"/"+category, fmt.Sprintf("%s (category %s)", pair.LLMConfig, category), oldPassRate, newPassRate)
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	comparison := compareResults(baseline.Results, results)
+	fmt.Printf("\nComparison with baseline %s:\n\n", config.Baseline)
+	fmt.Println("| LLM Config | Tasks | Baseline Pass Rate | Pass Rate | Drop |")
+	fmt.Println("|------------|-------|--------------------|-----------|------|")
+	var regressed []string
+	for _, key := range keys {
+		g := groups[key]
+		fmt.Printf("| %s | %d | %.0f%% | %.0f%% | %.2f |\n", g.description, g.total,
+			100*g.oldPassRate/float64(g.total), 100*g.newPassRate/float64(g.total), g.drop())
+		if g.drop() > config.MaxRegression {
+			regressed = append(regressed, fmt.Sprintf("%s dropped by %.2f", g.description, g.drop()))
+		}
+	}
+	fmt.Println()
+	for _, change := range comparison.Regressions {
+		fmt.Printf("Regressed: %s with %s (%s -> %s)\n", change.Task, change.LLMConfig, change.Old, change.New)
+	}
+	for _, change := range comparison.Improvements {
+		fmt.Printf("Improved: %s with %s (%s -> %s)\n", change.Task, change.LLMConfig, change.Old, change.New)
+	}
+	for _, pair := range comparison.OnlyInOld {
+		fmt.Printf("Not run (in baseline only): %s with %s\n", pair.Task, pair.LLMConfig)
+	}
+	if len(comparison.OnlyInNew) > 0 {
+		fmt.Printf("Not in baseline (not compared): %d task/LLM config pairs\n", len(comparison.OnlyInNew))
+	}
+
+	if len(regressed) > 0 {
+		return fmt.Errorf("pass rate regressed by more than %.2f compared to the baseline: %s", config.MaxRegression, strings.Join(regressed, ", "))
+	}
+	return nil
+}
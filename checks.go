@@ -0,0 +1,161 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// ClusterCheck is a declarative assertion about an object in the cluster,
+// evaluated after the agent finishes. Exactly one assertion must be set.
+type ClusterCheck struct {
+	Resource ResourceRef `json:"resource"`
+
+	// Exists checks whether the object exists (true) or does not exist (false).
+	Exists *bool `json:"exists,omitempty"`
+	// Condition checks that the object has a status condition with the given status.
+	Condition *ConditionCheck `json:"condition,omitempty"`
+	// ReadyReplicas checks .status.readyReplicas, e.g. of a Deployment or StatefulSet.
+	ReadyReplicas *int64 `json:"readyReplicas,omitempty"`
+	// Phase checks .status.phase, e.g. of a Pod.
+	Phase string `json:"phase,omitempty"`
+	// ConfigMapKey checks that a key in .data has the given value.
+	ConfigMapKey *ConfigMapKeyCheck `json:"configMapKey,omitempty"`
+}
+
+type ConditionCheck struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+type ConfigMapKeyCheck struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// checkedObject holds the fields of an object that checks can assert on.
+type checkedObject struct {
+	Status struct {
+		Phase         string `json:"phase"`
+		ReadyReplicas int64  `json:"readyReplicas"`
+		Conditions    []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+	Data map[string]string `json:"data"`
+}
+
+// Validate checks that the resource is identified and exactly one assertion is set.
+func (c ClusterCheck) Validate() error {
+	if c.Resource.Kind == "" || c.Resource.Name == "" {
+		return fmt.Errorf("resource must specify kind and name")
+	}
+	set := 0
+	for _, isSet := range []bool{c.Exists != nil, c.Condition != nil, c.ReadyReplicas != nil, c.Phase != "", c.ConfigMapKey != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of exists, condition, readyReplicas, phase or configMapKey must be set for %s, found %d", c.Resource, set)
+	}
+	if c.Condition != nil && (c.Condition.Type == "" || c.Condition.Status == "") {
+		return fmt.Errorf("condition must specify type and status for %s", c.Resource)
+	}
+	if c.ConfigMapKey != nil && c.ConfigMapKey.Key == "" {
+		return fmt.Errorf("configMapKey must specify key for %s", c.Resource)
+	}
+	return nil
+}
+
+// Evaluate runs the check against the cluster, returning a failure if it is not met.
+func (c ClusterCheck) Evaluate(ctx context.Context, kubeconfig string) *model.Failure {
+	args := []string{"get", c.Resource.kubectlType(), c.Resource.Name, "--ignore-not-found", "-o", "json"}
+	if c.Resource.Namespace != "" {
+		args = append(args, "--namespace", c.Resource.Namespace)
+	}
+	output, err := runKubectl(ctx, kubeconfig, args...)
+	if err != nil {
+		return &model.Failure{Message: fmt.Sprintf("%s: %v", c.Resource, err)}
+	}
+	exists := len(output) > 0
+
+	if c.Exists != nil {
+		if exists != *c.Exists {
+			return c.failure("exists", *c.Exists, exists)
+		}
+		return nil
+	}
+	if !exists {
+		return &model.Failure{Message: fmt.Sprintf("%s: not found", c.Resource)}
+	}
+
+	var obj checkedObject
+	if err := json.Unmarshal(output, &obj); err != nil {
+		return &model.Failure{Message: fmt.Sprintf("%s: parsing object: %v", c.Resource, err)}
+	}
+
+	switch {
+	case c.Condition != nil:
+		observed := "<missing>"
+		for _, condition := range obj.Status.Conditions {
+			if condition.Type == c.Condition.Type {
+				observed = condition.Status
+			}
+		}
+		if observed != c.Condition.Status {
+			return c.failure(fmt.Sprintf("condition %s", c.Condition.Type), c.Condition.Status, observed)
+		}
+	case c.ReadyReplicas != nil:
+		if obj.Status.ReadyReplicas != *c.ReadyReplicas {
+			return c.failure("readyReplicas", *c.ReadyReplicas, obj.Status.ReadyReplicas)
+		}
+	case c.Phase != "":
+		if obj.Status.Phase != c.Phase {
+			return c.failure("phase", c.Phase, obj.Status.Phase)
+		}
+	case c.ConfigMapKey != nil:
+		observed, ok := obj.Data[c.ConfigMapKey.Key]
+		if !ok {
+			observed = "<missing>"
+		}
+		if observed != c.ConfigMapKey.Value {
+			return c.failure(fmt.Sprintf("data key %s", c.ConfigMapKey.Key), c.ConfigMapKey.Value, observed)
+		}
+	}
+	return nil
+}
+
+func (c ClusterCheck) failure(what string, expected, observed any) *model.Failure {
+	return &model.Failure{
+		Message: fmt.Sprintf("%s: expected %s %v, observed %v", c.Resource, what, expected, observed),
+	}
+}
+
+// evaluateChecks runs all checks against the cluster and returns the failures.
+func evaluateChecks(ctx context.Context, checks []ClusterCheck, kubeconfig string) []model.Failure {
+	var failures []model.Failure
+	for _, check := range checks {
+		if failure := check.Evaluate(ctx, kubeconfig); failure != nil {
+			failures = append(failures, *failure)
+		}
+	}
+	return failures
+}
@@ -0,0 +1,235 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/vcluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+type CleanupConfig struct {
+	// Prefix selects the clusters to clean up by name.
+	Prefix string
+	// RunID selects the clusters created by a specific run (vcluster only).
+	RunID string
+	// OlderThan only selects clusters created more than this long ago, if the provider can report creation times.
+	OlderThan time.Duration
+	// KeptIn selects the clusters kept by --keep-cluster-on-failure in this output directory.
+	KeptIn string
+	// Delete actually deletes the clusters; otherwise we only print them.
+	Delete bool
+	// Yes skips the confirmation prompt before deleting.
+	Yes bool
+}
+
+func runClusterCleanup() error {
+	var evalConfig EvalConfig
+	config := CleanupConfig{
+		Prefix: "k8s-ai-bench-",
+	}
+
+	// Set custom usage for 'cleanup' subcommand
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s cleanup [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Find (and optionally delete) stale k8s-ai-bench clusters.\n")
+		fmt.Fprintf(os.Stderr, "Without --delete, the matching clusters are only listed.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&config.Prefix, "prefix", config.Prefix, "Only consider clusters whose name starts with this prefix")
+	flag.StringVar(&config.RunID, "run-id", "", "Only consider clusters created by this run (vcluster only)")
+	flag.StringVar(&config.KeptIn, "kept-in", "", "Only consider clusters kept by --keep-cluster-on-failure in this output directory")
+	flag.DurationVar(&config.OlderThan, "older-than", 0, "Only consider clusters created more than this long ago (e.g. 2h)")
+	flag.BoolVar(&config.Delete, "delete", false, "Delete the matching clusters (default is a dry run)")
+	flag.BoolVar(&config.Yes, "yes", false, "Do not ask for confirmation before deleting")
+	completeClusterFlags := registerClusterFlags(&evalConfig)
+	flag.Parse()
+
+	if err := completeClusterFlags(); err != nil {
+		return err
+	}
+
+	clusterProvider, cleanupProvider, err := newClusterProvider(evalConfig)
+	if err != nil {
+		return err
+	}
+	defer cleanupProvider()
+
+	var keptMarkers map[string][]string
+	if config.KeptIn != "" {
+		keptMarkers, err = findKeptClusters(config.KeptIn, evalConfig.ClusterProvider)
+		if err != nil {
+			return err
+		}
+	}
+
+	names, err := findStaleClusters(clusterProvider, config, keptMarkers)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No matching clusters found")
+		return nil
+	}
+
+	fmt.Printf("Found %d matching %s clusters:\n", len(names), evalConfig.ClusterProvider)
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+
+	if !config.Delete {
+		fmt.Println("\nDry run; pass --delete to delete these clusters")
+		return nil
+	}
+
+	if !config.Yes {
+		fmt.Printf("\nDelete %d clusters? [y/N] ", len(names))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	var errs []error
+	// A marker is removed once every cluster it records is deleted.
+	undeleted := make(map[string]int)
+	for _, name := range names {
+		for _, marker := range keptMarkers[name] {
+			undeleted[marker]++
+		}
+	}
+	for _, name := range names {
+		if err := clusterProvider.Delete(name); err != nil {
+			errs = append(errs, fmt.Errorf("deleting cluster %q: %w", name, err))
+			continue
+		}
+		for _, marker := range keptMarkers[name] {
+			if undeleted[marker]--; undeleted[marker] > 0 {
+				continue
+			}
+			if err := os.Remove(marker); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// findStaleClusters returns the clusters matching the cleanup config.
+// With --kept-in, the candidates are the kept clusters instead of the provider's clusters.
+func findStaleClusters(clusterProvider cluster.Provider, config CleanupConfig, kept map[string][]string) ([]string, error) {
+	var names []string
+	if config.KeptIn != "" {
+		for name := range kept {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else if config.RunID != "" {
+		vclusterProvider, ok := clusterProvider.(*vcluster.Provider)
+		if !ok {
+			return nil, fmt.Errorf("--run-id is only supported with the vcluster cluster provider")
+		}
+		runClusters, err := vclusterProvider.ListRunClusters(config.RunID)
+		if err != nil {
+			return nil, err
+		}
+		names = runClusters
+	} else {
+		allClusters, err := clusterProvider.List()
+		if err != nil {
+			return nil, fmt.Errorf("listing clusters: %w", err)
+		}
+		names = allClusters
+	}
+
+	var matches []string
+	for _, name := range names {
+		if name == "" || !strings.HasPrefix(name, config.Prefix) {
+			continue
+		}
+
+		if config.OlderThan > 0 {
+			reporter, ok := clusterProvider.(cluster.CreationTimeReporter)
+			if !ok {
+				return nil, fmt.Errorf("--older-than is not supported, the cluster provider cannot report creation times")
+			}
+			created, err := reporter.CreationTime(name)
+			if err != nil {
+				fmt.Printf("Warning: skipping cluster %q: %v\n", name, err)
+				continue
+			}
+			if time.Since(created) < config.OlderThan {
+				continue
+			}
+		}
+
+		matches = append(matches, name)
+	}
+	return matches, nil
+}
+
+// findKeptClusters finds the kept-cluster.yaml markers of clusters kept by --keep-cluster-on-failure
+// under dir, for the given cluster provider. It returns the marker files of each cluster, by cluster name.
+func findKeptClusters(dir string, provider string) (map[string][]string, error) {
+	kept := make(map[string][]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() != keptClusterFile {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var cluster model.KeptCluster
+		if err := yaml.Unmarshal(data, &cluster); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if cluster.Name == "" || cluster.Provider != provider {
+			return nil
+		}
+		kept[cluster.Name] = append(kept[cluster.Name], path)
+		// The other clusters of a task with several were kept with the first.
+		for _, other := range cluster.Clusters {
+			if other.Name != cluster.Name {
+				kept[other.Name] = append(kept[other.Name], path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding kept clusters in %s: %w", dir, err)
+	}
+	return kept, nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// clusterDiffFile holds the diff of the cluster state before and after the agent ran, in the
+// task output directory, with --capture-diff.
+const clusterDiffFile = "cluster-diff.yaml"
+
+// defaultDiffResources are the resource types snapshotted with --capture-diff by default.
+var defaultDiffResources = []string{
+	"namespaces", "deployments", "statefulsets", "daemonsets", "jobs", "cronjobs", "services",
+	"configmaps", "persistentvolumeclaims", "ingresses", "networkpolicies", "serviceaccounts",
+	"roles", "rolebindings", "clusterroles", "clusterrolebindings", "horizontalpodautoscalers",
+}
+
+// noisyFields are fields that change without anyone changing the object, removed from snapshots
+// wherever they are.
+var noisyFields = map[string]bool{
+	"resourceVersion":    true,
+	"managedFields":      true,
+	"lastTransitionTime": true,
+	"lastUpdateTime":     true,
+	"lastProbeTime":      true,
+	"lastHeartbeatTime":  true,
+	"lastScaleTime":      true,
+}
+
+// noisyAnnotations are annotations that repeat other fields of the object.
+var noisyAnnotations = []string{"kubectl.kubernetes.io/last-applied-configuration"}
+
+// diffSnapshot holds the objects of the snapshotted resource types, by objectKey.
+type diffSnapshot map[string]map[string]any
+
+// clusterDiff is the content of cluster-diff.yaml.
+type clusterDiff struct {
+	// Resources are the snapshotted resource types.
+	Resources []string       `json:"resources"`
+	Created   []string       `json:"created,omitempty"`
+	Deleted   []string       `json:"deleted,omitempty"`
+	Modified  []modifiedDiff `json:"modified,omitempty"`
+}
+
+// modifiedDiff is an object changed by the agent, with its changed fields.
+type modifiedDiff struct {
+	Object  string        `json:"object"`
+	Changes []fieldChange `json:"changes"`
+}
+
+// fieldChange is a changed field, by its path in the object (e.g. spec.replicas);
+// Before is unset for an added field, and After for a removed one.
+type fieldChange struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// snapshotCluster gets the objects of the diff resource types from the task's cluster, without
+// their noisy fields.
+func (x *TaskExecution) snapshotCluster(ctx context.Context) (diffSnapshot, error) {
+	output, err := runKubectl(ctx, x.kubeConfig, "get", strings.Join(x.diffResources, ","), "--all-namespaces", "--ignore-not-found", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(diffSnapshot)
+	if len(strings.TrimSpace(string(output))) == 0 {
+		return snapshot, nil
+	}
+	var list struct {
+		Items []map[string]any `json:"items"`
+	}
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("parsing the cluster snapshot: %w", err)
+	}
+	for _, object := range list.Items {
+		stripNoisyFields(object)
+		snapshot[objectKey(object)] = object
+	}
+	return snapshot, nil
+}
+
+// objectKey identifies an object in a snapshot, e.g. "Deployment.apps shop/web".
+func objectKey(object map[string]any) string {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	ref := ResourceRef{Kind: kind, Name: name, Namespace: namespace}
+	if group, _, ok := strings.Cut(apiVersion, "/"); ok {
+		ref.Group = group
+	}
+	return ref.String()
+}
+
+// stripNoisyFields removes the noisy fields and annotations from the object.
+func stripNoisyFields(value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if noisyFields[key] {
+				delete(value, key)
+				continue
+			}
+			stripNoisyFields(field)
+		}
+		if metadata, ok := value["metadata"].(map[string]any); ok {
+			if annotations, ok := metadata["annotations"].(map[string]any); ok {
+				for _, annotation := range noisyAnnotations {
+					delete(annotations, annotation)
+				}
+				if len(annotations) == 0 {
+					delete(metadata, "annotations")
+				}
+			}
+		}
+	case []any:
+		for _, item := range value {
+			stripNoisyFields(item)
+		}
+	}
+}
+
+// diffSnapshots returns the objects created, deleted and modified between the snapshots.
+func diffSnapshots(resources []string, before, after diffSnapshot) clusterDiff {
+	diff := clusterDiff{Resources: resources}
+	for key, object := range after {
+		previous, ok := before[key]
+		if !ok {
+			diff.Created = append(diff.Created, key)
+			continue
+		}
+		if changes := diffFields("", previous, object); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, modifiedDiff{Object: key, Changes: changes})
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			diff.Deleted = append(diff.Deleted, key)
+		}
+	}
+	sort.Strings(diff.Created)
+	sort.Strings(diff.Deleted)
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].Object < diff.Modified[j].Object })
+	return diff
+}
+
+// diffFields returns the changes from before to after, recursing into maps and lists of the same length.
+func diffFields(path string, before, after any) []fieldChange {
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			break
+		}
+		var changes []fieldChange
+		for _, key := range sortedKeys(mergeKeys(b, a)) {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			changes = append(changes, diffFields(fieldPath, b[key], a[key])...)
+		}
+		return changes
+	case []any:
+		a, ok := after.([]any)
+		if !ok || len(a) != len(b) {
+			break
+		}
+		var changes []fieldChange
+		for i := range b {
+			changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, i), b[i], a[i])...)
+		}
+		return changes
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []fieldChange{{Path: path, Before: before, After: after}}
+}
+
+// mergeKeys returns a map with the keys of both maps, for iterating over them.
+func mergeKeys(a, b map[string]any) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// summary counts the created, modified and deleted objects of each kind.
+func (d clusterDiff) summary() []model.ResourceDiff {
+	counts := make(map[string]*model.ResourceDiff)
+	count := func(key string) *model.ResourceDiff {
+		kind, _, _ := strings.Cut(key, " ")
+		if counts[kind] == nil {
+			counts[kind] = &model.ResourceDiff{Kind: kind}
+		}
+		return counts[kind]
+	}
+	for _, key := range d.Created {
+		count(key).Created++
+	}
+	for _, modified := range d.Modified {
+		count(modified.Object).Modified++
+	}
+	for _, key := range d.Deleted {
+		count(key).Deleted++
+	}
+	summary := []model.ResourceDiff{}
+	for _, kind := range sortedKeys(counts) {
+		summary = append(summary, *counts[kind])
+	}
+	return summary
+}
+
+// captureClusterDiff snapshots the cluster after the agent ran, and writes the diff with the
+// snapshot taken before to cluster-diff.yaml, summarized in the result.
+func (x *TaskExecution) captureClusterDiff(ctx context.Context, before diffSnapshot) error {
+	after, err := x.snapshotCluster(ctx)
+	if err != nil {
+		return fmt.Errorf("snapshotting the cluster after the agent ran: %w", err)
+	}
+	diff := diffSnapshots(x.diffResources, before, after)
+	if err := writeToYAMLFile(filepath.Join(x.taskOutputDir, clusterDiffFile), diff); err != nil {
+		return err
+	}
+	x.result.ClusterDiff = diff.summary()
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// resultPair identifies the results of a task with an LLM config.
+type resultPair struct {
+	Task      string `json:"task"`
+	LLMConfig string `json:"llmConfig"`
+}
+
+// resultChange is a pair whose outcome differs between two runs.
+type resultChange struct {
+	resultPair
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// runComparison is the difference between the results of two runs.
+type runComparison struct {
+	// Regressions passed in the old run and failed or errored in the new one.
+	Regressions []resultChange `json:"regressions"`
+	// Improvements failed or errored in the old run and passed in the new one.
+	Improvements []resultChange `json:"improvements"`
+	// UnchangedPassing and UnchangedFailing count the pairs with the same outcome in both runs.
+	UnchangedPassing int `json:"unchangedPassing"`
+	UnchangedFailing int `json:"unchangedFailing"`
+	// OnlyInOld and OnlyInNew are the pairs that ran in only one of the runs.
+	OnlyInOld []resultPair `json:"onlyInOld"`
+	OnlyInNew []resultPair `json:"onlyInNew"`
+}
+
+func runCompare() error {
+	var jsonPath string
+
+	// Set custom usage for 'compare' subcommand
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s compare [options] <old-output-dir> <new-output-dir>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Compare the results of two k8s-ai-bench runs, matching results by task and LLM config.\n")
+		fmt.Fprintf(os.Stderr, "Exits non-zero if any task regressed.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&jsonPath, "json", "", "Also write the comparison as JSON to this path ('-' for stdout, instead of the text summary)")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return fmt.Errorf("compare needs exactly two output directories")
+	}
+
+	oldResults, err := loadRunResults(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	newResults, err := loadRunResults(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	comparison := compareResults(oldResults.Results, newResults.Results)
+	if jsonPath != "-" {
+		printComparison(comparison)
+	}
+
+	if jsonPath != "" {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling comparison: %w", err)
+		}
+		data = append(data, '\n')
+		if jsonPath == "-" {
+			os.Stdout.Write(data)
+		} else if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+			return fmt.Errorf("writing to file %q: %w", jsonPath, err)
+		}
+	}
+
+	if len(comparison.Regressions) > 0 {
+		return fmt.Errorf("found %d regressions", len(comparison.Regressions))
+	}
+	return nil
+}
+
+// loadRunResults loads the aggregated results of a run from a results.json file, or from an
+// output directory: its results.json if there is one, otherwise its results.yaml files.
+func loadRunResults(path string) (*model.RunResults, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	file := path
+	if info.IsDir() {
+		file = filepath.Join(path, runResultsFile)
+	}
+	data, err := os.ReadFile(file)
+	if err == nil {
+		var runResults model.RunResults
+		if err := json.Unmarshal(data, &runResults); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		return &runResults, nil
+	}
+	if !info.IsDir() || !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s: %w", file, err)
+	}
+
+	// Runs from before results.json was written.
+	results, err := collectResults(path)
+	if err != nil {
+		return nil, fmt.Errorf("collecting results from %s: %w", path, err)
+	}
+	return &model.RunResults{Results: results}, nil
+}
+
+// pairOutcomes reduces the results of each task and LLM config pair to one outcome:
+// "success" if every run passed, "fail" if any run failed, otherwise "error".
+// Skipped results are ignored.
+func pairOutcomes(results []model.TaskResult) map[resultPair]string {
+	outcomes := make(map[resultPair]string)
+	for _, result := range evaluatedResults(results) {
+		pair := resultPair{Task: result.Task, LLMConfig: result.ConfigID()}
+		switch outcome, seen := outcomes[pair]; {
+		case !seen, outcome == "success":
+			outcomes[pair] = result.Result
+		case outcome == "error" && result.Result == "fail":
+			outcomes[pair] = "fail"
+		}
+	}
+	return outcomes
+}
+
+// compareResults matches the results of two runs by task and LLM config.
+func compareResults(oldResults, newResults []model.TaskResult) runComparison {
+	oldOutcomes := pairOutcomes(oldResults)
+	newOutcomes := pairOutcomes(newResults)
+
+	comparison := runComparison{
+		Regressions:  []resultChange{},
+		Improvements: []resultChange{},
+		OnlyInOld:    []resultPair{},
+		OnlyInNew:    []resultPair{},
+	}
+	for pair, oldOutcome := range oldOutcomes {
+		newOutcome, ok := newOutcomes[pair]
+		if !ok {
+			comparison.OnlyInOld = append(comparison.OnlyInOld, pair)
+			continue
+		}
+		oldPassed, newPassed := oldOutcome == "success", newOutcome == "success"
+		switch {
+		case oldPassed && !newPassed:
+			comparison.Regressions = append(comparison.Regressions, resultChange{resultPair: pair, Old: oldOutcome, New: newOutcome})
+		case !oldPassed && newPassed:
+			comparison.Improvements = append(comparison.Improvements, resultChange{resultPair: pair, Old: oldOutcome, New: newOutcome})
+		case newPassed:
+			comparison.UnchangedPassing++
+		default:
+			comparison.UnchangedFailing++
+		}
+	}
+	for pair := range newOutcomes {
+		if _, ok := oldOutcomes[pair]; !ok {
+			comparison.OnlyInNew = append(comparison.OnlyInNew, pair)
+		}
+	}
+
+	sortChanges(comparison.Regressions)
+	sortChanges(comparison.Improvements)
+	sortPairs(comparison.OnlyInOld)
+	sortPairs(comparison.OnlyInNew)
+	return comparison
+}
+
+func sortPairs(pairs []resultPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].LLMConfig != pairs[j].LLMConfig {
+			return pairs[i].LLMConfig < pairs[j].LLMConfig
+		}
+		return pairs[i].Task < pairs[j].Task
+	})
+}
+
+func sortChanges(changes []resultChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].LLMConfig != changes[j].LLMConfig {
+			return changes[i].LLMConfig < changes[j].LLMConfig
+		}
+		return changes[i].Task < changes[j].Task
+	})
+}
+
+func printComparison(comparison runComparison) {
+	fmt.Printf("Regressions (%d):\n", len(comparison.Regressions))
+	for _, change := range comparison.Regressions {
+		fmt.Printf("  %s with %s: %s -> %s\n", change.Task, change.LLMConfig, change.Old, change.New)
+	}
+	fmt.Printf("\nImprovements (%d):\n", len(comparison.Improvements))
+	for _, change := range comparison.Improvements {
+		fmt.Printf("  %s with %s: %s -> %s\n", change.Task, change.LLMConfig, change.Old, change.New)
+	}
+	fmt.Printf("\nUnchanged: %d passing, %d failing\n", comparison.UnchangedPassing, comparison.UnchangedFailing)
+	if len(comparison.OnlyInOld) > 0 {
+		fmt.Printf("\nOnly in the old run (%d):\n", len(comparison.OnlyInOld))
+		for _, pair := range comparison.OnlyInOld {
+			fmt.Printf("  %s with %s\n", pair.Task, pair.LLMConfig)
+		}
+	}
+	if len(comparison.OnlyInNew) > 0 {
+		fmt.Printf("\nOnly in the new run (%d):\n", len(comparison.OnlyInNew))
+		for _, pair := range comparison.OnlyInNew {
+			fmt.Printf("  %s with %s\n", pair.Task, pair.LLMConfig)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// clusterProviders are the names of the supported cluster providers (see newClusterProvider).
+var clusterProviders = []string{"kind", "vcluster", "minikube", "gke", "external"}
+
+// loadConfigFile reads a YAML file of EvalConfig fields into config, over the values it already has.
+// Fields are named as in EvalConfig or their JSON names, in any case (e.g. tasksDir), durations
+// are written like 30s, and unknown fields are an error.
+func loadConfigFile(path string, config *EvalConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	var raw any
+	if err := json.Unmarshal(jsonData, &raw); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if raw == nil {
+		return nil
+	}
+	raw, err = parseDurations(raw, reflect.TypeOf(*config), "")
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseDurations replaces the strings in value that are decoded into a time.Duration of t
+// with their number of nanoseconds, which is how encoding/json decodes durations.
+// Fields are matched like encoding/json matches them; unknown fields are left to it.
+func parseDurations(value any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case string:
+		if t != durationType {
+			return v, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return int64(d), nil
+	case map[string]any:
+		for key, item := range v {
+			var itemType reflect.Type
+			switch t.Kind() {
+			case reflect.Struct:
+				field, ok := jsonField(t, key)
+				if !ok {
+					continue
+				}
+				itemType = field.Type
+			case reflect.Map:
+				itemType = t.Elem()
+			default:
+				continue
+			}
+			parsed, err := parseDurations(item, itemType, strings.TrimPrefix(path+"."+key, "."))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = parsed
+		}
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return v, nil
+		}
+		for i, item := range v {
+			parsed, err := parseDurations(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = parsed
+		}
+	}
+	return value, nil
+}
+
+// jsonField returns the exported field of the struct type that encoding/json decodes key into.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// resettable flag values are cleared before the command line is parsed again, so that
+// repeated flags replace the values from the config file rather than adding to them.
+type resettable interface {
+	reset()
+}
+
+// applyFlagOverrides parses the command line again over the values from the config file,
+// so flags that were given explicitly take precedence.
+func applyFlagOverrides(args []string) error {
+	flag.Visit(func(f *flag.Flag) {
+		if value, ok := f.Value.(resettable); ok {
+			value.reset()
+		}
+	})
+	return flag.CommandLine.Parse(args)
+}
+
+// validateEvalConfig checks the config before anything is created, so misconfigurations
+// fail right away rather than after the cluster was created.
+func validateEvalConfig(config EvalConfig) error {
+	if !slices.Contains(clusterProviders, config.ClusterProvider) {
+		return fmt.Errorf("unknown cluster provider %q, must be one of %s", config.ClusterProvider, strings.Join(clusterProviders, ", "))
+	}
+	for _, tasksDir := range config.tasksDirs() {
+		info, err := os.Stat(tasksDir)
+		if err != nil {
+			return fmt.Errorf("invalid tasks directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid tasks directory: %s is not a directory", tasksDir)
+		}
+	}
+	if config.AgentImage != "" && config.AgentRunner == AgentRunnerHTTP {
+		return fmt.Errorf("--agent-image is not supported with --agent-runner=http")
+	}
+	if config.AgentImage == "" && (config.AgentRunner == "" || config.AgentRunner == AgentRunnerExec) {
+		for _, agent := range config.Agents {
+			if agent.Bin == "" {
+				return fmt.Errorf("no agent binary, set --agent-bin or --agent")
+			}
+			if _, err := exec.LookPath(agent.Bin); err != nil {
+				return fmt.Errorf("agent binary %q is not executable: %w", agent.Bin, err)
+			}
+		}
+	}
+	if len(config.LLMConfigs) == 0 {
+		return fmt.Errorf("no LLM configs to evaluate")
+	}
+	if err := validateLLMConfigs(config.LLMConfigs); err != nil {
+		return err
+	}
+	if config.OutputDir == "" {
+		return fmt.Errorf("no output directory, set --output-dir")
+	}
+	return nil
+}
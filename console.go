@@ -0,0 +1,190 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ConsoleOutput selects what the console shows while tasks run, with --console-output.
+// Task logs always get everything.
+type ConsoleOutput string
+
+const (
+	// ConsoleOutputQuiet shows the worker start and finish lines, warnings and the summary.
+	ConsoleOutputQuiet ConsoleOutput = "quiet"
+	// ConsoleOutputSummary also shows the progress of each task (steps, commands, verifiers),
+	// but not the output of the agent and commands. This is the default.
+	ConsoleOutputSummary ConsoleOutput = "summary"
+	// ConsoleOutputFull also shows the output of the agent and commands.
+	ConsoleOutputFull ConsoleOutput = "full"
+)
+
+func (c ConsoleOutput) Validate() error {
+	switch c {
+	case ConsoleOutputQuiet, ConsoleOutputSummary, ConsoleOutputFull:
+		return nil
+	default:
+		return fmt.Errorf("invalid console output %q, must be %q, %q or %q", c, ConsoleOutputQuiet, ConsoleOutputSummary, ConsoleOutputFull)
+	}
+}
+
+// taskConsole holds the console writers of a task.
+type taskConsole struct {
+	// stdout and stderr receive the output of the agent and commands.
+	stdout io.Writer
+	stderr io.Writer
+	// progress receives messages about the progress of the task.
+	progress io.Writer
+	// color is set if the console output can be colored.
+	color bool
+	// prefixers hold the partial lines written to the console, if its lines are prefixed.
+	prefixers []*linePrefixer
+}
+
+// newTaskConsole returns the console writers of a task for the console output mode.
+// With a prefix, each line written to the console starts with it, so the output of
+// concurrent tasks can be told apart.
+func newTaskConsole(mode ConsoleOutput, prefix string, color bool) *taskConsole {
+	console := &taskConsole{stdout: io.Discard, stderr: io.Discard, progress: io.Discard, color: color}
+	switch mode {
+	case ConsoleOutputFull:
+		console.stdout = os.Stdout
+		console.stderr = os.Stderr
+		console.progress = os.Stdout
+	case ConsoleOutputSummary, "":
+		console.progress = os.Stdout
+	}
+	if prefix == "" {
+		return console
+	}
+	prefix = "[" + prefix + "] "
+	if color {
+		prefix = colorize(prefixColor(prefix), prefix)
+	}
+	// Progress messages and the output of commands share stdout, so they share a prefixer.
+	stdout := newLinePrefixer(os.Stdout, prefix)
+	stderr := newLinePrefixer(os.Stderr, prefix)
+	console.prefixers = []*linePrefixer{stdout, stderr}
+	if console.stdout != io.Discard {
+		console.stdout = stdout
+		console.stderr = stderr
+	}
+	if console.progress != io.Discard {
+		console.progress = stdout
+	}
+	return console
+}
+
+// flush writes out any partial lines held for prefixing.
+func (c *taskConsole) flush() {
+	for _, prefixer := range c.prefixers {
+		prefixer.Flush()
+	}
+}
+
+// ANSI colors of console output.
+const (
+	colorGreen   = "32"
+	colorYellow  = "33"
+	colorBlue    = "34"
+	colorMagenta = "35"
+	colorCyan    = "36"
+)
+
+// colorize wraps text in the escape codes of an ANSI color.
+func colorize(color, text string) string {
+	return "\033[" + color + "m" + text + "\033[0m"
+}
+
+// prefixColors are the colors of line prefixes, picked by a hash of the prefix so each task keeps its color.
+var prefixColors = []string{colorCyan, colorMagenta, colorBlue, colorYellow, colorGreen}
+
+func prefixColor(prefix string) string {
+	hash := 0
+	for _, c := range prefix {
+		hash = hash*31 + int(c)
+	}
+	if hash < 0 {
+		hash = -hash
+	}
+	return prefixColors[hash%len(prefixColors)]
+}
+
+// isTerminal reports whether f is a terminal, so its output can be colored.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// linePrefixer writes each line written to it to out, starting with a prefix. Lines are written
+// whole, so lines of concurrent tasks do not mix. A carriage return (e.g. from a spinner) starts
+// the line over, so only the latest version of a redrawn line is written.
+type linePrefixer struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	prefix string
+	line   []byte
+	// carriageReturn is set after a carriage return, which starts the line over unless a newline follows.
+	carriageReturn bool
+}
+
+func newLinePrefixer(out io.Writer, prefix string) *linePrefixer {
+	return &linePrefixer{out: out, prefix: prefix}
+}
+
+func (p *linePrefixer) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, c := range b {
+		if p.carriageReturn && c != '\n' {
+			p.line = p.line[:0]
+		}
+		p.carriageReturn = false
+		switch c {
+		case '\r':
+			p.carriageReturn = true
+		case '\n':
+			p.writeLine()
+		default:
+			p.line = append(p.line, c)
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes out the partial line, if any.
+func (p *linePrefixer) Flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.carriageReturn = false
+	if len(p.line) > 0 {
+		p.writeLine()
+	}
+}
+
+func (p *linePrefixer) writeLine() {
+	// Console errors are ignored, they must not fail the task.
+	p.out.Write(append([]byte(p.prefix), append(p.line, '\n')...))
+	p.line = p.line[:0]
+}
+
+// progressf writes a message about the progress of the task to the console.
+func (x *TaskExecution) progressf(format string, args ...any) {
+	fmt.Fprintf(x.console.progress, format, args...)
+}
@@ -0,0 +1,295 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ContainerAgentConfig configures how the agent is run in a container, with --agent-image.
+type ContainerAgentConfig struct {
+	// Runtime is the container CLI, "docker" or "podman".
+	Runtime string
+	// Network is the network of the container. On the "host" network, the agent reaches clusters
+	// served on the host's loopback address (e.g. kind). On other networks, loopback servers of
+	// the kubeconfig are rewritten to the host's address as seen from the container.
+	Network string
+	// PassEnv are glob patterns of the environment variables passed to the agent (e.g. LLM API keys),
+	// besides the task's env and the LLM config's env. Nothing else of the environment is passed.
+	PassEnv []string
+}
+
+// defaultAgentPassEnv selects the environment variables passed to an agent in a container by default.
+var defaultAgentPassEnv = []string{"*_API_KEY", "*_ENDPOINT", "*_BASE_URL", "GOOGLE_CLOUD_*", "VERTEXAI_*"}
+
+// Paths in the agent container of the kubeconfig, mounted read-only, and of the task output
+// directory, where the agent writes its trace. The kubeconfigs of the task's named clusters are
+// mounted next to the kubeconfig, as kubeconfig-<name>.
+const (
+	containerKubeConfig = "/k8s-ai-bench/kubeconfig"
+	containerOutputDir  = "/k8s-ai-bench/output"
+)
+
+// containerHostName is the name of the host in agent containers that are not on the host network.
+const containerHostName = "host.docker.internal"
+
+// containerStopTimeout bounds the commands that stop and remove an agent container.
+const containerStopTimeout = 30 * time.Second
+
+// containerAgentRunner runs the agent in a container of an image, with the prompts on its stdin.
+// The agent sees nothing of the host but the kubeconfig, the task output directory and the
+// environment variables passed to it.
+type containerAgentRunner struct {
+	image string
+	// entrypoint is the agent binary in the image, or empty to run the image's entrypoint.
+	entrypoint string
+	args       []string
+	config     ContainerAgentConfig
+}
+
+func newContainerAgentRunner(image string, config ContainerAgentConfig, agent AgentConfig) (*containerAgentRunner, error) {
+	if config.Runtime == "" {
+		config.Runtime = "docker"
+	}
+	if config.Network == "" {
+		config.Network = "host"
+	}
+	if _, err := exec.LookPath(config.Runtime); err != nil {
+		return nil, fmt.Errorf("container runtime %q for --agent-image not found: %w", config.Runtime, err)
+	}
+	return &containerAgentRunner{image: image, entrypoint: agent.Bin, args: agent.Args, config: config}, nil
+}
+
+func (r *containerAgentRunner) Run(ctx context.Context, spec AgentRunSpec) (AgentRunResult, error) {
+	kubeconfig, removeKubeConfig, err := r.mountedKubeConfig(spec.KubeConfig)
+	if err != nil {
+		return AgentRunResult{}, err
+	}
+	defer removeKubeConfig()
+	// The KUBECONFIG_<NAME> variables point at the mounted kubeconfigs instead of the host's.
+	var clusterArgs []string
+	for _, variable := range slices.Sorted(maps.Keys(spec.ClusterKubeConfigs)) {
+		clusterKubeConfig, removeClusterKubeConfig, err := r.mountedKubeConfig(spec.ClusterKubeConfigs[variable])
+		if err != nil {
+			return AgentRunResult{}, err
+		}
+		defer removeClusterKubeConfig()
+		containerPath := containerKubeConfig + "-" + strings.ToLower(strings.TrimPrefix(variable, "KUBECONFIG_"))
+		clusterArgs = append(clusterArgs,
+			"--volume", clusterKubeConfig+":"+containerPath+":ro",
+			"--env", variable+"="+containerPath)
+	}
+	outputDir, err := filepath.Abs(filepath.Dir(spec.TracePath))
+	if err != nil {
+		return AgentRunResult{}, err
+	}
+
+	name, err := agentResourceName()
+	if err != nil {
+		return AgentRunResult{}, err
+	}
+	args := []string{
+		"run", "--rm", "--interactive",
+		"--name", name,
+		"--label", "k8s-ai-bench=agent",
+		"--network", r.config.Network,
+		// The agent writes its trace as the user running k8s-ai-bench.
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"--volume", kubeconfig + ":" + containerKubeConfig + ":ro",
+		"--volume", outputDir + ":" + containerOutputDir,
+		"--env", "KUBECONFIG=" + containerKubeConfig,
+		"--env", "HOME=/tmp",
+	}
+	args = append(args, clusterArgs...)
+	if r.config.Network != "host" {
+		args = append(args, "--add-host", containerHostName+":host-gateway")
+	}
+	// Values are passed in the environment of the container CLI rather than on its command line.
+	for _, name := range r.passedEnv(spec.TaskEnv, spec.ClusterKubeConfigs) {
+		args = append(args, "--env", name)
+	}
+	if r.entrypoint != "" {
+		args = append(args, "--entrypoint", r.entrypoint)
+	}
+	args = append(args, r.image)
+	args = append(args, agentArgs(spec, containerKubeConfig, path.Join(containerOutputDir, filepath.Base(spec.TracePath)), r.args)...)
+
+	cmd := exec.CommandContext(ctx, r.config.Runtime, args...)
+	cmd.Env = append(os.Environ(), spec.TaskEnv...)
+	// Killing the container CLI may leave the container running, so timeouts and stalls kill the container.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		r.removeContainer(name)
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// The container is removed however the agent ended, e.g. if the container CLI crashed.
+	defer r.removeContainer(name)
+	return runAgentProcess(ctx, cmd, spec)
+}
+
+// passedEnv returns the names of the environment variables passed to the agent: those the task
+// and LLM config set, and those of the environment matching the PassEnv patterns. The variables
+// of the cluster kubeconfigs are left out, since they are set to the mounted paths.
+func (r *containerAgentRunner) passedEnv(taskEnv []string, clusterKubeConfigs map[string]string) []string {
+	var names []string
+	seen := map[string]bool{"KUBECONFIG": true, "HOME": true}
+	for variable := range clusterKubeConfigs {
+		seen[variable] = true
+	}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, kv := range taskEnv {
+		name, _, _ := strings.Cut(kv, "=")
+		add(name)
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, pattern := range r.config.PassEnv {
+			if ok, _ := path.Match(pattern, name); ok {
+				add(name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// mountedKubeConfig returns the absolute path of the kubeconfig to mount in the agent container,
+// a copy whose servers are reachable from the container when it is not on the host network.
+// The returned function removes the copy.
+func (r *containerAgentRunner) mountedKubeConfig(kubeconfigPath string) (string, func(), error) {
+	remove := func() {}
+	if r.config.Network != "host" {
+		rewritten, err := reachableKubeConfig(kubeconfigPath)
+		if err != nil {
+			return "", nil, err
+		}
+		remove = func() { os.Remove(rewritten) }
+		kubeconfigPath = rewritten
+	}
+	kubeconfigPath, err := filepath.Abs(kubeconfigPath)
+	if err != nil {
+		remove()
+		return "", nil, err
+	}
+	return kubeconfigPath, remove, nil
+}
+
+// removeContainer kills and removes the agent container, if it still exists.
+func (r *containerAgentRunner) removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerStopTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, r.config.Runtime, "rm", "--force", name).CombinedOutput()
+	if err != nil && !strings.Contains(strings.ToLower(string(output)), "no such container") {
+		slog.Warn("failed to remove agent container", "container", name, "error", err, "output", strings.TrimSpace(string(output)))
+	}
+}
+
+// containerImageVersion describes the agent image for the run metadata: the image and its ID,
+// if it can be inspected.
+func containerImageVersion(ctx context.Context, runtime, image string) string {
+	output, err := exec.CommandContext(ctx, runtime, "image", "inspect", "--format", "{{.Id}}", image).Output()
+	if err != nil {
+		return image
+	}
+	return fmt.Sprintf("%s (%s)", image, strings.TrimSpace(string(output)))
+}
+
+// agentResourceName returns a unique name for what is created for a run of the agent,
+// like its container or its ServiceAccount.
+func agentResourceName() (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return "k8s-ai-bench-agent-" + hex.EncodeToString(suffix), nil
+}
+
+// reachableKubeConfig writes a copy of the kubeconfig to a temporary file, with the servers on the
+// host's loopback address rewritten to the host's address in containers. TLS still verifies the
+// original address. The caller removes the file.
+func reachableKubeConfig(kubeconfigPath string) (string, error) {
+	data, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return "", fmt.Errorf("reading kubeconfig: %w", err)
+	}
+	var kubeconfig map[string]any
+	if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+		return "", fmt.Errorf("parsing kubeconfig %s: %w", kubeconfigPath, err)
+	}
+	clusters, _ := kubeconfig["clusters"].([]any)
+	for _, entry := range clusters {
+		entry, _ := entry.(map[string]any)
+		cluster, _ := entry["cluster"].(map[string]any)
+		server, _ := cluster["server"].(string)
+		u, err := url.Parse(server)
+		if err != nil || !isLoopbackHost(u.Hostname()) {
+			continue
+		}
+		if _, ok := cluster["tls-server-name"]; !ok {
+			cluster["tls-server-name"] = u.Hostname()
+		}
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(containerHostName, port)
+		} else {
+			u.Host = containerHostName
+		}
+		cluster["server"] = u.String()
+	}
+	data, err = yaml.Marshal(kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "agent-kubeconfig-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// isLoopbackHost reports whether the host of a server URL is the loopback address.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
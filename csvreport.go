@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// csvReportColumns are the columns of the CSV report.
+// Columns may be appended, but must not be renamed, removed or reordered.
+var csvReportColumns = []string{
+	"task",
+	"category",
+	"difficulty",
+	"llm_config",
+	"provider",
+	"model",
+	"run",
+	"result",
+	"score",
+	"duration",
+	"failure_count",
+	"first_failure",
+	"output_dir",
+	"agent",
+}
+
+// maxCSVFailureLength bounds the failure message in the CSV report.
+const maxCSVFailureLength = 500
+
+// writeCSVReport writes one row per task, LLM config and run to config.ReportCSV.
+func writeCSVReport(config EvalConfig, results []model.TaskResult) error {
+	sorted := append([]model.TaskResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Task != sorted[j].Task {
+			return sorted[i].Task < sorted[j].Task
+		}
+		if sorted[i].ConfigID() != sorted[j].ConfigID() {
+			return sorted[i].ConfigID() < sorted[j].ConfigID()
+		}
+		return sorted[i].Run < sorted[j].Run
+	})
+
+	f, err := os.Create(config.ReportCSV)
+	if err != nil {
+		return fmt.Errorf("creating file %q: %w", config.ReportCSV, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(csvReportColumns); err != nil {
+		return err
+	}
+	for _, result := range sorted {
+		firstFailure := result.Error
+		if len(result.Failures) > 0 {
+			firstFailure = result.Failures[0].Message
+		}
+		if len(firstFailure) > maxCSVFailureLength {
+			firstFailure = firstFailure[:maxCSVFailureLength] + "..."
+		}
+		run := max(result.Run, 1)
+		record := []string{
+			result.Task,
+			result.Category,
+			result.Difficulty,
+			result.LLMConfig.ID,
+			result.LLMConfig.ProviderID,
+			result.LLMConfig.ModelID,
+			strconv.Itoa(run),
+			result.Result,
+			strconv.FormatFloat(result.Score, 'f', -1, 64),
+			result.Duration,
+			strconv.Itoa(len(result.Failures)),
+			firstFailure,
+			taskOutputPath(config, result.Task, result.ConfigID(), run),
+			result.Agent,
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing to file %q: %w", config.ReportCSV, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,318 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// taskOrder returns the task IDs in sorted order, or shuffled with the seed if shuffle is set.
+func taskOrder(tasks map[string]Task, shuffle bool, seed uint64) []string {
+	order := make([]string, 0, len(tasks))
+	for taskID := range tasks {
+		order = append(order, taskID)
+	}
+	sort.Strings(order)
+	if shuffle {
+		r := rand.New(rand.NewPCG(seed, 0))
+		r.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	}
+	return order
+}
+
+// randomSeed returns a random seed for shuffling the task order.
+func randomSeed() uint64 {
+	return rand.Uint64()
+}
+
+// resolveDependencies drops the dependsOn of the loaded tasks on tasks that were not loaded,
+// with a warning, as well as all dependencies when each task runs in its own cluster (isolated
+// is true). Cycles are rejected by checkDependencyCycles when the tasks are loaded.
+func resolveDependencies(tasks map[string]Task, isolated bool) error {
+	taskIDs := make([]string, 0, len(tasks))
+	for taskID := range tasks {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	for _, taskID := range taskIDs {
+		task := tasks[taskID]
+		if len(task.DependsOn) == 0 {
+			continue
+		}
+		if isolated || task.Isolation == IsolationModeCluster {
+			slog.Warn("ignoring dependsOn of task, which runs in its own cluster", "task", taskID)
+			task.DependsOn = nil
+			tasks[taskID] = task
+			continue
+		}
+		var dependsOn []string
+		for _, dep := range task.DependsOn {
+			if _, ok := tasks[dep]; !ok {
+				slog.Warn("ignoring dependency of task, which is not selected to run", "task", taskID, "dependency", dep)
+				continue
+			}
+			dependsOn = append(dependsOn, dep)
+		}
+		task.DependsOn = dependsOn
+		tasks[taskID] = task
+	}
+	return nil
+}
+
+// checkDependencyCycles returns an error if the dependsOn of the tasks, by task ID, form a cycle.
+// It is given every task found, whether or not it was selected to run, so that the same task
+// definitions are rejected whatever the filters.
+func checkDependencyCycles(dependencies map[string][]string) error {
+	taskIDs := make([]string, 0, len(dependencies))
+	for taskID := range dependencies {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	// Detect cycles with a depth-first search.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(taskID string, stack []string) error
+	visit = func(taskID string, stack []string) error {
+		stack = append(stack, taskID)
+		switch state[taskID] {
+		case visiting:
+			return fmt.Errorf("task dependency cycle detected: %s", strings.Join(stack, " -> "))
+		case visited:
+			return nil
+		}
+		state[taskID] = visiting
+		for _, dep := range dependencies[taskID] {
+			if err := visit(dep, stack); err != nil {
+				return err
+			}
+		}
+		state[taskID] = visited
+		return nil
+	}
+	for _, taskID := range taskIDs {
+		if err := visit(taskID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatchInDependencyOrder sends the task IDs in order to jobs, holding back each task until
+// all of its dependencies have been reported on done, then closes jobs.
+// Every dispatched task must be reported on done.
+func dispatchInDependencyOrder(order []string, tasks map[string]Task, jobs chan<- string, done <-chan string) {
+	defer close(jobs)
+
+	remaining := slices.Clone(order)
+	completed := make(map[string]bool, len(tasks))
+	inFlight := 0
+
+	for len(remaining) > 0 || inFlight > 0 {
+		var blocked []string
+		for _, taskID := range remaining {
+			isReady := true
+			for _, dep := range tasks[taskID].DependsOn {
+				if !completed[dep] {
+					isReady = false
+					break
+				}
+			}
+			if !isReady {
+				blocked = append(blocked, taskID)
+				continue
+			}
+			jobs <- taskID
+			inFlight++
+		}
+		remaining = blocked
+		if inFlight == 0 {
+			// Unreachable after resolveDependencies, which rejects cycles.
+			return
+		}
+		completed[<-done] = true
+		inFlight--
+	}
+}
+
+// declaresResources reports whether the task declares the resources it touches on the shared cluster.
+func (t *Task) declaresResources() bool {
+	return len(t.Namespaces) > 0 || len(t.ClusterResources) > 0
+}
+
+// conflictsWith reports whether the task and other, a different task, may touch the same
+// resources of the shared cluster.
+func (t *Task) conflictsWith(other *Task) bool {
+	if !t.declaresResources() || !other.declaresResources() {
+		return true
+	}
+	return slices.ContainsFunc(t.Namespaces, func(ns string) bool { return slices.Contains(other.Namespaces, ns) }) ||
+		slices.ContainsFunc(t.ClusterResources, func(r string) bool { return slices.Contains(other.ClusterResources, r) })
+}
+
+// undeclaredSharedTasks returns the sorted IDs of the tasks on the shared cluster that do not
+// declare the resources they touch, which run alone.
+func undeclaredSharedTasks(config EvalConfig, tasks map[string]Task) []string {
+	var undeclared []string
+	for taskID, task := range tasks {
+		if runsOnSharedCluster(config, task) && !task.declaresResources() {
+			undeclared = append(undeclared, taskID)
+		}
+	}
+	sort.Strings(undeclared)
+	return undeclared
+}
+
+// jobQueue hands the jobs of the dispatched tasks (one for each agent and LLM config) to the workers.
+// A job is only handed out when it can start right away: the jobs of a task that runs on the shared
+// cluster do not run at the same time, since they use the same namespaces, nor with those of other
+// tasks that conflict with it (see Task.conflictsWith), and jobs whose provider limit is full wait
+// while the jobs of other providers run. A job waiting on a conflict holds back the later jobs that
+// conflict with it, so tasks that run alone are not starved by a stream of others.
+type jobQueue struct {
+	limiters providerLimiters
+	// done receives each task once all its jobs have finished, for dispatchInDependencyOrder.
+	done chan<- string
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending []taskJob
+	closed  bool
+	// running counts the running jobs of the tasks that run on the shared cluster, and tasks
+	// holds the tasks that were added, to check their conflicts.
+	running map[string]int
+	tasks   map[string]*Task
+	// remaining counts the jobs of each task that have not finished.
+	remaining map[string]int
+}
+
+func newJobQueue(ctx context.Context, limiters providerLimiters, done chan<- string) *jobQueue {
+	q := &jobQueue{
+		limiters:  limiters,
+		done:      done,
+		running:   make(map[string]int),
+		tasks:     make(map[string]*Task),
+		remaining: make(map[string]int),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	// Once the run ends, waiting workers take the remaining jobs, which are skipped.
+	context.AfterFunc(ctx, func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		q.cond.Broadcast()
+	})
+	return q
+}
+
+// addTask queues the jobs of a task whose dependencies have completed.
+func (q *jobQueue) addTask(taskID string, jobs []taskJob) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(jobs) == 0 {
+		q.done <- taskID
+		return
+	}
+	q.remaining[taskID] = len(jobs)
+	q.tasks[taskID] = &jobs[0].task
+	q.pending = append(q.pending, jobs...)
+	q.cond.Broadcast()
+}
+
+// close marks that no more tasks will be added.
+func (q *jobQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// next waits for a job that can start, in the order they were queued, and returns false
+// once the queue is closed and empty.
+func (q *jobQueue) next(ctx context.Context) (taskJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for {
+		if len(q.pending) == 0 && q.closed {
+			return taskJob{}, false
+		}
+		// Tasks of the jobs before the candidate that wait on a conflict.
+		var waiting []string
+		i := slices.IndexFunc(q.pending, func(job taskJob) bool {
+			if ctx.Err() != nil {
+				return true
+			}
+			if job.sharedCluster {
+				if q.running[job.taskID] > 0 || q.conflicts(job.taskID, waiting) {
+					waiting = append(waiting, job.taskID)
+					return false
+				}
+				for taskID, n := range q.running {
+					if n > 0 && q.conflicts(job.taskID, []string{taskID}) {
+						waiting = append(waiting, job.taskID)
+						return false
+					}
+				}
+			}
+			return q.limiters.forConfig(job.llmConfig).available()
+		})
+		if i >= 0 {
+			job := q.pending[i]
+			q.pending = slices.Delete(q.pending, i, i+1)
+			if job.sharedCluster {
+				q.running[job.taskID]++
+			}
+			return job, true
+		}
+		q.cond.Wait()
+	}
+}
+
+// conflicts reports whether the task conflicts with any of the other tasks, which run on the shared cluster.
+func (q *jobQueue) conflicts(taskID string, others []string) bool {
+	for _, other := range others {
+		if other != taskID && q.tasks[taskID].conflictsWith(q.tasks[other]) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish records that a job returned by next has finished.
+func (q *jobQueue) finish(job taskJob) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if job.sharedCluster {
+		q.running[job.taskID]--
+	}
+	q.remaining[job.taskID]--
+	if q.remaining[job.taskID] == 0 {
+		q.done <- job.taskID
+	}
+	q.cond.Broadcast()
+}
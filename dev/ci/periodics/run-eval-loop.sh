@@ -102,7 +102,7 @@ do
   
   echo "Running iteration $i of $ITERATIONS..."
 
-  K8S_AI_BENCH_ARGS="--agent-bin kubectl-ai --kubeconfig ${KUBECONFIG:-~/.kube/config} --enable-tool-use-shim=false --llm-provider=${PROVIDER} --models=${MODEL} --quiet --output-dir=${OUTPUT_DIR} --cluster-creation-policy=${CLUSTER_CREATION_POLICY} --concurrency ${CONCURRENCY} --tasks-dir=${REPO_ROOT}/k8s-ai-bench/tasks "
+  K8S_AI_BENCH_ARGS="--agent-bin kubectl-ai --kubeconfig ${KUBECONFIG:-~/.kube/config} --enable-tool-use-shim=false --llm-provider=${PROVIDER} --models=${MODEL} --quiet --output-dir=${OUTPUT_DIR} --flat-output --exit-policy=always-zero --cluster-creation-policy=${CLUSTER_CREATION_POLICY} --concurrency ${CONCURRENCY} --tasks-dir=${REPO_ROOT}/k8s-ai-bench/tasks "
 
   if [ -n "$TASK_PATTERN" ]; then
     K8S_AI_BENCH_ARGS+="--task-pattern=${TASK_PATTERN} "
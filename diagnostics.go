@@ -0,0 +1,162 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// diagnosticsCommandTimeout bounds each diagnostics command, so collection cannot hang the run.
+const diagnosticsCommandTimeout = 30 * time.Second
+
+// collectDiagnostics writes a debugging bundle for a failed task into <taskOutputDir>/diagnostics:
+// all objects, events, descriptions of pods that are not ready, logs of crashing pods,
+// and the output of the task's own diagnostics commands.
+// Errors are returned for reporting only; collection continues after a failed command.
+func (x *TaskExecution) collectDiagnostics() error {
+	dir := filepath.Join(x.taskOutputDir, "diagnostics")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory %q: %w", dir, err)
+	}
+
+	var errs []error
+	collect := func(file string, args ...string) []byte {
+		ctx, cancel := context.WithTimeout(context.Background(), diagnosticsCommandTimeout)
+		defer cancel()
+		output, err := runKubectl(ctx, x.kubeConfig, args...)
+		if err != nil {
+			errs = append(errs, err)
+			output = append(output, []byte("\n"+err.Error()+"\n")...)
+		}
+		if file != "" {
+			if err := os.WriteFile(filepath.Join(dir, file), output, 0644); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return output
+	}
+
+	collect("get-all.txt", "get", "all", "--all-namespaces", "-o", "wide")
+	collect("events.txt", "get", "events", "--all-namespaces", "--sort-by=.lastTimestamp")
+
+	var pods podList
+	if err := json.Unmarshal(collect("", "get", "pods", "--all-namespaces", "-o", "json"), &pods); err != nil {
+		errs = append(errs, fmt.Errorf("parsing pods: %w", err))
+	}
+	for _, pod := range pods.Items {
+		name := pod.Metadata.Namespace + "_" + pod.Metadata.Name
+		if !pod.isReady() {
+			collect("describe-"+name+".txt", "describe", "pod", "--namespace", pod.Metadata.Namespace, pod.Metadata.Name)
+		}
+		if pod.isCrashing() {
+			collect("logs-"+name+".txt", "logs", "--namespace", pod.Metadata.Namespace, pod.Metadata.Name, "--all-containers")
+			collect("logs-previous-"+name+".txt", "logs", "--namespace", pod.Metadata.Namespace, pod.Metadata.Name, "--all-containers", "--previous")
+		}
+	}
+
+	for i, command := range x.task.Diagnostics {
+		ctx, cancel := context.WithTimeout(context.Background(), diagnosticsCommandTimeout)
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = x.taskDir
+		cmd.Env = x.taskEnv()
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("running diagnostics command %q: %w", command, err))
+			fmt.Fprintf(&output, "\n%v\n", err)
+		}
+		cancel()
+		file := filepath.Join(dir, fmt.Sprintf("task-%d.txt", i+1))
+		if err := os.WriteFile(file, append([]byte("$ "+command+"\n"), output.Bytes()...), 0644); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// podList is the subset of a PodList needed to select pods for diagnostics.
+type podList struct {
+	Items []podListItem `json:"items"`
+}
+
+type podListItem struct {
+	Metadata struct {
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+		ContainerStatuses []containerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type containerStatus struct {
+	State struct {
+		Waiting *struct {
+			Reason string `json:"reason"`
+		} `json:"waiting"`
+		Terminated *struct {
+			Reason string `json:"reason"`
+		} `json:"terminated"`
+	} `json:"state"`
+	LastState struct {
+		Terminated *struct {
+			Reason string `json:"reason"`
+		} `json:"terminated"`
+	} `json:"lastState"`
+}
+
+// isReady reports whether the pod has completed or is Ready.
+func (p podListItem) isReady() bool {
+	if p.Status.Phase == "Succeeded" {
+		return true
+	}
+	for _, condition := range p.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
+
+// isCrashing reports whether any container of the pod is in CrashLoopBackOff or terminated with an error.
+func (p podListItem) isCrashing() bool {
+	for _, status := range p.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
+		if status.State.Terminated != nil && status.State.Terminated.Reason == "Error" {
+			return true
+		}
+		if status.LastState.Terminated != nil && status.LastState.Terminated.Reason == "Error" {
+			return true
+		}
+	}
+	return false
+}
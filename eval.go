@@ -15,48 +15,139 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/external"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/gke"
 	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/kind"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/minikube"
 	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/vcluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/judge"
 	"github.com/gke-labs/k8s-ai-bench/pkg/model"
-	"k8s.io/klog/v2"
+	"github.com/gke-labs/k8s-ai-bench/pkg/runlog"
 	"sigs.k8s.io/yaml"
 )
 
-func runEvaluation(ctx context.Context, config EvalConfig) error {
-	logger := klog.FromContext(ctx)
+func runEvaluation(ctx context.Context, config EvalConfig) (err error) {
+	// A dry run only plans the run, so none of the outputs below are written.
+	if config.DryRun {
+		return dryRun(config)
+	}
+	// Nothing is written before checking that the outputs of a previous run would not be clobbered.
+	if err := checkOutputDir(config); err != nil {
+		return err
+	}
 
-	var clusterProvider cluster.Provider
-	switch config.ClusterProvider {
-	case "kind":
-		clusterProvider = kind.New()
-	case "vcluster":
-		var cleanup func()
-		var err error
-		clusterProvider, cleanup, err = vcluster.New(config.HostClusterContext, config.HostClusterKubeConfig)
+	// The aggregated results and reports are written even if the run ends early.
+	startTime := time.Now()
+	var allResults []model.TaskResult
+	var metadata *model.RunMetadata
+	// The exit policy is applied last, so the outputs of a run that completed are handled as such.
+	defer func() {
+		if err == nil {
+			err = config.exitPolicy.check(allResults)
+		}
+	}()
+	defer func() {
+		if reportErr := writeReports(config, startTime, allResults, err); reportErr != nil {
+			err = errors.Join(err, reportErr)
+		}
+		if metadata != nil {
+			if metadataErr := completeRunMetadata(config, metadata, allResults, err); metadataErr != nil {
+				err = errors.Join(err, metadataErr)
+			}
+		}
+		if err == nil && config.outputRoot != "" {
+			if linkErr := updateLatestLink(config); linkErr != nil {
+				slog.Warn("failed to update the latest link", "error", linkErr)
+			}
+		}
+		// The outputs are uploaded even if the run failed, so they can be debugged.
+		if config.UploadTo != "" && config.OutputDir != "" {
+			if uploadErr := uploadOutputs(context.Background(), config); uploadErr != nil {
+				if config.UploadFailureFatal {
+					err = errors.Join(err, fmt.Errorf("uploading outputs: %w", uploadErr))
+				} else {
+					slog.Warn("failed to upload outputs", "error", uploadErr)
+				}
+			}
+		}
+		// A notification that cannot be delivered does not fail the run.
+		if config.NotifyWebhook != "" {
+			notification := buildNotification(config, time.Since(startTime), allResults, err)
+			if notifyErr := notifyWebhook(context.Background(), config, notification); notifyErr != nil {
+				slog.Warn(notifyErr.Error())
+			}
+		}
+	}()
+
+	// Spans of the run are exported if an endpoint is set; the tracer is shut down after the run span ends.
+	var runSpan *span
+	if config.OTelEndpoint != "" {
+		t := newTracer(config.OTelEndpoint)
+		defer t.shutdown()
+		ctx = withTracer(ctx, t)
+	}
+	ctx, runSpan = startSpan(ctx, "run", map[string]any{
+		"run.id":           config.RunID,
+		"cluster.provider": config.ClusterProvider,
+	})
+	defer func() {
+		runSpan.finish(err)
+	}()
+
+	if config.MetricsAddr != "" || config.MetricsPushgateway != "" {
+		config.metrics = newRunMetrics()
+	}
+	if config.MetricsAddr != "" {
+		stopMetrics, err := serveMetrics(config.MetricsAddr, config.metrics)
 		if err != nil {
-			return fmt.Errorf("failed to create vcluster provider: %w", err)
+			return fmt.Errorf("serving metrics: %w", err)
 		}
-		defer cleanup()
-	default:
-		return fmt.Errorf("unknown cluster provider: %s", config.ClusterProvider)
+		defer stopMetrics()
+	}
+	if config.MetricsPushgateway != "" {
+		// The final metrics are pushed once the run ends, whether or not it succeeded.
+		defer func() {
+			if pushErr := pushMetrics(context.Background(), config.MetricsPushgateway, config.RunID, config.metrics); pushErr != nil {
+				slog.Warn(pushErr.Error())
+			}
+		}()
 	}
 
+	if config.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.RunTimeout)
+		defer cancel()
+	}
+
+	if config.ClusterProvider == "external" && config.ClusterCreationPolicy == AlwaysCreate {
+		return fmt.Errorf("cluster-creation-policy %s is not supported with the external cluster provider, which never creates or deletes clusters", AlwaysCreate)
+	}
+
+	clusterProvider, cleanupProvider, err := newClusterProvider(config)
+	if err != nil {
+		return err
+	}
+	defer cleanupProvider()
+
 	if config.ClusterCreationPolicy != DoNotCreate {
 		clusterName := "k8s-ai-bench-eval"
 
@@ -66,7 +157,7 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		}
 
 		if config.ClusterCreationPolicy == AlwaysCreate && clusterExists {
-			logger.Info("Deleting existing cluster for evaluation run", "name", clusterName, "provider", config.ClusterProvider)
+			slog.Info("Deleting existing cluster for evaluation run", "name", clusterName, "provider", config.ClusterProvider)
 			if err := clusterProvider.Delete(clusterName); err != nil {
 				return fmt.Errorf("failed to delete existing cluster: %w", err)
 			}
@@ -74,14 +165,14 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		}
 
 		if !clusterExists {
-			logger.Info("Creating cluster for evaluation run", "name", clusterName, "provider", config.ClusterProvider)
+			slog.Info("Creating cluster for evaluation run", "name", clusterName, "provider", config.ClusterProvider)
 			if err := clusterProvider.Create(clusterName); err != nil {
 				return fmt.Errorf("failed to create cluster: %w", err)
 			}
 		}
 
 		// Get kubeconfig
-		logger.Info("Getting kubeconfig for cluster", "name", clusterName)
+		slog.Debug("Getting kubeconfig for cluster", "name", clusterName)
 		kubeconfigBytes, err := clusterProvider.GetKubeconfig(clusterName)
 		if err != nil {
 			return fmt.Errorf("failed to get kubeconfig for cluster: %w", err)
@@ -99,47 +190,165 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		}
 		kubeconfigFile.Close()
 
-		logger.Info("Wrote Kubeconfig to", "path", kubeconfigFile.Name())
+		slog.Debug("Wrote kubeconfig", "path", kubeconfigFile.Name())
 		config.KubeConfig = kubeconfigFile.Name()
+		config.clusterName = clusterName
+	}
+
+	// With vcluster, every task runs in its own isolated cluster, and KubeConfig points at the host cluster.
+	if config.ClusterReadyTimeout > 0 && config.ClusterProvider != "vcluster" {
+		slog.Info("Waiting for cluster to be ready", "timeout", config.ClusterReadyTimeout)
+		readyCtx, cancel := context.WithTimeout(ctx, config.ClusterReadyTimeout)
+		err := cluster.WaitForReady(readyCtx, config.KubeConfig)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("cluster did not become ready within %v: %w", config.ClusterReadyTimeout, err)
+		}
 	}
 
 	if config.OutputDir == "" {
 		return fmt.Errorf("must set OutputDir")
 	}
 
-	tasks, err := loadTasks(config)
+	tasks, disabledTasks, err := loadTasks(config)
 	if err != nil {
 		return fmt.Errorf("failed to load tasks: %w", err)
 	}
 
+	var rerun *rerunSelection
+	if config.RerunFailed != "" {
+		rerun, err = loadRerunSelection(config.RerunFailed, tasks, runConfigIDs(config))
+		if err != nil {
+			return err
+		}
+		rerun.filter(tasks)
+		// Dependencies on tasks that are not rerun are dropped.
+		if err := resolveDependencies(tasks, config.ClusterProvider == "vcluster"); err != nil {
+			return err
+		}
+	}
+
+	var baseline *clusterSnapshot
+	if config.ResetBetweenTasks {
+		baseline, err = takeClusterSnapshot(ctx, config.KubeConfig)
+		if err != nil {
+			return fmt.Errorf("taking baseline snapshot of the shared cluster: %w", err)
+		}
+	}
+
+	if config.Overwrite {
+		if err := removeStaleOutputs(config, tasks, rerun); err != nil {
+			return err
+		}
+	}
+
+	// Tasks run in sorted order, unless shuffled; the order is recorded so it can be replayed.
+	order := taskOrder(tasks, config.Shuffle, config.Seed)
+	if config.Shuffle {
+		slog.Info("Shuffled task order", "seed", config.Seed)
+	}
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	runMetadata := model.RunMetadata{
+		RunID:     config.RunID,
+		Shuffle:   config.Shuffle,
+		Seed:      config.Seed,
+		TaskOrder: order,
+		StartTime: startTime,
+		TraceID:   runSpan.traceIDString(),
+
+		LLMConfigs: config.LLMConfigs,
+	}
+	if rerun != nil {
+		runMetadata.RerunOf = rerun.rerunOf
+	}
+	if err := describeEnvironment(ctx, config, &runMetadata); err != nil {
+		return fmt.Errorf("describing run environment: %w", err)
+	}
+	if err := writeRunMetadata(config, &runMetadata); err != nil {
+		return err
+	}
+	metadata = &runMetadata
+
+	// Disabled tasks are reported without being scheduled, so they take no worker or cluster.
+	disabledResults, err := writeDisabledResults(config, disabledTasks, rerun)
+	if err != nil {
+		return err
+	}
+
 	// Fallback to sequential execution if concurrency is not set
 	if config.Concurrency <= 0 {
 		config.Concurrency = 1
 	}
 
-	// Create a channel for tasks to be processed
-	type taskJob struct {
-		taskID string
-		task   Task
-	}
-	taskCh := make(chan taskJob, len(tasks))
-
 	// Create a channel for collecting results
-	resultsCh := make(chan model.TaskResult, len(tasks)*len(config.LLMConfigs))
+	resultsCh := make(chan model.TaskResult, len(tasks)*len(config.LLMConfigs)*max(len(config.Agents), 1)*max(config.Runs, 1))
 
 	// Create a separate channel for errors
 	errorsCh := make(chan error, config.Concurrency)
 
-	// Load all tasks into the tasks channel
-	for taskID, task := range tasks {
-		taskCh <- taskJob{taskID: taskID, task: task}
+	// With a run timeout, no task is started unless the longest task timeout still fits in the budget.
+	var runDeadline time.Time
+	var headroom time.Duration
+	if deadline, ok := ctx.Deadline(); ok && config.RunTimeout > 0 {
+		runDeadline = deadline
+		for _, task := range tasks {
+			headroom = max(headroom, taskTimeout(task))
+		}
+	}
+
+	scheduler := &taskScheduler{
+		config:          config,
+		clusterProvider: clusterProvider,
+		baseline:        baseline,
+		results:         resultsCh,
+		rerun:           rerun,
+		passed:          make(map[string]map[string]bool),
+		runDeadline:     runDeadline,
+		headroom:        headroom,
+		limiters:        newProviderLimiters(config.ProviderLimits),
+		limitSkips:      make(map[string]int),
+		evaluate:        evaluateTaskWithRetries,
+	}
+
+	total := 0
+	for taskID := range tasks {
+		for _, id := range runConfigIDs(config) {
+			if rerun.selects(taskID, id) {
+				total += max(config.Runs, 1)
+			}
+		}
+	}
+	scheduler.progress = newProgressReporter(total, config.Concurrency, config.ProgressInterval)
+
+	// Tasks on the shared cluster that do not declare the resources they touch run alone,
+	// which serializes concurrent runs, so their authors are nudged to declare them.
+	if config.Concurrency > 1 {
+		if undeclared := undeclaredSharedTasks(config, tasks); len(undeclared) > 0 {
+			slog.Warn("tasks on the shared cluster declare no namespaces or clusterResources, so they run alone",
+				"count", len(undeclared), "tasks", strings.Join(undeclared, ","))
+		}
 	}
-	close(taskCh)
+
+	// Tasks are dispatched in dependency order: tasks that other tasks depend on must complete
+	// (with every LLM config) before their dependents are dispatched. The unit of work is a job
+	// of a dispatched task with one agent and LLM config, so the LLM configs of a task run concurrently.
+	readyCh := make(chan string, len(tasks))
+	doneCh := make(chan string, len(tasks))
+	go dispatchInDependencyOrder(order, tasks, readyCh, doneCh)
+	queue := newJobQueue(ctx, scheduler.limiters, doneCh)
+	go func() {
+		defer queue.close()
+		for taskID := range readyCh {
+			queue.addTask(taskID, taskJobs(config, taskID, tasks[taskID], rerun))
+		}
+	}()
 
 	// Create a wait group to track all workers
 	var wg sync.WaitGroup
 
-	fmt.Printf("Running tasks with concurrency: %d\n", config.Concurrency)
+	slog.Info("Running tasks", "concurrency", config.Concurrency)
 
 	// Start workers based on concurrency setting
 	for i := 0; i < config.Concurrency; i++ {
@@ -147,50 +356,16 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		go func(workerID int) {
 			defer wg.Done()
 
-			for job := range taskCh {
-				fmt.Printf("Worker %d: Evaluating task: %s\n", workerID, job.taskID)
-
-				for _, llmConfig := range config.LLMConfigs {
-					taskOutputDir := ""
-					if config.OutputDir != "" {
-						taskOutputDir = filepath.Join(config.OutputDir, job.taskID)
-						if err := os.MkdirAll(taskOutputDir, 0755); err != nil {
-							errorsCh <- fmt.Errorf("creating directory %q: %w", taskOutputDir, err)
-							return
-						}
-					}
-
-					var log io.Writer
-					if taskOutputDir != "" {
-						logPath := filepath.Join(taskOutputDir, "log.txt")
-						logFile, err := os.Create(logPath)
-						if err != nil {
-							errorsCh <- fmt.Errorf("creating log file %q: %w", logPath, err)
-							return
-						}
-						defer logFile.Close()
-						log = logFile
-					}
-
-					start := time.Now()
-					fmt.Printf("\033[36mWorker %d: Started %s for %s\033[0m\n", workerID, llmConfig.ID, job.taskID)
-
-					result := evaluateTask(ctx, config, job.taskID, job.task, llmConfig, clusterProvider, log)
-
-					fmt.Printf("\033[32mWorker %d: Completed %s for %s in %s\033[0m\n",
-						workerID,
-						llmConfig.ID,
-						job.taskID,
-						time.Since(start).Round(time.Second),
-					)
-
-					if taskOutputDir != "" {
-						if err := writeToYAMLFile(filepath.Join(taskOutputDir, "results.yaml"), result); err != nil {
-							errorsCh <- fmt.Errorf("writing results to file: %w", err)
-							return
-						}
-					}
-					resultsCh <- result
+			for {
+				job, ok := queue.next(ctx)
+				if !ok {
+					return
+				}
+				err := scheduler.runTaskJob(ctx, workerID, job)
+				queue.finish(job)
+				if err != nil {
+					errorsCh <- err
+					return
 				}
 			}
 		}(i)
@@ -198,9 +373,16 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 
 	// Wait for all workers to complete
 	wg.Wait()
+	scheduler.progress.stop()
 	close(resultsCh)
 	close(errorsCh)
 
+	// Collect the results, including those completed before any error
+	for result := range resultsCh {
+		allResults = append(allResults, result)
+	}
+	allResults = append(allResults, disabledResults...)
+
 	// Check if there were any errors
 	for err := range errorsCh {
 		if err != nil {
@@ -208,264 +390,1311 @@ func runEvaluation(ctx context.Context, config EvalConfig) error {
 		}
 	}
 
-	// Collect and print results
-	var allResults []model.TaskResult
-	for result := range resultsCh {
-		allResults = append(allResults, result)
+	printResults(allResults, config.Runs, config.VerboseResults)
+	if disabled := countDisabled(allResults); disabled > 0 {
+		fmt.Printf("Skipped %d task/LLM config combinations of disabled tasks (run them with --include-disabled)\n", disabled)
 	}
-
-	printResults(allResults)
-	return nil
-}
-
-// writeToYAMLFile will encode the specified object as yaml, and write it to the file.
-func writeToYAMLFile(p string, obj any) error {
-	data, err := yaml.Marshal(obj)
-	if err != nil {
-		return fmt.Errorf("marshaling to yaml: %w", err)
+	if scheduler.timeoutSkips > 0 {
+		fmt.Printf("Run ended due to the --run-timeout budget of %v: skipped %d task/LLM config combinations that were not started\n", config.RunTimeout, scheduler.timeoutSkips)
 	}
-	if err := os.WriteFile(p, data, 0644); err != nil {
-		return fmt.Errorf("writing to file %q: %w", p, err)
+	for _, name := range sortedKeys(scheduler.limitSkips) {
+		fmt.Printf("Skipped %d task/LLM config combinations: the --provider-limit of %s is 0\n", scheduler.limitSkips[name], name)
+	}
+	if timedOut, passed := countTimedOut(allResults); timedOut > 0 {
+		fmt.Printf("%d task evaluations timed out, %d of them with the task solved (passed verification after the timeout)\n", timedOut, passed)
+	}
+	if withCleanupErrors := countCleanupErrors(allResults); withCleanupErrors > 0 {
+		fmt.Printf("%d task evaluations had cleanup errors, which may have left clusters or resources behind (see cleanupErrors in their results.yaml)\n", withCleanupErrors)
+	}
+	if panicked := countPanicked(allResults); panicked > 0 {
+		fmt.Printf("%d task evaluations panicked and are reported as errors (see their log.txt for the stack trace)\n", panicked)
+	}
+	if scheduler.cost > 0 || scheduler.tokens > 0 {
+		fmt.Printf("Usage of the tasks run: estimated cost $%.4f, %d tokens\n", scheduler.cost, scheduler.tokens)
+	}
+	if scheduler.budgetSkips > 0 {
+		var budgets []string
+		if config.MaxCost > 0 {
+			budgets = append(budgets, fmt.Sprintf("--max-cost $%.2f", config.MaxCost))
+		}
+		if config.MaxTotalTokens > 0 {
+			budgets = append(budgets, fmt.Sprintf("--max-total-tokens %d", config.MaxTotalTokens))
+		}
+		fmt.Printf("Budget exceeded (%s): skipped %d remaining task/LLM config combinations\n", strings.Join(budgets, ", "), scheduler.budgetSkips)
+	}
+	if flagged := countCostCapExceeded(allResults); flagged > 0 {
+		fmt.Printf("%d tasks cost more than the --task-cost-cap of $%.2f\n", flagged, config.TaskCostCap)
+	}
+	if scheduler.stoppedSkips > 0 {
+		fmt.Printf("Stopped after %d failures: skipped %d remaining task/LLM config combinations\n", scheduler.failures, scheduler.stoppedSkips)
+	}
+	if config.Resume {
+		resumed := 0
+		for _, result := range allResults {
+			if result.Resumed {
+				resumed++
+			}
+		}
+		fmt.Printf("Loaded from previous run: %d, executed now: %d\n", resumed, len(allResults)-resumed)
+	}
+	if config.Baseline != "" {
+		return checkBaseline(config, allResults)
 	}
 	return nil
 }
 
-func loadTasks(config EvalConfig) (map[string]Task, error) {
-	tasks := make(map[string]Task)
+// taskJob is the evaluation of a task with an agent and LLM config, config.Runs times.
+type taskJob struct {
+	taskID    string
+	task      Task
+	agentID   string
+	llmConfig model.LLMConfig
+	// sharedCluster is set if the task runs on the shared cluster, rather than in its own.
+	sharedCluster bool
+}
 
-	var taskFilter *regexp.Regexp
-	if config.TaskPattern != "" {
-		var err error
-		taskFilter, err = regexp.Compile(config.TaskPattern)
-		if err != nil {
-			return nil, fmt.Errorf("compiling task pattern regex %q: %w", config.TaskPattern, err)
+// taskJobs returns the jobs of the task, for every agent and LLM config selected to run.
+func taskJobs(config EvalConfig, taskID string, task Task, rerun *rerunSelection) []taskJob {
+	var jobs []taskJob
+	for _, agent := range config.Agents {
+		for _, llmConfig := range config.LLMConfigs {
+			if !rerun.selects(taskID, model.ConfigID(agent.ID, llmConfig.ID)) {
+				continue
+			}
+			jobs = append(jobs, taskJob{
+				taskID:        taskID,
+				task:          task,
+				agentID:       agent.ID,
+				llmConfig:     llmConfig,
+				sharedCluster: runsOnSharedCluster(config, task),
+			})
 		}
 	}
+	return jobs
+}
 
-	entries, err := os.ReadDir(config.TasksDir)
-	if err != nil {
-		return nil, err
-	}
+// runsOnSharedCluster reports whether the task runs on the cluster shared by the run,
+// rather than in a cluster of its own.
+func runsOnSharedCluster(config EvalConfig, task Task) bool {
+	return task.Isolation != IsolationModeCluster && config.ClusterProvider != "vcluster"
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+// taskScheduler holds the state shared by the workers of an evaluation run.
+type taskScheduler struct {
+	config          EvalConfig
+	clusterProvider cluster.Provider
+	baseline        *clusterSnapshot
+	results         chan<- model.TaskResult
+	// rerun selects the task and LLM config pairs to run with --rerun-failed, or nil to run all.
+	rerun *rerunSelection
+	// progress reports the progress of the run, if enabled.
+	progress *progressReporter
+
+	mutex sync.Mutex
+	// passed records which tasks passed for each LLM config, so dependents of failed tasks can be skipped.
+	passed map[string]map[string]bool
+	// failures counts the failed tasks, and stoppedSkips the tasks skipped once --max-failures was reached.
+	failures     int
+	stoppedSkips int
+
+	// cost and tokens are the estimated cost and tokens used by the tasks run so far, for --max-cost
+	// and --max-total-tokens. budgetSkips counts the tasks skipped once a budget was reached.
+	cost        float64
+	tokens      int
+	budgetSkips int
+
+	// runDeadline is the end of the --run-timeout budget (zero if unlimited); no task is started
+	// within headroom of it. timeoutSkips counts the tasks skipped because of the budget.
+	runDeadline  time.Time
+	headroom     time.Duration
+	timeoutSkips int
+
+	// limiters enforce the --provider-limit and --provider-rate of the LLM configs.
+	// limitSkips counts the combinations skipped because their limit is 0, by limiter.
+	limiters   providerLimiters
+	limitSkips map[string]int
+
+	// evaluate evaluates a run of a task: evaluateTaskWithRetries, unless replaced in tests.
+	evaluate func(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, taskOutputDir string) (model.TaskResult, error)
+}
 
-		taskID := entry.Name()
-		if taskFilter != nil && !taskFilter.MatchString(taskID) {
-			continue
-		}
+// runTaskJob evaluates the task of the job with its agent and LLM config, writing the results
+// to the task output directories.
+// A task whose dependency did not pass for the agent and LLM config is skipped.
+func (s *taskScheduler) runTaskJob(ctx context.Context, workerID int, job taskJob) error {
+	config := s.config
+	config.agent = s.config.agentRunners[job.agentID]
+	return s.runTaskWith(ctx, workerID, job, config, job.agentID, job.llmConfig)
+}
 
-		taskFile := filepath.Join(config.TasksDir, taskID, "task.yaml")
+// runTaskWith evaluates the task with an agent and LLM config, config.Runs times.
+func (s *taskScheduler) runTaskWith(ctx context.Context, workerID int, job taskJob, config EvalConfig, agentID string, llmConfig model.LLMConfig) error {
+	configID := model.ConfigID(agentID, llmConfig.ID)
+	if !s.rerun.selects(job.taskID, configID) {
+		return nil
+	}
 
-		data, err := os.ReadFile(taskFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read task file %s: %w", taskFile, err)
-		}
+	runs := max(config.Runs, 1)
+	config.configID = configID
+	if config.Concurrency > 1 {
+		// The console output of concurrent tasks is told apart by its prefix.
+		config.consolePrefix = fmt.Sprintf("w%d %s %s", workerID, job.taskID, configID)
+	}
 
-		var task Task
-		if err := yaml.Unmarshal(data, &task); err != nil {
-			return nil, fmt.Errorf("failed to parse task file %s: %w", taskFile, err)
+	s.mutex.Lock()
+	blockedBy := ""
+	for _, dep := range job.task.DependsOn {
+		if !s.passed[dep][configID] {
+			blockedBy = dep
+			break
 		}
+	}
+	s.mutex.Unlock()
 
-		// Skip disabled tasks
-		if task.Disabled {
-			fmt.Printf("Skipping disabled task: %s\n", taskID)
-			continue
+	for run := 1; run <= runs; run++ {
+		if err := s.runTaskOnce(ctx, workerID, job, config, agentID, llmConfig, blockedBy, run); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		tasks[taskID] = task
+// runTaskOnce evaluates a run of the task with the agent and LLM config, or skips it.
+// A panic while evaluating it is recovered into an error result, so the other tasks still run.
+func (s *taskScheduler) runTaskOnce(ctx context.Context, workerID int, job taskJob, config EvalConfig, agentID string, llmConfig model.LLMConfig, blockedBy string, run int) (err error) {
+	configID := model.ConfigID(agentID, llmConfig.ID)
+	runs := max(config.Runs, 1)
+	progressKey := job.taskID + " " + configID
+	if runs > 1 {
+		progressKey += fmt.Sprintf(" run %d", run)
 	}
+	logger := slog.With("worker", workerID, "task", job.taskID, "config", configID)
+	if runs > 1 {
+		logger = logger.With("run", run)
+	}
+	taskOutputDir := ""
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recoverTaskPanic(job, config, agentID, llmConfig, run, progressKey, taskOutputDir, r, debug.Stack())
+		}
+	}()
 
-	return tasks, nil
-}
+	if reason := s.stopReason(); reason != "" {
+		// Results of combinations skipped by a stopped run are not written, so --resume runs them.
+		s.sendResult(progressKey, skippedResult(job, config, agentID, llmConfig, reason))
+		s.mutex.Lock()
+		switch reason {
+		case runTimeoutReason:
+			s.timeoutSkips++
+		case costBudgetReason, tokenBudgetReason:
+			s.budgetSkips++
+		default:
+			s.stoppedSkips++
+		}
+		s.mutex.Unlock()
+		return nil
+	}
 
-// getLastNLines returns the last n lines of a string.
-func getLastNLines(s string, n int) (string, bool) {
-	lines := strings.Split(s, "\n")
-	if len(lines) > n {
-		return strings.Join(lines[len(lines)-n:], "\n"), true
+	if config.OutputDir != "" {
+		taskOutputDir = taskOutputPath(config, job.taskID, configID, run)
+		if err := os.MkdirAll(taskOutputDir, 0755); err != nil {
+			return fmt.Errorf("creating directory %q: %w", taskOutputDir, err)
+		}
 	}
-	return s, false
-}
 
-func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, log io.Writer) model.TaskResult {
-	result := model.TaskResult{
-		Task:      taskID,
-		LLMConfig: llmConfig,
+	if config.Resume && taskOutputDir != "" {
+		// Tasks that were disabled in the resumed run are run if they are now enabled or included.
+		if previous := loadCompletedResult(taskOutputDir); previous != nil && !previous.Disabled {
+			logger.Info("Loaded previous result", "result", previous.Result)
+			previous.Resumed = true
+			s.recordResult(job.taskID, configID, *previous)
+			s.sendResult(progressKey, *previous)
+			return nil
+		}
 	}
 
-	// Timeout limit for the whole task (setup, agent actions, verify)
-	timeout := 10 * time.Minute
-	if task.Timeout != "" {
+	var release func()
+	if blockedBy == "" {
+		limiter := s.limiters.forConfig(llmConfig)
+		if limiter.blocked() {
+			// Like those of a stopped run, the results are not written, so --resume runs them once the limit is fixed.
+			reason := fmt.Sprintf("provider %s has a concurrency limit of 0", limiter.name)
+			s.sendResult(progressKey, skippedResult(job, config, agentID, llmConfig, reason))
+			s.mutex.Lock()
+			s.limitSkips[limiter.name]++
+			s.mutex.Unlock()
+			return nil
+		}
 		var err error
-		timeout, err = time.ParseDuration(task.Timeout)
+		if limiter != nil {
+			s.progress.waitingOn(limiter.name, 1)
+			release, err = limiter.acquire(ctx)
+			s.progress.waitingOn(limiter.name, -1)
+		} else {
+			release = func() {}
+		}
 		if err != nil {
-			result.Result = "fail"
-			result.Error = fmt.Sprintf("parsing timeout: %v", err)
-			return result
+			reason := fmt.Sprintf("run ended while waiting on provider %s", limiter.name)
+			s.sendResult(progressKey, skippedResult(job, config, agentID, llmConfig, reason))
+			return nil
 		}
 	}
 
-	taskCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	var result model.TaskResult
+	if blockedBy != "" {
+		logger.Info("Skipping, dependency did not pass", "dependency", blockedBy)
+		result = skippedResult(job, config, agentID, llmConfig, fmt.Sprintf("blocked by dependency %s, which did not pass", blockedBy))
+	} else {
+		start := time.Now()
+		logger.Info("Started", runlog.EventKey, runlog.EventStarted)
 
-	taskOutputDir := filepath.Join(config.OutputDir, taskID)
+		s.progress.started(progressKey)
+		config.metrics.taskStarted()
+		var err error
+		func() {
+			// The provider slot is released even if the evaluation panics.
+			defer release()
+			defer config.metrics.taskFinished()
+			result, err = s.evaluate(ctx, config, job.taskID, job.task, llmConfig, s.clusterProvider, taskOutputDir)
+		}()
+		if err != nil {
+			return err
+		}
+		// The duration comes from the task's own timing when it has one, so they agree.
+		duration := time.Since(start)
+		if result.Timing != nil && !result.Timing.EndTime.IsZero() {
+			duration = result.Timing.EndTime.Sub(result.Timing.StartTime)
+		}
+		result.Duration = duration.Round(time.Millisecond).String()
 
-	var logBuffer bytes.Buffer
-	multiWriter := io.MultiWriter(&logBuffer)
-	if log != nil {
-		multiWriter = io.MultiWriter(log, &logBuffer)
+		logger.Info("Completed", runlog.EventKey, runlog.EventCompleted, "duration", duration.Round(time.Second), "result", result.Result)
+		// Without the task output on the console, the reason of a failure is shown here.
+		if config.ConsoleOutput != ConsoleOutputFull {
+			if message := firstFailure(result); message != "" {
+				logger.Info("Reason", "result", result.Result, "reason", message)
+			}
+		}
+		if config.TaskCostCap > 0 && result.Usage != nil && result.Usage.Cost != nil && *result.Usage.Cost > config.TaskCostCap {
+			result.CostCapExceeded = true
+			logger.Warn(fmt.Sprintf("cost an estimated $%.4f, above the --task-cost-cap of $%.2f", *result.Usage.Cost, config.TaskCostCap))
+		}
+	}
+	if runs > 1 {
+		result.Run = run
 	}
+	if s.rerun != nil {
+		result.RerunOf = s.rerun.rerunOf
+	}
+	result.RunID = config.RunID
+	result.Agent = agentID
+	result.Worker = &workerID
 
-	x := &TaskExecution{
-		AgentBin:        config.AgentBin,
-		kubeConfig:      config.KubeConfig,
-		result:          &result,
-		llmConfig:       llmConfig,
-		log:             multiWriter,
-		task:            &task,
-		taskID:          taskID,
-		taskOutputDir:   taskOutputDir,
-		clusterProvider: clusterProvider,
+	s.recordResult(job.taskID, configID, result)
+
+	if taskOutputDir != "" {
+		if err := writeToYAMLFile(filepath.Join(taskOutputDir, "results.yaml"), result); err != nil {
+			return fmt.Errorf("writing results to file: %w", err)
+		}
 	}
+	s.sendResult(progressKey, result)
 
-	// Set the isolation mode to cluster if vcluster is used.
-	if config.ClusterProvider == "vcluster" {
-		x.task.Isolation = IsolationModeCluster
+	if blockedBy == "" && s.baseline != nil && job.sharedCluster {
+		resetSharedCluster(ctx, config, s.baseline)
 	}
+	return nil
+}
 
-	taskDir := filepath.Join(config.TasksDir, taskID)
-	taskDirAbs, err := filepath.Abs(taskDir)
-	if err != nil {
-		result.Result = "fail"
-		result.Error = err.Error()
-		return result
+// recoverTaskPanic records the run of the task that panicked as an error, with the stack trace
+// appended to its log, and returns any error writing its result.
+func (s *taskScheduler) recoverTaskPanic(job taskJob, config EvalConfig, agentID string, llmConfig model.LLMConfig, run int, progressKey, taskOutputDir string, value any, stack []byte) error {
+	slog.Error("Task panicked", "task", job.taskID, "config", model.ConfigID(agentID, llmConfig.ID), "panic", value)
+	result := skippedResult(job, config, agentID, llmConfig, "")
+	result.Result = "error"
+	result.Error = fmt.Sprintf("panic: %v", value)
+	result.Panicked = true
+	if config.Runs > 1 {
+		result.Run = run
+	}
+	if s.rerun != nil {
+		result.RerunOf = s.rerun.rerunOf
 	}
-	taskDir = taskDirAbs
-	x.taskDir = taskDir
 
-	defer func() {
-		if err := x.runCleanup(context.Background()); err != nil {
-			fmt.Printf("Warning: cleanup failed for task %s: %v\n", taskID, err)
+	var errs []error
+	if taskOutputDir != "" {
+		if err := appendToFile(filepath.Join(taskOutputDir, "log.txt"), fmt.Sprintf("\npanic: %v\n\n%s", value, stack)); err != nil {
+			errs = append(errs, fmt.Errorf("writing the panic to the task log: %w", err))
+		}
+		result.Error += fmt.Sprintf(" (stack trace in %s)", filepath.Join(taskOutputDir, "log.txt"))
+	}
+	s.recordResult(job.taskID, model.ConfigID(agentID, llmConfig.ID), result)
+	if taskOutputDir != "" {
+		if err := writeToYAMLFile(filepath.Join(taskOutputDir, "results.yaml"), result); err != nil {
+			errs = append(errs, fmt.Errorf("writing results to file: %w", err))
 		}
-	}()
-
-	if err := x.runSetup(taskCtx); err != nil {
-		// Unexpected error
-		result.Error = err.Error()
-		return result
 	}
+	s.sendResult(progressKey, result)
+	return errors.Join(errs...)
+}
 
-	// Run the agent
-	agentOutput, err := x.runAgent(taskCtx)
+// appendToFile appends text to the file, creating it if needed.
+func appendToFile(path, text string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		if taskCtx.Err() == context.DeadlineExceeded {
-			result.Result = "fail"
-			result.AddFailure("task timed out after %v", timeout)
-			return result
+		return err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// countTimedOut returns the number of results whose agent hit the task timeout, and how many of them passed.
+func countTimedOut(results []model.TaskResult) (int, int) {
+	timedOut, passed := 0, 0
+	for _, result := range results {
+		if result.TimedOut {
+			timedOut++
+			if result.Result == "success" {
+				passed++
+			}
 		}
-		// Unexpected error
-		result.Result = "error"
-		const maxErrLogLines = 3
-		logString := logBuffer.String()
-		logTail, truncated := getLastNLines(logString, maxErrLogLines)
-		// build log file path
-		logPath := taskOutputDir
-		errorMessage := fmt.Sprintf("agent encountered error: %v\n---LOG---\n%s", err, logTail)
-		if truncated {
-			errorMessage += fmt.Sprintf("\n... (log truncated, full log at %s)", logPath)
+	}
+	return timedOut, passed
+}
+
+// countCleanupErrors returns the number of results whose cleanup failed.
+func countCleanupErrors(results []model.TaskResult) int {
+	n := 0
+	for _, result := range results {
+		if len(result.CleanupErrors) > 0 {
+			n++
 		}
-		result.Error = errorMessage
-		return result
 	}
+	return n
+}
 
-	var expectationFailures []model.Failure
+// countDisabled returns the number of skipped results of disabled tasks.
+func countDisabled(results []model.TaskResult) int {
+	n := 0
+	for _, result := range results {
+		if result.Disabled {
+			n++
+		}
+	}
+	return n
+}
 
-	if len(task.Expect) > 0 {
-		// find the output after the last run command and search it
-		var lastCmdOutput string
-		lastToolRunIndex := strings.LastIndex(agentOutput, "Running:")
-		if lastToolRunIndex == -1 {
-			// if no tool run found, parse the entire output
-			lastCmdOutput = agentOutput
-		} else {
-			remaining := agentOutput[lastToolRunIndex:]
-			newlineIndex := strings.Index(remaining, "\n")
-			if newlineIndex != -1 {
-				lastCmdOutput = remaining[newlineIndex+1:]
-			}
-			// if no newline, lastCmdOutput is empty string
+// countPanicked returns the number of results of evaluations that panicked.
+func countPanicked(results []model.TaskResult) int {
+	n := 0
+	for _, result := range results {
+		if result.Panicked {
+			n++
 		}
+	}
+	return n
+}
 
-		for _, expect := range task.Expect {
-			if expect.Contains != "" {
-				re, err := regexp.Compile(expect.Contains)
-				if err != nil {
-					expectationFailures = append(expectationFailures, model.Failure{
-						Message: fmt.Sprintf("invalid regex %q in task spec: %v", expect.Contains, err),
-					})
-					continue
+// countCostCapExceeded returns the number of results flagged for exceeding the --task-cost-cap.
+func countCostCapExceeded(results []model.TaskResult) int {
+	n := 0
+	for _, result := range results {
+		if result.CostCapExceeded {
+			n++
+		}
+	}
+	return n
+}
+
+// skippedResult returns the result of a task combination that is skipped without being evaluated.
+func skippedResult(job taskJob, config EvalConfig, agentID string, llmConfig model.LLMConfig, reason string) model.TaskResult {
+	return model.TaskResult{
+		Task:       job.taskID,
+		LLMConfig:  llmConfig,
+		Agent:      agentID,
+		Result:     "skipped",
+		SkipReason: reason,
+		Difficulty: job.task.Difficulty,
+		Category:   job.task.Category,
+		Suite:      config.Suite,
+		RunID:      config.RunID,
+	}
+}
+
+// writeDisabledResults returns the skipped results of the disabled tasks, one per agent and LLM config
+// selected to run, and writes them to the output directory like those of the tasks that run.
+func writeDisabledResults(config EvalConfig, disabledTasks map[string]Task, rerun *rerunSelection) ([]model.TaskResult, error) {
+	var results []model.TaskResult
+	for _, taskID := range sortedKeys(disabledTasks) {
+		task := disabledTasks[taskID]
+		reason := "task is disabled"
+		if task.DisabledReason != "" {
+			reason += ": " + task.DisabledReason
+		}
+		for _, job := range taskJobs(config, taskID, task, rerun) {
+			result := skippedResult(job, config, job.agentID, job.llmConfig, reason)
+			result.Disabled = true
+			results = append(results, result)
+			// With --runs, the result is not repeated for each run; it goes in the directory of the first.
+			taskOutputDir := taskOutputPath(config, taskID, result.ConfigID(), 1)
+			if err := os.MkdirAll(taskOutputDir, 0755); err != nil {
+				return nil, fmt.Errorf("creating directory %q: %w", taskOutputDir, err)
+			}
+			if err := writeToYAMLFile(filepath.Join(taskOutputDir, "results.yaml"), result); err != nil {
+				return nil, fmt.Errorf("writing results to file: %w", err)
+			}
+		}
+	}
+	return results, nil
+}
+
+// sendResult sends the result of a task combination to be reported, and records its progress.
+func (s *taskScheduler) sendResult(progressKey string, result model.TaskResult) {
+	s.progress.finished(progressKey, result)
+	s.config.metrics.recordResult(result)
+	s.results <- result
+}
+
+// recordResult records whether the task passed for the LLM config (see model.ConfigID), for its dependents,
+// and counts the failures of executed tasks for --max-failures.
+// Dependents see the cluster as left by the most recent run.
+func (s *taskScheduler) recordResult(taskID string, llmID string, result model.TaskResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.passed[taskID] == nil {
+		s.passed[taskID] = make(map[string]bool)
+	}
+	s.passed[taskID][llmID] = result.Result == "success"
+
+	if result.Resumed {
+		return
+	}
+	if result.Result == "fail" || (result.Result == "error" && !s.config.MaxFailuresIgnoreErrors) {
+		s.failures++
+	}
+	if result.Usage != nil {
+		s.tokens += result.Usage.Tokens()
+		if result.Usage.Cost != nil {
+			s.cost += *result.Usage.Cost
+		}
+	}
+}
+
+// runTimeoutReason is the skip reason of tasks not started because of --run-timeout.
+const runTimeoutReason = "run timeout"
+
+// costBudgetReason and tokenBudgetReason are the skip reasons of tasks not started because
+// of --max-cost and --max-total-tokens.
+const (
+	costBudgetReason  = "cost budget exceeded"
+	tokenBudgetReason = "token budget exceeded"
+)
+
+// stopReason returns why no more tasks should be run, or "" to keep running.
+func (s *taskScheduler) stopReason() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.runDeadline.IsZero() && time.Now().Add(s.headroom).After(s.runDeadline) {
+		return runTimeoutReason
+	}
+	if s.config.MaxCost > 0 && s.cost >= s.config.MaxCost {
+		return costBudgetReason
+	}
+	if s.config.MaxTotalTokens > 0 && s.tokens >= s.config.MaxTotalTokens {
+		return tokenBudgetReason
+	}
+	if s.config.MaxFailures > 0 && s.failures >= s.config.MaxFailures {
+		return fmt.Sprintf("run stopped after %d failures (--max-failures %d)", s.failures, s.config.MaxFailures)
+	}
+	return ""
+}
+
+// taskOutputPath returns the output directory of a run of the task with an LLM config:
+// <output-dir>/<taskID>/<llmID>, with a run-<n> subdirectory for each run if there are several.
+// When the run compares agents, llmID is <agentID>/<llmID> (see model.ConfigID), adding a level.
+func taskOutputPath(config EvalConfig, taskID string, llmID string, run int) string {
+	// Task IDs with directories (e.g. networking/dns-failure) get nested output directories.
+	dir := filepath.Join(config.OutputDir, filepath.FromSlash(taskID), llmID)
+	if config.Runs > 1 {
+		dir = filepath.Join(dir, fmt.Sprintf("run-%d", run))
+	}
+	return dir
+}
+
+// firstFailure returns the first line of the first failure or error of the result, if any.
+func firstFailure(result model.TaskResult) string {
+	message := result.Error
+	if len(result.Failures) > 0 {
+		message = result.Failures[0].Message
+	}
+	message, _, _ = strings.Cut(message, "\n")
+	return message
+}
+
+// runConfigIDs returns the IDs of the LLM configs the run evaluates, per agent (see model.ConfigID).
+func runConfigIDs(config EvalConfig) []string {
+	var ids []string
+	for _, agent := range config.Agents {
+		for _, llmConfig := range config.LLMConfigs {
+			ids = append(ids, model.ConfigID(agent.ID, llmConfig.ID))
+		}
+	}
+	return ids
+}
+
+// newClusterProvider constructs the cluster provider selected in the config.
+// The returned cleanup function releases any resources held by the provider.
+func newClusterProvider(config EvalConfig) (cluster.Provider, func(), error) {
+	switch config.ClusterProvider {
+	case "kind":
+		opts := config.Kind
+		opts.Timeouts = config.ClusterTimeouts
+		return kind.New(opts), func() {}, nil
+	case "vcluster":
+		vclusterLabels := map[string]string{vcluster.RunIDLabel: config.RunID}
+		for k, v := range config.VClusterLabels {
+			vclusterLabels[k] = v
+		}
+		provider, cleanup, err := vcluster.New(vcluster.Options{
+			HostContext:    config.HostClusterContext,
+			HostKubeConfig: config.HostClusterKubeConfig,
+			ReadyTimeout:   config.VClusterReadyTimeout,
+			Timeouts:       config.ClusterTimeouts,
+			ClusterOptions: config.VCluster,
+
+			ExposureMode:      vcluster.ExposureMode(config.VClusterExposureMode),
+			IngressExternalIP: config.VClusterIngressExternalIP,
+
+			NamespacePrefix: config.VClusterNamespacePrefix,
+			Labels:          vclusterLabels,
+			Annotations:     config.VClusterAnnotations,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create vcluster provider: %w", err)
+		}
+		return provider, cleanup, nil
+	case "minikube":
+		return minikube.New(config.MinikubeDriver, config.KubernetesVersion, config.ClusterTimeouts), func() {}, nil
+	case "gke":
+		opts := config.GKE
+		opts.Timeouts = config.ClusterTimeouts
+		provider, err := gke.New(opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create gke provider: %w", err)
+		}
+		return provider, func() {}, nil
+	case "external":
+		return external.New(config.KubeConfig, config.KubeContext, config.ClusterTimeouts), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown cluster provider: %s", config.ClusterProvider)
+	}
+}
+
+// writeToYAMLFile will encode the specified object as yaml, and write it to the file.
+// The file is replaced atomically, so an interrupted run does not leave it partially written.
+func writeToYAMLFile(p string, obj any) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshaling to yaml: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return fmt.Errorf("writing to file %q: %w", p, err)
+	}
+	return nil
+}
+
+func loadTasks(config EvalConfig) (map[string]Task, map[string]Task, error) {
+	tasks := make(map[string]Task)
+	disabledTasks := make(map[string]Task)
+
+	var taskFilter *regexp.Regexp
+	if config.TaskPattern != "" {
+		var err error
+		taskFilter, err = regexp.Compile(config.TaskPattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("compiling task pattern regex %q: %w", config.TaskPattern, err)
+		}
+	}
+
+	dirs, err := findTaskDirs(config.tasksDirs())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var suitePatterns []string
+	if config.Suite != "" {
+		suites, err := loadSuites(config.tasksDirs())
+		if err != nil {
+			return nil, nil, err
+		}
+		taskIDs := make(map[string]bool)
+		for _, dir := range dirs {
+			taskIDs[dir.id] = true
+		}
+		suitePatterns, err = resolveSuite(suites, config.Suite, taskIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var filteredBySuite, filteredByPattern, excludedByTags, notIncludedByTags, disabled int
+	problems := make(map[string][]string)
+	// dependencies are the dependsOn of every task found, selected or not, to check for cycles.
+	dependencies := make(map[string][]string)
+	for _, dir := range dirs {
+		inSuite := config.Suite == "" || matchesAnyPattern(dir.id, suitePatterns)
+		// Matrix task instances can match the pattern even if their directory does not.
+		dirMatches := taskFilter == nil || taskFilter.MatchString(dir.id)
+
+		taskPath := filepath.Join(dir.path(), taskFile)
+
+		data, err := os.ReadFile(taskPath)
+		if err != nil {
+			if !inSuite {
+				filteredBySuite++
+				continue
+			}
+			if !dirMatches {
+				filteredByPattern++
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to read task file %s: %w", taskPath, err)
+		}
+
+		instances, err := expandTask(dir.id, data)
+		if err != nil {
+			if !inSuite {
+				filteredBySuite++
+				continue
+			}
+			if !dirMatches {
+				filteredByPattern++
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to parse task file %s: %w", taskPath, err)
+		}
+		for taskID, task := range instances {
+			dependencies[taskID] = task.DependsOn
+		}
+		if !inSuite {
+			filteredBySuite++
+			continue
+		}
+
+		for taskID, task := range instances {
+			task.root = dir.root
+			if !dirMatches && !taskFilter.MatchString(taskID) {
+				filteredByPattern++
+				continue
+			}
+			if hasAnyTag(task.Tags, config.ExcludeTags) {
+				excludedByTags++
+				continue
+			}
+			if len(config.IncludeTags) > 0 && !hasAnyTag(task.Tags, config.IncludeTags) {
+				notIncludedByTags++
+				continue
+			}
+
+			// Disabled tasks are reported as skipped, unless they are included.
+			if task.Disabled && !config.IncludeDisabled {
+				slog.Debug("Skipping disabled task", "task", taskID)
+				disabledTasks[taskID] = task
+				disabled++
+				continue
+			}
+
+			// The selected tasks are checked before anything is created, reporting all their problems at once.
+			if taskProblems := lintTask(task.root, task); len(taskProblems) > 0 {
+				problems[taskID] = taskProblems
+				continue
+			}
+			tasks[taskID] = task
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid tasks (run the lint command to check all tasks):\n%s", strings.TrimSuffix(formatLintProblems(problems), "\n"))
+	}
+	if err := checkDependencyCycles(dependencies); err != nil {
+		return nil, nil, err
+	}
+
+	attrs := []any{"count", len(tasks)}
+	if config.Suite != "" {
+		attrs = append(attrs, "suite", config.Suite)
+	}
+	if filtered := filteredBySuite + filteredByPattern + excludedByTags + notIncludedByTags + disabled; filtered > 0 {
+		attrs = append(attrs, slog.Group("filteredOut",
+			"suite", filteredBySuite, "pattern", filteredByPattern, "excludeTags", excludedByTags,
+			"includeTags", notIncludedByTags, "disabled", disabled))
+	}
+	slog.Info("Loaded tasks", attrs...)
+
+	// With vcluster, every task runs in its own cluster, so dependencies are ignored.
+	if err := resolveDependencies(tasks, config.ClusterProvider == "vcluster"); err != nil {
+		return nil, nil, err
+	}
+
+	return tasks, disabledTasks, nil
+}
+
+// hasAnyTag reports whether tags contains any of wanted.
+func hasAnyTag(tags []string, wanted []string) bool {
+	for _, tag := range wanted {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateTaskWithRetries evaluates the task, retrying according to the task's retry settings.
+// The log of the first attempt is written to log.txt, later attempts to log-attempt-<n>.txt.
+// Attempts that fail with a transient error (see classifyTransient) are first retried up to
+// --transient-retries times in total, keeping their logs as <log>-transient-<n>.txt.
+func evaluateTaskWithRetries(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, taskOutputDir string) (model.TaskResult, error) {
+	retries := config.TaskRetries
+	if task.Retries != nil {
+		retries = *task.Retries
+	}
+	policy := task.RetryPolicy
+	if policy == "" {
+		policy = RetryPolicyAny
+	}
+
+	var attempts []model.TaskResult
+	transientRetries := 0
+	transientError := ""
+	for attempt := 1; attempt <= retries+1; attempt++ {
+		if attempt > 1 {
+			slog.Info("Retrying task", "task", taskID, "config", llmConfig.ID, "attempt", attempt, "attempts", retries+1)
+		}
+
+		logName := "log.txt"
+		if attempt > 1 {
+			logName = fmt.Sprintf("log-attempt-%d.txt", attempt)
+		}
+		result, err := evaluateTaskAttempt(ctx, config, taskID, task, llmConfig, clusterProvider, taskOutputDir, logName)
+		if err != nil {
+			return model.TaskResult{}, err
+		}
+		for result.TransientError != "" && transientRetries < config.TransientRetries {
+			transientRetries++
+			transientError = result.TransientError
+			backoff := transientBackoff(config.TransientBackoff, transientRetries)
+			slog.Info("Task failed with a transient error, retrying", "task", taskID, "config", llmConfig.ID,
+				"matched", result.TransientError, "backoff", backoff, "retry", transientRetries, "retries", config.TransientRetries)
+			if taskOutputDir != "" {
+				kept := fmt.Sprintf("%s-transient-%d.txt", strings.TrimSuffix(logName, ".txt"), transientRetries)
+				if err := os.Rename(filepath.Join(taskOutputDir, logName), filepath.Join(taskOutputDir, kept)); err != nil {
+					slog.Warn("failed to keep the log of the transient failure", "task", taskID, "error", err)
 				}
-				if !re.MatchString(lastCmdOutput) {
-					expectationFailures = append(expectationFailures, model.Failure{
-						Message: fmt.Sprintf("regex %q did not match output %q", expect.Contains, lastCmdOutput),
-					})
+			}
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			result, err = evaluateTaskAttempt(ctx, config, taskID, task, llmConfig, clusterProvider, taskOutputDir, logName)
+			if err != nil {
+				return model.TaskResult{}, err
+			}
+		}
+		attempts = append(attempts, result)
+
+		passed := result.Result == "success"
+		if policy == RetryPolicyAny && passed {
+			break
+		}
+		if policy == RetryPolicyAll && !passed {
+			break
+		}
+	}
+
+	// A single attempt is reported as-is.
+	if len(attempts) == 1 {
+		return withTransientRetries(attempts[0], transientRetries, transientError), nil
+	}
+
+	// Report the deciding attempt: the passing one for "any", the failing one for "all" (or the last attempt otherwise).
+	final := attempts[len(attempts)-1]
+	if final.Timing != nil && attempts[0].Timing != nil {
+		timing := *final.Timing
+		timing.StartTime = attempts[0].Timing.StartTime
+		final.Timing = &timing
+	}
+	for _, attempt := range attempts {
+		final.Attempts = append(final.Attempts, model.AttemptResult{
+			Result:   attempt.Result,
+			Score:    attempt.Score,
+			Failures: attempt.Failures,
+			Error:    attempt.Error,
+		})
+	}
+	return withTransientRetries(final, transientRetries, transientError), nil
+}
+
+// withTransientRetries records the transient retries of the task on its result; the matched
+// pattern is that of the last transient failure, unless the result itself is one.
+func withTransientRetries(result model.TaskResult, retries int, transientError string) model.TaskResult {
+	result.TransientRetries = retries
+	if result.TransientError == "" {
+		result.TransientError = transientError
+	}
+	return result
+}
+
+// evaluateTaskAttempt evaluates the task once, writing its log to logName in the task output directory.
+func evaluateTaskAttempt(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, taskOutputDir string, logName string) (model.TaskResult, error) {
+	var log io.Writer
+	var logFile *os.File
+	var limitedLog *limitedWriter
+	if taskOutputDir != "" {
+		logPath := filepath.Join(taskOutputDir, logName)
+		var err error
+		logFile, err = os.Create(logPath)
+		if err != nil {
+			return model.TaskResult{}, fmt.Errorf("creating log file %q: %w", logPath, err)
+		}
+		limitedLog = newLimitedWriter(logFile, config.MaxLogBytes)
+		log = limitedLog
+	}
+
+	result := evaluateTask(ctx, config, taskID, task, llmConfig, clusterProvider, taskOutputDir, log)
+	if logFile != nil {
+		logFile.Close()
+		result.LogTruncated = limitedLog.Truncated()
+	}
+	return result, nil
+}
+
+// agentOutputs returns the parts of the agent output that expectations can target, and their source:
+// the agent's trace, or if the trace has no tool calls, the console output after the last "Running:" line.
+func (x *TaskExecution) agentOutputs(agentOutput string) (agentOutputs, string) {
+	if lastToolOutput, finalMessage, ok := traceOutputs(x.trace); ok {
+		return agentOutputs{lastToolOutput: lastToolOutput, finalMessage: finalMessage, transcript: agentOutput, stderr: x.stderrOutput.String()}, model.OutputSourceTrace
+	}
+
+	// find the output after the last run command and search it
+	var lastCmdOutput string
+	lastToolRunIndex := strings.LastIndex(agentOutput, "Running:")
+	if lastToolRunIndex == -1 {
+		// if no tool run found, parse the entire output
+		lastCmdOutput = agentOutput
+	} else {
+		remaining := agentOutput[lastToolRunIndex:]
+		newlineIndex := strings.Index(remaining, "\n")
+		if newlineIndex != -1 {
+			lastCmdOutput = remaining[newlineIndex+1:]
+		}
+		// if no newline, lastCmdOutput is empty string
+	}
+	return agentOutputs{lastToolOutput: lastCmdOutput, finalMessage: lastCmdOutput, transcript: agentOutput, stderr: x.stderrOutput.String()}, model.OutputSourceConsole
+}
+
+// getLastNLines returns the last n lines of a string.
+func getLastNLines(s string, n int) (string, bool) {
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		return strings.Join(lines[len(lines)-n:], "\n"), true
+	}
+	return s, false
+}
+
+// defaultTaskTimeout bounds a task without a timeout.
+const defaultTaskTimeout = 10 * time.Minute
+
+func evaluateTask(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, taskOutputDir string, log io.Writer) (result model.TaskResult) {
+	result = model.TaskResult{
+		Task:       taskID,
+		LLMConfig:  llmConfig,
+		Difficulty: task.Difficulty,
+		Category:   task.Category,
+		Suite:      config.Suite,
+	}
+
+	// Timeout limit for the whole task (setup, agent actions, verify)
+	timeout := defaultTaskTimeout
+	if task.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(task.Timeout)
+		if err != nil {
+			result.Result = "fail"
+			result.Error = fmt.Sprintf("parsing timeout: %v", err)
+			return result
+		}
+	}
+
+	spanAttributes := map[string]any{
+		"task.id":          taskID,
+		"llm_config.id":    llmConfig.ID,
+		"cluster.provider": config.ClusterProvider,
+	}
+	ctx, taskSpan := startSpan(ctx, "task", spanAttributes)
+	// Failures and errors of the task are recorded on its span.
+	defer func() {
+		taskSpan.setAttribute("result", result.Result)
+		for _, failure := range result.Failures {
+			taskSpan.addEvent("failure", map[string]any{"message": failure.Message})
+		}
+		if result.Result == "fail" || result.Result == "error" {
+			taskSpan.fail(firstFailure(result))
+		}
+		taskSpan.finish(nil)
+	}()
+
+	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Only the tail of the log is kept in memory, for error messages.
+	logBuffer := newTailBuffer(int(config.MaxLogBufferBytes))
+	multiWriter := io.MultiWriter(logBuffer)
+	if log != nil {
+		multiWriter = io.MultiWriter(log, logBuffer)
+	}
+	// Secrets are masked before anything reaches the log.
+	redactedLog := newRedactingWriter(multiWriter, config.redactor)
+
+	x := &TaskExecution{
+		agentRunner:     config.agent,
+		kubeConfig:      config.KubeConfig,
+		result:          &result,
+		llmConfig:       llmConfig,
+		llmEnv:          config.llmEnvs[llmConfig.ID],
+		log:             redactedLog,
+		redactor:        config.redactor,
+		task:            &task,
+		taskID:          taskID,
+		configID:        config.configID,
+		taskOutputDir:   taskOutputDir,
+		clusterProvider: clusterProvider,
+		sharedCluster:   config.clusterName,
+		readyTimeout:    config.ClusterReadyTimeout,
+		diffResources:   diffResources(config),
+		judge:           config.Judge,
+		modelPrices:     config.ModelPrices,
+
+		stepReadyPattern: config.StepReadyPattern,
+		stepIdleTime:     config.StepIdleTime,
+		console:          newTaskConsole(config.ConsoleOutput, config.consolePrefix, config.color),
+		stallTimeout:     config.AgentStallTimeout,
+		metrics:          config.metrics,
+	}
+	// Partial lines held for prefixing are written out once everything else is done.
+	defer x.console.flush()
+
+	// Set the isolation mode to cluster if vcluster is used.
+	if config.ClusterProvider == "vcluster" {
+		x.task.Isolation = IsolationModeCluster
+	}
+
+	taskDir := filepath.Join(task.root, filepath.FromSlash(task.dir))
+	taskDirAbs, err := filepath.Abs(taskDir)
+	if err != nil {
+		result.Result = "fail"
+		result.Error = err.Error()
+		return result
+	}
+	taskDir = taskDirAbs
+	x.taskDir = taskDir
+	x.tasksDir = task.root
+
+	timing := &model.TaskTiming{StartTime: time.Now()}
+	result.Timing = timing
+	phaseStart := timing.StartTime
+	endPhase := func(duration *string) {
+		now := time.Now()
+		*duration = now.Sub(phaseStart).Round(time.Millisecond).String()
+		phaseStart = now
+	}
+
+	defer func() {
+		// Keep the isolated cluster of a failed task if requested; the task's other cleanup still runs.
+		if (config.KeepClusterOnFailure || task.KeepOnFailure) && (result.Result == "fail" || result.Result == "error") && x.clusterName != "" {
+			kept, err := x.keepCluster(config)
+			if err != nil {
+				slog.Warn("failed to keep cluster, deleting it", "cluster", x.clusterName, "task", taskID, "error", err)
+				result.CleanupErrors = append(result.CleanupErrors, fmt.Sprintf("keeping cluster %s: %v", x.clusterName, err))
+			} else {
+				result.KeptCluster = kept
+			}
+		}
+		// Cleanup runs even if the task timed out, in the task's span.
+		cleanupCtx, cleanupSpan := startSpan(context.WithoutCancel(taskCtx), "cleanup", spanAttributes)
+		// Cleanup failures are recorded in the result, without changing its outcome.
+		cleanupErrs := x.runCleanup(cleanupCtx)
+		cleanupSpan.finish(errors.Join(cleanupErrs...))
+		for _, err := range cleanupErrs {
+			slog.Warn("cleanup failed", "task", taskID, "error", err)
+			result.CleanupErrors = append(result.CleanupErrors, err.Error())
+		}
+		endPhase(&timing.Cleanup)
+		timing.EndTime = phaseStart
+
+		redactedLog.Flush()
+		config.redactor.redactResult(&result)
+	}()
+
+	// Collect diagnostics of a failed task before cleanup (defers run in reverse order).
+	// Collection failures are only reported, they do not change the result.
+	defer func() {
+		if result.Result != "fail" && result.Result != "error" {
+			return
+		}
+		if err := x.collectDiagnostics(); err != nil {
+			slog.Warn("some diagnostics could not be collected", "task", taskID, "error", err)
+		}
+	}()
+
+	// Export cluster logs before cleanup deletes the cluster (defers run in reverse order).
+	if config.CollectClusterLogs {
+		defer func() {
+			if result.Result == "success" {
+				return
+			}
+			if err := x.exportClusterLogs(); err != nil {
+				slog.Warn("failed to export cluster logs", "task", taskID, "error", err)
+			}
+		}()
+	}
+
+	// Verification ends when the task returns, and the deferred cleanup starts.
+	defer func() {
+		if timing.Agent != "" {
+			endPhase(&timing.Verify)
+		}
+	}()
+
+	setupCtx, setupSpan := startSpan(taskCtx, "setup", spanAttributes)
+	err = x.runSetup(setupCtx)
+	setupSpan.finish(err)
+	endPhase(&timing.Setup)
+	if err != nil {
+		// Unexpected error
+		result.Result = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	// The cluster is described once setup has created or selected it; an unknown version is left empty.
+	result.Cluster = &model.ClusterInfo{
+		Provider:      config.ClusterProvider,
+		Name:          x.sharedCluster,
+		Isolation:     string(x.task.Isolation),
+		ServerVersion: serverVersion(taskCtx, x.kubeConfig),
+	}
+	if x.clusterName != "" {
+		result.Cluster.Name = x.clusterName
+	}
+	if len(x.task.Clusters) > 0 {
+		for _, c := range x.clusters {
+			result.Cluster.Clusters = append(result.Cluster.Clusters, c.clusterName)
+		}
+	}
+
+	// Snapshots for the cluster diff count against the task timeout; without a snapshot of the
+	// cluster before the agent ran, there is no diff.
+	var snapshot diffSnapshot
+	if len(x.diffResources) > 0 && taskOutputDir != "" {
+		if snapshot, err = x.snapshotCluster(taskCtx); err != nil {
+			slog.Warn("failed to snapshot the cluster before the agent ran, not capturing its diff", "task", taskID, "error", err)
+		}
+	}
+
+	// Run the agent
+	agentCtx, agentSpan := startSpan(taskCtx, "agent", spanAttributes)
+	agentOutput, err := x.runAgent(agentCtx)
+	agentSpan.finish(err)
+	endPhase(&timing.Agent)
+	if snapshot != nil {
+		if err := x.captureClusterDiff(taskCtx, snapshot); err != nil {
+			slog.Warn("failed to capture the cluster diff", "task", taskID, "error", err)
+		}
+	}
+	if taskOutputDir != "" {
+		x.analyzeTrace(filepath.Join(taskOutputDir, "trace.yaml"))
+	}
+	if x.idleErr != nil {
+		// The agent may have exited because the steps were cut short, which is not its failure.
+		result.Result = "error"
+		result.Error = x.idleErr.Error()
+		return result
+	}
+	if err != nil {
+		if taskCtx.Err() == context.DeadlineExceeded {
+			// The agent may have solved the task and been cut short while summarizing, so the
+			// cluster state is still verified, unless the whole run ended. Failures recorded while
+			// sending the script steps, and a judge (which grades the cut-short transcript), fail the task.
+			result.TimedOut = true
+			agentFailed := len(result.Failures) > 0
+			result.Result = "fail"
+			result.AddFailure("task timed out after %v", timeout)
+			if task.verifiesAfterTimeout() && ctx.Err() == nil {
+				verifyCtx, verifySpan := startSpan(ctx, "verify", spanAttributes)
+				passed := x.verifyAfterTimeout(verifyCtx)
+				verifySpan.finish(nil)
+				if passed && !agentFailed && task.Judge == nil {
+					result.Result = "success"
+					result.Score = 1
+					result.Failures = nil
 				}
 			}
-			if expect.NotContains != "" {
-				re, err := regexp.Compile(expect.NotContains)
-				if err != nil {
-					expectationFailures = append(expectationFailures, model.Failure{
-						Message: fmt.Sprintf("invalid regex %q in task spec: %v", expect.NotContains, err),
-					})
-					continue
-				}
-				if re.MatchString(lastCmdOutput) {
-					expectationFailures = append(expectationFailures, model.Failure{
-						Message: fmt.Sprintf("regex %q matched output %q (should not have matched)", expect.NotContains, lastCmdOutput),
-					})
-				}
+			return result
+		}
+		const maxErrLogLines = 3
+		const maxErrStderrLines = 10
+		redactedLog.Flush()
+		logString := logBuffer.String()
+		logTail, truncated := getLastNLines(logString, maxErrLogLines)
+		truncated = truncated || logBuffer.Truncated()
+		// build log file path
+		logPath := taskOutputDir
+		errorMessage := fmt.Sprintf("agent encountered error: %v\n---LOG---\n%s", err, logTail)
+		if truncated {
+			errorMessage += fmt.Sprintf("\n... (log truncated, full log at %s)", logPath)
+		}
+		// The agent's stderr usually holds the reason it failed.
+		if x.agentStderr != nil {
+			if stderrTail, _ := getLastNLines(strings.TrimRight(x.agentStderr.String(), "\n"), maxErrStderrLines); stderrTail != "" {
+				errorMessage += fmt.Sprintf("\n---STDERR---\n%s", stderrTail)
+			}
+		}
+		// An agent that does not support the generation parameters of the config cannot be evaluated with it.
+		if exit := result.AgentExit; exit != nil && !exit.Killed && exit.Signal == "" {
+			if rejected := rejectedGenerationArgs(llmConfig, x.agentStderr.String()); rejected != nil {
+				result.Result = "error"
+				result.Error = fmt.Sprintf("agent rejected the flags %s of LLM config %s: %s", strings.Join(rejected, " "), llmConfig.ID, errorMessage)
+				return result
 			}
 		}
+		// Failures caused by the LLM provider or the network (e.g. rate limits) are infrastructure
+		// errors, which are retried; agents killed by a signal or by the benchmark are not.
+		if exit := result.AgentExit; exit == nil || (!exit.Killed && exit.Signal == "") {
+			tracePath := ""
+			if taskOutputDir != "" {
+				tracePath = filepath.Join(taskOutputDir, "trace.yaml")
+			}
+			if pattern := classifyTransient(config.transientPatterns, err, x.agentStderr.String(), tracePath); pattern != "" {
+				result.Result = "error"
+				result.Error = errorMessage
+				result.TransientError = pattern
+				return result
+			}
+		}
+		if classifyAgentExit(result.AgentExit, config.AgentFailExitCodes) == "fail" {
+			result.Result = "fail"
+			result.AddFailure("%s", errorMessage)
+			return result
+		}
+		result.Result = "error"
+		result.Error = errorMessage
+		return result
+	}
+
+	// Failures recorded while sending the script steps fail the task.
+	agentFailed := len(result.Failures) > 0
+
+	var expectationFailures []model.Failure
+
+	if len(task.Expect) > 0 {
+		outputs, source := x.agentOutputs(agentOutput)
+		result.OutputSource = source
+		expectationFailures = evaluateExpectations(taskCtx, task.Expect, outputs, x.kubeConfig)
 
 		if len(expectationFailures) == 0 {
-			fmt.Printf("\nAll output expectations met\n")
+			x.progressf("\nAll output expectations met\n")
+		}
+	}
+
+	hasStepExpectations := false
+	for i, step := range task.Script {
+		if len(step.Expect) == 0 {
+			continue
+		}
+		hasStepExpectations = true
+		var stepOutput string
+		if i < len(x.stepOutputs) {
+			stepOutput = x.stepOutputs[i]
+		}
+		for _, failure := range evaluateExpectations(taskCtx, step.Expect, uniformOutputs(stepOutput, x.stderrOutput.String()), x.kubeConfig) {
+			failure.Step = i + 1
+			failure.Message = fmt.Sprintf("step %d: %s", i+1, failure.Message)
+			expectationFailures = append(expectationFailures, failure)
 		}
 	}
 
+	verifiers := task.verifierScripts()
+	var checkFailures []model.Failure
 	verifierSucceeded := false
-	// Run verifier if specified
-	if task.Verifier != "" {
-		verifierPath := filepath.Join(taskDir, task.Verifier)
-		cmd := exec.CommandContext(taskCtx, verifierPath)
-		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
-		fmt.Printf("\nRunning verifier for task %s\n", taskID)
-
-		err := x.runCommand(cmd)
-		if err == nil {
-			verifierSucceeded = true
-		} else {
-			const maxLogLines = 20
-			logString := logBuffer.String()
-			logTail, truncated := getLastNLines(logString, maxLogLines)
-			// build log file path
-			logPath := taskOutputDir
-			failureMessage := fmt.Sprintf("verifier script failed: %v\n---LOG---\n%s", err, logTail)
-			if truncated {
-				failureMessage += fmt.Sprintf("\n... (log truncated, full log at %s)", logPath)
-			}
-			result.AddFailure("%s", failureMessage)
+	verifierScore := 0.0
+	if len(task.Checks) > 0 || len(verifiers) > 0 {
+		start := time.Now()
+		verifyCtx, verifySpan := startSpan(taskCtx, "verify", spanAttributes)
+		v, attempts := x.verify(verifyCtx)
+		verifySpan.setAttribute("attempts", attempts)
+		verifySpan.finish(nil)
+		result.VerifyAttempts = attempts
+		result.VerifyDuration = time.Since(start).Round(time.Millisecond).String()
+		result.Verifiers = v.verifiers
+		checkFailures = v.checkFailures
+		verifierSucceeded = v.verifierSucceeded
+		verifierScore = v.verifierScore
+		if len(verifiers) > 0 && !verifierSucceeded {
+			result.Result = "fail"
+			result.Failures = append(result.Failures, v.verifierFailures...)
+		}
+	}
+
+	// Judge the transcript if requested; a judge that cannot give a verdict is an infrastructure error.
+	if task.Judge != nil {
+		judgeResult, err := x.runJudge(taskCtx, agentOutput)
+		if err != nil {
+			result.Result = "error"
+			result.Error = fmt.Sprintf("judge failed: %v", err)
+			return result
 		}
+		result.Judge = judgeResult
 	}
 
-	expectationsMet := len(task.Expect) > 0 && len(expectationFailures) == 0
-	if verifierSucceeded || expectationsMet {
+	hasExpectations := len(task.Expect) > 0 || hasStepExpectations
+	expectationsMet := hasExpectations && len(expectationFailures) == 0
+	succeeded := verifierSucceeded || expectationsMet
+	// Checks and the judge must pass in addition to any verifiers or expectations.
+	if len(verifiers) == 0 && !hasExpectations {
+		succeeded = len(task.Checks) > 0 || task.Judge != nil
+	}
+	if len(checkFailures) > 0 || agentFailed {
+		succeeded = false
+	}
+	var judgeFailures []model.Failure
+	if result.Judge != nil && !result.Judge.Pass {
+		succeeded = false
+		judgeFailures = append(judgeFailures, model.Failure{Message: fmt.Sprintf("judge did not pass the transcript: %s", result.Judge.Rationale)})
+	}
+	if succeeded {
 		result.Result = "success"
+		result.Score = 1
 	} else {
 		result.Result = "fail"
+		// Partial credit only comes from the grader that failed the task: the verifiers, or the
+		// judge of a task without verifiers. Failures of anything else score 0.
+		result.Score = 0
+		switch {
+		case len(verifiers) > 0 && !verifierSucceeded:
+			result.Score = verifierScore
+		case len(verifiers) == 0 && result.Judge != nil && !result.Judge.Pass:
+			result.Score = result.Judge.Score
+		}
 		result.Failures = append(result.Failures, expectationFailures...)
+		result.Failures = append(result.Failures, checkFailures...)
+		result.Failures = append(result.Failures, judgeFailures...)
 	}
 
 	return result
@@ -475,9 +1704,15 @@ type TaskExecution struct {
 	// kubeConfig is the path to the kubeconfig file we should use.
 	// It will be created in IsolationModeCluster
 	kubeConfig string
+	// agentKubeConfig is the restricted kubeconfig of the agent, if the task sets rbac.
+	agentKubeConfig string
 
-	// AgentBin holds the path to the agent to execute
-	AgentBin string
+	// agentRunner runs the agent under evaluation.
+	agentRunner AgentRunner
+
+	// console receives the output of the agent and commands, and progress messages,
+	// as selected by --console-output.
+	console *taskConsole
 
 	llmConfig model.LLMConfig
 	result    *model.TaskResult
@@ -485,6 +1720,37 @@ type TaskExecution struct {
 	task      *Task
 	taskID    string
 	taskDir   string
+	// configID is the agent and LLM config the task runs with (see model.ConfigID).
+	configID string
+
+	// llmEnv is the resolved Env of the LLM config, added to the environment of the agent only.
+	llmEnv []string
+
+	// agentStderr is the end of the agent's stderr, to classify its failures as transient.
+	agentStderr *tailBuffer
+	// stderrOutput is the agent's whole stderr, for expectations; set by runAgent.
+	stderrOutput *lockedBuffer
+
+	// stepReadyPattern is the default waitFor pattern of script steps, and stepIdleTime the silence
+	// after which steps without one are sent.
+	stepReadyPattern string
+	stepIdleTime     time.Duration
+	// idleErr is set when the agent was never detected idle before a script step.
+	idleErr error
+
+	// metrics records the metrics of the run, if they are enabled.
+	metrics *runMetrics
+
+	// stallTimeout stops an agent that produced no output for that long (0 for no limit),
+	// as tracked by watchdog while the agent runs.
+	stallTimeout time.Duration
+	watchdog     *stallWatchdog
+
+	// stepOutputs holds the agent output for each script step, set by runAgent.
+	stepOutputs []string
+
+	// tasksDir is the tasks directory of the task, used to resolve @fixtures/ paths.
+	tasksDir string
 
 	// taskOutputDir is where we can create artifacts or write logs while executing the task
 	taskOutputDir string
@@ -493,74 +1759,292 @@ type TaskExecution struct {
 	cleanupFunctions []func() error
 
 	clusterProvider cluster.Provider
+
+	// clusterName is the name of the isolated cluster created for this task, if any; with several
+	// clusters, that of the first.
+	clusterName string
+	// clusters are the isolated clusters of the task, if any.
+	clusters []*taskCluster
+	// clusterKept is set if the isolated cluster is kept after a failure, instead of being deleted.
+	clusterKept bool
+
+	// sharedCluster is the name of the shared cluster, if it is managed by the cluster provider.
+	sharedCluster string
+
+	// judge selects the model used to grade the transcript, if the task has a judge rubric.
+	judge judge.Config
+
+	// redactor masks secrets in the agent output.
+	redactor *redactor
+
+	// trace holds the events of the agent's trace, set after the agent ran if the trace could be read.
+	trace []traceEvent
+
+	// modelPrices are used to estimate the cost of the agent's LLM usage.
+	modelPrices map[string]ModelPrice
+
+	// readyTimeout bounds how long to wait for an isolated cluster to become ready (0 disables the check).
+	readyTimeout time.Duration
+
+	// diffResources are the resource types snapshotted before and after the agent ran, with --capture-diff.
+	diffResources []string
 }
 
-func (x *TaskExecution) runSetup(ctx context.Context) error {
-	log := klog.FromContext(ctx)
+// diffResources returns the resource types snapshotted for the cluster diff, or nil without --capture-diff.
+func diffResources(config EvalConfig) []string {
+	if !config.CaptureDiff {
+		return nil
+	}
+	return config.DiffResources
+}
 
-	// Create cluster if requested
+func (x *TaskExecution) runSetup(ctx context.Context) error {
+	// Create the isolated clusters if requested
 	if x.task.Isolation == IsolationModeCluster {
-		kubeconfigPath := filepath.Join(x.taskDir, "kubeconfig.yaml")
-		x.kubeConfig = kubeconfigPath
+		if err := x.createClusters(ctx); err != nil {
+			return err
+		}
+	}
+
+	if len(x.task.Images) > 0 {
+		if err := x.loadImages(); err != nil {
+			return err
+		}
+	}
+
+	// Apply setup manifests before the setup script
+	if len(x.task.SetupManifests) > 0 || len(x.task.WaitFor) > 0 {
+		if err := x.applySetupManifests(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Run setup if specified
+	if x.task.Setup != nil {
+		cmd, err := x.scriptCommand(ctx, *x.task.Setup)
+		if err != nil {
+			return err
+		}
+		cmd.Dir = x.taskDir
+
+		if output, err := x.runCommand(cmd); err != nil {
+			const maxOutputLines = 20
+			outputTail, _ := getLastNLines(output, maxOutputLines)
+			return fmt.Errorf("setup script failed: %w\n---OUTPUT---\n%s", err, outputTail)
+		}
+	}
 
-		clusterName := fmt.Sprintf("k8s-ai-bench-%s", x.taskID)
-		// Truncate to avoid issues with vcluster resource names (hostPod names can trigger 63 char limit)
-		if len(clusterName) > 45 {
-			hash := sha256.Sum256([]byte(clusterName))
-			shortHash := hex.EncodeToString(hash[:])[:6]
-			clusterName = fmt.Sprintf("%s-%s", clusterName[:38], shortHash)
+	if x.task.RBAC != nil {
+		if err := x.setupRBAC(ctx); err != nil {
+			return fmt.Errorf("restricting the agent's permissions: %w", err)
 		}
-		log.Info("creating cluster", "name", clusterName)
+	}
+
+	return nil
+}
+
+// taskCluster is an isolated cluster of a task.
+type taskCluster struct {
+	// name is the name of the cluster in the task's clusters, or empty for the task's only cluster.
+	name string
+	// clusterName is the name of the cluster in the cluster provider.
+	clusterName string
+	kubeConfig  string
+	// attempted is set once this task execution started creating the cluster, which did not
+	// exist before, and created once it succeeded.
+	attempted bool
+	created   bool
+}
+
+// isolatedClusterName returns the name in the cluster provider of the isolated cluster of the
+// task run with the agent and LLM config configID, or of its cluster with that name. Runs of the
+// task with other agents and LLM configs, which may run at the same time, get other names.
+func isolatedClusterName(taskID, configID, name string) string {
+	clusterName := fmt.Sprintf("k8s-ai-bench-%s", clusterNameSafe(taskID))
+	if name != "" {
+		clusterName += "-" + name
+	}
+	hash := sha256.Sum256([]byte(clusterName + "\x00" + configID))
+	shortHash := hex.EncodeToString(hash[:])[:6]
+	// Truncate to avoid issues with vcluster resource names (hostPod names can trigger 63 char limit)
+	if len(clusterName) > 38 {
+		clusterName = clusterName[:38]
+	}
+	return fmt.Sprintf("%s-%s", clusterName, shortHash)
+}
 
-		if err := x.clusterProvider.Create(clusterName); err != nil {
-			return fmt.Errorf("failed to create isolated cluster %q: %w", clusterName, err)
+// clusterKubeConfigVar returns the variable that holds the kubeconfig of the task's cluster with
+// that name, e.g. KUBECONFIG_PRIMARY.
+func clusterKubeConfigVar(name string) string {
+	return "KUBECONFIG_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// createClusters creates the isolated clusters of the task in parallel: its clusters, or a single
+// one. KUBECONFIG points at the first. Every cluster is deleted on cleanup, even if others failed
+// to be created.
+func (x *TaskExecution) createClusters(ctx context.Context) error {
+	if x.task.WorkerNodes > 0 {
+		configurer, ok := x.clusterProvider.(cluster.WorkerNodesConfigurer)
+		if !ok {
+			return fmt.Errorf("task requests %d worker nodes, but the cluster provider does not support configuring worker nodes", x.task.WorkerNodes)
 		}
+		x.clusterProvider = configurer.WithWorkerNodes(x.task.WorkerNodes)
+	}
 
+	if x.task.VCluster != nil {
+		vclusterProvider, ok := x.clusterProvider.(*vcluster.Provider)
+		if !ok {
+			return fmt.Errorf("task specifies vcluster options, but the cluster provider is not vcluster")
+		}
+		overrides := *x.task.VCluster
+		if overrides.ValuesFile != "" && !filepath.IsAbs(overrides.ValuesFile) {
+			overrides.ValuesFile = filepath.Join(x.taskDir, overrides.ValuesFile)
+		}
+		provider, removeValues, err := vclusterProvider.WithClusterOptions(overrides)
+		if err != nil {
+			return fmt.Errorf("failed to apply vcluster options for task: %w", err)
+		}
 		x.cleanupFunctions = append(x.cleanupFunctions, func() error {
-			if err := os.Remove(kubeconfigPath); err != nil {
-				log.Error(err, "failed to remove kubeconfig file", "path", kubeconfigPath)
-			}
-			return x.clusterProvider.Delete(clusterName)
+			removeValues()
+			return nil
 		})
+		x.clusterProvider = provider
+	}
 
-		// Get kubeconfig and write it to the file
-		kubeconfigBytes, err := x.clusterProvider.GetKubeconfig(clusterName)
+	names := x.task.Clusters
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	x.clusters = make([]*taskCluster, len(names))
+	for i, name := range names {
+		kubeconfigPath, err := x.clusterKubeConfigPath(name)
 		if err != nil {
-			return fmt.Errorf("failed to get kubeconfig for isolated cluster %q: %w", clusterName, err)
+			return err
 		}
+		x.cleanupFunctions = append(x.cleanupFunctions, func() error {
+			if name != "" && x.clusterKept {
+				// Recorded in kept-cluster.yaml.
+				return nil
+			}
+			if err := os.Remove(kubeconfigPath); err != nil && !os.IsNotExist(err) {
+				slog.Warn("failed to remove kubeconfig file", "task", x.taskID, "path", kubeconfigPath, "error", err)
+			}
+			return nil
+		})
+		x.clusters[i] = &taskCluster{name: name, clusterName: isolatedClusterName(x.taskID, x.configID, name), kubeConfig: kubeconfigPath}
+	}
+	x.kubeConfig = x.clusters[0].kubeConfig
 
-		if err := os.WriteFile(kubeconfigPath, kubeconfigBytes, 0644); err != nil {
-			return fmt.Errorf("failed to write kubeconfig for isolated cluster %q: %w", clusterName, err)
+	errs := make([]error, len(x.clusters))
+	var wg sync.WaitGroup
+	for i, c := range x.clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = x.createCluster(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range x.clusters {
+		if c.created {
+			x.metrics.clusterCreated()
 		}
+		x.cleanupFunctions = append(x.cleanupFunctions, func() error {
+			if !c.created {
+				if !c.attempted {
+					// Not created by this task execution, e.g. left over from an earlier run.
+					return nil
+				}
+				// A cluster whose creation failed midway may still exist.
+				exists, err := x.clusterProvider.Exists(c.clusterName)
+				if err != nil {
+					return fmt.Errorf("checking whether cluster %s exists: %w", c.clusterName, err)
+				}
+				if !exists {
+					return nil
+				}
+				return x.clusterProvider.Delete(c.clusterName)
+			}
+			x.metrics.clusterReleased()
+			if x.clusterKept {
+				return nil
+			}
+			return x.clusterProvider.Delete(c.clusterName)
+		})
 	}
+	if x.clusters[0].created {
+		x.clusterName = x.clusters[0].clusterName
+	}
+	return errors.Join(errs...)
+}
 
-	// Run setup if specified
-	if x.task.Setup != "" {
-		setupPath := filepath.Join(x.taskDir, x.task.Setup)
-		cmd := exec.CommandContext(ctx, setupPath)
-		cmd.Dir = x.taskDir
-		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
+// clusterKubeConfigPath returns the path to write the kubeconfig of the task's cluster with that
+// name to. The kubeconfig of the task's only cluster is written to a temp file of its own, rather
+// than into the task directory, so that concurrent runs of the task do not share it; keepCluster
+// copies it to the outputs. Those of the task's clusters are written to the task output directory.
+func (x *TaskExecution) clusterKubeConfigPath(name string) (string, error) {
+	if name != "" && x.taskOutputDir != "" {
+		return filepath.Abs(filepath.Join(x.taskOutputDir, "kubeconfig-"+name+".yaml"))
+	}
+	kubeconfigFile, err := os.CreateTemp("", "kubeconfig-"+clusterNameSafe(x.taskID)+"-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kubeconfig file for isolated cluster: %w", err)
+	}
+	kubeconfigFile.Close()
+	return kubeconfigFile.Name(), nil
+}
 
-		if err := x.runCommand(cmd); err != nil {
-			return err
-		}
+// createCluster creates an isolated cluster, writes its kubeconfig and waits for it to be ready.
+func (x *TaskExecution) createCluster(ctx context.Context, c *taskCluster) error {
+	exists, err := x.clusterProvider.Exists(c.clusterName)
+	if err != nil {
+		return fmt.Errorf("checking whether isolated cluster %q exists: %w", c.clusterName, err)
+	}
+	if exists {
+		return fmt.Errorf("isolated cluster %q already exists, delete it (e.g. with the cleanup command) to run the task", c.clusterName)
+	}
+	c.attempted = true
+	slog.Info("Creating isolated cluster", "task", x.taskID, "name", c.clusterName)
+	if err := x.clusterProvider.Create(c.clusterName); err != nil {
+		return fmt.Errorf("failed to create isolated cluster %q: %w", c.clusterName, err)
+	}
+	c.created = true
+
+	// Get kubeconfig and write it to the file
+	kubeconfigBytes, err := x.clusterProvider.GetKubeconfig(c.clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to get kubeconfig for isolated cluster %q: %w", c.clusterName, err)
+	}
+
+	if err := os.WriteFile(c.kubeConfig, kubeconfigBytes, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig for isolated cluster %q: %w", c.clusterName, err)
 	}
 
+	if x.readyTimeout > 0 {
+		readyCtx, cancel := context.WithTimeout(ctx, x.readyTimeout)
+		err := cluster.WaitForReady(readyCtx, c.kubeConfig)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("isolated cluster %q did not become ready within %v: %w", c.clusterName, x.readyTimeout, err)
+		}
+	}
 	return nil
 }
 
-func (x *TaskExecution) runCleanup(ctx context.Context) error {
+// runCleanup runs the task's cleanup script and the cleanup functions, and returns their errors.
+func (x *TaskExecution) runCleanup(ctx context.Context) []error {
 	var errs []error
 
 	// Run cleanup if specified
-	if x.task.Cleanup != "" {
-		cleanupPath := filepath.Join(x.taskDir, x.task.Cleanup)
-		cmd := exec.CommandContext(ctx, cleanupPath)
-		cmd.Dir = x.taskDir
-		cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
-
-		if err := x.runCommand(cmd); err != nil {
-			fmt.Printf("Warning: cleanup failed for task %s: %v\n", x.taskID, err)
+	if x.task.Cleanup != nil {
+		cmd, err := x.scriptCommand(ctx, *x.task.Cleanup)
+		if err == nil {
+			cmd.Dir = x.taskDir
+			_, err = x.runCommand(cmd)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cleanup script failed: %w", err))
 		}
 	}
 
@@ -570,89 +2054,356 @@ func (x *TaskExecution) runCleanup(ctx context.Context) error {
 		}
 	}
 
+	return errs
+}
+
+// loadImages preloads the task's images into the clusters the task runs against.
+func (x *TaskExecution) loadImages() error {
+	var clusterNames []string
+	for _, c := range x.clusters {
+		clusterNames = append(clusterNames, c.clusterName)
+	}
+	if len(clusterNames) == 0 && x.sharedCluster != "" {
+		clusterNames = []string{x.sharedCluster}
+	}
+	if len(clusterNames) == 0 {
+		return fmt.Errorf("cannot load images %v: the cluster is not managed by the cluster provider", x.task.Images)
+	}
+
+	loader, ok := x.clusterProvider.(cluster.ImageLoader)
+	if !ok {
+		return fmt.Errorf("cannot load images %v: the cluster provider does not support loading local images; push them to a registry reachable from the cluster instead", x.task.Images)
+	}
+
+	for _, clusterName := range clusterNames {
+		for _, image := range x.task.Images {
+			if err := loader.LoadImage(clusterName, image); err != nil {
+				return fmt.Errorf("failed to load image %q into cluster %q: %w", image, clusterName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keptClusterFile marks an isolated cluster kept after a failure, in the task output directory.
+const keptClusterFile = "kept-cluster.yaml"
+
+// keepCluster keeps the isolated cluster instead of deleting it on cleanup. Its kubeconfig is
+// copied into the task output directory, and kept-cluster.yaml records the cluster for the cleanup subcommand.
+func (x *TaskExecution) keepCluster(config EvalConfig) (*model.KeptCluster, error) {
+	kubeconfig, err := os.ReadFile(x.kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+	kubeconfigPath, err := filepath.Abs(filepath.Join(x.taskOutputDir, "kubeconfig.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(kubeconfigPath, kubeconfig, 0600); err != nil {
+		return nil, fmt.Errorf("writing kubeconfig: %w", err)
+	}
+	kept := &model.KeptCluster{
+		Name:       x.clusterName,
+		Provider:   config.ClusterProvider,
+		KubeConfig: kubeconfigPath,
+		RunID:      config.RunID,
+	}
+	// The kubeconfigs of named clusters are already in the task output directory.
+	for _, c := range x.clusters {
+		if c.name == "" {
+			continue
+		}
+		clusterKubeConfig, err := filepath.Abs(c.kubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		kept.Clusters = append(kept.Clusters, model.KeptCluster{Name: c.clusterName, Provider: config.ClusterProvider, KubeConfig: clusterKubeConfig})
+	}
+	if err := writeToYAMLFile(filepath.Join(x.taskOutputDir, keptClusterFile), kept); err != nil {
+		return nil, err
+	}
+	x.clusterKept = true
+	slog.Info("Keeping cluster of failed task for debugging", runlog.EventKey, runlog.EventKept,
+		"task", x.taskID, "cluster", kept.Name, "kubeconfig", kept.KubeConfig)
+	return kept, nil
+}
+
+// exportClusterLogs exports the logs of the isolated cluster into <taskOutputDir>/cluster-logs,
+// or those of each of the task's clusters into cluster-logs/<name>.
+func (x *TaskExecution) exportClusterLogs() error {
+	var errs []error
+	for _, c := range x.clusters {
+		if !c.created {
+			// Nothing to export if cluster creation failed.
+			continue
+		}
+		exporter, ok := x.clusterProvider.(cluster.LogExporter)
+		if !ok {
+			return fmt.Errorf("cluster provider does not support exporting logs")
+		}
+		dir := filepath.Join(x.taskOutputDir, "cluster-logs", c.name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory %q: %w", dir, err)
+		}
+		if err := exporter.ExportLogs(c.clusterName, dir); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c.clusterName, err))
+		}
+	}
 	return errors.Join(errs...)
 }
 
+// runJudge grades the transcript with the judge model, and saves the raw response to judge.yaml.
+func (x *TaskExecution) runJudge(ctx context.Context, transcript string) (*model.JudgeResult, error) {
+	rubric, err := x.task.Judge.ResolveRubric(x.taskDir)
+	if err != nil {
+		return nil, err
+	}
+	x.progressf("\nJudging transcript for task %s with %s/%s\n", x.taskID, x.judge.Provider, x.judge.Model)
+	verdict, response, err := judge.Grade(ctx, x.judge, rubric, transcript)
+
+	record := map[string]any{
+		"provider": x.judge.Provider,
+		"model":    x.judge.Model,
+		"response": response,
+	}
+	if verdict != nil {
+		record["verdict"] = verdict
+	}
+	if err != nil {
+		record["error"] = err.Error()
+	}
+	data, marshalErr := yaml.Marshal(record)
+	if marshalErr != nil {
+		return nil, fmt.Errorf("marshaling judge response: %w", marshalErr)
+	}
+	judgePath := filepath.Join(x.taskOutputDir, "judge.yaml")
+	if writeErr := os.WriteFile(judgePath, data, 0644); writeErr != nil {
+		return nil, fmt.Errorf("writing %s: %w", judgePath, writeErr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &model.JudgeResult{
+		Pass:      verdict.Pass,
+		Score:     verdict.Score,
+		Rationale: verdict.Rationale,
+	}, nil
+}
+
+// agentStderrLogFile holds the agent's stderr, in the task output directory.
+const agentStderrLogFile = "stderr.log"
+
 func (x *TaskExecution) runAgent(ctx context.Context) (string, error) {
-	tracePath := filepath.Join(x.taskOutputDir, "trace.yaml")
-
-	args := []string{
-		"--kubeconfig", x.kubeConfig,
-		"--llm-provider", x.llmConfig.ProviderID,
-		fmt.Sprintf("--enable-tool-use-shim=%t", x.llmConfig.EnableToolUseShim),
-		fmt.Sprintf("--quiet=%t", x.llmConfig.Quiet),
-		"--model", x.llmConfig.ModelID,
-		"--trace-path", tracePath,
-		"--skip-permissions",
-		"--show-tool-output",
-	}
-	if x.llmConfig.McpClient {
-		args = append(args, "--mcp-client")
-	}
-
-	stdinReader, stdinWriter := io.Pipe()
-
-	cmd := exec.CommandContext(ctx,
-		x.AgentBin,
-		args...,
-	)
-	cmd.Stdin = stdinReader
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	var stdoutBuffer bytes.Buffer
+	// With a stall timeout, the agent is stopped once it has been silent for that long.
+	if x.stallTimeout > 0 {
+		x.watchdog = newStallWatchdog(x.stallTimeout)
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		go x.watchdog.watch(ctx, cancel)
+	}
+
+	// The end of the agent's stderr is kept to classify its failures, and all of it for expectations
+	// and stderr.log. In the task log, its lines are prefixed to tell them from stdout.
+	x.agentStderr = newTailBuffer(maxTransientScanBytes)
+	x.stderrOutput = newLockedBuffer()
+	stderrWriters := []io.Writer{x.console.stderr, x.agentStderr, x.stderrOutput}
+	if x.log != nil {
+		logStderr := newLinePrefixer(x.log, "[stderr] ")
+		defer logStderr.Flush()
+		stderrWriters = append(stderrWriters, logStderr)
+	}
+	if x.taskOutputDir != "" {
+		stderrLog, err := os.Create(filepath.Join(x.taskOutputDir, agentStderrLogFile))
+		if err != nil {
+			slog.Warn("failed to create the agent stderr log", "task", x.taskID, "error", err)
+		} else {
+			defer stderrLog.Close()
+			stderrWriters = append(stderrWriters, stderrLog)
+		}
+	}
+	redactedStderr := newRedactingWriter(io.MultiWriter(stderrWriters...), x.redactor)
+
+	// stdoutBuffer is written while the steps are sent, which watch it for their waitFor patterns.
+	stdoutBuffer := newLockedBuffer()
+	output := io.MultiWriter(x.console.stdout, stdoutBuffer)
 	if x.log != nil {
-		cmd.Stdout = io.MultiWriter(cmd.Stdout, x.log, &stdoutBuffer)
-		cmd.Stderr = io.MultiWriter(cmd.Stderr, x.log)
+		output = io.MultiWriter(x.console.stdout, x.log, stdoutBuffer)
 	}
+	redactedOutput := newRedactingWriter(output, x.redactor)
 
-	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig))
+	var agentStdout, agentStderr io.Writer = redactedOutput, redactedStderr
+	if x.watchdog != nil {
+		agentStdout = x.watchdog.writer(redactedOutput)
+		agentStderr = x.watchdog.writer(redactedStderr)
+	}
 
+	prompts := make(chan string)
+	agentDone := make(chan struct{})
+	stepsDone := make(chan []int)
 	go func() {
-		// TODO: Wait for idle between sending steps?
-		for _, step := range x.task.Script {
-			prompt, err := step.ResolvePrompt(x.taskDir)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error resolving prompt: %v\n", err)
-				x.result.AddFailure("failed to resolve prompt: %v", err)
-				stdinWriter.Close()
-				return
-			}
-			fmt.Fprintf(stdinWriter, "%s\n", prompt)
-		}
-		stdinWriter.Close()
+		stepsDone <- x.sendSteps(ctx, prompts, agentDone, stdoutBuffer)
 	}()
 
-	if err := cmd.Run(); err != nil {
+	// With rbac, the agent gets its restricted kubeconfig, also as KUBECONFIG.
+	kubeconfig, agentVars := x.kubeConfig, append(x.taskVars(), x.llmEnv...)
+	if x.agentKubeConfig != "" {
+		kubeconfig = x.agentKubeConfig
+		agentVars = append(agentVars, "KUBECONFIG="+kubeconfig)
+	}
+	clusterKubeConfigs := make(map[string]string)
+	for _, c := range x.clusters {
+		if c.name != "" {
+			clusterKubeConfigs[clusterKubeConfigVar(c.name)] = c.kubeConfig
+		}
+	}
+	runResult, err := x.agentRunner.Run(ctx, AgentRunSpec{
+		KubeConfig:         kubeconfig,
+		ClusterKubeConfigs: clusterKubeConfigs,
+		LLMConfig:          x.llmConfig,
+		TracePath:          filepath.Join(x.taskOutputDir, "trace.yaml"),
+		Env:                append(os.Environ(), agentVars...),
+		TaskEnv:            agentVars,
+		Prompts:            prompts,
+		Output:             agentStdout,
+		Stderr:             agentStderr,
+	})
+	redactedOutput.Flush()
+	redactedStderr.Flush()
+	// The agent's output has ended, stop sending steps.
+	stdoutBuffer.close()
+	close(agentDone)
+	stepStarts := <-stepsDone
+
+	if err != nil {
+		x.result.AgentExit = runResult.Exit
+		var stalled *agentStalledError
+		if errors.As(context.Cause(ctx), &stalled) {
+			return "", stalled
+		}
 		return "", err
 	}
 
-	return stdoutBuffer.String(), nil
+	agentOutput := stdoutBuffer.String()
+	for i, start := range stepStarts {
+		end := len(agentOutput)
+		if i+1 < len(stepStarts) {
+			end = stepStarts[i+1]
+		}
+		x.stepOutputs = append(x.stepOutputs, agentOutput[start:end])
+	}
+	return agentOutput, nil
+}
+
+// agentExit describes how the agent process ended from the error returned by cmd.Wait,
+// or returns nil if the process did not run to an exit.
+func agentExit(ctx context.Context, err error) *model.AgentExit {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil
+	}
+	exit := &model.AgentExit{ExitCode: exitErr.ExitCode()}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		exit.Signal = status.Signal().String()
+	}
+	// exec.CommandContext kills the process when the context is done.
+	if ctx.Err() != nil {
+		exit.Killed = true
+		exit.Reason = context.Cause(ctx).Error()
+	}
+	return exit
+}
+
+// classifyAgentExit returns the result of a task whose agent did not exit successfully: "fail" if
+// the agent exited by itself with one of failExitCodes, with which it reports that the model failed
+// the task (e.g. the LLM refused). Other exit codes (e.g. a missing API key or a bad flag), signals
+// (e.g. the OOM killer), interruptions and failures to run the agent are infrastructure errors.
+func classifyAgentExit(exit *model.AgentExit, failExitCodes []int) string {
+	if exit != nil && !exit.Killed && exit.Signal == "" && slices.Contains(failExitCodes, exit.ExitCode) {
+		return "fail"
+	}
+	return "error"
 }
 
-func (x *TaskExecution) runCommand(cmd *exec.Cmd) error {
-	fmt.Printf("\nRunning command: %s\n", strings.Join(cmd.Args, " "))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// maxCommandOutputBytes is how much of the end of its output runCommand returns.
+const maxCommandOutputBytes = 1 << 20
+
+// runCommand runs the command in its own process group (see runInProcessGroup), with its output
+// copied to the console and the task log, and returns the end of its combined output, so failures
+// can show the command's own output rather than the end of the task log.
+func (x *TaskExecution) runCommand(cmd *exec.Cmd) (string, error) {
+	x.progressf("\nRunning command: %s\n", strings.Join(cmd.Args, " "))
+	output := newTailBuffer(maxCommandOutputBytes)
+	// Output is also copied to any writers already set on the command.
+	stdout := []io.Writer{x.console.stdout, output}
+	stderr := []io.Writer{x.console.stderr, output}
+	if cmd.Stdout != nil {
+		stdout = append(stdout, cmd.Stdout)
+	}
+	if cmd.Stderr != nil {
+		stderr = append(stderr, cmd.Stderr)
+	}
 	if x.log != nil {
-		cmd.Stdout = io.MultiWriter(cmd.Stdout, x.log)
-		cmd.Stderr = io.MultiWriter(cmd.Stderr, x.log)
+		stdout = append(stdout, x.log)
+		stderr = append(stderr, x.log)
 	}
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("running command %v: %w", strings.Join(cmd.Args, " "), err)
+	cmd.Stdout = io.MultiWriter(stdout...)
+	cmd.Stderr = io.MultiWriter(stderr...)
+	if err := runInProcessGroup(cmd); err != nil {
+		return output.String(), fmt.Errorf("running command %v: %w", strings.Join(cmd.Args, " "), err)
 	}
-	return nil
+	return output.String(), nil
 }
 
-func printResults(allResults []model.TaskResult) {
+func printResults(allResults []model.TaskResult, runs int, verbose bool) {
 	fmt.Println("\nEvaluation Results:")
 	fmt.Println("==================")
 
 	for _, result := range allResults {
-		fmt.Printf("\nTask: %s\n", result.Task)
-		fmt.Printf("  LLM Config: %+v\n", result.LLMConfig)
-		fmt.Printf("    %v\n", result.Result)
-		if result.Error != "" {
-			fmt.Printf("    Error: %s\n", result.Error)
+		if verbose {
+			fmt.Printf("\nTask: %s\n", result.Task)
+			fmt.Printf("  LLM Config: %+v\n", result.LLMConfig)
+			fmt.Printf("    %v (score %.2f)\n", result.Result, result.Score)
+			if result.Error != "" {
+				fmt.Printf("    Error: %s\n", result.Error)
+			}
+		}
+		if result.KeptCluster != nil {
+			// Kept clusters are always listed, so they are not forgotten.
+			fmt.Printf("\nKept cluster of %s with %s: %s (kubeconfig: %s)\n", result.Task, result.ConfigID(), result.KeptCluster.Name, result.KeptCluster.KubeConfig)
+			for _, c := range result.KeptCluster.Clusters[min(1, len(result.KeptCluster.Clusters)):] {
+				fmt.Printf("  and cluster %s (kubeconfig: %s)\n", c.Name, c.KubeConfig)
+			}
 		}
 	}
+
+	evaluated := evaluatedResults(allResults)
+	passed := 0
+	for _, result := range evaluated {
+		if result.Result == "success" {
+			passed++
+		}
+	}
+	fmt.Printf("\nPassed: %d/%d (%d%%), mean score: %.2f\n", passed, len(evaluated), calculatePercentage(passed, len(evaluated)), meanScore(evaluated))
+
+	var breakdown strings.Builder
+	breakdown.WriteString("\nBy LLM config:\n\n")
+	writeLLMConfigSummaryTable(&breakdown, allResults)
+	breakdown.WriteString("Time by phase:\n\n")
+	writePhaseTimingTable(&breakdown, allResults)
+	breakdown.WriteString("LLM usage:\n\n")
+	writeUsageTable(&breakdown, allResults)
+	breakdown.WriteString("Agent efficiency (medians):\n\n")
+	writeMetricsTable(&breakdown, allResults)
+	writeFailedTasks(&breakdown, allResults)
+	breakdown.WriteString("By difficulty:\n\n")
+	writeBreakdownTable(&breakdown, allResults, "Difficulty", difficultyOf)
+	breakdown.WriteString("By category:\n\n")
+	writeBreakdownTable(&breakdown, allResults, "Category", categoryOf)
+	if runs > 1 {
+		fmt.Fprintf(&breakdown, "Pass@k over %d runs (errors are excluded):\n\n", runs)
+		writePassAtKTables(&breakdown, allResults, runs)
+	}
+	fmt.Print(breakdown.String())
 }
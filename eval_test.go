@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// fakeAgentScript ends in the way selected by $MODE, ignoring the agent flags and prompts.
+const fakeAgentScript = `#!/bin/sh
+case "$MODE" in
+exit0) exit 0 ;;
+exit1) exit 1 ;;
+sigkill) kill -KILL $$ ;;
+hang) sleep 300 ;;
+esac
+`
+
+func TestClassifyAgentExit(t *testing.T) {
+	agentBin := filepath.Join(t.TempDir(), "agent.sh")
+	if err := os.WriteFile(agentBin, []byte(fakeAgentScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		mode          string
+		failExitCodes []int
+		// wantExit is nil if the agent exits successfully.
+		wantExit   *model.AgentExit
+		wantResult string
+	}{
+		{name: "exits 0", mode: "exit0"},
+		{name: "exits 1", mode: "exit1", wantExit: &model.AgentExit{ExitCode: 1}, wantResult: "error"},
+		{name: "exits with a fail exit code", mode: "exit1", failExitCodes: []int{1}, wantExit: &model.AgentExit{ExitCode: 1}, wantResult: "fail"},
+		{name: "exits with another exit code", mode: "exit1", failExitCodes: []int{2}, wantExit: &model.AgentExit{ExitCode: 1}, wantResult: "error"},
+		{name: "killed by SIGKILL", mode: "sigkill", failExitCodes: []int{1}, wantExit: &model.AgentExit{ExitCode: -1, Signal: "killed"}, wantResult: "error"},
+		{name: "cancelled", mode: "hang", failExitCodes: []int{1}, wantExit: &model.AgentExit{ExitCode: -1, Signal: "killed", Killed: true, Reason: "context deadline exceeded"}, wantResult: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			prompts := make(chan string)
+			close(prompts)
+			runner := &execAgentRunner{agentBin: agentBin}
+			runResult, err := runner.Run(ctx, AgentRunSpec{
+				Env:     []string{"MODE=" + tt.mode, "PATH=" + os.Getenv("PATH")},
+				Prompts: prompts,
+				Output:  io.Discard,
+				Stderr:  io.Discard,
+			})
+
+			if tt.wantExit == nil {
+				if err != nil || runResult.Exit != nil {
+					t.Fatalf("Run() = %+v, %v, want a successful exit", runResult.Exit, err)
+				}
+				return
+			}
+			if err == nil || runResult.Exit == nil {
+				t.Fatalf("Run() = %+v, %v, want exit %+v", runResult.Exit, err, tt.wantExit)
+			}
+			if *runResult.Exit != *tt.wantExit {
+				t.Errorf("AgentExit = %+v, want %+v", *runResult.Exit, *tt.wantExit)
+			}
+			if result := classifyAgentExit(runResult.Exit, tt.failExitCodes); result != tt.wantResult {
+				t.Errorf("classifyAgentExit() = %q, want %q", result, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestRunTaskJobRecoversPanic(t *testing.T) {
+	outputDir := t.TempDir()
+	results := make(chan model.TaskResult, 2)
+	s := &taskScheduler{
+		config:     EvalConfig{OutputDir: outputDir},
+		results:    results,
+		passed:     make(map[string]map[string]bool),
+		limiters:   newProviderLimiters(nil),
+		limitSkips: make(map[string]int),
+		evaluate: func(ctx context.Context, config EvalConfig, taskID string, task Task, llmConfig model.LLMConfig, clusterProvider cluster.Provider, taskOutputDir string) (model.TaskResult, error) {
+			if taskID == "panics" {
+				var task *Task
+				_ = task.Setup // nil pointer dereference
+			}
+			return model.TaskResult{Task: taskID, LLMConfig: llmConfig, Result: "success"}, nil
+		},
+	}
+	llmConfig := model.LLMConfig{ID: "m1"}
+
+	// Like a worker, run the job that panics, then the next one.
+	for _, taskID := range []string{"panics", "next"} {
+		if err := s.runTaskJob(context.Background(), 0, taskJob{taskID: taskID, llmConfig: llmConfig}); err != nil {
+			t.Fatalf("runTaskJob(%s) = %v, want no error", taskID, err)
+		}
+	}
+	close(results)
+
+	byTask := make(map[string]model.TaskResult)
+	for result := range results {
+		byTask[result.Task] = result
+	}
+	panicked := byTask["panics"]
+	if panicked.Result != "error" || !panicked.Panicked {
+		t.Errorf("result of the task that panicked = %q (panicked %t), want an error that panicked", panicked.Result, panicked.Panicked)
+	}
+	if !strings.Contains(panicked.Error, "nil pointer dereference") {
+		t.Errorf("error = %q, want the panic", panicked.Error)
+	}
+	log, err := os.ReadFile(filepath.Join(outputDir, "panics", "m1", "log.txt"))
+	if err != nil {
+		t.Fatalf("reading the task log: %v", err)
+	}
+	if !strings.Contains(string(log), "panic: runtime error") || !strings.Contains(string(log), "TestRunTaskJobRecoversPanic") {
+		t.Errorf("task log = %q, want the panic and its stack trace", log)
+	}
+	if next := byTask["next"]; next.Result != "success" {
+		t.Errorf("result of the next task = %q, want success", next.Result)
+	}
+}
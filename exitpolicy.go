@@ -0,0 +1,105 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// Exit policies decide whether the results of a run make the process exit non-zero. Errors of
+// the run itself (e.g. a cluster that could not be created) always do.
+const (
+	// ExitPolicyFailOnFailure exits non-zero if any task and LLM config combination failed or errored.
+	ExitPolicyFailOnFailure = "fail-on-failure"
+	// ExitPolicyFailOnError exits non-zero only if a combination errored, e.g. its setup failed.
+	ExitPolicyFailOnError = "fail-on-error"
+	// ExitPolicyAlwaysZero exits zero whatever the results.
+	ExitPolicyAlwaysZero = "always-zero"
+	// exitPolicyThreshold, as threshold=<pass rate>, exits non-zero if the pass rate is below the value.
+	exitPolicyThreshold = "threshold"
+)
+
+// exitPolicy is a parsed --exit-policy.
+type exitPolicy struct {
+	name string
+	// threshold is the lowest pass rate (0 to 1) that exits zero, for the threshold policy.
+	threshold float64
+}
+
+func (p exitPolicy) String() string {
+	if p.name == exitPolicyThreshold {
+		return fmt.Sprintf("%s=%g", p.name, p.threshold)
+	}
+	return p.name
+}
+
+// parseExitPolicy parses an exit policy: fail-on-failure, fail-on-error, always-zero or threshold=<pass rate>.
+func parseExitPolicy(s string) (exitPolicy, error) {
+	switch s {
+	case ExitPolicyFailOnFailure, ExitPolicyFailOnError, ExitPolicyAlwaysZero:
+		return exitPolicy{name: s}, nil
+	}
+	if value, ok := strings.CutPrefix(s, exitPolicyThreshold+"="); ok {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return exitPolicy{}, fmt.Errorf("invalid pass rate %q of %s, must be between 0 and 1", value, exitPolicyThreshold)
+		}
+		return exitPolicy{name: exitPolicyThreshold, threshold: threshold}, nil
+	}
+	return exitPolicy{}, fmt.Errorf("invalid exit policy %q, must be %s, %s, %s or %s=<pass rate>", s, ExitPolicyFailOnFailure, ExitPolicyFailOnError, ExitPolicyAlwaysZero, exitPolicyThreshold)
+}
+
+// check returns an error explaining why the results trigger the policy, if they do, and otherwise
+// prints that they do not.
+// Skipped combinations are left out of the counts and the pass rate.
+func (p exitPolicy) check(results []model.TaskResult) error {
+	var total, passed, failed, errored int
+	for _, result := range evaluatedResults(results) {
+		switch result.Result {
+		case "success":
+			passed++
+		case "fail":
+			failed++
+		case "error":
+			errored++
+		}
+		total++
+	}
+
+	var triggered string
+	switch p.name {
+	case ExitPolicyFailOnFailure:
+		if failed+errored > 0 {
+			triggered = fmt.Sprintf("%d of %d task/LLM config combinations failed and %d errored", failed, total, errored)
+		}
+	case ExitPolicyFailOnError:
+		if errored > 0 {
+			triggered = fmt.Sprintf("%d of %d task/LLM config combinations errored", errored, total)
+		}
+	case exitPolicyThreshold:
+		if total > 0 && float64(passed)/float64(total) < p.threshold {
+			triggered = fmt.Sprintf("pass rate %.4f (%d of %d) is below %g", float64(passed)/float64(total), passed, total, p.threshold)
+		}
+	}
+	if triggered != "" {
+		return fmt.Errorf("exit policy %s: %s", p, triggered)
+	}
+	fmt.Printf("Exit policy %s: not triggered (%d passed, %d failed, %d errored of %d task/LLM config combinations)\n", p, passed, failed, errored, total)
+	return nil
+}
@@ -0,0 +1,378 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// Expectation is a single check against the agent output, against the
+// stdout of Command if it is set, or against the JSONPath value of an object
+// in the cluster if Resource is set. Exactly one of the matchers must be set.
+type Expectation struct {
+	// Command is a shell command run against the cluster after the agent finishes.
+	Command string `json:"command,omitempty"`
+	// Resource is an object in the cluster to read JSONPath from after the agent finishes.
+	Resource *ResourceRef `json:"resource,omitempty"`
+	// JSONPath selects the value of Resource to check, e.g. {.status.availableReplicas}.
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Contains is a regex that must match the output.
+	Contains string `json:"contains,omitempty"`
+	// NotContains is a regex that must not match the output.
+	NotContains string `json:"notContains,omitempty"`
+	// Equals must be equal to the output, ignoring leading and trailing whitespace.
+	Equals string `json:"equals,omitempty"`
+	// ContainsLiteral is a plain substring that must appear in the output.
+	ContainsLiteral string `json:"containsLiteral,omitempty"`
+	// Matches is a regex that must match the value of a resource expectation.
+	Matches string `json:"matches,omitempty"`
+	// GreaterThan is a number the value of a resource expectation must be greater than.
+	GreaterThan *json.Number `json:"greaterThan,omitempty"`
+	// Target selects the agent output an output expectation is checked against: lastToolOutput (the default),
+	// finalMessage, or transcript to require that something appears anywhere in the agent output.
+	// Expectations of script steps are always checked against the output of their step.
+	Target ExpectTarget `json:"target,omitempty"`
+	// Stream selects the agent output stream an output expectation is checked against: stdout (the
+	// default), or stderr for the agent's whole stderr, e.g. to assert that no permission errors appeared.
+	Stream ExpectStream `json:"stream,omitempty"`
+}
+
+// ExpectStream selects the agent output stream an expectation is checked against.
+type ExpectStream string
+
+const (
+	ExpectStreamStdout ExpectStream = "stdout"
+	ExpectStreamStderr ExpectStream = "stderr"
+)
+
+// ExpectTarget selects the part of the agent output an expectation is checked against.
+type ExpectTarget string
+
+const (
+	// ExpectTargetLastToolOutput is the output of the agent's last tool call.
+	ExpectTargetLastToolOutput ExpectTarget = "lastToolOutput"
+	// ExpectTargetFinalMessage is the agent's final message.
+	ExpectTargetFinalMessage ExpectTarget = "finalMessage"
+	// ExpectTargetTranscript is the whole agent output.
+	ExpectTargetTranscript ExpectTarget = "transcript"
+)
+
+// agentOutputs are the parts of the agent output that expectations can target.
+type agentOutputs struct {
+	lastToolOutput string
+	finalMessage   string
+	transcript     string
+	// stderr is the agent's whole stderr, which targets do not apply to.
+	stderr string
+	// uniform is set when every target is the same output, so failures need not name their target.
+	uniform bool
+}
+
+// uniformOutputs returns agentOutputs where every target is output, and stderr the agent's stderr.
+func uniformOutputs(output, stderr string) agentOutputs {
+	return agentOutputs{lastToolOutput: output, finalMessage: output, transcript: output, stderr: stderr, uniform: true}
+}
+
+// get returns the output expect is checked against.
+func (o agentOutputs) get(expect Expectation) string {
+	if expect.Stream == ExpectStreamStderr {
+		return o.stderr
+	}
+	switch expect.Target {
+	case ExpectTargetFinalMessage:
+		return o.finalMessage
+	case ExpectTargetTranscript:
+		return o.transcript
+	default:
+		return o.lastToolOutput
+	}
+}
+
+// describe names the output expect is checked against in failure messages, so that a mismatch
+// shows which part of the agent output was searched; it returns "" if stdout outputs are uniform.
+func (o agentOutputs) describe(expect Expectation) string {
+	if expect.Stream == ExpectStreamStderr {
+		return "stream stderr"
+	}
+	if o.uniform {
+		return ""
+	}
+	target := expect.Target
+	if target == "" {
+		target = ExpectTargetLastToolOutput
+	}
+	return fmt.Sprintf("target %s", target)
+}
+
+// ResourceRef identifies a single object in the cluster.
+type ResourceRef struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// String returns a human-readable description of the object, e.g. Deployment shop/web.
+func (r ResourceRef) String() string {
+	kind := r.Kind
+	if r.Group != "" {
+		kind += "." + r.Group
+	}
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s %s", kind, r.Name)
+}
+
+// kubectlType returns the type argument for kubectl get, fully qualified when a group is set.
+func (r ResourceRef) kubectlType() string {
+	switch {
+	case r.Group == "":
+		return r.Kind
+	case r.Version == "":
+		return r.Kind + "." + r.Group
+	default:
+		return r.Kind + "." + r.Version + "." + r.Group
+	}
+}
+
+// Validate checks that exactly one kind of expectation is set.
+func (e Expectation) Validate() error {
+	if e.Resource != nil {
+		return e.validateResource()
+	}
+	if e.JSONPath != "" || e.Matches != "" || e.GreaterThan != nil {
+		return fmt.Errorf("jsonPath, matches and greaterThan can only be used with resource")
+	}
+	switch e.Target {
+	case "", ExpectTargetLastToolOutput, ExpectTargetFinalMessage, ExpectTargetTranscript:
+	default:
+		return fmt.Errorf("invalid target %q, must be %q, %q or %q", e.Target, ExpectTargetLastToolOutput, ExpectTargetFinalMessage, ExpectTargetTranscript)
+	}
+	if e.Target != "" && e.Command != "" {
+		return fmt.Errorf("target cannot be used with command")
+	}
+	switch e.Stream {
+	case "", ExpectStreamStdout:
+	case ExpectStreamStderr:
+		if e.Target != "" {
+			return fmt.Errorf("target cannot be used with stream %q", e.Stream)
+		}
+	default:
+		return fmt.Errorf("invalid stream %q, must be %q or %q", e.Stream, ExpectStreamStdout, ExpectStreamStderr)
+	}
+	if e.Stream != "" && e.Command != "" {
+		return fmt.Errorf("stream cannot be used with command")
+	}
+	set := 0
+	for _, v := range []string{e.Contains, e.NotContains, e.Equals, e.ContainsLiteral} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of contains, notContains, equals or containsLiteral must be set, found %d", set)
+	}
+	for _, pattern := range []string{e.Contains, e.NotContains} {
+		if pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (e Expectation) validateResource() error {
+	if e.Resource.Kind == "" || e.Resource.Name == "" {
+		return fmt.Errorf("resource must specify kind and name")
+	}
+	if e.JSONPath == "" {
+		return fmt.Errorf("jsonPath must be set for resource %s", e.Resource)
+	}
+	if e.Command != "" || e.Contains != "" || e.NotContains != "" || e.ContainsLiteral != "" || e.Target != "" || e.Stream != "" {
+		return fmt.Errorf("only equals, matches or greaterThan can be used with resource %s", e.Resource)
+	}
+	set := 0
+	for _, isSet := range []bool{e.Equals != "", e.Matches != "", e.GreaterThan != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of equals, matches or greaterThan must be set for resource %s, found %d", e.Resource, set)
+	}
+	if e.Matches != "" {
+		if _, err := regexp.Compile(e.Matches); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", e.Matches, err)
+		}
+	}
+	if e.GreaterThan != nil {
+		if _, err := e.GreaterThan.Float64(); err != nil {
+			return fmt.Errorf("greaterThan %q is not a number", e.GreaterThan.String())
+		}
+	}
+	return nil
+}
+
+// Check evaluates the expectation against output, returning a failure if it is not met.
+func (e Expectation) Check(output string) *model.Failure {
+	var message string
+	switch {
+	case e.Contains != "":
+		re, err := regexp.Compile(e.Contains)
+		if err != nil {
+			message = fmt.Sprintf("invalid regex %q in task spec: %v", e.Contains, err)
+		} else if !re.MatchString(output) {
+			message = fmt.Sprintf("regex %q did not match output %q", e.Contains, output)
+		}
+	case e.NotContains != "":
+		re, err := regexp.Compile(e.NotContains)
+		if err != nil {
+			message = fmt.Sprintf("invalid regex %q in task spec: %v", e.NotContains, err)
+		} else if re.MatchString(output) {
+			message = fmt.Sprintf("output unexpectedly matched %q: %q", e.NotContains, output)
+		}
+	case e.Equals != "":
+		if strings.TrimSpace(output) != strings.TrimSpace(e.Equals) {
+			message = fmt.Sprintf("output %q does not equal %q", strings.TrimSpace(output), strings.TrimSpace(e.Equals))
+		}
+	case e.ContainsLiteral != "":
+		if !strings.Contains(output, e.ContainsLiteral) {
+			message = fmt.Sprintf("output %q does not contain %q", output, e.ContainsLiteral)
+		}
+	case e.Matches != "":
+		re, err := regexp.Compile(e.Matches)
+		if err != nil {
+			message = fmt.Sprintf("invalid regex %q in task spec: %v", e.Matches, err)
+		} else if !re.MatchString(output) {
+			message = fmt.Sprintf("value %q does not match %q", output, e.Matches)
+		}
+	case e.GreaterThan != nil:
+		greater, err := greaterThan(output, *e.GreaterThan)
+		if err != nil {
+			message = err.Error()
+		} else if !greater {
+			message = fmt.Sprintf("value %s is not greater than %s", strings.TrimSpace(output), e.GreaterThan)
+		}
+	}
+	if message == "" {
+		return nil
+	}
+	return &model.Failure{Message: message}
+}
+
+// greaterThan reports whether value is greater than threshold.
+// Integers are compared exactly, anything else as float64.
+func greaterThan(value string, threshold json.Number) (bool, error) {
+	value = strings.TrimSpace(value)
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if t, err := threshold.Int64(); err == nil {
+			return v > t, nil
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false, fmt.Errorf("value %q is not a number", value)
+	}
+	t, err := threshold.Float64()
+	if err != nil {
+		return false, fmt.Errorf("greaterThan %q is not a number", threshold.String())
+	}
+	return v > t, nil
+}
+
+// evaluateExpectations checks all expectations and returns the failures.
+// Expectations without a command or resource are checked against their target in outputs,
+// which their failures name; the others are checked against the stdout of their command or the JSONPath
+// value of their resource, using kubeconfig to reach the cluster.
+func evaluateExpectations(ctx context.Context, expects []Expectation, outputs agentOutputs, kubeconfig string) []model.Failure {
+	var failures []model.Failure
+	for _, expect := range expects {
+		output := outputs.get(expect)
+		prefix := outputs.describe(expect)
+		switch {
+		case expect.Command != "":
+			prefix = fmt.Sprintf("expectation command %q", expect.Command)
+			commandOutput, err := runExpectationCommand(ctx, expect.Command, kubeconfig)
+			if err != nil {
+				failures = append(failures, model.Failure{
+					Message: fmt.Sprintf("%s failed: %v", prefix, err),
+				})
+				continue
+			}
+			output = commandOutput
+		case expect.Resource != nil:
+			prefix = fmt.Sprintf("%s %s", expect.Resource, expect.JSONPath)
+			value, err := getResourceValue(ctx, kubeconfig, *expect.Resource, expect.JSONPath)
+			if err != nil {
+				failures = append(failures, model.Failure{
+					Message: fmt.Sprintf("reading %s: %v", expect.Resource, err),
+				})
+				continue
+			}
+			output = value
+		}
+		if failure := expect.Check(output); failure != nil {
+			if prefix != "" {
+				failure.Message = fmt.Sprintf("%s: %s", prefix, failure.Message)
+			}
+			failures = append(failures, *failure)
+		}
+	}
+	return failures
+}
+
+// getResourceValue returns the value at jsonPath of the object identified by ref.
+func getResourceValue(ctx context.Context, kubeconfig string, ref ResourceRef, jsonPath string) (string, error) {
+	if !strings.HasPrefix(jsonPath, "{") {
+		jsonPath = "{" + jsonPath + "}"
+	}
+	args := []string{"get", ref.kubectlType(), ref.Name, "-o", "jsonpath=" + jsonPath}
+	if ref.Namespace != "" {
+		args = append(args, "--namespace", ref.Namespace)
+	}
+	output, err := runKubectl(ctx, kubeconfig, args...)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// runExpectationCommand runs command in a shell and returns its stdout.
+// On failure, the error includes the command's stderr.
+func runExpectationCommand(ctx context.Context, command string, kubeconfig string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", kubeconfig))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,332 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+func TestExpectationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		expect  Expectation
+		wantErr string
+	}{
+		{name: "contains", expect: Expectation{Contains: "ready.*3"}},
+		{name: "notContains", expect: Expectation{NotContains: "error"}},
+		{name: "equals", expect: Expectation{Equals: "3"}},
+		{name: "containsLiteral", expect: Expectation{ContainsLiteral: "a.b[0]"}},
+		{name: "none", expect: Expectation{}, wantErr: "exactly one of contains, notContains, equals or containsLiteral must be set, found 0"},
+		{name: "two", expect: Expectation{Contains: "a", NotContains: "b"}, wantErr: "found 2"},
+		{name: "invalid contains regex", expect: Expectation{Contains: "("}, wantErr: "invalid regex"},
+		{name: "invalid notContains regex", expect: Expectation{NotContains: "[a"}, wantErr: "invalid regex"},
+		{name: "literal is not a regex", expect: Expectation{ContainsLiteral: "("}},
+		{name: "matches without resource", expect: Expectation{Matches: "a"}, wantErr: "can only be used with resource"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.expect.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpectationCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		expect      Expectation
+		output      string
+		wantFailure string
+	}{
+		{name: "contains matches", expect: Expectation{Contains: "replicas: [0-9]+"}, output: "replicas: 3"},
+		{name: "contains does not match", expect: Expectation{Contains: "replicas: 5"}, output: "replicas: 3", wantFailure: `regex "replicas: 5" did not match output "replicas: 3"`},
+		{name: "notContains absent", expect: Expectation{NotContains: "(?i)error"}, output: "all good"},
+		{name: "notContains present", expect: Expectation{NotContains: "(?i)error"}, output: "an Error occurred", wantFailure: `output unexpectedly matched "(?i)error"`},
+		{name: "equals ignores surrounding whitespace", expect: Expectation{Equals: "3"}, output: "  3\n"},
+		{name: "equals differs", expect: Expectation{Equals: "3"}, output: "30", wantFailure: `output "30" does not equal "3"`},
+		{name: "containsLiteral does not interpret regex", expect: Expectation{ContainsLiteral: "a.b[0]"}, output: "value of a.b[0] is 1"},
+		{name: "containsLiteral missing", expect: Expectation{ContainsLiteral: "a.b"}, output: "axb", wantFailure: `output "axb" does not contain "a.b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := tt.expect.Check(tt.output)
+			if tt.wantFailure == "" {
+				if failure != nil {
+					t.Fatalf("Check(%q) = %q, want no failure", tt.output, failure.Message)
+				}
+				return
+			}
+			if failure == nil || !strings.Contains(failure.Message, tt.wantFailure) {
+				t.Fatalf("Check(%q) = %v, want failure containing %q", tt.output, failure, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpectationsCombinations(t *testing.T) {
+	const output = "deployment web scaled to 3 replicas"
+	tests := []struct {
+		name         string
+		expects      []Expectation
+		wantFailures int
+	}{
+		{
+			name: "all met",
+			expects: []Expectation{
+				{Contains: "scaled to [0-9]+"},
+				{NotContains: "error"},
+				{ContainsLiteral: "web"},
+			},
+		},
+		{
+			name: "one of several unmet",
+			expects: []Expectation{
+				{Contains: "scaled"},
+				{NotContains: "replicas"},
+				{ContainsLiteral: "web"},
+			},
+			wantFailures: 1,
+		},
+		{
+			name: "every unmet expectation is reported",
+			expects: []Expectation{
+				{Equals: "done"},
+				{NotContains: "web"},
+				{ContainsLiteral: "api"},
+			},
+			wantFailures: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := evaluateExpectations(context.Background(), tt.expects, uniformOutputs(output, ""), "")
+			if len(failures) != tt.wantFailures {
+				t.Fatalf("evaluateExpectations() returned %d failures %v, want %d", len(failures), failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestAgentOutputs(t *testing.T) {
+	tests := []struct {
+		name               string
+		trace              string
+		stdout             string
+		wantLastToolOutput string
+		wantFinalMessage   string
+		wantSource         string
+	}{
+		{
+			name: "trace with a final message",
+			trace: `timestamp: "1"
+action: tool-response
+payload:
+  response: first
+---
+timestamp: "2"
+action: tool-response
+payload:
+  response:
+    stdout: pod/web created
+    stderr: warning
+---
+timestamp: "3"
+action: llm-response
+payload:
+  text: I created the pod.
+`,
+			stdout:             "Running: kubectl run web\npod/web created\n",
+			wantLastToolOutput: "pod/web created",
+			wantFinalMessage:   "I created the pod.",
+			wantSource:         model.OutputSourceTrace,
+		},
+		{
+			name: "trace without a final message after the last tool output",
+			trace: `action: llm-response
+payload:
+  text: Let me check.
+---
+action: tool_result
+payload:
+  output: 3 replicas
+`,
+			stdout:             "Running: kubectl get deploy\n3 replicas\n",
+			wantLastToolOutput: "3 replicas",
+			wantFinalMessage:   "3 replicas",
+			wantSource:         model.OutputSourceTrace,
+		},
+		{
+			name: "trace without tool calls falls back to the console output",
+			trace: `action: llm-response
+payload:
+  text: Nothing to do.
+`,
+			stdout:             "Running: kubectl get pods\nno pods\nRunning: kubectl get nodes\nnode-1 Ready\n",
+			wantLastToolOutput: "node-1 Ready\n",
+			wantFinalMessage:   "node-1 Ready\n",
+			wantSource:         model.OutputSourceConsole,
+		},
+		{
+			name:               "console output without Running: is searched whole",
+			stdout:             "All pods are healthy.\n",
+			wantLastToolOutput: "All pods are healthy.\n",
+			wantFinalMessage:   "All pods are healthy.\n",
+			wantSource:         model.OutputSourceConsole,
+		},
+		{
+			name:               "console output ending on a Running: line",
+			stdout:             "Running: kubectl get pods",
+			wantLastToolOutput: "",
+			wantFinalMessage:   "",
+			wantSource:         model.OutputSourceConsole,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := &TaskExecution{stderrOutput: newLockedBuffer()}
+			x.stderrOutput.Write([]byte("agent stderr"))
+			if tt.trace != "" {
+				path := filepath.Join(t.TempDir(), "trace.yaml")
+				if err := os.WriteFile(path, []byte(tt.trace), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				events, err := readTrace(path)
+				if err != nil {
+					t.Fatalf("readTrace() error = %v", err)
+				}
+				x.trace = events
+			}
+			outputs, source := x.agentOutputs(tt.stdout)
+			if source != tt.wantSource {
+				t.Errorf("source = %q, want %q", source, tt.wantSource)
+			}
+			if outputs.lastToolOutput != tt.wantLastToolOutput {
+				t.Errorf("lastToolOutput = %q, want %q", outputs.lastToolOutput, tt.wantLastToolOutput)
+			}
+			if outputs.finalMessage != tt.wantFinalMessage {
+				t.Errorf("finalMessage = %q, want %q", outputs.finalMessage, tt.wantFinalMessage)
+			}
+			if outputs.transcript != tt.stdout {
+				t.Errorf("transcript = %q, want the whole stdout %q", outputs.transcript, tt.stdout)
+			}
+			if outputs.stderr != "agent stderr" {
+				t.Errorf("stderr = %q, want %q", outputs.stderr, "agent stderr")
+			}
+			if outputs.uniform {
+				t.Errorf("uniform is set for the outputs of an agent")
+			}
+		})
+	}
+}
+
+func TestEvaluateExpectationsTargets(t *testing.T) {
+	outputs := agentOutputs{
+		lastToolOutput: "pod/web created",
+		finalMessage:   "I created the pod.",
+		transcript:     "Running: kubectl run web\npod/web created\nI created the pod.",
+		stderr:         "warning: deprecated flag",
+	}
+	tests := []struct {
+		name        string
+		outputs     agentOutputs
+		expect      Expectation
+		wantMessage string // prefix of the failure message, or "" if the expectation is met
+	}{
+		{
+			name:    "last tool output by default",
+			outputs: outputs,
+			expect:  Expectation{Contains: "created"},
+		},
+		{
+			name:        "default target is named on failure",
+			outputs:     outputs,
+			expect:      Expectation{Contains: "I created"},
+			wantMessage: "target lastToolOutput: ",
+		},
+		{
+			name:    "final message",
+			outputs: outputs,
+			expect:  Expectation{Target: ExpectTargetFinalMessage, Contains: "I created"},
+		},
+		{
+			name:        "final message is named on failure",
+			outputs:     outputs,
+			expect:      Expectation{Target: ExpectTargetFinalMessage, Contains: "pod/web"},
+			wantMessage: "target finalMessage: ",
+		},
+		{
+			name:    "transcript",
+			outputs: outputs,
+			expect:  Expectation{Target: ExpectTargetTranscript, Contains: "Running: kubectl run"},
+		},
+		{
+			name:        "transcript is named on failure",
+			outputs:     outputs,
+			expect:      Expectation{Target: ExpectTargetTranscript, NotContains: "Running:"},
+			wantMessage: "target transcript: ",
+		},
+		{
+			name:        "stderr is named on failure",
+			outputs:     outputs,
+			expect:      Expectation{Stream: ExpectStreamStderr, NotContains: "deprecated"},
+			wantMessage: "stream stderr: ",
+		},
+		{
+			name:        "uniform outputs are not named",
+			outputs:     uniformOutputs("pod/web created", ""),
+			expect:      Expectation{Target: ExpectTargetFinalMessage, Contains: "I created"},
+			wantMessage: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := evaluateExpectations(context.Background(), []Expectation{tt.expect}, tt.outputs, "")
+			wantFailure := tt.wantMessage != "" || tt.outputs.uniform
+			if !wantFailure {
+				if len(failures) != 0 {
+					t.Fatalf("evaluateExpectations() = %v, want no failures", failures)
+				}
+				return
+			}
+			if len(failures) != 1 {
+				t.Fatalf("evaluateExpectations() = %v, want 1 failure", failures)
+			}
+			message := failures[0].Message
+			if tt.wantMessage == "" {
+				if strings.HasPrefix(message, "target ") || strings.HasPrefix(message, "stream ") {
+					t.Errorf("failure message %q names its target, want no prefix for uniform outputs", message)
+				}
+				return
+			}
+			if !strings.HasPrefix(message, tt.wantMessage) {
+				t.Errorf("failure message %q, want prefix %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
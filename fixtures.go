@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// fixturesDir is the directory in the tasks directory holding files shared between tasks.
+	// It is not loaded as a task.
+	fixturesDir = "fixtures"
+	// fixturesPrefix marks a task path as relative to the fixtures directory, e.g. @fixtures/sample-app/deploy.yaml.
+	fixturesPrefix = "@fixtures/"
+)
+
+// resolveTaskPath resolves a path from task.yaml: paths starting with @fixtures/ are
+// relative to the fixtures directory, other paths are relative to the task directory.
+// Paths must not escape the tasks directory.
+func resolveTaskPath(tasksDir, taskDir, p string) (string, error) {
+	var resolved string
+	if rest, ok := strings.CutPrefix(p, fixturesPrefix); ok {
+		resolved = filepath.Join(tasksDir, fixturesDir, rest)
+	} else {
+		resolved = filepath.Join(taskDir, p)
+	}
+
+	root, err := filepath.Abs(tasksDir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes the tasks directory", p)
+	}
+	return abs, nil
+}
+
+// checkPaths checks that the scripts and manifests referenced by the task stay inside
+// the tasks directory, and that the referenced fixtures exist.
+func (t *Task) checkPaths(tasksDir string) error {
+	taskDir := filepath.Join(tasksDir, filepath.FromSlash(t.dir))
+	var paths []string
+	for _, script := range []*ScriptRef{t.Setup, t.Cleanup} {
+		if script != nil {
+			paths = append(paths, script.Script)
+		}
+	}
+	for _, verifier := range t.verifierScripts() {
+		paths = append(paths, verifier.Script)
+	}
+	paths = append(paths, t.SetupManifests...)
+
+	for _, p := range paths {
+		resolved, err := resolveTaskPath(tasksDir, taskDir, p)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(p, fixturesPrefix) {
+			continue
+		}
+		if _, err := os.Stat(resolved); err != nil {
+			return fmt.Errorf("fixture %q not found: %w", p, err)
+		}
+	}
+	return nil
+}
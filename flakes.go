@@ -0,0 +1,247 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+type FlakesConfig struct {
+	// MinSamples is the least number of samples of a task and LLM config pair to consider it.
+	MinSamples int
+	// JSONPath is the path to also write the report to as JSON, if set.
+	JSONPath string
+}
+
+// flakesReport lists the task and LLM config pairs with mixed outcomes across their samples.
+type flakesReport struct {
+	// Samples is the number of results read, and Pairs the number of task and LLM config pairs
+	// with at least MinSamples of them.
+	Samples int `json:"samples"`
+	Pairs   int `json:"pairs"`
+	// Flaky are the pairs with mixed outcomes, flakiest first.
+	Flaky []flakyPair `json:"flaky"`
+}
+
+// flakyPair is a task and LLM config pair whose samples both passed and did not.
+type flakyPair struct {
+	Task      string  `json:"task"`
+	LLMConfig string  `json:"llmConfig"`
+	Samples   int     `json:"samples"`
+	Success   int     `json:"success"`
+	Fail      int     `json:"fail"`
+	Error     int     `json:"error"`
+	PassRate  float64 `json:"passRate"`
+	// Variance is the variance of the pass outcome across samples, PassRate × (1 - PassRate):
+	// 0.25 for a pair that passes half of the time, the flakiest.
+	Variance float64 `json:"variance"`
+	// Correlations describe what all the samples that did not pass have in common, e.g. a worker
+	// or cluster that none of the passing samples ran on, or a single failure reason.
+	Correlations []string `json:"correlations,omitempty"`
+	// FailureReasons counts the first failure or error of the samples that did not pass.
+	FailureReasons map[string]int `json:"failureReasons,omitempty"`
+}
+
+func runFlakes() error {
+	config := FlakesConfig{}
+
+	// Set custom usage for 'flakes' subcommand
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s flakes [options] <runs-root>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Report the tasks whose outcome with an LLM config is mixed across the results under runs-root,\n")
+		fmt.Fprintf(os.Stderr, "i.e. across the samples of --runs and across repeated runs, sorted by flakiness.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.IntVar(&config.MinSamples, "min-samples", 2, "Only consider task/LLM config pairs with at least this many samples")
+	flag.StringVar(&config.JSONPath, "json", "", "Also write the report as JSON to this path")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return fmt.Errorf("flakes needs exactly one runs root directory")
+	}
+
+	results, err := collectResults(flag.Arg(0))
+	if err != nil {
+		return fmt.Errorf("reading results from %s: %w", flag.Arg(0), err)
+	}
+	report := findFlakes(results, max(config.MinSamples, 2))
+	printFlakes(report)
+
+	if config.JSONPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(config.JSONPath, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("writing flakes report: %w", err)
+		}
+	}
+	return nil
+}
+
+// findFlakes groups the results by task and LLM config, and reports the pairs with at least
+// minSamples samples whose outcomes are mixed. Skipped results are not samples.
+func findFlakes(results []model.TaskResult, minSamples int) flakesReport {
+	type pairKey struct{ task, llmConfig string }
+	samples := make(map[pairKey][]model.TaskResult)
+	report := flakesReport{}
+	for _, result := range evaluatedResults(results) {
+		key := pairKey{result.Task, result.ConfigID()}
+		samples[key] = append(samples[key], result)
+		report.Samples++
+	}
+
+	report.Flaky = []flakyPair{}
+	for key, pairResults := range samples {
+		if len(pairResults) < minSamples {
+			continue
+		}
+		report.Pairs++
+		pair := flakyPair{Task: key.task, LLMConfig: key.llmConfig, Samples: len(pairResults)}
+		var passed, notPassed []model.TaskResult
+		for _, result := range pairResults {
+			switch result.Result {
+			case "success":
+				pair.Success++
+				passed = append(passed, result)
+				continue
+			case "error":
+				pair.Error++
+			default:
+				pair.Fail++
+			}
+			notPassed = append(notPassed, result)
+		}
+		if len(passed) == 0 || len(notPassed) == 0 {
+			continue
+		}
+		pair.PassRate = float64(pair.Success) / float64(pair.Samples)
+		pair.Variance = pair.PassRate * (1 - pair.PassRate)
+		pair.FailureReasons = make(map[string]int)
+		for _, result := range notPassed {
+			pair.FailureReasons[failureReason(result)]++
+		}
+		pair.Correlations = failureCorrelations(passed, notPassed, pair.FailureReasons)
+		report.Flaky = append(report.Flaky, pair)
+	}
+
+	sort.Slice(report.Flaky, func(i, j int) bool {
+		a, b := report.Flaky[i], report.Flaky[j]
+		if a.Variance != b.Variance {
+			return a.Variance > b.Variance
+		}
+		if a.Samples != b.Samples {
+			return a.Samples > b.Samples
+		}
+		if a.Task != b.Task {
+			return a.Task < b.Task
+		}
+		return a.LLMConfig < b.LLMConfig
+	})
+	return report
+}
+
+// maxFailureReasonLength bounds the failure reasons of the report, which group similar failures.
+const maxFailureReasonLength = 120
+
+// failureReason is the first failure or error of a result that did not pass, prefixed with its result.
+func failureReason(result model.TaskResult) string {
+	reason := firstFailure(result)
+	if reason == "" {
+		reason = "no failure recorded"
+	}
+	if len(reason) > maxFailureReasonLength {
+		reason = reason[:maxFailureReasonLength] + "..."
+	}
+	return result.Result + ": " + reason
+}
+
+// failureCorrelations describes what all the samples that did not pass have in common: the
+// worker, cluster or run they ran in if none of the passing samples did, and their failure
+// reason if there is only one.
+func failureCorrelations(passed, notPassed []model.TaskResult, failureReasons map[string]int) []string {
+	attributes := []struct {
+		name  string
+		value func(model.TaskResult) string
+	}{
+		{"worker", func(r model.TaskResult) string {
+			if r.Worker == nil {
+				return ""
+			}
+			return fmt.Sprint(*r.Worker)
+		}},
+		{"cluster", func(r model.TaskResult) string {
+			if r.Cluster == nil {
+				return ""
+			}
+			return r.Cluster.Name
+		}},
+		{"run", func(r model.TaskResult) string { return r.RunID }},
+	}
+
+	var correlations []string
+	for _, attribute := range attributes {
+		value := attribute.value(notPassed[0])
+		if value == "" {
+			continue
+		}
+		shared := true
+		for _, result := range notPassed[1:] {
+			if attribute.value(result) != value {
+				shared = false
+				break
+			}
+		}
+		for _, result := range passed {
+			if attribute.value(result) == value {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			correlations = append(correlations, fmt.Sprintf("all failures on %s %s", attribute.name, value))
+		}
+	}
+	if len(failureReasons) == 1 && len(notPassed) > 1 {
+		for reason := range failureReasons {
+			correlations = append(correlations, fmt.Sprintf("all failures with %q", reason))
+		}
+	}
+	return correlations
+}
+
+func printFlakes(report flakesReport) {
+	if len(report.Flaky) == 0 {
+		fmt.Printf("No flaky tasks among %d task/LLM config pairs (%d samples)\n", report.Pairs, report.Samples)
+		return
+	}
+	fmt.Printf("%d of %d task/LLM config pairs are flaky (%d samples)\n\n", len(report.Flaky), report.Pairs, report.Samples)
+	fmt.Println("| Task | LLM Config | Samples | Success | Fail | Error | Pass Rate | Variance | Correlations |")
+	fmt.Println("|------|------------|---------|---------|------|-------|-----------|----------|--------------|")
+	for _, pair := range report.Flaky {
+		fmt.Printf("| %s | %s | %d | %d | %d | %d | %d%% | %.2f | %s |\n",
+			pair.Task, pair.LLMConfig, pair.Samples, pair.Success, pair.Fail, pair.Error,
+			calculatePercentage(pair.Success, pair.Samples), pair.Variance, strings.Join(pair.Correlations, "; "))
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// htmlReport is the data rendered by htmlReportTemplate.
+type htmlReport struct {
+	RunID       string
+	GeneratedAt string
+	Summaries   []model.LLMConfigSummary
+	LLMConfigs  []string
+	Rows        []htmlReportRow
+}
+
+type htmlReportRow struct {
+	Task  string
+	Cells []htmlReportCell
+}
+
+// htmlReportCell holds the results (one per run) of a task with an LLM config.
+type htmlReportCell struct {
+	// Class is "pass" if all runs passed, "fail" if any failed or errored, "skip" otherwise.
+	Class   string
+	Label   string
+	Results []htmlReportResult
+}
+
+type htmlReportResult struct {
+	model.TaskResult
+	// LogLink and TraceLink are relative to the report file.
+	LogLink   string
+	TraceLink string
+}
+
+// writeHTMLReport writes a self-contained HTML report of the results to config.ReportHTML.
+func writeHTMLReport(config EvalConfig, results []model.TaskResult) error {
+	report := htmlReport{
+		RunID:       config.RunID,
+		GeneratedAt: time.Now().Format(time.RFC1123),
+		Summaries:   summarizeLLMConfigs(results),
+	}
+	for _, summary := range report.Summaries {
+		report.LLMConfigs = append(report.LLMConfigs, summary.ID)
+	}
+
+	byTask := make(map[string]map[string][]model.TaskResult)
+	for _, result := range results {
+		if byTask[result.Task] == nil {
+			byTask[result.Task] = make(map[string][]model.TaskResult)
+		}
+		byTask[result.Task][result.ConfigID()] = append(byTask[result.Task][result.ConfigID()], result)
+	}
+	tasks := make([]string, 0, len(byTask))
+	for task := range byTask {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	reportDir, err := filepath.Abs(filepath.Dir(config.ReportHTML))
+	if err != nil {
+		return err
+	}
+	link := func(p string) string {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return ""
+		}
+		rel, err := filepath.Rel(reportDir, abs)
+		if err != nil {
+			return ""
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	for _, task := range tasks {
+		row := htmlReportRow{Task: task}
+		for _, llmID := range report.LLMConfigs {
+			cellResults := byTask[task][llmID]
+			sort.Slice(cellResults, func(i, j int) bool { return cellResults[i].Run < cellResults[j].Run })
+			cell := htmlReportCell{Class: "skip", Label: "-"}
+			passed, failed, ran := 0, 0, 0
+			for _, result := range cellResults {
+				outputDir := taskOutputPath(config, result.Task, result.ConfigID(), max(result.Run, 1))
+				cell.Results = append(cell.Results, htmlReportResult{
+					TaskResult: result,
+					LogLink:    link(filepath.Join(outputDir, "log.txt")),
+					TraceLink:  link(filepath.Join(outputDir, "trace.yaml")),
+				})
+				switch result.Result {
+				case "success":
+					passed++
+					ran++
+				case "fail", "error":
+					failed++
+					ran++
+				}
+			}
+			switch {
+			case failed > 0:
+				cell.Class = "fail"
+			case passed > 0:
+				cell.Class = "pass"
+			}
+			if ran > 0 {
+				cell.Label = fmt.Sprintf("%d/%d", passed, ran)
+			} else if len(cellResults) > 0 {
+				cell.Label = cellResults[0].Result
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	var out strings.Builder
+	if err := htmlReportTemplate.Execute(&out, report); err != nil {
+		return fmt.Errorf("rendering HTML report: %w", err)
+	}
+	if err := os.WriteFile(config.ReportHTML, []byte(out.String()), 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", config.ReportHTML, err)
+	}
+	return nil
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>k8s-ai-bench results {{.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; cursor: pointer; }
+td.pass { background: #d4f7d4; }
+td.fail { background: #f9d0d0; }
+td.skip { background: #e4e4e4; }
+details summary { cursor: pointer; }
+pre { white-space: pre-wrap; max-width: 60em; max-height: 30em; overflow: auto; background: #fafafa; padding: 4px; }
+</style>
+</head>
+<body>
+<h1>k8s-ai-bench results</h1>
+<p>Run {{.RunID}}, generated {{.GeneratedAt}}</p>
+
+<h2>Summary</h2>
+<table class="sortable">
+<thead><tr><th>LLM Config</th><th>Total</th><th>Success</th><th>Fail</th><th>Error</th><th>Skipped</th><th>Pass Rate</th><th>Mean Score</th></tr></thead>
+<tbody>
+{{- range .Summaries}}
+<tr><td>{{.ID}}</td><td>{{.Total}}</td><td>{{.Success}}</td><td>{{.Fail}}</td><td>{{.Error}}</td><td>{{.Skipped}}</td><td>{{percent .PassRate}}</td><td>{{printf "%.2f" .MeanScore}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+<h2>Tasks</h2>
+<p><input id="filter" type="search" placeholder="Filter tasks" oninput="filterRows(this.value)"></p>
+<table id="matrix" class="sortable">
+<thead><tr><th>Task</th>{{range .LLMConfigs}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td>{{.Task}}</td>
+{{- range .Cells}}
+<td class="{{.Class}}">{{if .Results}}<details><summary>{{.Label}}</summary>
+{{- range .Results}}
+<p>{{if .Run}}Run {{.Run}}: {{end}}<b>{{.Result}}</b>{{if .Duration}} in {{.Duration}}{{end}}, score {{printf "%.2f" .Score}}
+(<a href="{{.LogLink}}">log</a>, <a href="{{.TraceLink}}">trace</a>)</p>
+{{- with .Timing}}<p>Setup {{or .Setup "-"}}, agent {{or .Agent "-"}}, verify {{or .Verify "-"}}, cleanup {{or .Cleanup "-"}}</p>{{end}}
+{{- if .SkipReason}}<p>{{.SkipReason}}</p>{{end}}
+{{- range .Failures}}<pre>{{.Message}}</pre>{{end}}
+{{- if .Error}}<pre>{{.Error}}</pre>{{end}}
+{{- end}}
+</details>{{else}}{{.Label}}{{end}}</td>
+{{- end}}
+</tr>
+{{- end}}
+</tbody>
+</table>
+
+<script>
+function filterRows(text) {
+  text = text.toLowerCase();
+  for (const row of document.querySelectorAll("#matrix tbody tr")) {
+    row.style.display = row.cells[0].textContent.toLowerCase().includes(text) ? "" : "none";
+  }
+}
+for (const table of document.querySelectorAll("table.sortable")) {
+  table.querySelectorAll("th").forEach((th, column) => {
+    th.addEventListener("click", () => {
+      const body = table.tBodies[0];
+      const ascending = th.dataset.order !== "asc";
+      th.dataset.order = ascending ? "asc" : "desc";
+      const key = (row) => row.cells[column].querySelector("summary")?.textContent ?? row.cells[column].textContent;
+      const rows = Array.from(body.rows).sort((a, b) => {
+        const result = key(a).localeCompare(key(b), undefined, {numeric: true});
+        return ascending ? result : -result;
+      });
+      rows.forEach((row) => body.appendChild(row));
+    });
+  });
+}
+</script>
+</body>
+</html>
+`))
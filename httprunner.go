@@ -0,0 +1,259 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// HTTPAgentConfig configures the http agent runner, which sends the prompts to an agent
+// served behind an OpenAI compatible chat completions endpoint.
+type HTTPAgentConfig struct {
+	// URL is the base URL of the agent; requests are sent to <URL>/v1/chat/completions.
+	URL string
+	// AuthHeader is a "Name: value" header sent with each request, e.g. "Authorization: Bearer $TOKEN".
+	// Environment variables in the value are expanded.
+	AuthHeader string
+	// TurnTimeout bounds each prompt's request, including its streamed response (0 for no limit).
+	TurnTimeout time.Duration
+}
+
+// httpAgentRunner sends each prompt to an HTTP agent as a chat completion request, with the
+// conversation so far, and streams the response into the agent output.
+type httpAgentRunner struct {
+	endpoint    string
+	headerName  string
+	headerValue string
+	turnTimeout time.Duration
+	client      *http.Client
+}
+
+func newHTTPAgentRunner(config HTTPAgentConfig) (*httpAgentRunner, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the http agent runner needs --agent-url")
+	}
+	endpoint, err := url.JoinPath(config.URL, "v1", "chat", "completions")
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent URL %q: %w", config.URL, err)
+	}
+	r := &httpAgentRunner{
+		endpoint:    endpoint,
+		turnTimeout: config.TurnTimeout,
+		client:      &http.Client{},
+	}
+	if config.AuthHeader != "" {
+		name, value, ok := strings.Cut(config.AuthHeader, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid agent auth header, must be \"Name: value\"")
+		}
+		r.headerName = strings.TrimSpace(name)
+		r.headerValue = os.ExpandEnv(strings.TrimSpace(value))
+	}
+	return r, nil
+}
+
+// chatMessage is a message of a chat completion request.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatRequest is a chat completion request. The agent gets the kubeconfig of the task's
+// cluster, and the LLM provider to use, as extension fields.
+type chatRequest struct {
+	Model         string            `json:"model"`
+	Messages      []chatMessage     `json:"messages"`
+	Stream        bool              `json:"stream"`
+	StreamOptions map[string]bool   `json:"stream_options,omitempty"`
+	LLMProvider   string            `json:"llm_provider,omitempty"`
+	KubeConfig    string            `json:"kubeconfig,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Temperature   *float64          `json:"temperature,omitempty"`
+	MaxTokens     int               `json:"max_tokens,omitempty"`
+}
+
+// chatChunk holds the fields used from both streamed chunks and complete responses.
+type chatChunk struct {
+	Choices []struct {
+		Delta   chatMessage `json:"delta"`
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage map[string]any `json:"usage,omitempty"`
+}
+
+func (r *httpAgentRunner) Run(ctx context.Context, spec AgentRunSpec) (AgentRunResult, error) {
+	kubeConfig, err := os.ReadFile(spec.KubeConfig)
+	if err != nil {
+		return AgentRunResult{}, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+	trace, err := os.Create(spec.TracePath)
+	if err != nil {
+		return AgentRunResult{}, fmt.Errorf("creating trace: %w", err)
+	}
+	defer trace.Close()
+
+	var messages []chatMessage
+	for turn := 1; ; turn++ {
+		var prompt string
+		select {
+		case p, ok := <-spec.Prompts:
+			if !ok {
+				return AgentRunResult{}, nil
+			}
+			prompt = p
+		case <-ctx.Done():
+			return AgentRunResult{}, ctx.Err()
+		}
+
+		messages = append(messages, chatMessage{Role: "user", Content: prompt})
+		request := chatRequest{
+			Model:         spec.LLMConfig.ModelID,
+			Messages:      messages,
+			Stream:        true,
+			StreamOptions: map[string]bool{"include_usage": true},
+			LLMProvider:   spec.LLMConfig.ProviderID,
+			KubeConfig:    string(kubeConfig),
+			Metadata:      map[string]string{"llmConfig": spec.LLMConfig.ID},
+			Temperature:   spec.LLMConfig.Temperature,
+			MaxTokens:     spec.LLMConfig.MaxTokens,
+		}
+		writeTraceEvent(trace, "llm-request", map[string]any{"model": request.Model, "prompt": prompt})
+
+		reply, usage, err := r.sendTurn(ctx, request, spec.Output)
+		if err != nil {
+			return AgentRunResult{}, fmt.Errorf("turn %d: %w", turn, err)
+		}
+		fmt.Fprintln(spec.Output)
+		messages = append(messages, chatMessage{Role: "assistant", Content: reply})
+		payload := map[string]any{"text": reply}
+		if usage != nil {
+			payload["usage"] = usage
+		}
+		writeTraceEvent(trace, "llm-response", payload)
+	}
+}
+
+// sendTurn sends a chat completion request, copying the reply to output as it streams in.
+// It returns the reply and the usage reported by the agent, if any.
+func (r *httpAgentRunner) sendTurn(ctx context.Context, request chatRequest, output io.Writer) (string, map[string]any, error) {
+	turnCtx := ctx
+	if r.turnTimeout > 0 {
+		var cancel context.CancelFunc
+		turnCtx, cancel = context.WithTimeout(ctx, r.turnTimeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", nil, err
+	}
+	req, err := http.NewRequestWithContext(turnCtx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if r.headerName != "" {
+		req.Header.Set(r.headerName, r.headerValue)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", nil, r.turnError(ctx, turnCtx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", nil, fmt.Errorf("agent returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var reply strings.Builder
+	var usage map[string]any
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// The agent does not stream, it returned the whole reply.
+		var chunk chatChunk
+		if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+			return "", nil, r.turnError(ctx, turnCtx, fmt.Errorf("parsing agent response: %w", err))
+		}
+		for _, choice := range chunk.Choices {
+			reply.WriteString(choice.Message.Content)
+		}
+		io.WriteString(output, reply.String())
+		return reply.String(), chunk.Usage, nil
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return reply.String(), usage, fmt.Errorf("parsing agent response: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			reply.WriteString(choice.Delta.Content)
+			io.WriteString(output, choice.Delta.Content)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return reply.String(), usage, r.turnError(ctx, turnCtx, fmt.Errorf("reading agent response: %w", err))
+	}
+	return reply.String(), usage, nil
+}
+
+// turnError reports a turn that ran out of time as such, rather than as the resulting I/O error.
+func (r *httpAgentRunner) turnError(ctx, turnCtx context.Context, err error) error {
+	if ctx.Err() == nil && errors.Is(turnCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v: %w", r.turnTimeout, err)
+	}
+	return err
+}
+
+// writeTraceEvent appends an event to a trace in the format written by kubectl-ai, so the
+// usage and metrics of HTTP agents are read the same way.
+func writeTraceEvent(trace io.Writer, action string, payload map[string]any) {
+	data, err := yaml.Marshal(traceEvent{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		Action:    action,
+		Payload:   payload,
+	})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(trace, "---\n%s", data)
+}
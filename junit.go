@@ -0,0 +1,159 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// maxJUnitMessageLength truncates long failure messages in the JUnit report; the full output is in the task log.
+const maxJUnitMessageLength = 8 * 1024
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Error      *junitMessage   `xml:"error,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes the results as a JUnit XML report, with a test suite per LLM config
+// and a test case named <taskID>[<llmConfig.ID>] per result.
+func writeJUnitReport(config EvalConfig, results []model.TaskResult) error {
+	suites := make(map[string]*junitTestSuite)
+	for _, result := range results {
+		suite := suites[result.ConfigID()]
+		if suite == nil {
+			suite = &junitTestSuite{Name: result.ConfigID()}
+			suites[result.ConfigID()] = suite
+		}
+
+		name := fmt.Sprintf("%s[%s]", result.Task, result.ConfigID())
+		run := max(result.Run, 1)
+		if result.Run > 0 {
+			name += fmt.Sprintf(" (run %d)", result.Run)
+		}
+		testCase := junitTestCase{
+			Name:      name,
+			ClassName: result.Task,
+		}
+		if d, err := time.ParseDuration(result.Duration); err == nil {
+			testCase.Time = d.Seconds()
+		}
+		if timing := result.Timing; timing != nil {
+			for _, phase := range []junitProperty{
+				{Name: "setup", Value: timing.Setup},
+				{Name: "agent", Value: timing.Agent},
+				{Name: "verify", Value: timing.Verify},
+				{Name: "cleanup", Value: timing.Cleanup},
+			} {
+				if phase.Value != "" {
+					testCase.Properties = append(testCase.Properties, phase)
+				}
+			}
+		}
+		logPath := filepath.Join(taskOutputPath(config, result.Task, result.ConfigID(), run), "log.txt")
+
+		switch result.Result {
+		case "success":
+		case "error":
+			testCase.Error = &junitMessage{Message: "infrastructure error", Text: truncateJUnitMessage(result.Error, logPath)}
+			suite.Errors++
+		case "skipped":
+			testCase.Skipped = &junitMessage{Message: result.SkipReason}
+			suite.Skipped++
+		default:
+			var messages []string
+			for _, failure := range result.Failures {
+				messages = append(messages, failure.Message)
+			}
+			message := "task failed"
+			if len(messages) > 0 {
+				message = firstLine(messages[0])
+			}
+			testCase.Failure = &junitMessage{Message: message, Text: truncateJUnitMessage(strings.Join(messages, "\n\n"), logPath)}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Time += testCase.Time
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	var report junitTestSuites
+	for _, suite := range suites {
+		sort.Slice(suite.Cases, func(i, j int) bool { return suite.Cases[i].Name < suite.Cases[j].Name })
+		report.Suites = append(report.Suites, *suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool { return report.Suites[i].Name < report.Suites[j].Name })
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	if err := os.WriteFile(config.ReportJUnit, data, 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", config.ReportJUnit, err)
+	}
+	return nil
+}
+
+// truncateJUnitMessage truncates a long message, pointing to the log file for the full output.
+func truncateJUnitMessage(message string, logPath string) string {
+	if len(message) <= maxJUnitMessageLength {
+		return message
+	}
+	return message[:maxJUnitMessageLength] + fmt.Sprintf("\n... (truncated, full log at %s)", logPath)
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,255 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+type LeaderboardConfig struct {
+	// Model only shows the history of LLM configs with this ID or model.
+	Model string
+	// OutputFormat is the format of the export: csv or json.
+	OutputFormat string
+	// OutputPath is the path to export the leaderboard entries to, if set.
+	OutputPath string
+}
+
+// leaderboardEntry is the pass rate and mean score of an LLM config in a run.
+type leaderboardEntry struct {
+	RunID     string    `json:"runID"`
+	RunDir    string    `json:"runDir"`
+	Date      time.Time `json:"date"`
+	LLMConfig string    `json:"llmConfig"`
+	Model     string    `json:"model"`
+	Total     int       `json:"total"`
+	Success   int       `json:"success"`
+	PassRate  float64   `json:"passRate"`
+	MeanScore float64   `json:"meanScore"`
+}
+
+func runLeaderboard() error {
+	config := LeaderboardConfig{}
+
+	// Set custom usage for 'leaderboard' subcommand
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s leaderboard [options] <runs-root>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Aggregate the pass rates of each LLM config across the run output directories under runs-root.\n")
+		fmt.Fprintf(os.Stderr, "Runs are found by their results.json or run-metadata.yaml.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&config.Model, "model", "", "Only show the history of this LLM config ID or model")
+	flag.StringVar(&config.OutputFormat, "output-format", "csv", "Format of the export to --output (csv or json)")
+	flag.StringVar(&config.OutputPath, "output", "", "Also export one entry per LLM config and run to this path")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return fmt.Errorf("leaderboard needs exactly one runs root directory")
+	}
+	if config.OutputFormat != "csv" && config.OutputFormat != "json" {
+		return fmt.Errorf("invalid output format: %s, valid options are 'csv' or 'json'", config.OutputFormat)
+	}
+
+	entries, err := collectLeaderboardEntries(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	if config.Model != "" {
+		var filtered []leaderboardEntry
+		for _, entry := range entries {
+			if entry.LLMConfig == config.Model || entry.Model == config.Model {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	if len(entries) == 0 {
+		fmt.Println("No runs found")
+		return nil
+	}
+
+	sortLeaderboard(entries)
+	printLeaderboard(entries)
+
+	if config.OutputPath != "" {
+		if err := exportLeaderboard(config, entries); err != nil {
+			return fmt.Errorf("exporting leaderboard: %w", err)
+		}
+	}
+	return nil
+}
+
+// collectLeaderboardEntries finds the run output directories under root, and summarizes
+// each LLM config in each run. Runs whose date is unknown are skipped with a warning.
+func collectLeaderboardEntries(root string) ([]leaderboardEntry, error) {
+	var entries []leaderboardEntry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		_, resultsErr := os.Stat(filepath.Join(path, runResultsFile))
+		_, metadataErr := os.Stat(filepath.Join(path, runMetadataFile))
+		if resultsErr != nil && metadataErr != nil {
+			return nil
+		}
+
+		runEntries, err := summarizeRun(path)
+		if err != nil {
+			fmt.Printf("Warning: skipping run %s: %v\n", path, err)
+		}
+		entries = append(entries, runEntries...)
+		// Task output directories are not runs.
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding runs in %s: %w", root, err)
+	}
+	return entries, nil
+}
+
+// summarizeRun returns the leaderboard entries of the run in dir.
+func summarizeRun(dir string) ([]leaderboardEntry, error) {
+	runResults, err := loadRunResults(dir)
+	if err != nil {
+		return nil, err
+	}
+	// The run metadata is preferred, falling back to results.json for runs without it.
+	if data, err := os.ReadFile(filepath.Join(dir, runMetadataFile)); err == nil {
+		var metadata model.RunMetadata
+		if err := yaml.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("parsing run metadata: %w", err)
+		}
+		if metadata.RunID != "" {
+			runResults.RunID = metadata.RunID
+		}
+		if !metadata.StartTime.IsZero() {
+			runResults.StartTime = metadata.StartTime
+		}
+	}
+	date := runResults.StartTime
+	if date.IsZero() && len(runResults.RunID) >= len(runIDTimeFormat) {
+		// Run IDs start with the time the run started.
+		date, _ = time.ParseInLocation(runIDTimeFormat, runResults.RunID[:len(runIDTimeFormat)], time.Local)
+	}
+	if date.IsZero() {
+		return nil, fmt.Errorf("run date is unknown")
+	}
+
+	models := make(map[string]string)
+	for _, result := range runResults.Results {
+		models[result.ConfigID()] = result.LLMConfig.ModelID
+	}
+	var entries []leaderboardEntry
+	for _, summary := range summarizeLLMConfigs(runResults.Results) {
+		entries = append(entries, leaderboardEntry{
+			RunID:     runResults.RunID,
+			RunDir:    dir,
+			Date:      date,
+			LLMConfig: summary.ID,
+			Model:     models[summary.ID],
+			Total:     summary.Total - summary.Skipped,
+			Success:   summary.Success,
+			PassRate:  summary.PassRate,
+			MeanScore: summary.MeanScore,
+		})
+	}
+	return entries, nil
+}
+
+// sortLeaderboard sorts the entries by the pass rate of their LLM config in its latest run
+// (best first), then by LLM config ID, with the runs of each LLM config latest first.
+func sortLeaderboard(entries []leaderboardEntry) {
+	latest := make(map[string]leaderboardEntry)
+	for _, entry := range entries {
+		if l, ok := latest[entry.LLMConfig]; !ok || entry.Date.After(l.Date) {
+			latest[entry.LLMConfig] = entry
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.LLMConfig != b.LLMConfig {
+			if latest[a.LLMConfig].PassRate != latest[b.LLMConfig].PassRate {
+				return latest[a.LLMConfig].PassRate > latest[b.LLMConfig].PassRate
+			}
+			return a.LLMConfig < b.LLMConfig
+		}
+		return a.Date.After(b.Date)
+	})
+}
+
+func printLeaderboard(entries []leaderboardEntry) {
+	fmt.Println("| LLM Config | Run Date | Run ID | Passed | Total | Pass Rate | Mean Score |")
+	fmt.Println("|------------|----------|--------|--------|-------|-----------|------------|")
+	for _, entry := range entries {
+		fmt.Printf("| %s | %s | %s | %d | %d | %d%% | %.2f |\n",
+			entry.LLMConfig, entry.Date.Format("2006-01-02 15:04"), entry.RunID,
+			entry.Success, entry.Total, calculatePercentage(entry.Success, entry.Total), entry.MeanScore)
+	}
+}
+
+func exportLeaderboard(config LeaderboardConfig, entries []leaderboardEntry) error {
+	f, err := os.Create(config.OutputPath)
+	if err != nil {
+		return fmt.Errorf("creating file %q: %w", config.OutputPath, err)
+	}
+	defer f.Close()
+
+	switch config.OutputFormat {
+	case "json":
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return err
+		}
+	case "csv":
+		w := csv.NewWriter(f)
+		w.Write([]string{"llm_config", "model", "date", "run_id", "run_dir", "passed", "total", "pass_rate", "mean_score"})
+		for _, entry := range entries {
+			w.Write([]string{
+				entry.LLMConfig,
+				entry.Model,
+				entry.Date.Format(time.RFC3339),
+				entry.RunID,
+				entry.RunDir,
+				strconv.Itoa(entry.Success),
+				strconv.Itoa(entry.Total),
+				strconv.FormatFloat(entry.PassRate, 'f', 4, 64),
+				strconv.FormatFloat(entry.MeanScore, 'f', 4, 64),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,177 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// ProviderLimit limits the tasks that run with an LLM config or provider, to stay within its quota.
+type ProviderLimit struct {
+	// MaxConcurrent is the number of tasks that may run at the same time (-1 for no limit).
+	// A limit of 0 runs no tasks: they are reported as skipped.
+	MaxConcurrent int `json:"maxConcurrent"`
+	// PerMinute is the number of tasks that may start per minute (0 for no limit).
+	PerMinute float64 `json:"perMinute,omitempty"`
+}
+
+// parseProviderLimits parses --provider-limit NAME=MAX and --provider-rate NAME=PER_MINUTE values.
+func parseProviderLimits(maxConcurrent, perMinute []string) (map[string]ProviderLimit, error) {
+	limits := make(map[string]ProviderLimit)
+	concurrent, err := parseKeyValues(maxConcurrent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --provider-limit: %w", err)
+	}
+	for name, value := range concurrent {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid --provider-limit for %s: %q is not a number of tasks", name, value)
+		}
+		limits[name] = ProviderLimit{MaxConcurrent: n}
+	}
+	rates, err := parseKeyValues(perMinute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --provider-rate: %w", err)
+	}
+	for name, value := range rates {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid --provider-rate for %s: %q is not a positive number of tasks per minute", name, value)
+		}
+		limit, ok := limits[name]
+		if !ok {
+			limit.MaxConcurrent = -1
+		}
+		limit.PerMinute = rate
+		limits[name] = limit
+	}
+	return limits, nil
+}
+
+// providerLimiter enforces a ProviderLimit on the tasks of the run.
+type providerLimiter struct {
+	name  string
+	limit ProviderLimit
+	// slots holds a token for each running task, if the concurrency is limited.
+	slots chan struct{}
+
+	mutex sync.Mutex
+	// tokens and refilled are the state of the token bucket of PerMinute, which holds up to a minute
+	// of starts (and at least one).
+	tokens   float64
+	refilled time.Time
+}
+
+func newProviderLimiter(name string, limit ProviderLimit) *providerLimiter {
+	l := &providerLimiter{name: name, limit: limit}
+	if limit.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	if limit.PerMinute > 0 {
+		l.tokens = 1
+		l.refilled = time.Now()
+	}
+	return l
+}
+
+// blocked reports whether the limiter runs no tasks at all.
+func (l *providerLimiter) blocked() bool {
+	return l != nil && l.limit.MaxConcurrent == 0
+}
+
+// available reports whether a task could start without waiting for a running task to finish.
+// A nil limiter is always available.
+func (l *providerLimiter) available() bool {
+	return l == nil || l.slots == nil || len(l.slots) < cap(l.slots)
+}
+
+// acquire waits until a task may start within the limits, and returns the function
+// that releases its slot when the task is done. A nil limiter does not wait.
+func (l *providerLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+	if err := l.waitForRate(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// waitForRate takes a token from the bucket, waiting for one to be refilled if it is empty.
+func (l *providerLimiter) waitForRate(ctx context.Context) error {
+	if l.limit.PerMinute <= 0 {
+		return nil
+	}
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		l.tokens = min(max(l.limit.PerMinute, 1), l.tokens+now.Sub(l.refilled).Minutes()*l.limit.PerMinute)
+		l.refilled = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.limit.PerMinute * float64(time.Minute))
+		l.mutex.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// providerLimiters are the limiters of the run, by the LLM config ID or provider ID they apply to.
+type providerLimiters map[string]*providerLimiter
+
+func newProviderLimiters(limits map[string]ProviderLimit) providerLimiters {
+	limiters := make(providerLimiters, len(limits))
+	for name, limit := range limits {
+		limiters[name] = newProviderLimiter(name, limit)
+	}
+	return limiters
+}
+
+// forConfig returns the limiter of the LLM config: the one for its ID, if any, otherwise the one
+// for its provider, or nil if it is not limited.
+func (l providerLimiters) forConfig(llmConfig model.LLMConfig) *providerLimiter {
+	if limiter, ok := l[llmConfig.ID]; ok {
+		return limiter
+	}
+	return l[llmConfig.ProviderID]
+}
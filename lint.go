@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runLint() error {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lint [tasks-dir...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Check every task definition in the tasks directories (default ./tasks), including disabled tasks,\n")
+		fmt.Fprintf(os.Stderr, "and print all problems found. Exits non-zero if there are any.\n")
+	}
+	flag.Parse()
+	tasksDirs := flag.Args()
+	if len(tasksDirs) == 0 {
+		tasksDirs = []string{"./tasks"}
+	}
+	tasksDir := strings.Join(tasksDirs, ", ")
+
+	problems, checked, err := lintTasks(tasksDirs)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Printf("Checked %d tasks in %s, no problems found\n", checked, tasksDir)
+		return nil
+	}
+	fmt.Print(formatLintProblems(problems))
+	return fmt.Errorf("found problems in %d of %d tasks in %s", len(problems), checked, tasksDir)
+}
+
+// lintTasks checks every task in the tasks directories, including disabled ones, and returns their
+// problems by task ID (or directory, for a task file that cannot be parsed), and the number of tasks checked.
+func lintTasks(tasksDirs []string) (map[string][]string, int, error) {
+	// Directories without a task file (e.g. shared scripts) are not tasks.
+	dirs, err := findTaskDirs(tasksDirs)
+	if err != nil {
+		return nil, 0, err
+	}
+	problems := make(map[string][]string)
+	checked := 0
+	for _, dir := range dirs {
+		checked++
+		data, err := os.ReadFile(filepath.Join(dir.path(), taskFile))
+		if err != nil {
+			problems[dir.id] = []string{err.Error()}
+			continue
+		}
+		instances, err := expandTask(dir.id, data)
+		if err != nil {
+			problems[dir.id] = []string{err.Error()}
+			continue
+		}
+		checked += len(instances) - 1
+		for taskID, task := range instances {
+			task.root = dir.root
+			if taskProblems := lintTask(task.root, task); len(taskProblems) > 0 {
+				problems[taskID] = taskProblems
+			}
+		}
+	}
+	return problems, checked, nil
+}
+
+// lintTask returns all the problems of the task that can be found without running it.
+func lintTask(tasksDir string, task Task) []string {
+	var problems []string
+	for _, err := range []error{task.Validate(), task.checkPaths(tasksDir), task.checkFiles(tasksDir)} {
+		problems = append(problems, errorMessages(err)...)
+	}
+	return problems
+}
+
+// errorMessages returns the messages of the errors joined in err, or nil if err is nil.
+func errorMessages(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var messages []string
+		for _, err := range joined.Unwrap() {
+			messages = append(messages, errorMessages(err)...)
+		}
+		return messages
+	}
+	return []string{err.Error()}
+}
+
+// checkFiles checks that the scripts of the task are executable files, and that its
+// setup manifests and prompt files exist.
+func (t *Task) checkFiles(tasksDir string) error {
+	taskDir := filepath.Join(tasksDir, filepath.FromSlash(t.dir))
+	var errs []error
+	scripts := t.verifierScripts()
+	for _, script := range []*ScriptRef{t.Setup, t.Cleanup} {
+		if script != nil {
+			scripts = append(scripts, *script)
+		}
+	}
+	for _, script := range scripts {
+		path, err := resolveTaskPath(tasksDir, taskDir, script.Script)
+		if err != nil {
+			// Reported by checkPaths.
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("script %q not found", script.Script))
+		} else if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			errs = append(errs, fmt.Errorf("script %q is not an executable file", script.Script))
+		}
+	}
+	for _, manifest := range t.SetupManifests {
+		path, err := resolveTaskPath(tasksDir, taskDir, manifest)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("setup manifest %q not found", manifest))
+		}
+	}
+	for i, step := range t.Script {
+		if step.PromptFile == "" {
+			continue
+		}
+		path := step.PromptFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(taskDir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("prompt file %q of script step %d not found", step.PromptFile, i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formatLintProblems lists the problems grouped by task, in task order.
+func formatLintProblems(problems map[string][]string) string {
+	var b strings.Builder
+	for _, taskID := range sortedKeys(problems) {
+		fmt.Fprintf(&b, "%s:\n", taskID)
+		for _, problem := range problems[taskID] {
+			fmt.Fprintf(&b, "  - %s\n", problem)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// llmConfigsFile is the format of the --llm-configs file. Defaults are merged into each of
+// the configs, and into the configs expanded from the matrix.
+type llmConfigsFile struct {
+	// Defaults holds LLMConfig fields that apply to every config that does not set them.
+	Defaults map[string]any `json:"defaults,omitempty"`
+	// Configs are LLMConfig entries.
+	Configs []map[string]any `json:"configs,omitempty"`
+	// Matrix entries expand to a config for each of their providers with each of their models,
+	// with IDs like gemini/gemini-2.0-flash.
+	Matrix []llmConfigMatrix `json:"matrix,omitempty"`
+}
+
+type llmConfigMatrix struct {
+	Providers []string `json:"providers"`
+	Models    []string `json:"models"`
+}
+
+// loadLLMConfigs reads the LLM configs of the run from a --llm-configs file.
+func loadLLMConfigs(path string) ([]model.LLMConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file llmConfigsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	entries := file.Configs
+	for _, matrix := range file.Matrix {
+		if len(matrix.Providers) == 0 || len(matrix.Models) == 0 {
+			return nil, fmt.Errorf("%s: matrix entries need providers and models", path)
+		}
+		for _, provider := range matrix.Providers {
+			for _, modelID := range matrix.Models {
+				entries = append(entries, map[string]any{
+					"id":       provider + "/" + modelID,
+					"provider": provider,
+					"model":    modelID,
+				})
+			}
+		}
+	}
+
+	var llmConfigs []model.LLMConfig
+	for i, entry := range entries {
+		merged, err := json.Marshal(mergeFields(file.Defaults, entry))
+		if err != nil {
+			return nil, err
+		}
+		var llmConfig model.LLMConfig
+		if err := yaml.UnmarshalStrict(merged, &llmConfig); err != nil {
+			return nil, fmt.Errorf("%s: LLM config %d: %w", path, i+1, err)
+		}
+		if llmConfig.ID == "" {
+			if llmConfig.ProviderID == "" || llmConfig.ModelID == "" {
+				return nil, fmt.Errorf("%s: LLM config %d needs an id, or a provider and model", path, i+1)
+			}
+			llmConfig.ID = llmConfig.ProviderID + "/" + llmConfig.ModelID
+		}
+		llmConfigs = append(llmConfigs, llmConfig)
+	}
+	if len(llmConfigs) == 0 {
+		return nil, fmt.Errorf("%s has no LLM configs", path)
+	}
+	return llmConfigs, nil
+}
+
+// mergeFields returns the fields of defaults overridden by those of fields.
+// Maps set in both (like env) are merged the same way.
+func mergeFields(defaults, fields map[string]any) map[string]any {
+	merged := make(map[string]any, len(defaults)+len(fields))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		defaultMap, ok1 := merged[k].(map[string]any)
+		fieldMap, ok2 := v.(map[string]any)
+		if ok1 && ok2 {
+			v = mergeFields(defaultMap, fieldMap)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// validateLLMConfigs checks that the LLM configs have unique IDs and valid idle markers.
+func validateLLMConfigs(llmConfigs []model.LLMConfig) error {
+	seen := make(map[string]bool)
+	for _, llmConfig := range llmConfigs {
+		if llmConfig.ID == "" {
+			return fmt.Errorf("LLM config for %s %s has no ID", llmConfig.ProviderID, llmConfig.ModelID)
+		}
+		if seen[llmConfig.ID] {
+			return fmt.Errorf("duplicate LLM config ID %q", llmConfig.ID)
+		}
+		seen[llmConfig.ID] = true
+		if llmConfig.IdleMarker != "" {
+			if _, err := regexp.Compile(llmConfig.IdleMarker); err != nil {
+				return fmt.Errorf("LLM config %s: invalid idleMarker: %w", llmConfig.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// printLLMConfigs logs the LLM configs the run evaluates.
+func printLLMConfigs(llmConfigs []model.LLMConfig) {
+	for _, llmConfig := range llmConfigs {
+		details := []string{"provider " + llmConfig.ProviderID, "model " + llmConfig.ModelID}
+		if llmConfig.EnableToolUseShim {
+			details = append(details, "tool use shim")
+		}
+		if llmConfig.McpClient {
+			details = append(details, "MCP client")
+		}
+		if llmConfig.Temperature != nil {
+			details = append(details, fmt.Sprintf("temperature %g", *llmConfig.Temperature))
+		}
+		if llmConfig.MaxTokens > 0 {
+			details = append(details, fmt.Sprintf("max tokens %d", llmConfig.MaxTokens))
+		}
+		if len(llmConfig.ExtraArgs) > 0 {
+			details = append(details, "args "+strings.Join(llmConfig.ExtraArgs, " "))
+		}
+		if len(llmConfig.Env) > 0 {
+			names, _ := llmConfig.Env.MarshalJSON()
+			details = append(details, "env "+string(names))
+		}
+		slog.Info("LLM config", "id", llmConfig.ID, "details", strings.Join(details, ", "))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// envFilePrefix marks LLM config env values that are read from a file, e.g. file:/secrets/openai-key.
+const envFilePrefix = "file:"
+
+// resolveLLMEnv resolves the Env of the LLM config to KEY=value entries for the agent:
+// ${VAR} references are expanded from the environment, and file:<path> values are read
+// from the file (without a trailing newline). Variables that are referenced but not set
+// are an error, so a missing key is not passed to the agent as empty.
+func resolveLLMEnv(llmConfig model.LLMConfig) ([]string, error) {
+	var env []string
+	for _, name := range slices.Sorted(maps.Keys(llmConfig.Env)) {
+		value := llmConfig.Env[name]
+		if file, ok := strings.CutPrefix(value, envFilePrefix); ok {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("LLM config %s: reading env %s: %w", llmConfig.ID, name, err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		} else {
+			var missing []string
+			value = os.Expand(value, func(ref string) string {
+				v, ok := os.LookupEnv(ref)
+				if !ok {
+					missing = append(missing, ref)
+				}
+				return v
+			})
+			if len(missing) > 0 {
+				return nil, fmt.Errorf("LLM config %s: env %s references unset variables %s", llmConfig.ID, name, strings.Join(missing, ", "))
+			}
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
+
+// resolveLLMEnvs resolves the Env of each LLM config, by ID. The values are added to the
+// redactor, so they are masked wherever they appear in logs and results.
+func resolveLLMEnvs(llmConfigs []model.LLMConfig, r *redactor) (map[string][]string, error) {
+	envs := make(map[string][]string)
+	for _, llmConfig := range llmConfigs {
+		env, err := resolveLLMEnv(llmConfig)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range env {
+			_, value, _ := strings.Cut(kv, "=")
+			r.add(value)
+		}
+		envs[llmConfig.ID] = env
+	}
+	return envs, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultMaxLogBytes caps each task log file.
+	defaultMaxLogBytes = 50 << 20
+	// defaultMaxLogBufferBytes caps the in-memory tail of the task log, used in error messages.
+	defaultMaxLogBufferBytes = 1 << 20
+)
+
+// limitedWriter writes up to limit bytes to w, then drops further writes after a single
+// truncation marker. Writes never fail because of the limit, so the writers next to it
+// in an io.MultiWriter still get everything.
+type limitedWriter struct {
+	mutex     sync.Mutex
+	w         io.Writer
+	limit     int64
+	written   int64
+	truncated bool
+}
+
+func newLimitedWriter(w io.Writer, limit int64) *limitedWriter {
+	return &limitedWriter{w: w, limit: limit}
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.truncated {
+		return len(p), nil
+	}
+	if w.limit > 0 && w.written+int64(len(p)) > w.limit {
+		n, err := w.w.Write(p[:w.limit-w.written])
+		w.written += int64(n)
+		w.truncated = true
+		fmt.Fprintf(w.w, "\n... log truncated at %d bytes\n", w.limit)
+		if err != nil {
+			return n, err
+		}
+		return len(p), nil
+	}
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Truncated reports whether writes were dropped.
+func (w *limitedWriter) Truncated() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.truncated
+}
+
+// tailBuffer keeps the last limit bytes written to it, rotating out older content.
+type tailBuffer struct {
+	mutex     sync.Mutex
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.data = append(b.data, p...)
+	// Compact once the buffer holds twice the limit, so rotation is amortized.
+	if b.limit > 0 && len(b.data) > 2*b.limit {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.limit:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the retained content. Once older content was rotated out,
+// the partial first line is dropped, so the content starts at a line boundary.
+func (b *tailBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data := b.data
+	truncated := b.truncated
+	if b.limit > 0 && len(data) > b.limit {
+		data = data[len(data)-b.limit:]
+		truncated = true
+	}
+	s := string(data)
+	if truncated {
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return s
+}
+
+// Truncated reports whether older content was rotated out.
+func (b *tailBuffer) Truncated() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.truncated || (b.limit > 0 && len(b.data) > b.limit)
+}
@@ -16,16 +16,30 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/gke"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/kind"
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster/vcluster"
+	"github.com/gke-labs/k8s-ai-bench/pkg/judge"
 	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"github.com/gke-labs/k8s-ai-bench/pkg/runlog"
 	"sigs.k8s.io/yaml"
 )
 
@@ -38,20 +52,255 @@ const (
 )
 
 type Task struct {
-	Setup      string `json:"setup,omitempty"`
-	Verifier   string `json:"verifier,omitempty"`
-	Cleanup    string `json:"cleanup,omitempty"`
-	Difficulty string `json:"difficulty"`
-	Disabled   bool   `json:"disabled,omitempty"`
-	Timeout    string `json:"timeout,omitempty"`
+	Setup      *ScriptRef `json:"setup,omitempty"`
+	Verifier   *ScriptRef `json:"verifier,omitempty"`
+	Cleanup    *ScriptRef `json:"cleanup,omitempty"`
+	Difficulty string     `json:"difficulty"`
+	Category   string     `json:"category,omitempty"`
+	Disabled   bool       `json:"disabled,omitempty"`
+	Timeout    string     `json:"timeout,omitempty"`
+
+	// DisabledReason explains why the task is disabled, in its skipped results.
+	DisabledReason string `json:"disabledReason,omitempty"`
+
+	// SetupManifests are files or directories (relative to the task directory) applied before the setup script.
+	SetupManifests []string `json:"setupManifests,omitempty"`
+	// WaitFor lists objects that must become ready after the setup manifests are applied.
+	WaitFor []WaitFor `json:"waitFor,omitempty"`
+
+	// Env is added to the environment of the setup, agent, verifier and cleanup commands.
+	// ${TASK_DIR} and ${TASK_OUTPUT_DIR} are expanded in the values.
+	Env map[string]string `json:"env,omitempty"`
+
+	// DependsOn lists tasks that must complete before this task starts, when running on a shared cluster.
+	// If a dependency does not pass, this task is skipped.
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Tags categorize the task, for selecting tasks with --include-tags and --exclude-tags.
+	Tags []string `json:"tags,omitempty"`
+
+	// Retries is the number of times to retry the task if an attempt fails.
+	// If not set, the --task-retries default is used.
+	Retries *int `json:"retries,omitempty"`
+	// RetryPolicy determines the final result when a task is attempted multiple times.
+	RetryPolicy RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Verifiers is a list of verifier scripts, run in order after the agent finishes.
+	// Verifier is kept as a shorthand for a single verifier and runs first.
+	Verifiers []ScriptRef `json:"verifiers,omitempty"`
+	// VerifierPolicy determines whether all verifiers or any verifier must pass.
+	VerifierPolicy VerifierPolicy `json:"verifierPolicy,omitempty"`
+	// VerifyRetry re-runs the verifiers and checks until they pass, for state that
+	// takes a while to converge after the agent finishes (e.g. a rollout).
+	VerifyRetry *VerifyRetry `json:"verifyRetry,omitempty"`
+	// VerifyAfterTimeout runs the verifiers and checks when the agent hits the task timeout,
+	// so a task that was solved before the agent was cut short passes (default true). Set it to
+	// false for tasks where finishing in time is the point.
+	VerifyAfterTimeout *bool `json:"verifyAfterTimeout,omitempty"`
 
 	Expect []Expectation `json:"expect,omitempty"`
 
+	// Checks are declarative assertions about cluster state, evaluated after the agent finishes.
+	// If set, they must pass in addition to the verifiers or expectations.
+	Checks []ClusterCheck `json:"checks,omitempty"`
+
+	// Judge grades the agent transcript against a rubric using an LLM.
+	// If set, the verdict must pass in addition to any other verification.
+	Judge *JudgeSpec `json:"judge,omitempty"`
+
 	Script []ScriptStep `json:"script,omitempty"`
 
 	// Isolation can be set to automatically create an isolated cluster
 	// TODO: support namespaces also
 	Isolation IsolationMode `json:"isolation,omitempty"`
+
+	// Namespaces are the namespaces the task creates or changes, and ClusterResources the
+	// cluster-scoped resources it does, as kind/name (e.g. clusterrole/pod-reader). On the shared
+	// cluster, tasks whose declarations overlap do not run at the same time; tasks that declare
+	// neither may touch anything, so they run alone.
+	Namespaces       []string `json:"namespaces,omitempty"`
+	ClusterResources []string `json:"clusterResources,omitempty"`
+
+	// Clusters names the isolated clusters of a task that needs several (e.g. [primary, secondary]),
+	// created in parallel. The setup, agent and verifiers get the path to the kubeconfig of each as
+	// KUBECONFIG_<NAME> (e.g. KUBECONFIG_PRIMARY), and KUBECONFIG is that of the first.
+	// Requires isolation: cluster.
+	Clusters []string `json:"clusters,omitempty"`
+
+	// WorkerNodes overrides the number of worker nodes for the isolated cluster,
+	// for providers that support it (e.g. kind).
+	WorkerNodes int `json:"workerNodes,omitempty"`
+
+	// Diagnostics are extra shell commands whose output is collected into the diagnostics
+	// directory when the task fails, in addition to the standard bundle (objects, events, pod logs).
+	Diagnostics []string `json:"diagnostics,omitempty"`
+
+	// KeepOnFailure keeps the isolated cluster when the task fails, for debugging (see --keep-cluster-on-failure).
+	KeepOnFailure bool `json:"keepOnFailure,omitempty"`
+
+	// Images is a list of local container images to load into the task's cluster
+	// before the setup script runs (e.g. locally built images for the agent to debug).
+	Images []string `json:"images,omitempty"`
+
+	// Matrix expands the task into one instance per combination of values.
+	// The values are available as {{.name}} template variables in task.yaml and prompt files.
+	Matrix map[string][]string `json:"matrix,omitempty"`
+
+	// RBAC restricts the agent to the permissions of a ServiceAccount, created after the setup
+	// script, instead of the admin kubeconfig that the setup, verifiers and checks keep using.
+	RBAC *RBAC `json:"rbac,omitempty"`
+
+	// VCluster overrides the vcluster options for this task's isolated cluster.
+	// A relative valuesFile is resolved against the task directory.
+	VCluster *vcluster.ClusterOptions `json:"vcluster,omitempty"`
+
+	// dir is the directory of the task relative to root, its tasks directory, with forward slashes.
+	dir  string
+	root string
+	// vars are the matrix values of this task instance.
+	vars map[string]string
+}
+
+type RetryPolicy string
+
+const (
+	// RetryPolicyAny retries attempts that did not pass, whatever failed them (verifiers, checks,
+	// expectations, the judge or an error), and passes if any attempt passes. This is the default.
+	RetryPolicyAny RetryPolicy = "any"
+	// RetryPolicyAll repeats the task after each passing attempt, up to the retries, and passes only
+	// if all attempts pass. Nothing is retried: the first attempt that does not pass decides the result.
+	RetryPolicyAll RetryPolicy = "all"
+)
+
+type VerifierPolicy string
+
+const (
+	// VerifierPolicyAll passes only if all verifiers pass. This is the default.
+	VerifierPolicyAll VerifierPolicy = "all"
+	// VerifierPolicyAny passes if any verifier passes.
+	VerifierPolicyAny VerifierPolicy = "any"
+)
+
+// clusterNamePattern matches the names of the clusters of a task.
+var clusterNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Validate checks the task for errors that can be detected before running it, and returns all of them joined.
+func (t *Task) Validate() error {
+	var errs []error
+	if _, ok := t.Env["KUBECONFIG"]; ok {
+		errs = append(errs, fmt.Errorf("env must not set KUBECONFIG, it is set to the task's kubeconfig"))
+	}
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("parsing timeout: %w", err))
+		}
+	}
+	switch t.Isolation {
+	case "", IsolationModeCluster:
+	default:
+		errs = append(errs, fmt.Errorf("invalid isolation %q, must be %q or unset", t.Isolation, IsolationModeCluster))
+	}
+	if len(t.Clusters) > 0 {
+		if t.Isolation != IsolationModeCluster {
+			errs = append(errs, fmt.Errorf("clusters requires isolation %q", IsolationModeCluster))
+		}
+		if t.RBAC != nil {
+			errs = append(errs, fmt.Errorf("rbac is not supported with clusters"))
+		}
+	}
+	seenClusters := make(map[string]bool)
+	for _, name := range t.Clusters {
+		if !clusterNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid cluster name %q, must be lowercase alphanumeric characters or '-'", name))
+		}
+		if seenClusters[name] {
+			errs = append(errs, fmt.Errorf("duplicate cluster name %q", name))
+		}
+		seenClusters[name] = true
+		if _, ok := t.Env[clusterKubeConfigVar(name)]; ok {
+			errs = append(errs, fmt.Errorf("env must not set %s, it is set to the kubeconfig of cluster %s", clusterKubeConfigVar(name), name))
+		}
+	}
+	for i, expect := range t.Expect {
+		if err := expect.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid expectation %d: %w", i, err))
+		}
+	}
+	for i, step := range t.Script {
+		if step.Command != "" && (step.Prompt != "" || step.PromptFile != "") {
+			errs = append(errs, fmt.Errorf("script step %d specifies both command and a prompt", i+1))
+		}
+		switch step.OnError {
+		case "", CommandStepOnErrorFail, CommandStepOnErrorContinue:
+		default:
+			errs = append(errs, fmt.Errorf("invalid onError %q of script step %d", step.OnError, i+1))
+		}
+		if step.WaitFor != "" {
+			if _, err := regexp.Compile(step.WaitFor); err != nil {
+				errs = append(errs, fmt.Errorf("invalid waitFor of script step %d: %w", i+1, err))
+			}
+		}
+		if step.WaitTimeout != "" {
+			if _, err := time.ParseDuration(step.WaitTimeout); err != nil {
+				errs = append(errs, fmt.Errorf("parsing waitTimeout of script step %d: %w", i+1, err))
+			}
+		}
+		for j, expect := range step.Expect {
+			if err := expect.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid expectation %d of script step %d: %w", j, i+1, err))
+			}
+		}
+	}
+	for i, check := range t.Checks {
+		if err := check.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid check %d: %w", i, err))
+		}
+	}
+	for _, wait := range t.WaitFor {
+		if err := wait.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if t.RBAC != nil {
+		if err := t.RBAC.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid rbac: %w", err))
+		}
+	}
+	if t.VerifyRetry != nil {
+		if _, _, err := t.VerifyRetry.durations(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch t.RetryPolicy {
+	case "", RetryPolicyAny, RetryPolicyAll:
+	default:
+		errs = append(errs, fmt.Errorf("invalid retryPolicy %q, must be %q or %q", t.RetryPolicy, RetryPolicyAny, RetryPolicyAll))
+	}
+	switch t.VerifierPolicy {
+	case "", VerifierPolicyAll, VerifierPolicyAny:
+	default:
+		errs = append(errs, fmt.Errorf("invalid verifierPolicy %q", t.VerifierPolicy))
+	}
+	for _, ns := range t.Namespaces {
+		if ns == "" {
+			errs = append(errs, fmt.Errorf("namespaces must not contain empty names"))
+		}
+	}
+	for _, resource := range t.ClusterResources {
+		if kind, name, ok := strings.Cut(resource, "/"); !ok || kind == "" || name == "" {
+			errs = append(errs, fmt.Errorf("invalid clusterResources entry %q, must be kind/name", resource))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifierScripts returns the verifier scripts of the task, in the order they should run.
+func (t *Task) verifierScripts() []ScriptRef {
+	var verifiers []ScriptRef
+	if t.Verifier != nil {
+		verifiers = append(verifiers, *t.Verifier)
+	}
+	return append(verifiers, t.Verifiers...)
 }
 
 type IsolationMode string
@@ -62,10 +311,44 @@ const (
 )
 
 type ScriptStep struct {
+	// Prompt (or the contents of PromptFile) is a template rendered when it is sent, with the
+	// variables {{.TaskID}}, {{.TaskDir}}, {{.TaskOutputDir}}, {{.KubeConfig}}, {{.Namespace}} (the
+	// first of Namespaces), the matrix values, and the variables the setup script wrote to
+	// $TASK_OUTPUT_DIR/vars.env (KEY=VALUE lines) or vars.yaml. An unknown variable fails the task.
+	// In matrix tasks, variables of the setup script must be escaped in inline prompts
+	// ({{"{{.Port}}"}}), as task.yaml is rendered with the matrix values first.
 	Prompt     string `json:"prompt"`
 	PromptFile string `json:"promptFile"`
+
+	// Command is a shell command run by the framework instead of sending a prompt, e.g. to inject
+	// a fault while the agent is running. It runs in the task directory with the task's kubeconfig.
+	Command string `json:"command,omitempty"`
+	// OnError determines what happens when Command fails, defaulting to failing the task.
+	OnError CommandStepOnError `json:"onError,omitempty"`
+
+	// Expect is checked against the agent output for this step: the output produced after
+	// the step's prompt was sent, up to the next step's prompt (or the end of the run).
+	// Expectations on stream stderr are checked against the agent's whole stderr.
+	Expect []Expectation `json:"expect,omitempty"`
+
+	// WaitFor is a regular expression (e.g. the agent's input prompt) that must appear in the
+	// agent output before this step's prompt is sent, defaulting to the LLM config's idle marker,
+	// then --step-ready-pattern, then waiting for --step-idle-time of silence.
+	WaitFor string `json:"waitFor,omitempty"`
+	// WaitTimeout bounds the wait before the step is sent (default 5m). The task fails if WaitFor does
+	// not appear, and is an error if the agent is never detected idle (by its idle marker or silence).
+	WaitTimeout string `json:"waitTimeout,omitempty"`
 }
 
+type CommandStepOnError string
+
+const (
+	// CommandStepOnErrorFail fails the task, and stops sending script steps. This is the default.
+	CommandStepOnErrorFail CommandStepOnError = "fail"
+	// CommandStepOnErrorContinue logs the failure, and continues with the next script step.
+	CommandStepOnErrorContinue CommandStepOnError = "continue"
+)
+
 // ResolvePrompt resolves the prompt from either inline or file source
 func (s *ScriptStep) ResolvePrompt(baseDir string) (string, error) {
 	// Fail if both prompt and promptFile are provided to avoid confusion
@@ -98,9 +381,31 @@ func (s *ScriptStep) ResolvePrompt(baseDir string) (string, error) {
 	return "", fmt.Errorf("neither 'prompt' nor 'promptFile' is specified in script step")
 }
 
-type Expectation struct {
-	Contains    string `json:"contains,omitempty"`
-	NotContains string `json:"notContains,omitempty"`
+type JudgeSpec struct {
+	Rubric     string `json:"rubric,omitempty"`
+	RubricFile string `json:"rubricFile,omitempty"`
+}
+
+// ResolveRubric resolves the rubric from either inline or file source
+func (j *JudgeSpec) ResolveRubric(baseDir string) (string, error) {
+	if j.Rubric != "" && j.RubricFile != "" {
+		return "", fmt.Errorf("both 'rubric' and 'rubricFile' are specified in judge; only one should be provided")
+	}
+	if j.RubricFile != "" {
+		rubricPath := j.RubricFile
+		if !filepath.IsAbs(rubricPath) {
+			rubricPath = filepath.Join(baseDir, j.RubricFile)
+		}
+		content, err := os.ReadFile(rubricPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read rubric file %q: %w", rubricPath, err)
+		}
+		return string(content), nil
+	}
+	if j.Rubric != "" {
+		return j.Rubric, nil
+	}
+	return "", fmt.Errorf("neither 'rubric' nor 'rubricFile' is specified in judge")
 }
 
 type EvalConfig struct {
@@ -115,7 +420,246 @@ type EvalConfig struct {
 	HostClusterContext    string
 	HostClusterKubeConfig string
 
+	// TasksDirs are more tasks directories, whose tasks are run with those of TasksDir (--tasks-dir
+	// can be repeated). Task IDs are the paths of task directories relative to their tasks directory.
+	TasksDirs []string
+
+	// ConfigFile is the --config file the settings were read from, if any.
+	ConfigFile string
+	// LLMConfigsFile is the --llm-configs file the LLMConfigs were read from, if any.
+	LLMConfigsFile string
+
+	// Suite selects the tasks of a suite defined in suites.yaml in the tasks directory.
+	Suite string
+
+	// Shuffle runs the tasks in an order shuffled with Seed, instead of sorted by task ID.
+	Shuffle bool
+	Seed    uint64
+
+	// IncludeTags selects tasks with any of these tags (all tasks if empty).
+	IncludeTags []string
+	// IncludeDisabled runs disabled tasks, instead of reporting them as skipped.
+	IncludeDisabled bool
+	// ExcludeTags skips tasks with any of these tags, even if they match IncludeTags.
+	ExcludeTags []string
+
+	// RunTimeout bounds the whole run (0 for no limit). Tasks are not started unless the longest
+	// task timeout still fits in the remaining time; they are reported as skipped instead.
+	RunTimeout time.Duration
+
+	// KeepClusterOnFailure keeps the isolated clusters of failed tasks, for debugging.
+	KeepClusterOnFailure bool
+
+	// MaxFailures stops running new tasks once this many tasks failed (0 for no limit).
+	// In-flight tasks finish, and the remaining tasks are reported as skipped.
+	MaxFailures int
+	// MaxFailuresIgnoreErrors does not count infrastructure errors towards MaxFailures.
+	MaxFailuresIgnoreErrors bool
+
+	// MaxCost and MaxTotalTokens stop running new tasks once the estimated cost in USD, or the
+	// tokens, of the tasks run so far reach them (0 for no limit), like MaxFailures.
+	MaxCost        float64
+	MaxTotalTokens int
+	// TaskCostCap flags the results of tasks whose estimated cost in USD is above it, to spot
+	// runaway agents (0 for no cap). Flagged tasks are not failed.
+	TaskCostCap float64
+
+	// ReportJUnit is the path to write a JUnit XML report to, if set.
+	ReportJUnit string
+	// ReportHTML is the path to write a self-contained HTML report to, if set.
+	ReportHTML string
+	// VerboseResults prints every result in the console summary, not just the aggregates.
+	VerboseResults bool
+	// ReportCSV is the path to write a CSV file with one row per result to, if set.
+	ReportCSV string
+	// ReportMarkdown is the path to write a Markdown summary to, if set (e.g. $GITHUB_STEP_SUMMARY).
+	ReportMarkdown string
+
+	// Baseline is the results.json (or output directory) of a previous run to compare pass rates with.
+	Baseline string
+	// MaxRegression is the largest drop in pass rate (0 to 1) from the Baseline that does not fail the run.
+	MaxRegression float64
+	// BaselineByCategory also compares the pass rate of each category with the Baseline.
+	BaselineByCategory bool
+
+	// ExitPolicy decides whether the results make the run exit non-zero (see parseExitPolicy).
+	ExitPolicy string
+	exitPolicy exitPolicy
+
+	// Resume loads the results of task and LLM config pairs already completed in OutputDir, instead of running them again.
+	Resume bool
+	// Overwrite allows running into an OutputDir that holds the results of a previous run, removing
+	// the outputs of the task and LLM config pairs being run first.
+	Overwrite bool
+
+	// DryRun loads the tasks and prints the evaluations of the run, without running them.
+	// PlanFile is where the plan is also written, if set.
+	DryRun   bool
+	PlanFile string
+
+	// RerunFailed is the output directory of a previous run; only its failed task and LLM config pairs are run.
+	RerunFailed string
+
+	// Runs is the number of times each task is evaluated with each LLM config, for pass@k.
+	Runs int
+
+	// ConsoleOutput selects what the console shows while tasks run; task logs always get everything.
+	ConsoleOutput ConsoleOutput
+	// MetricsAddr is the address to serve Prometheus metrics of the run on, and MetricsPushgateway
+	// a Pushgateway URL to push the final metrics to, for short-lived jobs.
+	MetricsAddr        string
+	MetricsPushgateway string
+	// metrics records the metrics of the run, if either is set.
+	metrics *runMetrics
+
+	// OTelEndpoint is the OTLP/HTTP endpoint to export OpenTelemetry spans of the run to (none if empty).
+	OTelEndpoint string
+
+	// TransientRetries is how many times in total a task is run again when the agent fails with
+	// an error matching one of TransientPatterns, waiting TransientBackoff (doubled on each retry) first.
+	TransientRetries  int
+	TransientBackoff  time.Duration
+	TransientPatterns []string
+	transientPatterns []*regexp.Regexp
+
+	// ProviderLimits limit the tasks running with each LLM config or provider, by LLM config ID
+	// or provider ID; the limit of an LLM config's ID takes precedence over its provider's.
+	ProviderLimits map[string]ProviderLimit
+
+	// UploadTo is the gs:// or s3:// URL to upload the outputs of the run to, under <run ID>/,
+	// UploadConcurrency files at a time. Upload failures fail the run if UploadFailureFatal is set.
+	UploadTo           string
+	UploadConcurrency  int
+	UploadFailureFatal bool
+
+	// NotifyWebhook is the URL to post a summary of the run to when it ends, in NotifyFormat
+	// (NotifyFormatJSON or NotifyFormatSlack), with a link to the outputs from notifyOutputURL.
+	NotifyWebhook   string
+	NotifyFormat    string
+	NotifyOutputURL string
+	notifyOutputURL *template.Template
+
+	// ProgressInterval is how often the progress of the run is reported (0 to disable).
+	ProgressInterval time.Duration
+
+	// NoColor disables colors in the console output, which are only used on terminals.
+	NoColor bool
+	// LogFormat is the format of the messages of the run itself (runlog.FormatText or runlog.FormatJSON),
+	// and LogLevel the lowest level of them that is printed (error, warn, info or debug).
+	LogFormat string
+	LogLevel  string
+	// color is set if the console output is colored, and consolePrefix starts each line of
+	// the console output of the current task, when tasks run concurrently.
+	color         bool
+	consolePrefix string
+	// configID is the agent and LLM config of the current task (see model.ConfigID), which the
+	// names of its isolated clusters include.
+	configID string
+
+	// AgentStallTimeout stops an agent that produced no output for that long, as an error (0 for no limit).
+	AgentStallTimeout time.Duration
+	// AgentFailExitCodes are the exit codes with which the agent reports that the model failed
+	// the task (e.g. it gave up), rather than an error of the agent itself (see classifyAgentExit).
+	AgentFailExitCodes []int
+
+	// StepReadyPattern is the default waitFor pattern of script steps.
+	StepReadyPattern string
+	// StepIdleTime is how long the agent output must be silent before a script step without a
+	// waitFor pattern or idle marker is sent (0 to send it right away).
+	StepIdleTime time.Duration
+
+	// VClusterReadyTimeout bounds how long to wait for a vcluster API server to become ready.
+	VClusterReadyTimeout time.Duration
+	// VCluster configures the virtual clusters created by the vcluster provider.
+	VCluster vcluster.ClusterOptions
+	// VClusterExposureMode and VClusterIngressExternalIP configure how vcluster API servers are reached.
+	VClusterExposureMode      string
+	VClusterIngressExternalIP string
+	// VClusterNamespacePrefix, VClusterLabels and VClusterAnnotations make vcluster host namespaces identifiable.
+	VClusterNamespacePrefix string
+	VClusterLabels          map[string]string
+	VClusterAnnotations     map[string]string
+
+	// RunID uniquely identifies this evaluation run.
+	RunID string
+
+	// KubeContext is the context within KubeConfig used by the external cluster provider.
+	KubeContext string
+
+	// Kind configures the kind cluster provider.
+	Kind kind.Options
+
+	// MinikubeDriver and KubernetesVersion configure the minikube cluster provider.
+	MinikubeDriver    string
+	KubernetesVersion string
+
+	// GKE configures the gke cluster provider.
+	GKE gke.Options
+
+	// ClusterTimeouts bound each CLI command of the cluster providers, by operation (see cluster.Operations).
+	ClusterTimeouts cluster.Timeouts
+
+	// clusterName is the name of the shared cluster, if it was created or found by the cluster provider.
+	clusterName string
+
+	// ClusterReadyTimeout bounds how long to wait for a cluster to become ready before using it (0 disables the check).
+	ClusterReadyTimeout time.Duration
+
+	// TaskRetries is the default number of retries for tasks that don't set retries.
+	TaskRetries int
+
+	// ResetBetweenTasks resets the shared cluster to its state at the start of the run after each task.
+	ResetBetweenTasks bool
+
+	// CaptureDiff snapshots the DiffResources of the cluster after the setup and after the agent ran,
+	// and writes their diff to cluster-diff.yaml in the task output directory.
+	CaptureDiff   bool
+	DiffResources []string
+	// ResetAllowlist are namespaces that are never deleted by the reset.
+	ResetAllowlist []string
+
+	// Judge selects the model used to grade tasks with a judge rubric.
+	Judge judge.Config
+
+	// MaxLogBytes caps each task log file, and MaxLogBufferBytes the tail of the log kept in memory (0 for no limit).
+	MaxLogBytes       int64
+	MaxLogBufferBytes int64
+
+	// AgentRunner selects how the agent is run: AgentRunnerExec runs AgentBin,
+	// AgentRunnerHTTP sends the prompts to the agent served at AgentHTTP.
+	AgentRunner string
+	AgentHTTP   HTTPAgentConfig
+	// AgentImage, if set, runs the agent of AgentRunnerExec in a container of this image instead
+	// of on the host, configured by AgentContainer. AgentBin is then the agent binary in the image,
+	// defaulting to the image's entrypoint.
+	AgentImage     string
+	AgentContainer ContainerAgentConfig
+	// Agents are the agents to evaluate, each with every LLM config. A single agent (from
+	// --agent-bin) has no ID; results of several agents are reported per agent.
+	Agents []AgentConfig
+	// agentRunners run each of the Agents, by ID, and agent runs the agent of the current task.
+	agentRunners map[string]AgentRunner
+	agent        AgentRunner
+
+	// RedactEnvPatterns are glob patterns of environment variables whose values are masked in logs and results.
+	RedactEnvPatterns []string
+	// redactor masks secrets in task logs, agent output and results.
+	redactor *redactor
+	// llmEnvs are the resolved Env of each LLM config, by ID, as KEY=value entries.
+	llmEnvs map[string][]string
+
+	// ModelPrices maps model (or LLM config) IDs to their prices, to estimate the cost of tasks.
+	ModelPrices map[string]ModelPrice
+
+	// CollectClusterLogs exports the logs of isolated clusters into the task output directory when a task fails.
+	CollectClusterLogs bool
+
+	// OutputDir is where the outputs of the run are written: <output-dir>/<run ID>, unless FlatOutput is set.
 	OutputDir string
+	// FlatOutput writes the outputs directly into --output-dir, instead of a directory per run.
+	FlatOutput bool
+	// outputRoot is the --output-dir that contains the run's OutputDir, when it is not flat.
+	outputRoot string
 }
 
 type AnalyzeConfig struct {
@@ -154,11 +698,58 @@ func main() {
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "Commands:\n")
-	fmt.Fprintf(os.Stderr, "  run       Run evaluation benchmarks\n")
-	fmt.Fprintf(os.Stderr, "  analyze   Analyze results from previous benchmark runs\n\n")
+	fmt.Fprintf(os.Stderr, "  run          Run evaluation benchmarks\n")
+	fmt.Fprintf(os.Stderr, "  analyze      Analyze results from previous benchmark runs\n")
+	fmt.Fprintf(os.Stderr, "  cleanup      Delete stale benchmark clusters\n")
+	fmt.Fprintf(os.Stderr, "  compare      Compare the results of two benchmark runs\n")
+	fmt.Fprintf(os.Stderr, "  flakes       Report the tasks with mixed outcomes across repeated runs\n")
+	fmt.Fprintf(os.Stderr, "  leaderboard  Show pass rate trends across historical runs\n")
+	fmt.Fprintf(os.Stderr, "  lint         Check the task definitions for errors\n\n")
 	fmt.Fprintf(os.Stderr, "Run '%s <command> --help' for more information on a command.\n", os.Args[0])
 }
 
+// runIDTimeFormat is the format of the start time that run IDs begin with.
+const runIDTimeFormat = "20060102-150405"
+
+// newRunID generates a unique, sortable identifier for an evaluation run.
+func newRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return fmt.Sprintf("%s-%s", time.Now().Format(runIDTimeFormat), hex.EncodeToString(suffix))
+}
+
+// parseKeyValues parses a list of key=value strings into a map.
+func parseKeyValues(values []string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, kv := range values {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", kv)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// parseClusterTimeouts parses OPERATION=DURATION values of the operations of cluster providers.
+func parseClusterTimeouts(values []string) (cluster.Timeouts, error) {
+	timeouts := make(cluster.Timeouts)
+	for _, value := range values {
+		op, duration, ok := strings.Cut(value, "=")
+		if !ok || !slices.Contains(cluster.Operations, op) {
+			return nil, fmt.Errorf("invalid timeout %q, must be OPERATION=DURATION with an operation of %s", value, strings.Join(cluster.Operations, ", "))
+		}
+		timeout, err := time.ParseDuration(duration)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid duration of timeout %q", value)
+		}
+		timeouts[op] = timeout
+	}
+	return timeouts, nil
+}
+
 type Strings []string
 
 func (f *Strings) String() string {
@@ -170,6 +761,10 @@ func (f *Strings) Set(s string) error {
 	return nil
 }
 
+func (f *Strings) reset() {
+	*f = nil
+}
+
 func run(ctx context.Context) error {
 	// No need to check for help flags here anymore
 
@@ -186,9 +781,19 @@ func run(ctx context.Context) error {
 		return runEvals(ctx)
 	case "analyze":
 		return runAnalyze()
+	case "cleanup":
+		return runClusterCleanup()
+	case "compare":
+		return runCompare()
+	case "flakes":
+		return runFlakes()
+	case "leaderboard":
+		return runLeaderboard()
+	case "lint":
+		return runLint()
 	default:
 		printUsage()
-		return fmt.Errorf("unknown subcommand: %s, valid options are 'run' or 'analyze'", subCommand)
+		return fmt.Errorf("unknown subcommand: %s, valid options are 'run', 'analyze', 'cleanup', 'compare', 'flakes', 'leaderboard' or 'lint'", subCommand)
 	}
 }
 
@@ -208,63 +813,327 @@ func runEvals(ctx context.Context) error {
 
 	llmProvider := "gemini"
 	modelList := ""
-	defaultKubeConfig := "~/.kube/config"
 	enableToolUseShim := false
 	quiet := true
 	mcpClient := false
-	clusterProvider := "kind"
-	hostClusterContext := ""
 
-	flag.StringVar(&config.TasksDir, "tasks-dir", config.TasksDir, "Directory containing evaluation tasks")
-	flag.StringVar(&config.KubeConfig, "kubeconfig", config.KubeConfig, "Path to kubeconfig file")
+	flag.StringVar(&config.ConfigFile, "config", "", "YAML file of run settings, with the fields of EvalConfig (e.g. tasksDir, concurrency, llmConfigs); flags given on the command line take precedence")
+	flag.StringVar(&config.LLMConfigsFile, "llm-configs", "", "YAML file of the LLM configs to evaluate: a list of configs, defaults merged into each, and a matrix of providers and models (instead of --llm-provider and --models)")
+	var tasksDirs Strings
+	flag.Var(&tasksDirs, "tasks-dir", "Directory containing evaluation tasks, in subdirectories at any depth (default ./tasks); repeat to run the tasks of several directories")
 	flag.StringVar(&config.TaskPattern, "task-pattern", config.TaskPattern, "Pattern to filter tasks (e.g. 'pod' or 'redis')")
+	flag.StringVar(&config.Suite, "suite", config.Suite, "Run the tasks of a suite defined in suites.yaml in the tasks directory (e.g. 'smoke')")
+	flag.BoolVar(&config.Shuffle, "shuffle", false, "Run tasks in a shuffled order instead of sorted by task ID (see --seed)")
+	flag.Uint64Var(&config.Seed, "seed", 0, "Seed for --shuffle (defaults to a random seed, recorded in run-metadata.yaml)")
+	includeTags := ""
+	excludeTags := ""
+	flag.StringVar(&includeTags, "include-tags", includeTags, "Comma-separated tags; only run tasks with at least one of them")
+	flag.BoolVar(&config.IncludeDisabled, "include-disabled", false, "Run disabled tasks that match the filters, instead of reporting them as skipped")
+	flag.StringVar(&excludeTags, "exclude-tags", excludeTags, "Comma-separated tags; skip tasks with any of them (takes precedence over --include-tags)")
 	flag.StringVar(&config.AgentBin, "agent-bin", config.AgentBin, "Path to kubernetes agent binary")
+	flag.Var((*agentFlag)(&config.Agents), "agent", "Agent to evaluate, as 'ID=PATH [ARGS...]'; repeat to compare agents on the same tasks and LLM configs (instead of --agent-bin)")
+	flag.StringVar(&config.AgentRunner, "agent-runner", AgentRunnerExec, "How to run the agent: 'exec' runs --agent-bin, 'http' sends the prompts to --agent-url")
+	flag.StringVar(&config.AgentHTTP.URL, "agent-url", "", "Base URL of an agent serving OpenAI compatible chat completions, for --agent-runner=http")
+	flag.StringVar(&config.AgentHTTP.AuthHeader, "agent-auth-header", "", "Header sent to --agent-url as 'Name: value' (environment variables in the value are expanded, e.g. 'Authorization: Bearer $AGENT_TOKEN')")
+	flag.DurationVar(&config.AgentHTTP.TurnTimeout, "agent-turn-timeout", 0, "Timeout of each prompt sent to --agent-url, including its streamed response (0 = no limit)")
+	flag.StringVar(&config.AgentImage, "agent-image", config.AgentImage, "Container image to run the agent in, instead of on the host; --agent-bin is then the agent binary in the image (defaults to its entrypoint)")
+	flag.StringVar(&config.AgentContainer.Runtime, "agent-container-runtime", "docker", "Container CLI to run --agent-image with: 'docker' or 'podman'")
+	flag.StringVar(&config.AgentContainer.Network, "agent-container-network", "host", "Network of the agent container; on networks other than 'host', loopback servers of the kubeconfig are rewritten to the host's address")
+	agentPassEnv := strings.Join(defaultAgentPassEnv, ",")
+	flag.StringVar(&agentPassEnv, "agent-container-env", agentPassEnv, "Comma-separated glob patterns of environment variables passed to the agent container, besides the task and LLM config env")
+	flag.StringVar(&config.StepReadyPattern, "step-ready-pattern", "", "Regular expression to wait for in the agent output before sending each script step (steps can override with 'waitFor')")
+	flag.StringVar((*string)(&config.ConsoleOutput), "console-output", string(ConsoleOutputSummary), "What the console shows while tasks run: 'quiet' (worker start/finish lines and warnings), 'summary' (also task progress) or 'full' (also agent and command output)")
+	flag.StringVar(&config.MetricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics of the run on, at /metrics (e.g. ':9090')")
+	flag.StringVar(&config.MetricsPushgateway, "metrics-pushgateway", "", "URL of a Prometheus Pushgateway to push the metrics of the run to when it ends")
+	flag.StringVar(&config.OTelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint to export OpenTelemetry spans of the run, tasks and their phases to (e.g. 'http://localhost:4318')")
+	flag.IntVar(&config.TransientRetries, "transient-retries", 0, "Number of times to run a task again when the agent fails with a transient error matching --transient-patterns (e.g. a rate limit)")
+	flag.DurationVar(&config.TransientBackoff, "transient-backoff", 10*time.Second, "Wait before the first transient retry of a task, doubled for each following one")
+	transientPatterns := strings.Join(defaultTransientPatterns, ",")
+	flag.StringVar(&transientPatterns, "transient-patterns", transientPatterns, "Comma-separated regular expressions that classify a failure of the agent as transient, when they match its error, stderr or trace")
+	var providerLimits, providerRates Strings
+	flag.Var(&providerLimits, "provider-limit", "Maximum number of tasks running at the same time with an LLM provider or LLM config, as NAME=N (e.g. 'gemini=2'); can be repeated")
+	flag.Var(&providerRates, "provider-rate", "Maximum number of tasks started per minute with an LLM provider or LLM config, as NAME=N (e.g. 'gemini=10'); can be repeated")
+	flag.StringVar(&config.UploadTo, "upload-to", "", "Upload the outputs of the run to this gs://bucket/prefix or s3://bucket/prefix URL, under <run-id>/, when it ends")
+	flag.IntVar(&config.UploadConcurrency, "upload-concurrency", 8, "Number of files to upload concurrently with --upload-to")
+	flag.BoolVar(&config.UploadFailureFatal, "upload-failure-fatal", false, "Fail the run if any output could not be uploaded with --upload-to (otherwise only a warning is printed)")
+	flag.StringVar(&config.NotifyWebhook, "notify-webhook", "", "URL to POST a summary of the run to when it ends: pass rates, regressions from --baseline and a link to the outputs")
+	flag.StringVar(&config.NotifyFormat, "notify-format", NotifyFormatJSON, "Payload of --notify-webhook: 'json', or 'slack' for a Slack incoming webhook")
+	flag.StringVar(&config.NotifyOutputURL, "notify-output-url", "", "Template of the link to the run's outputs in --notify-webhook notifications, e.g. 'https://storage.googleapis.com/bucket/runs/{{.RunID}}/'")
+	flag.DurationVar(&config.ProgressInterval, "progress-interval", 30*time.Second, "How often to report the progress of the run (completed tasks, counts, in-flight tasks and ETA); on a terminal it is a status line, also updated when tasks complete (0 = disable)")
+	flag.BoolVar(&config.NoColor, "no-color", false, "Do not color the console output (it is only colored on terminals)")
+	flag.StringVar(&config.LogFormat, "log-format", runlog.FormatText, "Format of the messages of the run itself, like started and completed tasks: 'text', or 'json' for machine ingestion (task output is not affected)")
+	flag.StringVar(&config.LogLevel, "log-level", "info", "Lowest level of the messages of the run itself that are printed: error, warn, info or debug")
+	flag.DurationVar(&config.AgentStallTimeout, "agent-stall-timeout", 0, "Stop an agent that produced no output for this long (e.g. 90s), and report the task as an error (0 = no limit)")
+	agentFailExitCodes := ""
+	flag.StringVar(&agentFailExitCodes, "agent-fail-exit-codes", agentFailExitCodes, "Comma-separated exit codes with which the agent reports that the model failed the task; other failed exits of the agent are errors")
+	idleMarker := ""
+	flag.StringVar(&idleMarker, "idle-marker", "", "Regular expression the agent prints when waiting for input; script steps are sent once it appears after the previous step")
+	flag.DurationVar(&config.StepIdleTime, "step-idle-time", 0, "Send script steps without a waitFor pattern or idle marker once the agent output has been silent this long (0 = send right away)")
 	flag.StringVar(&llmProvider, "llm-provider", llmProvider, "Specific LLM provider to evaluate (e.g. 'gemini' or 'ollama')")
 	flag.StringVar(&modelList, "models", modelList, "Comma-separated list of models to evaluate (e.g. 'gemini-1.0,gemini-2.0')")
 	flag.BoolVar(&enableToolUseShim, "enable-tool-use-shim", enableToolUseShim, "Enable tool use shim")
 	flag.BoolVar(&quiet, "quiet", quiet, "Quiet mode (non-interactive mode)")
-	flag.IntVar(&config.Concurrency, "concurrency", 0, "Number of tasks to run concurrently (0 = auto, 1 = sequential)")
+	flag.DurationVar(&config.RunTimeout, "run-timeout", 0, "Time budget for the whole run; tasks that would not finish within it are skipped (0 = no limit)")
+	failFast := false
+	flag.BoolVar(&failFast, "fail-fast", false, "Stop running new tasks after the first failed task (same as --max-failures=1)")
+	flag.IntVar(&config.MaxFailures, "max-failures", 0, "Stop running new tasks after this many failed tasks; remaining tasks are reported as skipped (0 = no limit)")
+	flag.Float64Var(&config.MaxCost, "max-cost", 0, "Stop running new tasks once the estimated cost of the run reaches this many dollars; remaining tasks are reported as skipped (0 = no limit, needs --model-prices)")
+	flag.IntVar(&config.MaxTotalTokens, "max-total-tokens", 0, "Stop running new tasks once the tasks of the run used this many LLM tokens; remaining tasks are reported as skipped (0 = no limit)")
+	flag.Float64Var(&config.TaskCostCap, "task-cost-cap", 0, "Flag tasks whose estimated cost is above this many dollars, without failing them (0 = no cap, needs --model-prices)")
+	flag.BoolVar(&config.MaxFailuresIgnoreErrors, "max-failures-ignore-errors", false, "Do not count infrastructure errors towards --fail-fast and --max-failures")
+	flag.BoolVar(&config.VerboseResults, "verbose-results", false, "Print every task result in the console summary, before the aggregate tables")
+	flag.StringVar(&config.ReportCSV, "report-csv", "", "Write the results as CSV to this path, one row per task, LLM config and run")
+	flag.StringVar(&config.ReportMarkdown, "report-markdown", "", "Write a Markdown summary of the results to this path (e.g. $GITHUB_STEP_SUMMARY)")
+	flag.StringVar(&config.ReportHTML, "report-html", "", "Write a self-contained HTML report of the results to this path")
+	flag.StringVar(&config.ReportJUnit, "report-junit", "", "Write a JUnit XML report of the results to this path, for CI systems")
+	flag.BoolVar(&config.Resume, "resume", false, "Resume an interrupted run in --output-dir, loading the results of completed task/LLM config pairs instead of running them again")
+	flag.BoolVar(&config.Overwrite, "overwrite", false, "Run into an --output-dir that holds the results of a previous run, removing the outputs of the task/LLM config pairs being run first")
+	flag.BoolVar(&config.DryRun, "dry-run", false, "Load the tasks and print the task × LLM config matrix of the run with an estimated duration, without creating clusters or running the agent")
+	flag.StringVar(&config.PlanFile, "plan-file", "", "With --dry-run, also write the plan as YAML to this path")
+	flag.Int64Var(&config.MaxLogBytes, "max-log-bytes", defaultMaxLogBytes, "Maximum size of each task log file; later output is dropped after a truncation marker (0 = no limit)")
+	flag.Int64Var(&config.MaxLogBufferBytes, "max-log-buffer-bytes", defaultMaxLogBufferBytes, "Maximum size of the tail of the task log kept in memory for error messages (0 = no limit)")
+	redactEnv := strings.Join(defaultRedactEnvPatterns, ",")
+	flag.StringVar(&redactEnv, "redact-env", redactEnv, "Comma-separated glob patterns of environment variables whose values are masked in logs, agent output and results")
+	modelPricesPath := ""
+	flag.StringVar(&modelPricesPath, "model-prices", "", "YAML file mapping model IDs to {inputPerMillion, outputPerMillion} prices in USD, to estimate the cost of tasks")
+	flag.StringVar(&config.Baseline, "baseline", "", "Path to the results.json of a previous run; fail if the pass rate of any LLM config dropped by more than --max-regression")
+	flag.Float64Var(&config.MaxRegression, "max-regression", 0, "Largest allowed drop in pass rate compared to --baseline, as a fraction (e.g. 0.05)")
+	flag.BoolVar(&config.BaselineByCategory, "baseline-by-category", false, "Also compare the pass rate of each task category with --baseline")
+	flag.StringVar(&config.ExitPolicy, "exit-policy", ExitPolicyFailOnFailure, "When the results make the run exit non-zero: 'fail-on-failure' (any task failed or errored), 'fail-on-error' (any task errored), 'always-zero', or 'threshold=<pass rate>' (the pass rate is below the value, e.g. 'threshold=0.8')")
+	flag.StringVar(&config.RerunFailed, "rerun-failed", "", "Output directory of a previous run; only rerun its task/LLM config pairs that failed or errored")
+	flag.IntVar(&config.Runs, "runs", 1, "Number of times to evaluate each task with each LLM config; the summary reports pass@1 and pass@<runs>")
+	flag.IntVar(&config.Concurrency, "concurrency", 0, "Number of task and LLM config pairs to run concurrently; the LLM configs of a task on the shared cluster run one at a time (0 = auto, 1 = sequential)")
 	flag.StringVar((*string)(&config.ClusterCreationPolicy), "cluster-creation-policy", string(CreateIfNotExist), "Cluster creation policy: AlwaysCreate, CreateIfNotExist, DoNotCreate")
 	flag.StringVar(&config.OutputDir, "output-dir", config.OutputDir, "Directory to write results to")
 	flag.BoolVar(&mcpClient, "mcp-client", mcpClient, "Enable MCP client in kubectl-ai")
-	flag.StringVar(&config.ClusterProvider, "cluster-provider", clusterProvider, "Cluster provider to use (kind or vcluster)")
-	flag.StringVar(&config.HostClusterContext, "host-cluster-context", hostClusterContext, "Host cluster context for vcluster (optional)")
-	flag.StringVar(&config.HostClusterKubeConfig, "host-cluster-kubeconfig", "", "Host cluster kubeconfig for vcluster (optional, defaults to --kubeconfig)")
+	flag.BoolVar(&config.FlatOutput, "flat-output", false, "Write outputs directly into --output-dir, instead of into <output-dir>/<run-id>/")
+	flag.StringVar(&config.RunID, "run-id", "", "Identifier for this run (defaults to a generated timestamp-based ID)")
+	flag.StringVar(&config.Judge.Provider, "judge-llm-provider", "gemini", "LLM provider used to grade tasks with a judge rubric ('gemini' or 'openai')")
+	flag.StringVar(&config.Judge.Model, "judge-model", "gemini-2.5-pro", "Model used to grade tasks with a judge rubric")
+	flag.BoolVar(&config.KeepClusterOnFailure, "keep-cluster-on-failure", false, "Keep the isolated clusters of a failed task for debugging; see keptCluster in results.yaml, and 'cleanup --kept-in' to delete them")
+	flag.BoolVar(&config.CollectClusterLogs, "collect-cluster-logs", false, "Export isolated cluster logs into the task output directory when a task fails (can be large)")
+	flag.IntVar(&config.TaskRetries, "task-retries", 0, "Default number of times to retry a failed task (tasks can override with 'retries')")
+	flag.BoolVar(&config.ResetBetweenTasks, "reset-between-tasks", false, "Reset the shared cluster after each task, deleting namespaces, CRDs, webhooks and cluster roles created since the run started (requires --concurrency=1, which it sets if concurrency is auto)")
+	flag.BoolVar(&config.CaptureDiff, "capture-diff", false, "Snapshot the cluster after the setup and after the agent ran, and write the objects the agent created, modified and deleted to cluster-diff.yaml")
+	diffResources := strings.Join(defaultDiffResources, ",")
+	flag.StringVar(&diffResources, "diff-resources", diffResources, "Comma-separated resource types snapshotted with --capture-diff")
+	resetAllowlist := strings.Join(defaultResetAllowlist, ",")
+	flag.StringVar(&resetAllowlist, "reset-allowlist", resetAllowlist, "Comma-separated namespaces that are never deleted by --reset-between-tasks")
+	completeClusterFlags := registerClusterFlags(&config)
 	flag.Parse()
 
-	if config.ClusterProvider == "vcluster" {
-		if config.HostClusterContext == "" {
-			return fmt.Errorf("--host-cluster-context is required when using --cluster-provider=vcluster")
+	// The config file is applied over the flag defaults, and the flags given on the command line over it.
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if config.ConfigFile != "" {
+		if err := loadConfigFile(config.ConfigFile, &config); err != nil {
+			return fmt.Errorf("invalid --config: %w", err)
+		}
+		if err := applyFlagOverrides(os.Args[1:]); err != nil {
+			return err
 		}
-		fmt.Println("When using vCluster as cluster provider, defaulting cluster-creation-policy to DoNotCreate")
-		config.ClusterCreationPolicy = DoNotCreate
 	}
 
-	if config.KubeConfig == "" {
-		config.KubeConfig = defaultKubeConfig
+	// Messages of the run go through the default slog logger from here on.
+	config.color = !config.NoColor && isTerminal(os.Stdout)
+	logLevel, err := runlog.ParseLevel(config.LogLevel)
+	if err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
 	}
-
-	expandedKubeconfig, err := expandPath(config.KubeConfig)
+	logger, err := runlog.New(os.Stdout, runlog.Options{Format: config.LogFormat, Level: logLevel, Color: config.color})
 	if err != nil {
-		return fmt.Errorf("failed to expand kubeconfig path %q: %w", config.KubeConfig, err)
+		return fmt.Errorf("invalid --log-format: %w", err)
 	}
-	config.KubeConfig = expandedKubeconfig
+	slog.SetDefault(logger)
 
-	if config.HostClusterKubeConfig == "" {
-		config.HostClusterKubeConfig = config.KubeConfig
-	} else {
-		expandedHostKubeconfig, err := expandPath(config.HostClusterKubeConfig)
+	if explicit["reset-allowlist"] || config.ResetAllowlist == nil {
+		config.ResetAllowlist = strings.Split(resetAllowlist, ",")
+	}
+	if explicit["diff-resources"] || config.DiffResources == nil {
+		config.DiffResources = strings.Split(diffResources, ",")
+	}
+	if len(tasksDirs) > 0 {
+		config.TasksDir, config.TasksDirs = tasksDirs[0], tasksDirs[1:]
+	}
+	if includeTags != "" {
+		config.IncludeTags = strings.Split(includeTags, ",")
+	}
+	if agentFailExitCodes != "" {
+		for _, s := range strings.Split(agentFailExitCodes, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || code <= 0 {
+				return fmt.Errorf("invalid --agent-fail-exit-codes value %q, must be positive exit codes", s)
+			}
+			config.AgentFailExitCodes = append(config.AgentFailExitCodes, code)
+		}
+	}
+	if excludeTags != "" {
+		config.ExcludeTags = strings.Split(excludeTags, ",")
+	}
+
+	if redactEnv != "" && (explicit["redact-env"] || config.RedactEnvPatterns == nil) {
+		config.RedactEnvPatterns = strings.Split(redactEnv, ",")
+	}
+	config.redactor = newRedactor(config.RedactEnvPatterns)
+
+	if len(config.Agents) == 0 {
+		config.Agents = []AgentConfig{{Bin: config.AgentBin}}
+	} else if config.AgentBin != "" {
+		return fmt.Errorf("use either --agent-bin or --agent, not both")
+	} else if config.AgentRunner == AgentRunnerHTTP {
+		return fmt.Errorf("--agent is not supported with --agent-runner=http")
+	}
+	if agentPassEnv != "" && (explicit["agent-container-env"] || config.AgentContainer.PassEnv == nil) {
+		config.AgentContainer.PassEnv = strings.Split(agentPassEnv, ",")
+	}
+	config.agentRunners = make(map[string]AgentRunner)
+	for _, agent := range config.Agents {
+		runner, err := newAgentRunner(config, agent)
 		if err != nil {
-			return fmt.Errorf("failed to expand host cluster kubeconfig path %q: %w", config.HostClusterKubeConfig, err)
+			return err
 		}
-		config.HostClusterKubeConfig = expandedHostKubeconfig
+		config.agentRunners[agent.ID] = runner
+	}
+
+	if modelPricesPath != "" {
+		prices, err := loadModelPrices(modelPricesPath)
+		if err != nil {
+			return fmt.Errorf("invalid --model-prices: %w", err)
+		}
+		config.ModelPrices = prices
+	}
+
+	if config.Overwrite && config.Resume {
+		return fmt.Errorf("--overwrite and --resume cannot be used together")
+	}
+
+	runIDSet := config.RunID != ""
+	if config.RunID == "" {
+		config.RunID = newRunID()
+	}
+	slog.Info("Starting run", "runID", config.RunID)
+
+	if config.OutputDir != "" && !config.FlatOutput {
+		if config.Resume && !runIDSet {
+			return fmt.Errorf("--resume needs the --run-id of the run to resume (or --flat-output)")
+		}
+		config.outputRoot = config.OutputDir
+		config.OutputDir = filepath.Join(config.OutputDir, config.RunID)
+		slog.Info("Writing outputs", "dir", config.OutputDir)
+	}
+
+	if (config.MaxCost > 0 || config.TaskCostCap > 0) && len(config.ModelPrices) == 0 {
+		return fmt.Errorf("--max-cost and --task-cost-cap need --model-prices to estimate the cost of tasks")
+	}
+
+	if failFast && config.MaxFailures == 0 {
+		config.MaxFailures = 1
+	}
+
+	if err := config.ConsoleOutput.Validate(); err != nil {
+		return err
+	}
+
+	if config.Runs < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+
+	if config.Baseline != "" {
+		// Check the baseline up front, rather than after a long run.
+		if _, err := loadRunResults(config.Baseline); err != nil {
+			return fmt.Errorf("invalid --baseline: %w", err)
+		}
+	}
+	if transientPatterns != "" && (explicit["transient-patterns"] || config.TransientPatterns == nil) {
+		config.TransientPatterns = strings.Split(transientPatterns, ",")
+	}
+	compiled, err := compileTransientPatterns(config.TransientPatterns)
+	if err != nil {
+		return err
+	}
+	config.transientPatterns = compiled
+	limits, err := parseProviderLimits(providerLimits, providerRates)
+	if err != nil {
+		return err
+	}
+	if config.ProviderLimits == nil {
+		config.ProviderLimits = make(map[string]ProviderLimit)
+	}
+	for name, limit := range limits {
+		config.ProviderLimits[name] = limit
+	}
+	if config.UploadTo != "" {
+		if config.OutputDir == "" {
+			return fmt.Errorf("--upload-to needs --output-dir")
+		}
+		if _, err := parseUploadDestination(config.UploadTo); err != nil {
+			return fmt.Errorf("invalid --upload-to: %w", err)
+		}
+	}
+	if config.NotifyFormat != NotifyFormatJSON && config.NotifyFormat != NotifyFormatSlack {
+		return fmt.Errorf("--notify-format must be %s or %s", NotifyFormatJSON, NotifyFormatSlack)
+	}
+	if config.NotifyOutputURL != "" {
+		t, err := parseOutputURLTemplate(config.NotifyOutputURL)
+		if err != nil {
+			return fmt.Errorf("invalid --notify-output-url: %w", err)
+		}
+		config.notifyOutputURL = t
+	}
+	if config.MaxRegression < 0 || config.MaxRegression > 1 {
+		return fmt.Errorf("--max-regression must be between 0 and 1")
+	}
+	config.exitPolicy, err = parseExitPolicy(config.ExitPolicy)
+	if err != nil {
+		return fmt.Errorf("invalid --exit-policy: %w", err)
+	}
+
+	if config.StepReadyPattern != "" {
+		if _, err := regexp.Compile(config.StepReadyPattern); err != nil {
+			return fmt.Errorf("invalid --step-ready-pattern: %w", err)
+		}
+	}
+	if idleMarker != "" {
+		if _, err := regexp.Compile(idleMarker); err != nil {
+			return fmt.Errorf("invalid --idle-marker: %w", err)
+		}
+	}
+
+	if config.Shuffle && config.Seed == 0 {
+		config.Seed = randomSeed()
+	}
+
+	if err := completeClusterFlags(); err != nil {
+		return err
+	}
+
+	if config.ClusterProvider == "vcluster" {
+		slog.Info("When using vCluster as cluster provider, defaulting cluster-creation-policy to DoNotCreate")
+		config.ClusterCreationPolicy = DoNotCreate
 	}
 
 	defaultModels := map[string][]string{
 		"gemini": {"gemini-2.5-pro"},
 	}
 
+	// LLM configs from the config file are only replaced by those of the flags if they are given.
 	models := defaultModels
+	switch {
+	case config.LLMConfigsFile != "":
+		if explicit["llm-provider"] || explicit["models"] {
+			return fmt.Errorf("use either --llm-configs or --llm-provider and --models, not both")
+		}
+		llmConfigs, err := loadLLMConfigs(config.LLMConfigsFile)
+		if err != nil {
+			return fmt.Errorf("invalid --llm-configs: %w", err)
+		}
+		config.LLMConfigs = llmConfigs
+		models = nil
+	case len(config.LLMConfigs) > 0 && !explicit["llm-provider"] && !explicit["models"]:
+		models = nil
+	default:
+		config.LLMConfigs = nil
+	}
 	if modelList != "" {
 		if llmProvider == "" {
 			return fmt.Errorf("--llm-provider is required when --models is specified")
@@ -291,29 +1160,136 @@ func runEvals(ctx context.Context) error {
 				EnableToolUseShim: enableToolUseShim,
 				Quiet:             quiet,
 				McpClient:         mcpClient,
+				IdleMarker:        idleMarker,
 			})
 		}
 	}
 
-	tasks, err := loadTasks(config)
+	llmEnvs, err := resolveLLMEnvs(config.LLMConfigs, config.redactor)
+	if err != nil {
+		return err
+	}
+	config.llmEnvs = llmEnvs
+
+	if err := validateEvalConfig(config); err != nil {
+		return err
+	}
+	printLLMConfigs(config.LLMConfigs)
+
+	tasks, _, err := loadTasks(config)
 	if err != nil {
 		return fmt.Errorf("failed to load tasks: %w", err)
 	}
 
-	// If concurrency is set to auto (0), use the number of tasks
+	// Resetting the shared cluster would interfere with tasks running concurrently.
+	if config.ResetBetweenTasks {
+		if config.Concurrency > 1 {
+			return fmt.Errorf("--reset-between-tasks requires --concurrency=1")
+		}
+		config.Concurrency = 1
+	}
+
+	// If concurrency is set to auto (0), run every task with every agent and LLM config at once
 	if config.Concurrency == 0 {
-		config.Concurrency = len(tasks)
-		fmt.Printf("Auto-configuring concurrency to %d (number of tasks)\n", config.Concurrency)
+		config.Concurrency = len(tasks) * len(config.Agents) * len(config.LLMConfigs)
+		slog.Info("Auto-configured concurrency to the number of tasks × LLM configs", "concurrency", config.Concurrency)
 	}
 
 	if err := runEvaluation(ctx, config); err != nil {
 		return fmt.Errorf("running evaluation: %w", err)
 	}
 
+	// Printed to stdout, not logged: dev/ci/periodics/run-eval-loop.sh reads this line.
 	fmt.Printf("Total evaluation time: %s\n", time.Since(start))
 	return nil
 }
 
+// registerClusterFlags registers the flags that select and configure the cluster provider.
+// The returned function must be called after flag.Parse to validate and normalize the values.
+func registerClusterFlags(config *EvalConfig) func() error {
+	defaultKubeConfig := "~/.kube/config"
+	clusterProvider := "kind"
+	hostClusterContext := ""
+
+	flag.StringVar(&config.KubeConfig, "kubeconfig", config.KubeConfig, "Path to kubeconfig file")
+	flag.StringVar(&config.KubeContext, "kube-context", config.KubeContext, "Kubeconfig context to use with the external cluster provider (optional, defaults to current context)")
+	flag.StringVar(&config.ClusterProvider, "cluster-provider", clusterProvider, "Cluster provider to use (kind, vcluster, minikube, gke or external)")
+	flag.StringVar(&config.HostClusterContext, "host-cluster-context", hostClusterContext, "Host cluster context for vcluster (optional)")
+	flag.StringVar(&config.HostClusterKubeConfig, "host-cluster-kubeconfig", "", "Host cluster kubeconfig for vcluster (optional, defaults to --kubeconfig)")
+	flag.DurationVar(&config.ClusterReadyTimeout, "cluster-ready-timeout", 5*time.Minute, "How long to wait for the API server, nodes and default service account to be ready before using a cluster (0 = don't wait)")
+	flag.DurationVar(&config.VClusterReadyTimeout, "vcluster-ready-timeout", vcluster.DefaultReadyTimeout, "How long to wait for a vcluster API server to become ready")
+	flag.StringVar(&config.VCluster.Distro, "vcluster-distro", "", "Distro of the vcluster control plane: k8s or k3s (optional)")
+	flag.StringVar(&config.VCluster.KubernetesVersion, "vcluster-kubernetes-version", "", "Image tag of the vcluster distro, e.g. v1.30.2 (optional)")
+	flag.StringVar(&config.VCluster.ValuesFile, "vcluster-values", "", "Path to a vcluster values file merged over the default values (optional)")
+	flag.StringVar(&config.VClusterExposureMode, "vcluster-exposure", string(vcluster.ExposureModeProxy), "How to reach vcluster API servers: proxy, ingress or loadbalancer")
+	flag.StringVar(&config.VClusterIngressExternalIP, "vcluster-ingress-external-ip", "", "External IP of the host nginx ingress controller (required with --vcluster-exposure=ingress)")
+	var vclusterLabels, vclusterAnnotations Strings
+	flag.StringVar(&config.VClusterNamespacePrefix, "vcluster-namespace-prefix", vcluster.DefaultNamespacePrefix, "Prefix of the host namespace of each vcluster")
+	flag.Var(&vclusterLabels, "vcluster-label", "Label (key=value) to apply to vcluster host namespaces; can be repeated")
+	flag.Var(&vclusterAnnotations, "vcluster-annotation", "Annotation (key=value) to apply to vcluster host namespaces; can be repeated")
+	flag.StringVar(&config.Kind.NodeImage, "kind-node-image", "", "Node image for kind clusters (e.g. kindest/node:v1.29.2)")
+	flag.StringVar(&config.Kind.ConfigFile, "kind-config", "", "Path to a kind config file used when creating kind clusters")
+	flag.IntVar(&config.Kind.WorkerNodes, "kind-worker-nodes", 0, "Number of worker nodes for kind clusters (ignored if --kind-config is set)")
+	flag.StringVar(&config.MinikubeDriver, "minikube-driver", "", "Driver to use with the minikube cluster provider (e.g. docker, none, kvm2)")
+	flag.StringVar(&config.KubernetesVersion, "kubernetes-version", "", "Kubernetes version for created clusters (minikube only, optional)")
+	flag.StringVar(&config.GKE.Project, "gke-project", "", "GCP project for the gke cluster provider")
+	flag.StringVar(&config.GKE.Location, "gke-location", "", "Region or zone for the gke cluster provider (e.g. us-central1)")
+	flag.StringVar(&config.GKE.MachineType, "gke-machine-type", "", "Node machine type for gke standard clusters (optional)")
+	flag.IntVar(&config.GKE.NumNodes, "gke-num-nodes", 0, "Number of nodes per zone for gke standard clusters (0 = gcloud default)")
+	flag.BoolVar(&config.GKE.Autopilot, "gke-autopilot", false, "Create gke autopilot clusters instead of standard clusters")
+	var clusterTimeouts Strings
+	flag.Var(&clusterTimeouts, "cluster-timeout", fmt.Sprintf("Timeout of the cluster provider commands of an operation, as OPERATION=DURATION (e.g. 'create=30m'), where OPERATION is one of %s; can be repeated", strings.Join(cluster.Operations, ", ")))
+
+	return func() error {
+		// Labels and annotations from a config file are replaced if any are given as flags.
+		var err error
+		if len(vclusterLabels) > 0 || config.VClusterLabels == nil {
+			config.VClusterLabels, err = parseKeyValues(vclusterLabels)
+			if err != nil {
+				return fmt.Errorf("parsing --vcluster-label: %w", err)
+			}
+		}
+		if len(vclusterAnnotations) > 0 || config.VClusterAnnotations == nil {
+			config.VClusterAnnotations, err = parseKeyValues(vclusterAnnotations)
+			if err != nil {
+				return fmt.Errorf("parsing --vcluster-annotation: %w", err)
+			}
+		}
+
+		if len(clusterTimeouts) > 0 || config.ClusterTimeouts == nil {
+			config.ClusterTimeouts, err = parseClusterTimeouts(clusterTimeouts)
+			if err != nil {
+				return fmt.Errorf("parsing --cluster-timeout: %w", err)
+			}
+		}
+
+		if config.ClusterProvider == "vcluster" && config.HostClusterContext == "" {
+			return fmt.Errorf("--host-cluster-context is required when using --cluster-provider=vcluster")
+		}
+
+		if config.KubeConfig == "" {
+			config.KubeConfig = defaultKubeConfig
+		}
+
+		expandedKubeconfig, err := expandPath(config.KubeConfig)
+		if err != nil {
+			return fmt.Errorf("failed to expand kubeconfig path %q: %w", config.KubeConfig, err)
+		}
+		config.KubeConfig = expandedKubeconfig
+
+		if config.HostClusterKubeConfig == "" {
+			config.HostClusterKubeConfig = config.KubeConfig
+		} else {
+			expandedHostKubeconfig, err := expandPath(config.HostClusterKubeConfig)
+			if err != nil {
+				return fmt.Errorf("failed to expand host cluster kubeconfig path %q: %w", config.HostClusterKubeConfig, err)
+			}
+			config.HostClusterKubeConfig = expandedHostKubeconfig
+		}
+		return nil
+	}
+}
+
 func runAnalyze() error {
 	config := AnalyzeConfig{
 		InputDir:     "",
@@ -454,7 +1430,10 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 	}
 	sort.Strings(models)
 
-	// Overall summary across all results
+	// Overall summary across the evaluated results
+	skippedCount := len(results) - len(evaluatedResults(results))
+	disabledCount := countDisabled(results)
+	results = evaluatedResults(results)
 	totalCount := len(results)
 	overallSuccessCount := 0
 	overallFailCount := 0
@@ -569,9 +1548,29 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 	// --- Overall Summary ---
 	buffer.WriteString("## Overall Summary\n\n")
 	buffer.WriteString(fmt.Sprintf("- Total Runs: %d\n", totalCount))
+	if skippedCount > 0 {
+		buffer.WriteString(fmt.Sprintf("- Skipped (not counted): %d, of which %d of disabled tasks\n", skippedCount, disabledCount))
+	}
 	buffer.WriteString(fmt.Sprintf("- Overall Success: %d (%d%%)\n", overallSuccessCount, calculatePercentage(overallSuccessCount, totalCount)))
 	buffer.WriteString(fmt.Sprintf("- Overall Fail: %d (%d%%)\n", overallFailCount, calculatePercentage(overallFailCount, totalCount)))
-	buffer.WriteString(fmt.Sprintf("- Overall Error: %d (%d%%)\n\n", overallErrorCount, calculatePercentage(overallErrorCount, totalCount)))
+	buffer.WriteString(fmt.Sprintf("- Overall Error: %d (%d%%)\n", overallErrorCount, calculatePercentage(overallErrorCount, totalCount)))
+	buffer.WriteString(fmt.Sprintf("- Mean Score: %.2f\n\n", meanScore(results)))
+
+	// --- Breakdown by difficulty and category ---
+	buffer.WriteString("## Pass Rate by Difficulty\n\n")
+	writeBreakdownTable(&buffer, results, "Difficulty", difficultyOf)
+	buffer.WriteString("## Pass Rate by Category\n\n")
+	writeBreakdownTable(&buffer, results, "Category", categoryOf)
+
+	// --- Pass@k, when tasks were run several times ---
+	runs := 0
+	for _, result := range results {
+		runs = max(runs, result.Run)
+	}
+	if runs > 1 {
+		buffer.WriteString(fmt.Sprintf("## Pass@k over %d runs\n\nResults with an error are excluded from the samples.\n\n", runs))
+		writePassAtKTables(&buffer, results, runs)
+	}
 
 	// --- Detailed Results ---
 	if config.IgnoreToolUseShim {
@@ -619,7 +1618,8 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 			buffer.WriteString(fmt.Sprintf("- Total: %d\n", modelTotalCount))
 			buffer.WriteString(fmt.Sprintf("- Success: %d (%d%%)\n", modelSuccessCount, calculatePercentage(modelSuccessCount, modelTotalCount)))
 			buffer.WriteString(fmt.Sprintf("- Fail: %d (%d%%)\n", modelFailCount, calculatePercentage(modelFailCount, modelTotalCount)))
-			buffer.WriteString(fmt.Sprintf("- Error: %d (%d%%)\n\n", modelErrorCount, calculatePercentage(modelErrorCount, modelTotalCount)))
+			buffer.WriteString(fmt.Sprintf("- Error: %d (%d%%)\n", modelErrorCount, calculatePercentage(modelErrorCount, modelTotalCount)))
+			buffer.WriteString(fmt.Sprintf("- Mean Score: %.2f\n\n", meanScore(modelResults)))
 			// After the summary, print failure details
 			if config.ShowFailures {
 				printFailureAndErrorDetails(&buffer, modelResults, model, false)
@@ -687,7 +1687,8 @@ func printMarkdownResults(config AnalyzeConfig, results []model.TaskResult, resu
 			buffer.WriteString(fmt.Sprintf("\n**%s Summary**\n\n", toolUseShimStr))
 			buffer.WriteString(fmt.Sprintf("- Total: %d\n", totalCount))
 			buffer.WriteString(fmt.Sprintf("- Success: %d (%d%%)\n", successCount, calculatePercentage(successCount, totalCount)))
-			buffer.WriteString(fmt.Sprintf("- Fail: %d (%d%%)\n\n", failCount, calculatePercentage(failCount, totalCount)))
+			buffer.WriteString(fmt.Sprintf("- Fail: %d (%d%%)\n", failCount, calculatePercentage(failCount, totalCount)))
+			buffer.WriteString(fmt.Sprintf("- Mean Score: %.2f\n\n", meanScore(toolUseShimStrResults)))
 
 			// After the summary, print failure details
 			if config.ShowFailures {
@@ -724,6 +1725,31 @@ func calculatePercentage(part, total int) int {
 	return int((float64(part) / float64(total)) * 100)
 }
 
+// evaluatedResults returns the results that were evaluated, leaving out the skipped ones
+// (of disabled tasks, or tasks that were never started), which do not count towards totals,
+// pass rates and scores.
+func evaluatedResults(results []model.TaskResult) []model.TaskResult {
+	evaluated := make([]model.TaskResult, 0, len(results))
+	for _, result := range results {
+		if result.Result != "skipped" {
+			evaluated = append(evaluated, result)
+		}
+	}
+	return evaluated
+}
+
+// meanScore returns the mean score of the results.
+func meanScore(results []model.TaskResult) float64 {
+	if len(results) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, result := range results {
+		total += result.EffectiveScore()
+	}
+	return total / float64(len(results))
+}
+
 func printJSONResults(results []model.TaskResult, resultsFilePath string) error {
 	// Convert the results to JSON
 	jsonData, err := json.MarshalIndent(results, "", "  ")
@@ -0,0 +1,137 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWaitForTimeout   = 2 * time.Minute
+	manifestCleanupTimeout  = 2 * time.Minute
+	defaultWaitForCondition = "Ready"
+)
+
+// WaitFor waits for an object to become ready.
+// Deployments, StatefulSets and DaemonSets wait for their rollout to complete,
+// other kinds wait for Condition (Ready by default).
+type WaitFor struct {
+	ResourceRef
+	Condition string `json:"condition,omitempty"`
+	// Timeout defaults to 2m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks that the object is identified and the timeout is valid.
+func (w WaitFor) Validate() error {
+	if w.Kind == "" || w.Name == "" {
+		return fmt.Errorf("waitFor must specify kind and name")
+	}
+	if w.Timeout != "" {
+		if _, err := time.ParseDuration(w.Timeout); err != nil {
+			return fmt.Errorf("parsing waitFor timeout for %s: %w", w.ResourceRef, err)
+		}
+	}
+	return nil
+}
+
+// kubectlArgs returns the kubectl arguments that wait for the object.
+func (w WaitFor) kubectlArgs() []string {
+	timeout := w.Timeout
+	if timeout == "" {
+		timeout = defaultWaitForTimeout.String()
+	}
+	object := w.kubectlType() + "/" + w.Name
+
+	var args []string
+	switch strings.ToLower(w.Kind) {
+	case "deployment", "statefulset", "daemonset":
+		if w.Condition == "" {
+			args = []string{"rollout", "status", object, "--timeout", timeout}
+			break
+		}
+		fallthrough
+	default:
+		condition := w.Condition
+		if condition == "" {
+			condition = defaultWaitForCondition
+		}
+		args = []string{"wait", object, "--for", "condition=" + condition, "--timeout", timeout}
+	}
+	if w.Namespace != "" {
+		args = append(args, "--namespace", w.Namespace)
+	}
+	return args
+}
+
+// kubectl runs kubectl against the task's cluster, copying its output to the console and task log.
+// On failure, the error includes kubectl's output.
+func (x *TaskExecution) kubectl(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig", x.kubeConfig}, args...)...)
+	if output, err := x.runCommand(cmd); err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// applySetupManifests applies the task's setupManifests, and waits for its waitFor objects.
+// Unless the task has a cleanup script or its own cluster, the manifests are deleted
+// in reverse order when the task is cleaned up.
+func (x *TaskExecution) applySetupManifests(ctx context.Context) error {
+	var applied []string
+	for _, manifest := range x.task.SetupManifests {
+		manifestPath, err := resolveTaskPath(x.tasksDir, x.taskDir, manifest)
+		if err != nil {
+			return err
+		}
+		if err := x.kubectl(ctx, "apply", "-f", manifestPath); err != nil {
+			return fmt.Errorf("applying setup manifest %s: %w", manifest, err)
+		}
+		applied = append(applied, manifestPath)
+	}
+
+	if len(applied) > 0 && x.task.Cleanup == nil && x.task.Isolation != IsolationModeCluster {
+		x.cleanupFunctions = append(x.cleanupFunctions, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), manifestCleanupTimeout)
+			defer cancel()
+			var errs []string
+			for i := len(applied) - 1; i >= 0; i-- {
+				if err := x.kubectl(ctx, "delete", "-f", applied[i], "--ignore-not-found"); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("deleting setup manifests: %s", strings.Join(errs, "; "))
+			}
+			return nil
+		})
+	}
+
+	return x.waitForResources(ctx, x.task.WaitFor)
+}
+
+// waitForResources waits for each of the objects to become ready.
+func (x *TaskExecution) waitForResources(ctx context.Context, waits []WaitFor) error {
+	for _, wait := range waits {
+		if err := x.kubectl(ctx, wait.kubectlArgs()...); err != nil {
+			return fmt.Errorf("waiting for %s: %w", wait.ResourceRef, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// maxMatrixCombinations bounds the number of instances a task matrix can expand into.
+const maxMatrixCombinations = 64
+
+// expandTask parses a task file from dir, expanding its matrix (if any) into one
+// task per combination of values, with IDs like dir[ns=shop,replicas=3].
+// The returned tasks are keyed by task ID.
+func expandTask(dir string, data []byte) (map[string]Task, error) {
+	var task Task
+	if err := yaml.UnmarshalStrict(data, &task); err != nil {
+		return nil, err
+	}
+	task.dir = dir
+	if len(task.Matrix) == 0 {
+		return map[string]Task{dir: task}, nil
+	}
+
+	names := make([]string, 0, len(task.Matrix))
+	total := 1
+	for name, values := range task.Matrix {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix variable %q has no values", name)
+		}
+		names = append(names, name)
+		total *= len(values)
+		if total > maxMatrixCombinations {
+			return nil, fmt.Errorf("matrix expands to more than %d combinations", maxMatrixCombinations)
+		}
+	}
+	sort.Strings(names)
+
+	combinations := []map[string]string{{}}
+	for _, name := range names {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range task.Matrix[name] {
+				vars := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					vars[k] = v
+				}
+				vars[name] = value
+				next = append(next, vars)
+			}
+		}
+		combinations = next
+	}
+
+	tasks := make(map[string]Task, len(combinations))
+	for _, vars := range combinations {
+		var parts []string
+		for _, name := range names {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, vars[name]))
+		}
+		taskID := fmt.Sprintf("%s[%s]", dir, strings.Join(parts, ","))
+
+		rendered, err := renderTemplate(taskID, string(data), withRuntimePromptVars(vars))
+		if err != nil {
+			return nil, err
+		}
+		var instance Task
+		if err := yaml.UnmarshalStrict([]byte(rendered), &instance); err != nil {
+			return nil, fmt.Errorf("parsing task %s: %w", taskID, err)
+		}
+		instance.dir = dir
+		instance.vars = vars
+		tasks[taskID] = instance
+	}
+	return tasks, nil
+}
+
+// withRuntimePromptVars returns vars with the runtime variables of prompts (see runtimePromptVars)
+// rendering as themselves, so that they are left for the prompts to be rendered with when they are sent.
+func withRuntimePromptVars(vars map[string]string) map[string]string {
+	all := make(map[string]string, len(vars)+len(runtimePromptVars))
+	for _, name := range runtimePromptVars {
+		all[name] = "{{." + name + "}}"
+	}
+	for k, v := range vars {
+		all[k] = v
+	}
+	return all
+}
+
+// renderTemplate substitutes {{.name}} template variables in text.
+func renderTemplate(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %w", name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+var clusterNameUnsafeChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// clusterNameSafe converts a task ID (which may contain matrix values) into a valid cluster name component.
+func clusterNameSafe(taskID string) string {
+	return strings.Trim(clusterNameUnsafeChars.ReplaceAllString(strings.ToLower(taskID), "-"), "-")
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// resultEmoji returns the emoji for a result in the Markdown report.
+func resultEmoji(result string) string {
+	switch result {
+	case "success":
+		return "✅"
+	case "fail":
+		return "❌"
+	case "error":
+		return "⚠️"
+	default:
+		return "➖"
+	}
+}
+
+// writeMarkdownReport writes a concise Markdown summary of the results, suitable for
+// GitHub Actions job summaries and PR comments, to config.ReportMarkdown.
+func writeMarkdownReport(config EvalConfig, startTime time.Time, results []model.TaskResult) error {
+	var buffer strings.Builder
+	summaries := summarizeLLMConfigs(results)
+
+	providers := make(map[string]bool)
+	tasks := make(map[string]bool)
+	disabledTasks := make(map[string]bool)
+	for _, result := range results {
+		providers[result.LLMConfig.ProviderID] = true
+		if result.Disabled {
+			disabledTasks[result.Task] = true
+			continue
+		}
+		tasks[result.Task] = true
+	}
+	var llmIDs []string
+	for _, summary := range summaries {
+		llmIDs = append(llmIDs, summary.ID)
+	}
+
+	buffer.WriteString("# k8s-ai-bench Results\n\n")
+	buffer.WriteString(fmt.Sprintf("- Run: %s\n", config.RunID))
+	buffer.WriteString(fmt.Sprintf("- Models: %s\n", strings.Join(llmIDs, ", ")))
+	buffer.WriteString(fmt.Sprintf("- Providers: %s\n", strings.Join(sortedKeys(providers), ", ")))
+	buffer.WriteString(fmt.Sprintf("- Tasks: %d\n", len(tasks)))
+	if len(disabledTasks) > 0 {
+		buffer.WriteString(fmt.Sprintf("- Disabled tasks: %d (not counted in the pass rates)\n", len(disabledTasks)))
+	}
+	buffer.WriteString(fmt.Sprintf("- Duration: %s\n\n", time.Since(startTime).Round(time.Second)))
+
+	buffer.WriteString("## Pass Rate\n\n")
+	buffer.WriteString("| LLM Config | Passed | Failed | Errors | Skipped | Pass Rate | Mean Score |\n")
+	buffer.WriteString("|------------|--------|--------|--------|---------|-----------|------------|\n")
+	for _, summary := range summaries {
+		buffer.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d%% | %.2f |\n",
+			summary.ID, summary.Success, summary.Fail, summary.Error, summary.Skipped,
+			calculatePercentage(summary.Success, summary.Total-summary.Skipped), summary.MeanScore))
+	}
+	buffer.WriteString("\n")
+
+	byTask := make(map[string]map[string][]model.TaskResult)
+	for _, result := range results {
+		if byTask[result.Task] == nil {
+			byTask[result.Task] = make(map[string][]model.TaskResult)
+		}
+		byTask[result.Task][result.ConfigID()] = append(byTask[result.Task][result.ConfigID()], result)
+	}
+
+	buffer.WriteString("## Tasks\n\n")
+	buffer.WriteString("| Task |")
+	for _, llmID := range llmIDs {
+		buffer.WriteString(fmt.Sprintf(" %s |", llmID))
+	}
+	buffer.WriteString("\n|------|")
+	for range llmIDs {
+		buffer.WriteString("------|")
+	}
+	buffer.WriteString("\n")
+	for _, task := range sortedKeys(tasks) {
+		buffer.WriteString(fmt.Sprintf("| %s |", task))
+		for _, llmID := range llmIDs {
+			cellResults := byTask[task][llmID]
+			sort.Slice(cellResults, func(i, j int) bool { return cellResults[i].Run < cellResults[j].Run })
+			var cell []string
+			for _, result := range cellResults {
+				cell = append(cell, resultEmoji(result.Result))
+			}
+			buffer.WriteString(fmt.Sprintf(" %s |", strings.Join(cell, "")))
+		}
+		buffer.WriteString("\n")
+	}
+	buffer.WriteString("\n")
+
+	var failures []model.TaskResult
+	for _, result := range results {
+		if result.Result == "fail" || result.Result == "error" {
+			failures = append(failures, result)
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Task != failures[j].Task {
+			return failures[i].Task < failures[j].Task
+		}
+		if failures[i].ConfigID() != failures[j].ConfigID() {
+			return failures[i].ConfigID() < failures[j].ConfigID()
+		}
+		return failures[i].Run < failures[j].Run
+	})
+	if len(failures) > 0 {
+		buffer.WriteString("## Failures\n\n")
+	}
+	for _, result := range failures {
+		message := result.Error
+		if len(result.Failures) > 0 {
+			message = result.Failures[0].Message
+		}
+		title := fmt.Sprintf("%s %s with %s", resultEmoji(result.Result), result.Task, result.ConfigID())
+		if result.Run > 0 {
+			title += fmt.Sprintf(" (run %d)", result.Run)
+		}
+		buffer.WriteString(fmt.Sprintf("<details>\n<summary>%s</summary>\n\n```\n%s\n```\n\n</details>\n\n", title, strings.ReplaceAll(message, "```", "` ` `")))
+	}
+
+	if err := os.WriteFile(config.ReportMarkdown, []byte(buffer.String()), 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", config.ReportMarkdown, err)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the map in sorted order.
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,299 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// metricsNamespace prefixes the names of the metrics of the run.
+const metricsNamespace = "k8s_ai_bench_"
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the duration histograms.
+var durationBuckets = []float64{5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// runMetrics holds the metrics of the run, in the Prometheus text format.
+// It is safe for concurrent use, and a nil *runMetrics records nothing.
+type runMetrics struct {
+	mutex sync.Mutex
+	// completed counts the task results by result and LLM config.
+	completed map[[2]string]float64
+	// durations holds the histograms of the phases of the tasks, by phase and LLM config.
+	durations map[[2]string]*histogram
+	// tokens counts the LLM tokens by LLM config and kind (prompt or completion), and cost the estimated cost.
+	tokens map[[2]string]float64
+	cost   map[string]float64
+	// inFlight counts the tasks being evaluated, and clusters the isolated clusters in use.
+	inFlight float64
+	clusters float64
+}
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		completed: make(map[[2]string]float64),
+		durations: make(map[[2]string]*histogram),
+		tokens:    make(map[[2]string]float64),
+		cost:      make(map[string]float64),
+	}
+}
+
+type histogram struct {
+	counts []float64
+	count  float64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range durationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// taskStarted and taskFinished track the tasks being evaluated.
+func (m *runMetrics) taskStarted() {
+	if m == nil {
+		return
+	}
+	m.addGauge(&m.inFlight, 1)
+}
+
+func (m *runMetrics) taskFinished() {
+	if m == nil {
+		return
+	}
+	m.addGauge(&m.inFlight, -1)
+}
+
+// clusterCreated and clusterReleased track the isolated clusters in use.
+func (m *runMetrics) clusterCreated() {
+	if m == nil {
+		return
+	}
+	m.addGauge(&m.clusters, 1)
+}
+
+func (m *runMetrics) clusterReleased() {
+	if m == nil {
+		return
+	}
+	m.addGauge(&m.clusters, -1)
+}
+
+func (m *runMetrics) addGauge(gauge *float64, delta float64) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	*gauge += delta
+}
+
+// recordResult records a task result: its count, and for evaluated tasks their durations and usage.
+func (m *runMetrics) recordResult(result model.TaskResult) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	id := result.ConfigID()
+	m.completed[[2]string{result.Result, id}]++
+
+	if result.Resumed {
+		return
+	}
+	if timing := result.Timing; timing != nil {
+		if !timing.EndTime.IsZero() {
+			m.observe("task", id, timing.EndTime.Sub(timing.StartTime))
+		}
+		for phase, value := range map[string]string{"agent": timing.Agent, "verify": timing.Verify} {
+			if d, err := time.ParseDuration(value); err == nil {
+				m.observe(phase, id, d)
+			}
+		}
+	}
+	if usage := result.Usage; usage != nil {
+		m.tokens[[2]string{id, "prompt"}] += float64(usage.PromptTokens)
+		m.tokens[[2]string{id, "completion"}] += float64(usage.CompletionTokens)
+		if usage.Cost != nil {
+			m.cost[id] += *usage.Cost
+		}
+	}
+}
+
+func (m *runMetrics) observe(phase, id string, d time.Duration) {
+	key := [2]string{phase, id}
+	h := m.durations[key]
+	if h == nil {
+		h = &histogram{counts: make([]float64, len(durationBuckets))}
+		m.durations[key] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// write writes the metrics in the Prometheus text exposition format.
+func (m *runMetrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsNamespace, name, help, metricsNamespace, name, kind)
+	}
+
+	header("tasks_completed_total", "counter", "Task results, by result and LLM config.")
+	for _, key := range sortedKeyPairs(m.completed) {
+		fmt.Fprintf(w, "%stasks_completed_total{result=%s,llm_config=%s} %v\n", metricsNamespace, quoteLabel(key[0]), quoteLabel(key[1]), m.completed[key])
+	}
+
+	header("tasks_in_flight", "gauge", "Tasks being evaluated.")
+	fmt.Fprintf(w, "%stasks_in_flight %v\n", metricsNamespace, m.inFlight)
+	header("isolated_clusters_in_use", "gauge", "Isolated clusters created for tasks and not yet released.")
+	fmt.Fprintf(w, "%sisolated_clusters_in_use %v\n", metricsNamespace, m.clusters)
+
+	for _, phase := range []string{"task", "agent", "verify"} {
+		name := phase + "_duration_seconds"
+		help := fmt.Sprintf("Duration of the %s phase of tasks, by LLM config.", phase)
+		if phase == "task" {
+			help = "Duration of tasks, by LLM config."
+		}
+		header(name, "histogram", help)
+		for _, key := range sortedHistogramKeys(m.durations) {
+			if key[0] != phase {
+				continue
+			}
+			h := m.durations[key]
+			label := "llm_config=" + quoteLabel(key[1])
+			for i, bound := range durationBuckets {
+				fmt.Fprintf(w, "%s%s_bucket{%s,le=%q} %v\n", metricsNamespace, name, label, strconv.FormatFloat(bound, 'f', -1, 64), h.counts[i])
+			}
+			fmt.Fprintf(w, "%s%s_bucket{%s,le=\"+Inf\"} %v\n", metricsNamespace, name, label, h.count)
+			fmt.Fprintf(w, "%s%s_sum{%s} %v\n", metricsNamespace, name, label, h.sum)
+			fmt.Fprintf(w, "%s%s_count{%s} %v\n", metricsNamespace, name, label, h.count)
+		}
+	}
+
+	header("llm_tokens_total", "counter", "LLM tokens used by the agent, by LLM config and kind.")
+	for _, key := range sortedKeyPairs(m.tokens) {
+		fmt.Fprintf(w, "%sllm_tokens_total{llm_config=%s,kind=%s} %v\n", metricsNamespace, quoteLabel(key[0]), quoteLabel(key[1]), m.tokens[key])
+	}
+	header("llm_cost_usd_total", "counter", "Estimated cost of the LLM usage of the agent in USD, by LLM config.")
+	ids := make([]string, 0, len(m.cost))
+	for id := range m.cost {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(w, "%sllm_cost_usd_total{llm_config=%s} %v\n", metricsNamespace, quoteLabel(id), m.cost[id])
+	}
+}
+
+func sortedKeyPairs(m map[[2]string]float64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+func sortedHistogramKeys(m map[[2]string]*histogram) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][1] < keys[j][1] })
+	return keys
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines.
+func quoteLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+// serveMetrics serves the metrics on /metrics at addr until the returned function is called.
+func serveMetrics(addr string, metrics *runMetrics) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("metrics server failed", "error", err)
+		}
+	}()
+	slog.Info("Serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// pushMetrics pushes the metrics to a Prometheus Pushgateway, grouped by job and run ID.
+func pushMetrics(ctx context.Context, gateway string, runID string, metrics *runMetrics) error {
+	endpoint, err := url.JoinPath(gateway, "metrics", "job", "k8s-ai-bench", "run_id", runID)
+	if err != nil {
+		return fmt.Errorf("invalid Pushgateway URL %q: %w", gateway, err)
+	}
+	var body bytes.Buffer
+	metrics.write(&body)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("pushing metrics: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// Formats of the --notify-webhook payload.
+const (
+	NotifyFormatJSON  = "json"
+	NotifyFormatSlack = "slack"
+)
+
+// runNotification is the payload posted to --notify-webhook in the json format.
+type runNotification struct {
+	RunID string `json:"runID"`
+	// Status is "success" if the run completed without error, otherwise "error".
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration string  `json:"duration"`
+	Seconds  float64 `json:"durationSeconds"`
+	// PassRates are the results of each LLM config (prefixed with the agent ID, when comparing agents).
+	PassRates []notificationPassRate `json:"passRates"`
+	// Baseline and Regressions are set if the run was compared with a --baseline.
+	Baseline    string   `json:"baseline,omitempty"`
+	Regressions []string `json:"regressions,omitempty"`
+	// OutputURL is the link to the outputs of the run, from --notify-output-url.
+	OutputURL string `json:"outputURL,omitempty"`
+}
+
+type notificationPassRate struct {
+	LLMConfig string  `json:"llmConfig"`
+	Total     int     `json:"total"`
+	Success   int     `json:"success"`
+	Fail      int     `json:"fail"`
+	Error     int     `json:"error"`
+	PassRate  float64 `json:"passRate"`
+}
+
+// outputURLValues are the values available in the --notify-output-url template.
+type outputURLValues struct {
+	RunID string
+}
+
+// parseOutputURLTemplate parses the --notify-output-url template, e.g. https://example.com/runs/{{.RunID}}/.
+func parseOutputURLTemplate(text string) (*template.Template, error) {
+	t, err := template.New("notify-output-url").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, outputURLValues{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// buildNotification summarizes the run for --notify-webhook. Regressions are the task and
+// LLM config pairs that passed in the baseline and no longer do; a baseline that cannot be
+// loaded is reported in the notification's error, rather than failing it.
+func buildNotification(config EvalConfig, duration time.Duration, results []model.TaskResult, runErr error) runNotification {
+	n := runNotification{
+		RunID:     config.RunID,
+		Status:    "success",
+		Duration:  duration.Round(time.Second).String(),
+		Seconds:   duration.Seconds(),
+		PassRates: []notificationPassRate{},
+	}
+	var errs []string
+	if runErr != nil {
+		n.Status = "error"
+		errs = append(errs, runErr.Error())
+	}
+	for _, summary := range summarizeLLMConfigs(results) {
+		n.PassRates = append(n.PassRates, notificationPassRate{
+			LLMConfig: summary.ID,
+			Total:     summary.Total - summary.Skipped,
+			Success:   summary.Success,
+			Fail:      summary.Fail,
+			Error:     summary.Error,
+			PassRate:  summary.PassRate,
+		})
+	}
+	if config.Baseline != "" {
+		n.Baseline = config.Baseline
+		if baseline, err := loadRunResults(config.Baseline); err != nil {
+			errs = append(errs, fmt.Sprintf("loading baseline: %v", err))
+		} else {
+			for _, change := range compareResults(baseline.Results, results).Regressions {
+				n.Regressions = append(n.Regressions, fmt.Sprintf("%s with %s (%s -> %s)", change.Task, change.LLMConfig, change.Old, change.New))
+			}
+		}
+	}
+	if config.notifyOutputURL != nil {
+		var outputURL strings.Builder
+		if err := config.notifyOutputURL.Execute(&outputURL, outputURLValues{RunID: config.RunID}); err != nil {
+			errs = append(errs, fmt.Sprintf("rendering output URL: %v", err))
+		} else {
+			n.OutputURL = outputURL.String()
+		}
+	}
+	n.Error = strings.Join(errs, "; ")
+	return n
+}
+
+// notificationPayload encodes the notification in the given format: the runNotification as is,
+// or a Slack message with its contents as mrkdwn text.
+func notificationPayload(format string, n runNotification) ([]byte, error) {
+	switch format {
+	case NotifyFormatJSON:
+		return json.Marshal(n)
+	case NotifyFormatSlack:
+		return json.Marshal(map[string]string{"text": slackText(n)})
+	default:
+		return nil, fmt.Errorf("unknown notification format %q (must be %s or %s)", format, NotifyFormatJSON, NotifyFormatSlack)
+	}
+}
+
+func slackText(n runNotification) string {
+	var text strings.Builder
+	status := "completed"
+	if n.Status != "success" {
+		status = "failed"
+	}
+	fmt.Fprintf(&text, "*k8s-ai-bench run %s %s* in %s\n", n.RunID, status, n.Duration)
+	if n.Error != "" {
+		fmt.Fprintf(&text, "Error: %s\n", n.Error)
+	}
+	for _, rate := range n.PassRates {
+		fmt.Fprintf(&text, "• `%s`: %d%% (%d/%d passed, %d failed, %d errors)\n", rate.LLMConfig,
+			calculatePercentage(rate.Success, rate.Total), rate.Success, rate.Total, rate.Fail, rate.Error)
+	}
+	if n.Baseline != "" {
+		if len(n.Regressions) == 0 {
+			fmt.Fprintf(&text, "No regressions compared to the baseline\n")
+		} else {
+			fmt.Fprintf(&text, "%d regressions compared to the baseline:\n", len(n.Regressions))
+			for _, regression := range n.Regressions {
+				fmt.Fprintf(&text, "• %s\n", regression)
+			}
+		}
+	}
+	if n.OutputURL != "" {
+		fmt.Fprintf(&text, "<%s|Outputs>\n", n.OutputURL)
+	}
+	return text.String()
+}
+
+// notifyWebhook posts the notification of the run to config.NotifyWebhook.
+func notifyWebhook(ctx context.Context, config EvalConfig, n runNotification) error {
+	payload, err := notificationPayload(config.NotifyFormat, n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.NotifyWebhook, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid --notify-webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Webhook URLs often embed a secret, so they are left out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("sending notification: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+func notifyResult(task, result string) model.TaskResult {
+	return model.TaskResult{Task: task, LLMConfig: model.LLMConfig{ID: "m1"}, Result: result}
+}
+
+func TestNotificationPayloads(t *testing.T) {
+	results := []model.TaskResult{notifyResult("a", "success"), notifyResult("b", "fail")}
+	passRates := []notificationPassRate{{LLMConfig: "m1", Total: 2, Success: 1, Fail: 1, PassRate: 0.5}}
+
+	tests := []struct {
+		name string
+		// baseline are the results of the --baseline run, if set.
+		baseline    []model.TaskResult
+		outputURL   string
+		runErr      error
+		want        runNotification
+		wantInSlack []string
+	}{
+		{
+			name: "completed",
+			want: runNotification{RunID: "r1", Status: "success", Duration: "1m30s", Seconds: 90, PassRates: passRates},
+			wantInSlack: []string{
+				"*k8s-ai-bench run r1 completed* in 1m30s\n",
+				"• `m1`: 50% (1/2 passed, 1 failed, 0 errors)\n",
+			},
+		},
+		{
+			name:     "baseline without regressions",
+			baseline: []model.TaskResult{notifyResult("a", "success"), notifyResult("b", "fail")},
+			want:     runNotification{RunID: "r1", Status: "success", Duration: "1m30s", Seconds: 90, PassRates: passRates, Baseline: "baseline"},
+			wantInSlack: []string{
+				"No regressions compared to the baseline\n",
+			},
+		},
+		{
+			name:     "baseline regressions",
+			baseline: []model.TaskResult{notifyResult("a", "success"), notifyResult("b", "success")},
+			want: runNotification{RunID: "r1", Status: "success", Duration: "1m30s", Seconds: 90, PassRates: passRates, Baseline: "baseline",
+				Regressions: []string{"b with m1 (success -> fail)"}},
+			wantInSlack: []string{
+				"1 regressions compared to the baseline:\n• b with m1 (success -> fail)\n",
+			},
+		},
+		{
+			name:      "output URL",
+			outputURL: "https://example.com/runs/{{.RunID}}/",
+			want:      runNotification{RunID: "r1", Status: "success", Duration: "1m30s", Seconds: 90, PassRates: passRates, OutputURL: "https://example.com/runs/r1/"},
+			wantInSlack: []string{
+				"<https://example.com/runs/r1/|Outputs>\n",
+			},
+		},
+		{
+			name:   "run error",
+			runErr: errors.New("creating the cluster: timed out"),
+			want:   runNotification{RunID: "r1", Status: "error", Error: "creating the cluster: timed out", Duration: "1m30s", Seconds: 90, PassRates: passRates},
+			wantInSlack: []string{
+				"*k8s-ai-bench run r1 failed* in 1m30s\n",
+				"Error: creating the cluster: timed out\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := EvalConfig{RunID: "r1"}
+			if tt.baseline != nil {
+				dir := t.TempDir()
+				data, err := json.Marshal(model.RunResults{Results: tt.baseline})
+				if err != nil {
+					t.Fatal(err)
+				}
+				config.Baseline = filepath.Join(dir, "baseline")
+				if err := os.WriteFile(config.Baseline, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				tt.want.Baseline = config.Baseline
+			}
+			if tt.outputURL != "" {
+				outputURL, err := parseOutputURLTemplate(tt.outputURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				config.notifyOutputURL = outputURL
+			}
+			n := buildNotification(config, 90*time.Second, results, tt.runErr)
+
+			payload, err := notificationPayload(NotifyFormatJSON, n)
+			if err != nil {
+				t.Fatalf("JSON payload: %v", err)
+			}
+			var got runNotification
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatalf("parsing JSON payload %s: %v", payload, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSON payload = %+v, want %+v", got, tt.want)
+			}
+
+			payload, err = notificationPayload(NotifyFormatSlack, n)
+			if err != nil {
+				t.Fatalf("Slack payload: %v", err)
+			}
+			var slack map[string]string
+			if err := json.Unmarshal(payload, &slack); err != nil {
+				t.Fatalf("parsing Slack payload %s: %v", payload, err)
+			}
+			for _, want := range tt.wantInSlack {
+				if !strings.Contains(slack["text"], want) {
+					t.Errorf("Slack text = %q, want it to contain %q", slack["text"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNotificationPayloadUnknownFormat(t *testing.T) {
+	if _, err := notificationPayload("xml", runNotification{}); err == nil {
+		t.Error("notificationPayload(xml) = nil error, want an error")
+	}
+}
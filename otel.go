@@ -0,0 +1,283 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otelExportInterval is how often ended spans are exported.
+const otelExportInterval = 5 * time.Second
+
+// tracer records OpenTelemetry spans of the run, and exports them with OTLP over HTTP (JSON encoding).
+// Spans are started with startSpan, which does nothing if the context has no tracer.
+type tracer struct {
+	endpoint string
+	client   *http.Client
+
+	mutex sync.Mutex
+	spans []*span
+	// warned is set once an export failed, so failures are only reported once.
+	warned bool
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// newTracer returns a tracer exporting to the OTLP/HTTP endpoint (e.g. http://localhost:4318).
+func newTracer(endpoint string) *tracer {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	t := &tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client:   &http.Client{Timeout: 30 * time.Second},
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+func (t *tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(otelExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopCh:
+			t.export()
+			return
+		case <-ticker.C:
+			t.export()
+		}
+	}
+}
+
+// shutdown exports the remaining spans. A nil tracer does nothing.
+func (t *tracer) shutdown() {
+	if t == nil {
+		return
+	}
+	close(t.stopCh)
+	<-t.done
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+
+// withTracer returns a context in which spans are recorded by t.
+func withTracer(ctx context.Context, t *tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// span is a span of the run. A nil span records nothing.
+type span struct {
+	tracer     *tracer
+	name       string
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	start      time.Time
+	end        time.Time
+	attributes map[string]any
+	events     []spanEvent
+	err        string
+}
+
+type spanEvent struct {
+	time       time.Time
+	name       string
+	attributes map[string]any
+}
+
+// startSpan starts a span, as a child of the span in ctx if any. It returns nil if ctx has no tracer.
+func startSpan(ctx context.Context, name string, attributes map[string]any) (context.Context, *span) {
+	t, _ := ctx.Value(tracerKey{}).(*tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &span{tracer: t, name: name, start: time.Now(), attributes: make(map[string]any)}
+	for k, v := range attributes {
+		s.attributes[k] = v
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok && parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// traceIDString returns the trace ID of the span in hex, or "" for a nil span.
+func (s *span) traceIDString() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func (s *span) setAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// addEvent records an event on the span, e.g. a failure of the task.
+func (s *span) addEvent(name string, attributes map[string]any) {
+	if s == nil {
+		return
+	}
+	s.events = append(s.events, spanEvent{time: time.Now(), name: name, attributes: attributes})
+}
+
+// finish ends the span, recording err if not nil.
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.fail(err.Error())
+		s.addEvent("exception", map[string]any{"exception.message": err.Error()})
+	}
+	s.end = time.Now()
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+// fail sets the status of the span to an error with the message.
+func (s *span) fail(message string) {
+	if s == nil {
+		return
+	}
+	s.err = message
+}
+
+// export sends the ended spans to the endpoint.
+func (t *tracer) export() {
+	t.mutex.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mutex.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	var otlpSpans []map[string]any
+	for _, s := range spans {
+		otlpSpan := map[string]any{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attributes),
+		}
+		if s.parentID != [8]byte{} {
+			otlpSpan["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			otlpSpan["status"] = map[string]any{"code": 2, "message": s.err} // STATUS_CODE_ERROR
+		}
+		var events []map[string]any
+		for _, e := range s.events {
+			events = append(events, map[string]any{
+				"timeUnixNano": strconv.FormatInt(e.time.UnixNano(), 10),
+				"name":         e.name,
+				"attributes":   otlpAttributes(e.attributes),
+			})
+		}
+		if len(events) > 0 {
+			otlpSpan["events"] = events
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+	request := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": "k8s-ai-bench"}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/gke-labs/k8s-ai-bench"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+
+	if err := t.post(request); err != nil {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if !t.warned {
+			t.warned = true
+			slog.Warn("failed to export OpenTelemetry spans", "error", err)
+		}
+	}
+}
+
+func (t *tracer) post(request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// otlpAttributes converts attributes to OTLP key-values, sorted by key.
+func otlpAttributes(attributes map[string]any) []map[string]any {
+	var keyValues []map[string]any
+	for _, key := range sortedKeys(attributes) {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		keyValues = append(keyValues, map[string]any{"key": key, "value": value})
+	}
+	return keyValues
+}
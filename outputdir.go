@@ -0,0 +1,91 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// errFoundResults stops the walk of an output directory at the first results found.
+var errFoundResults = errors.New("found results")
+
+// checkOutputDir refuses to run into an output directory that holds the results of a previous
+// run, unless the run resumes it or config.Overwrite is set.
+func checkOutputDir(config EvalConfig) error {
+	if config.OutputDir == "" || config.Resume || config.Overwrite {
+		return nil
+	}
+	found, err := findPreviousResults(config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("checking output directory: %w", err)
+	}
+	if found != "" {
+		return fmt.Errorf("output directory %s already holds the results of a run (%s); pass --overwrite to replace them, --resume to continue that run, or use another --output-dir or --run-id", config.OutputDir, found)
+	}
+	return nil
+}
+
+// findPreviousResults returns the path of the first results or run metadata file in dir,
+// or "" if there is none (or no dir).
+func findPreviousResults(dir string) (string, error) {
+	var found string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !entry.IsDir() && (entry.Name() == "results.yaml" || entry.Name() == runMetadataFile) {
+			found = path
+			return errFoundResults
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFoundResults) {
+		return "", err
+	}
+	return found, nil
+}
+
+// removeStaleOutputs removes the outputs of a previous run for the task and LLM config pairs
+// that are about to run with --overwrite, so its logs cannot be mistaken for those of this run.
+func removeStaleOutputs(config EvalConfig, tasks map[string]Task, rerun *rerunSelection) error {
+	removed := 0
+	for taskID := range tasks {
+		for _, configID := range runConfigIDs(config) {
+			if !rerun.selects(taskID, configID) {
+				continue
+			}
+			dir := filepath.Join(config.OutputDir, filepath.FromSlash(taskID), configID)
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("removing previous outputs: %w", err)
+			}
+			removed++
+		}
+	}
+	if removed > 0 {
+		slog.Info("Removed the outputs of a previous run", "pairs", removed, "dir", config.OutputDir)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Operations of the cluster providers, whose CLI commands are each bounded by the timeout of their operation.
+const (
+	OpCreate        = "create"
+	OpDelete        = "delete"
+	OpGetKubeconfig = "getkubeconfig"
+	OpList          = "list"
+	OpLoadImage     = "loadimage"
+	OpExportLogs    = "exportlogs"
+)
+
+// Operations are the operations that have a timeout.
+var Operations = []string{OpCreate, OpDelete, OpGetKubeconfig, OpList, OpLoadImage, OpExportLogs}
+
+// Timeouts bound each CLI command run by a provider, by operation. Operations without a timeout
+// use DefaultTimeouts.
+type Timeouts map[string]time.Duration
+
+// DefaultTimeouts are the timeouts of the operations, unless they are configured or the provider has its own.
+var DefaultTimeouts = Timeouts{
+	OpCreate:        15 * time.Minute,
+	OpDelete:        5 * time.Minute,
+	OpGetKubeconfig: 2 * time.Minute,
+	OpList:          time.Minute,
+	OpLoadImage:     10 * time.Minute,
+	OpExportLogs:    10 * time.Minute,
+}
+
+// WithDefaults returns the timeouts, with those of defaults for the operations they do not set.
+func (t Timeouts) WithDefaults(defaults Timeouts) Timeouts {
+	merged := make(Timeouts)
+	for op, timeout := range defaults {
+		merged[op] = timeout
+	}
+	for op, timeout := range t {
+		if timeout > 0 {
+			merged[op] = timeout
+		}
+	}
+	return merged
+}
+
+// For returns the timeout of the operation.
+func (t Timeouts) For(op string) time.Duration {
+	if timeout := t[op]; timeout > 0 {
+		return timeout
+	}
+	return DefaultTimeouts[op]
+}
+
+// Command is a CLI command run for a provider operation. If it runs longer than the timeout of its
+// operation, its whole process group is killed, and it fails with an error naming the operation.
+type Command struct {
+	*exec.Cmd
+	ctx    context.Context
+	cancel context.CancelFunc
+	// timedOut is the cause of ctx when the timeout of the operation expired.
+	timedOut error
+}
+
+// Command returns the command name with args for the operation.
+func (t Timeouts) Command(op, name string, args ...string) *Command {
+	return t.CommandContext(context.Background(), op, name, args...)
+}
+
+// CommandContext returns the command name with args for the operation, also stopped when ctx is done.
+func (t Timeouts) CommandContext(ctx context.Context, op, name string, args ...string) *Command {
+	timeout := t.For(op)
+	timedOut := fmt.Errorf("%s operation timed out after %v", op, timeout)
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, timedOut)
+	cmd := exec.CommandContext(ctx, name, args...)
+	// The CLIs start processes of their own (e.g. kind's docker commands), which are killed with them.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// Output is not waited for after the kill, in case a process outside the group holds the pipes.
+	cmd.WaitDelay = 10 * time.Second
+	return &Command{Cmd: cmd, ctx: ctx, cancel: cancel, timedOut: timedOut}
+}
+
+func (c *Command) Run() error {
+	defer c.cancel()
+	return c.wrap(c.Cmd.Run())
+}
+
+func (c *Command) Output() ([]byte, error) {
+	defer c.cancel()
+	output, err := c.Cmd.Output()
+	return output, c.wrap(err)
+}
+
+func (c *Command) CombinedOutput() ([]byte, error) {
+	defer c.cancel()
+	output, err := c.Cmd.CombinedOutput()
+	return output, c.wrap(err)
+}
+
+// wrap explains the error of a command that was killed at the timeout of its operation.
+func (c *Command) wrap(err error) error {
+	if err != nil && context.Cause(c.ctx) == c.timedOut {
+		return fmt.Errorf("%w running %q: %w", c.timedOut, strings.Join(c.Args, " "), err)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"fmt"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+)
+
+// Provider targets an existing cluster that is managed outside of k8s-ai-bench.
+// It never creates or deletes clusters.
+type Provider struct {
+	KubeConfig  string
+	ContextName string
+	// Timeouts bound the kubectl commands, by operation.
+	Timeouts cluster.Timeouts
+}
+
+func New(kubeconfigPath, contextName string, timeouts cluster.Timeouts) cluster.Provider {
+	return &Provider{
+		KubeConfig:  kubeconfigPath,
+		ContextName: contextName,
+		Timeouts:    timeouts,
+	}
+}
+
+// Exists always reports true; the cluster is assumed to be already running.
+func (p *Provider) Exists(name string) (bool, error) {
+	return true, nil
+}
+
+// List returns no clusters; the external cluster is not managed by us.
+func (p *Provider) List() ([]string, error) {
+	return nil, nil
+}
+
+func (p *Provider) Create(name string) error {
+	return fmt.Errorf("cannot create cluster %q: the external cluster provider only targets an existing cluster, so tasks using isolation mode %q are not supported", name, "cluster")
+}
+
+// Delete is a no-op; we never delete a cluster we did not create.
+func (p *Provider) Delete(name string) error {
+	return nil
+}
+
+// GetKubeconfig returns a minified, self-contained kubeconfig for the configured context.
+// The name is ignored, as there is only ever one external cluster.
+func (p *Provider) GetKubeconfig(name string) ([]byte, error) {
+	args := []string{"config", "view", "--minify", "--flatten"}
+	if p.KubeConfig != "" {
+		args = append(args, "--kubeconfig", p.KubeConfig)
+	}
+	if p.ContextName != "" {
+		args = append(args, "--context", p.ContextName)
+	}
+
+	output, err := p.Timeouts.Command(cluster.OpGetKubeconfig, "kubectl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract kubeconfig for context %q: %w", p.ContextName, err)
+	}
+	return output, nil
+}
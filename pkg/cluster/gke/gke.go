@@ -0,0 +1,206 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gke
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+)
+
+// defaultTimeouts are the timeouts of the gcloud commands, unless configured. A cluster creation
+// usually takes 5-10 minutes; the delete timeout bounds how long we wait for an (async) cluster
+// deletion to complete.
+var defaultTimeouts = cluster.Timeouts{
+	cluster.OpCreate:        20 * time.Minute,
+	cluster.OpDelete:        20 * time.Minute,
+	cluster.OpList:          2 * time.Minute,
+	cluster.OpGetKubeconfig: 2 * time.Minute,
+}
+
+// pollInterval is how often we check on asynchronous operations.
+const pollInterval = 15 * time.Second
+
+type Options struct {
+	// Project is the GCP project to create clusters in.
+	Project string
+	// Location is the region or zone of the clusters (e.g. us-central1 or us-central1-a).
+	Location string
+	// MachineType is the node machine type for standard clusters (e.g. e2-standard-4).
+	MachineType string
+	// NumNodes is the number of nodes per zone for standard clusters; 0 uses the gcloud default.
+	NumNodes int
+	// Autopilot creates autopilot clusters instead of standard clusters.
+	Autopilot bool
+	// Timeouts bound the gcloud commands, by operation.
+	Timeouts cluster.Timeouts
+}
+
+type Provider struct {
+	Options
+}
+
+func New(opts Options) (cluster.Provider, error) {
+	if opts.Project == "" {
+		return nil, fmt.Errorf("project is required for the gke cluster provider")
+	}
+	if opts.Location == "" {
+		return nil, fmt.Errorf("location is required for the gke cluster provider")
+	}
+	opts.Timeouts = opts.Timeouts.WithDefaults(defaultTimeouts)
+	return &Provider{Options: opts}, nil
+}
+
+// locationArgs returns the common --project/--location flags.
+func (p *Provider) locationArgs() []string {
+	return []string{"--project", p.Project, "--location", p.Location}
+}
+
+func (p *Provider) Exists(name string) (bool, error) {
+	names, err := p.List()
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *Provider) List() ([]string, error) {
+	args := append([]string{"container", "clusters", "list", "--format", "value(name)"}, p.locationArgs()...)
+	output, err := p.Timeouts.Command(cluster.OpList, "gcloud", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'gcloud container clusters list': %w", err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (p *Provider) CreationTime(name string) (time.Time, error) {
+	args := append([]string{"container", "clusters", "describe", name, "--format", "value(createTime)"}, p.locationArgs()...)
+	output, err := p.Timeouts.Command(cluster.OpList, "gcloud", args...).Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to describe GKE cluster %q: %w", name, err)
+	}
+	created, err := time.Parse(time.RFC3339, strings.TrimSpace(string(output)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse creation time of GKE cluster %q: %w", name, err)
+	}
+	return created, nil
+}
+
+func (p *Provider) Create(name string) error {
+	var args []string
+	if p.Autopilot {
+		args = []string{"container", "clusters", "create-auto", name}
+	} else {
+		args = []string{"container", "clusters", "create", name}
+		if p.MachineType != "" {
+			args = append(args, "--machine-type", p.MachineType)
+		}
+		if p.NumNodes > 0 {
+			args = append(args, "--num-nodes", strconv.Itoa(p.NumNodes))
+		}
+	}
+	args = append(args, p.locationArgs()...)
+	args = append(args, "--quiet")
+
+	var createErr error
+	for retry := range 3 {
+		if retry > 0 {
+			slog.Info("Retrying GKE cluster creation", "name", name, "attempt", retry+1)
+			time.Sleep(30 * time.Second)
+
+			// A failed attempt can leave a half-created cluster behind, which blocks re-creation.
+			if exists, err := p.Exists(name); err == nil && exists {
+				if err := p.Delete(name); err != nil {
+					slog.Warn("failed to delete partially created GKE cluster", "name", name, "error", err)
+				}
+			}
+		}
+
+		createCmd := p.Timeouts.Command(cluster.OpCreate, "gcloud", args...)
+		slog.Info("Creating GKE cluster", "name", name, "location", p.Location)
+		createCmd.Stdout = os.Stdout
+		createCmd.Stderr = os.Stderr
+		createErr = createCmd.Run()
+		if createErr == nil {
+			return nil
+		}
+		slog.Warn("failed to create GKE cluster, retrying", "name", name, "error", createErr)
+	}
+	return fmt.Errorf("failed to create GKE cluster after multiple retries: %w", createErr)
+}
+
+// Delete starts an asynchronous deletion and polls until the cluster is gone.
+func (p *Provider) Delete(name string) error {
+	args := append([]string{"container", "clusters", "delete", name, "--quiet", "--async"}, p.locationArgs()...)
+
+	deleteCmd := p.Timeouts.Command(cluster.OpDelete, "gcloud", args...)
+	slog.Info("Deleting GKE cluster", "name", name)
+	deleteCmd.Stdout = os.Stdout
+	deleteCmd.Stderr = os.Stderr
+	if err := deleteCmd.Run(); err != nil {
+		return fmt.Errorf("failed to start deletion of GKE cluster %q: %w", name, err)
+	}
+
+	deleteTimeout := p.Timeouts.For(cluster.OpDelete)
+	deadline := time.Now().Add(deleteTimeout)
+	for time.Now().Before(deadline) {
+		exists, err := p.Exists(name)
+		if err != nil {
+			slog.Debug("failed to check GKE cluster status, will retry", "name", name, "error", err)
+		} else if !exists {
+			return nil
+		}
+		time.Sleep(pollInterval)
+	}
+	return fmt.Errorf("GKE cluster %q still exists %v after deletion was requested", name, deleteTimeout)
+}
+
+// GetKubeconfig writes credentials to a temporary kubeconfig with get-credentials and returns its contents.
+func (p *Provider) GetKubeconfig(name string) ([]byte, error) {
+	tmpFile, err := os.CreateTemp("", "gke-kubeconfig-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp kubeconfig file: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	args := append([]string{"container", "clusters", "get-credentials", name}, p.locationArgs()...)
+	cmd := p.Timeouts.Command(cluster.OpGetKubeconfig, "gcloud", args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", tmpFile.Name()))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get credentials for GKE cluster %q: %w: %s", name, err, stderr.String())
+	}
+
+	return os.ReadFile(tmpFile.Name())
+}
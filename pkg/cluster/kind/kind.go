@@ -15,28 +15,117 @@
 package kind
 
 import (
+	"bytes"
 	"fmt"
+	"log/slog"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
 )
 
-type Provider struct{}
+type Options struct {
+	// NodeImage is the node image to use (e.g. kindest/node:v1.29.2); if empty, kind's default is used.
+	NodeImage string
+	// ConfigFile is the path to an explicit kind config file.
+	// If set, it takes precedence over WorkerNodes.
+	ConfigFile string
+	// WorkerNodes is the number of worker nodes to create in addition to the control plane.
+	// If zero, a single-node cluster is created.
+	WorkerNodes int
+	// Timeouts bound the kind and docker commands, by operation.
+	Timeouts cluster.Timeouts
+}
+
+type Provider struct {
+	Options
 
-func New() cluster.Provider {
-	return &Provider{}
+	// runner runs the kind and docker commands; if nil, they are executed.
+	runner runner
 }
 
-func (p *Provider) Exists(name string) (bool, error) {
-	cmd := exec.Command("kind", "get", "clusters")
+// runner runs the commands of the provider operations, so that tests can fake them.
+type runner interface {
+	// output runs the command for the operation and returns its stdout. Its error includes the stderr.
+	output(op, name string, args ...string) ([]byte, error)
+	// run runs the command for the operation, with its output shown on ours.
+	run(op, name string, args ...string) error
+}
+
+// execRunner executes the commands, bounded by the timeouts of their operation.
+type execRunner struct {
+	timeouts cluster.Timeouts
+}
+
+func (r execRunner) output(op, name string, args ...string) ([]byte, error) {
+	cmd := r.timeouts.Command(op, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
 	if err != nil {
-		return false, fmt.Errorf("failed to run 'kind get clusters': %w", err)
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+func (r execRunner) run(op, name string, args ...string) error {
+	cmd := r.timeouts.Command(op, name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// commands returns the runner of the provider's commands.
+func (p *Provider) commands() runner {
+	if p.runner != nil {
+		return p.runner
+	}
+	return execRunner{timeouts: p.Timeouts}
+}
+
+func New(opts Options) cluster.Provider {
+	return &Provider{Options: opts}
+}
+
+// WithWorkerNodes returns a copy of the provider that creates clusters with n worker nodes.
+func (p *Provider) WithWorkerNodes(n int) cluster.Provider {
+	if p.ConfigFile != "" {
+		slog.Warn("ignoring worker node count, the kind config file takes precedence", "workerNodes", n, "configFile", p.ConfigFile)
+	}
+	clone := *p
+	clone.WorkerNodes = n
+	return &clone
+}
+
+// generateConfig writes a kind config with the requested number of worker nodes to a temp file.
+func generateConfig(workerNodes int) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("kind: Cluster\n")
+	sb.WriteString("apiVersion: kind.x-k8s.io/v1alpha4\n")
+	sb.WriteString("nodes:\n")
+	sb.WriteString("- role: control-plane\n")
+	for range workerNodes {
+		sb.WriteString("- role: worker\n")
+	}
+
+	tmpFile, err := os.CreateTemp("", "kind-config-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp kind config file: %w", err)
+	}
+	defer tmpFile.Close()
+	if _, err := tmpFile.WriteString(sb.String()); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write temp kind config file: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
+func (p *Provider) Exists(name string) (bool, error) {
+	clusters, err := p.List()
+	if err != nil {
+		return false, err
 	}
-	clusters := strings.Split(string(output), "\n")
 	for _, cluster := range clusters {
 		if cluster == name {
 			return true, nil
@@ -45,34 +134,137 @@ func (p *Provider) Exists(name string) (bool, error) {
 	return false, nil
 }
 
+func (p *Provider) List() ([]string, error) {
+	output, err := p.commands().output(cluster.OpList, "kind", "get", "clusters")
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'kind get clusters': %w", err)
+	}
+	return parseClusterList(string(output)), nil
+}
+
+// noClustersMessage is what 'kind get clusters' prints when there are no clusters, on stderr
+// in current versions of kind.
+const noClustersMessage = "No kind clusters found."
+
+// parseClusterList returns the cluster names in the output of 'kind get clusters', one per line.
+func parseClusterList(output string) []string {
+	var clusters []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == noClustersMessage {
+			continue
+		}
+		clusters = append(clusters, line)
+	}
+	return clusters
+}
+
+// CreationTime reports when the control-plane node container of the cluster was created.
+func (p *Provider) CreationTime(name string) (time.Time, error) {
+	output, err := p.commands().output(cluster.OpList, "docker", "inspect", "--format", "{{.Created}}", name+"-control-plane")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to inspect control-plane container of kind cluster %q: %w", name, err)
+	}
+	created, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(output)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse creation time of kind cluster %q: %w", name, err)
+	}
+	return created, nil
+}
+
 func (p *Provider) Create(name string) error {
+	args := []string{"create", "cluster", "--name", name, "--wait", "5m"}
+	if p.NodeImage != "" {
+		args = append(args, "--image", p.NodeImage)
+	}
+	if p.ConfigFile != "" {
+		args = append(args, "--config", p.ConfigFile)
+	} else if p.WorkerNodes > 0 {
+		configPath, err := generateConfig(p.WorkerNodes)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(configPath)
+		args = append(args, "--config", configPath)
+	}
+
 	var createErr error
 	for retry := range 3 {
 		if retry > 0 {
-			fmt.Printf("Retrying cluster creation, attempt %d\n", retry+1)
+			slog.Info("Retrying kind cluster creation", "name", name, "attempt", retry+1)
 			time.Sleep(5 * time.Second)
 		}
-		createCmd := exec.Command("kind", "create", "cluster", "--name", name, "--wait", "5m")
-		fmt.Printf("Creating kind cluster %q\n", name)
-		createCmd.Stdout = os.Stdout
-		createCmd.Stderr = os.Stderr
-		createErr = createCmd.Run()
+		slog.Info("Creating kind cluster", "name", name)
+		createErr = p.commands().run(cluster.OpCreate, "kind", args...)
 		if createErr == nil {
 			return nil
 		}
-		fmt.Printf("failed to create kind cluster, retrying...: %v\n", createErr)
+		slog.Warn("failed to create kind cluster, retrying", "name", name, "error", createErr)
 	}
 	return fmt.Errorf("failed to create kind cluster after multiple retries: %w", createErr)
 }
 
+// deleteTimeout bounds how long Delete waits for the cluster to be gone, polling every deletePollInterval.
+// They are variables for tests.
+var (
+	deleteTimeout      = 2 * time.Minute
+	deletePollInterval = 2 * time.Second
+)
+
+// Delete deletes the cluster, and waits until kind no longer lists it and its node containers
+// are gone, since creating a cluster of the same name fails until they are.
 func (p *Provider) Delete(name string) error {
-	deleteCmd := exec.Command("kind", "delete", "cluster", "--name", name)
-	fmt.Printf("Deleting kind cluster %q\n", name)
-	deleteCmd.Stdout = os.Stdout
-	deleteCmd.Stderr = os.Stderr
-	return deleteCmd.Run()
+	slog.Info("Deleting kind cluster", "name", name)
+	if err := p.commands().run(cluster.OpDelete, "kind", "delete", "cluster", "--name", name); err != nil {
+		return fmt.Errorf("failed to delete kind cluster %q: %w", name, err)
+	}
+
+	deadline := time.Now().Add(deleteTimeout)
+	for {
+		remains, err := p.remains(name)
+		if err == nil && remains == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("could not check that kind cluster %q was deleted: %w", name, err)
+			}
+			return fmt.Errorf("kind cluster %q was not gone %v after deleting it: %s", name, deleteTimeout, remains)
+		}
+		time.Sleep(deletePollInterval)
+	}
+}
+
+// remains describes what is left of the cluster, or returns "" if nothing is.
+func (p *Provider) remains(name string) (string, error) {
+	exists, err := p.Exists(name)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "it is still listed by 'kind get clusters'", nil
+	}
+	output, err := p.commands().output(cluster.OpList, "docker", "ps", "--all", "--filter", "label=io.x-k8s.kind.cluster="+name, "--format", "{{.Names}}")
+	if err != nil {
+		// Without docker (e.g. with kind's podman provider), only kind's view is checked.
+		return "", nil
+	}
+	if containers := strings.Fields(string(output)); len(containers) > 0 {
+		return "node containers " + strings.Join(containers, ", ") + " still exist", nil
+	}
+	return "", nil
 }
 
 func (p *Provider) GetKubeconfig(name string) ([]byte, error) {
-	return exec.Command("kind", "get", "kubeconfig", "--name", name).Output()
+	return p.commands().output(cluster.OpGetKubeconfig, "kind", "get", "kubeconfig", "--name", name)
+}
+
+func (p *Provider) ExportLogs(name, dir string) error {
+	slog.Info("Exporting kind cluster logs", "name", name, "dir", dir)
+	return p.commands().run(cluster.OpExportLogs, "kind", "export", "logs", dir, "--name", name)
+}
+
+func (p *Provider) LoadImage(name, image string) error {
+	slog.Info("Loading image into kind cluster", "image", image, "name", name)
+	return p.commands().run(cluster.OpLoadImage, "kind", "load", "docker-image", image, "--name", name)
 }
@@ -0,0 +1,197 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kind
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner fakes the kind and docker CLIs for a single cluster.
+type fakeRunner struct {
+	// clusters is the stdout of 'kind get clusters'; Delete removes the cluster from it unless it lingers.
+	clusters string
+	// listErr and deleteErr are the errors of 'kind get clusters' and 'kind delete cluster'.
+	listErr, deleteErr error
+	// lingers keeps the cluster listed after it is deleted.
+	lingers bool
+	// containers is the stdout of 'docker ps' for the node containers of the cluster.
+	containers string
+
+	deleted bool
+}
+
+func (r *fakeRunner) output(op, name string, args ...string) ([]byte, error) {
+	switch command := name + " " + strings.Join(args, " "); {
+	case command == "kind get clusters":
+		if r.listErr != nil {
+			return nil, r.listErr
+		}
+		return []byte(r.clusters), nil
+	case strings.HasPrefix(command, "docker ps"):
+		return []byte(r.containers), nil
+	default:
+		return nil, errors.New("unexpected command: " + command)
+	}
+}
+
+func (r *fakeRunner) run(op, name string, args ...string) error {
+	if command := name + " " + strings.Join(args, " "); !strings.HasPrefix(command, "kind delete cluster --name ") {
+		return errors.New("unexpected command: " + command)
+	}
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	r.deleted = true
+	if !r.lingers {
+		r.clusters = ""
+	}
+	return nil
+}
+
+func TestExists(t *testing.T) {
+	tests := []struct {
+		name     string
+		clusters string
+		listErr  error
+		want     bool
+		wantErr  string
+	}{
+		{
+			name:     "listed",
+			clusters: "other\ntask\n",
+			want:     true,
+		},
+		{
+			name:     "not listed",
+			clusters: "other\n",
+		},
+		{
+			name: "empty output",
+		},
+		{
+			name:     "trailing whitespace",
+			clusters: "other  \r\n  task \t\n\n",
+			want:     true,
+		},
+		{
+			// Current versions of kind print the message on stderr, older ones on stdout.
+			name:     "no clusters message on stdout",
+			clusters: "No kind clusters found.\n",
+		},
+		{
+			name:    "command failure",
+			listErr: errors.New("exit status 1: Cannot connect to the Docker daemon"),
+			wantErr: "failed to run 'kind get clusters': exit status 1: Cannot connect to the Docker daemon",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{runner: &fakeRunner{clusters: tt.clusters, listErr: tt.listErr}}
+			got, err := p.Exists("task")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Exists() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exists() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Exists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExistsExecuted runs a fake kind CLI, which prints the message of current versions of kind
+// when there are no clusters on stderr only.
+func TestExistsExecuted(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'No kind clusters found.' >&2\n"
+	if err := os.WriteFile(filepath.Join(dir, "kind"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	p := &Provider{}
+	clusters, err := p.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(clusters) != 0 {
+		t.Errorf("List() = %q, want no clusters", clusters)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	timeout, interval := deleteTimeout, deletePollInterval
+	t.Cleanup(func() { deleteTimeout, deletePollInterval = timeout, interval })
+	deleteTimeout, deletePollInterval = 50*time.Millisecond, 10*time.Millisecond
+
+	tests := []struct {
+		name    string
+		runner  *fakeRunner
+		wantErr string
+	}{
+		{
+			name:   "deleted",
+			runner: &fakeRunner{clusters: "task\n"},
+		},
+		{
+			name:    "delete command failure",
+			runner:  &fakeRunner{clusters: "task\n", deleteErr: errors.New("exit status 1")},
+			wantErr: `failed to delete kind cluster "task": exit status 1`,
+		},
+		{
+			name:    "still listed",
+			runner:  &fakeRunner{clusters: "task\n", lingers: true},
+			wantErr: `kind cluster "task" was not gone 50ms after deleting it: it is still listed by 'kind get clusters'`,
+		},
+		{
+			name:    "node containers remain",
+			runner:  &fakeRunner{clusters: "task\n", containers: "task-control-plane\ntask-worker\n"},
+			wantErr: `kind cluster "task" was not gone 50ms after deleting it: node containers task-control-plane, task-worker still exist`,
+		},
+		{
+			name:    "listing fails after the delete",
+			runner:  &fakeRunner{clusters: "task\n", listErr: errors.New("exit status 1")},
+			wantErr: `could not check that kind cluster "task" was deleted: failed to run 'kind get clusters': exit status 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{runner: tt.runner}
+			err := p.Delete("task")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Delete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if !tt.runner.deleted {
+				t.Errorf("Delete() did not run 'kind delete cluster'")
+			}
+		})
+	}
+}
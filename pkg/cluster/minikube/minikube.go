@@ -0,0 +1,148 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package minikube
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+)
+
+type Provider struct {
+	// Driver is the minikube driver to use (e.g. docker, none, kvm2).
+	// If empty, minikube picks its default driver.
+	Driver string
+
+	// KubernetesVersion is the version of kubernetes to start (e.g. v1.30.0).
+	// If empty, minikube picks its default version.
+	KubernetesVersion string
+
+	// Timeouts bound the minikube and kubectl commands, by operation.
+	Timeouts cluster.Timeouts
+}
+
+func New(driver, kubernetesVersion string, timeouts cluster.Timeouts) cluster.Provider {
+	return &Provider{
+		Driver:            driver,
+		KubernetesVersion: kubernetesVersion,
+		Timeouts:          timeouts,
+	}
+}
+
+// profileList is the subset of `minikube profile list -o json` that we care about.
+type profileList struct {
+	Valid []struct {
+		Name   string `json:"Name"`
+		Status string `json:"Status"`
+	} `json:"valid"`
+	Invalid []struct {
+		Name string `json:"Name"`
+	} `json:"invalid"`
+}
+
+func (p *Provider) Exists(name string) (bool, error) {
+	names, err := p.List()
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *Provider) List() ([]string, error) {
+	cmd := p.Timeouts.Command(cluster.OpList, "minikube", "profile", "list", "-o", "json")
+	output, runErr := cmd.Output()
+
+	// minikube exits non-zero when there are no profiles at all, but still prints valid json.
+	var profiles profileList
+	if err := json.Unmarshal(output, &profiles); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("failed to run 'minikube profile list': %w", runErr)
+		}
+		return nil, fmt.Errorf("failed to parse minikube profile list json: %w", err)
+	}
+
+	var names []string
+	for _, profile := range profiles.Valid {
+		names = append(names, profile.Name)
+	}
+	for _, profile := range profiles.Invalid {
+		names = append(names, profile.Name)
+	}
+	return names, nil
+}
+
+func (p *Provider) Create(name string) error {
+	args := []string{"start", "-p", name, "--wait", "all"}
+	if p.Driver != "" {
+		args = append(args, "--driver", p.Driver)
+	}
+	if p.KubernetesVersion != "" {
+		args = append(args, "--kubernetes-version", p.KubernetesVersion)
+	}
+
+	var createErr error
+	for retry := range 3 {
+		if retry > 0 {
+			slog.Info("Retrying minikube cluster creation", "name", name, "attempt", retry+1)
+			time.Sleep(5 * time.Second)
+		}
+		createCmd := p.Timeouts.Command(cluster.OpCreate, "minikube", args...)
+		slog.Info("Creating minikube cluster", "name", name)
+		createCmd.Stdout = os.Stdout
+		createCmd.Stderr = os.Stderr
+		createErr = createCmd.Run()
+		if createErr == nil {
+			return nil
+		}
+		slog.Warn("failed to create minikube cluster, retrying", "name", name, "error", createErr)
+	}
+	return fmt.Errorf("failed to create minikube cluster after multiple retries: %w", createErr)
+}
+
+func (p *Provider) Delete(name string) error {
+	deleteCmd := p.Timeouts.Command(cluster.OpDelete, "minikube", "delete", "-p", name)
+	slog.Info("Deleting minikube cluster", "name", name)
+	deleteCmd.Stdout = os.Stdout
+	deleteCmd.Stderr = os.Stderr
+	return deleteCmd.Run()
+}
+
+func (p *Provider) GetKubeconfig(name string) ([]byte, error) {
+	// minikube merges its contexts into the user's kubeconfig; extract just this profile's
+	// context (with embedded certificates) so it can be written out as a standalone file.
+	cmd := p.Timeouts.Command(cluster.OpGetKubeconfig, "kubectl", "config", "view", "--context", name, "--minify", "--flatten")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract kubeconfig for minikube profile %q: %w", name, err)
+	}
+	return output, nil
+}
+
+func (p *Provider) LoadImage(name, image string) error {
+	loadCmd := p.Timeouts.Command(cluster.OpLoadImage, "minikube", "image", "load", image, "-p", name)
+	slog.Info("Loading image into minikube cluster", "image", image, "name", name)
+	loadCmd.Stdout = os.Stdout
+	loadCmd.Stderr = os.Stderr
+	return loadCmd.Run()
+}
@@ -14,9 +14,38 @@
 
 package cluster
 
+import "time"
+
 type Provider interface {
 	Exists(name string) (bool, error)
 	Create(name string) error
 	Delete(name string) error
 	GetKubeconfig(name string) ([]byte, error)
+	// List returns the names of all clusters known to the provider.
+	List() ([]string, error)
+}
+
+// CreationTimeReporter is implemented by providers that can report when a cluster was created.
+type CreationTimeReporter interface {
+	CreationTime(name string) (time.Time, error)
+}
+
+// WorkerNodesConfigurer is implemented by providers that can create clusters
+// with a specific number of worker nodes.
+type WorkerNodesConfigurer interface {
+	// WithWorkerNodes returns a provider that creates clusters with n worker nodes.
+	WithWorkerNodes(n int) Provider
+}
+
+// LogExporter is implemented by providers that can export cluster logs
+// (e.g. kubelet, apiserver and container runtime logs) for debugging.
+type LogExporter interface {
+	// ExportLogs writes the logs of cluster name into dir.
+	ExportLogs(name, dir string) error
+}
+
+// ImageLoader is implemented by providers that can preload local container images into a cluster.
+type ImageLoader interface {
+	// LoadImage makes the local image available on the nodes of cluster name.
+	LoadImage(name, image string) error
 }
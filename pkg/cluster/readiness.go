@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// WaitForReady polls the cluster described by the kubeconfig file until the API server is ready,
+// all nodes are Ready, and the default service account exists; or until ctx is done.
+func WaitForReady(ctx context.Context, kubeconfigPath string) error {
+	backoff := time.Second
+	const maxBackoff = 10 * time.Second
+	var lastErr error
+	for {
+		lastErr = checkReady(ctx, kubeconfigPath)
+		if lastErr == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("cluster not ready: %w", lastErr)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// checkReady performs a single readiness check, returning an error describing what is not ready yet.
+func checkReady(ctx context.Context, kubeconfigPath string) error {
+	if _, err := kubectl(ctx, kubeconfigPath, "get", "--raw", "/readyz"); err != nil {
+		return fmt.Errorf("API server is not ready: %w", err)
+	}
+
+	output, err := kubectl(ctx, kubeconfigPath, "get", "nodes", "-o", "json")
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+	var nodes struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &nodes); err != nil {
+		return fmt.Errorf("parsing nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		return fmt.Errorf("no nodes registered")
+	}
+	for _, node := range nodes.Items {
+		ready := false
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" && condition.Status == "True" {
+				ready = true
+			}
+		}
+		if !ready {
+			return fmt.Errorf("node %q is not Ready", node.Metadata.Name)
+		}
+	}
+
+	if _, err := kubectl(ctx, kubeconfigPath, "get", "serviceaccount", "default", "-n", "default"); err != nil {
+		return fmt.Errorf("default service account does not exist yet: %w", err)
+	}
+	return nil
+}
+
+// kubectl runs a kubectl command against the cluster and returns its stdout.
+func kubectl(ctx context.Context, kubeconfigPath string, args ...string) ([]byte, error) {
+	args = append([]string{"--kubeconfig", kubeconfigPath, "--request-timeout", "10s"}, args...)
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+)
+
+// listEntry is an entry in the output of `vcluster list`.
+type listEntry struct {
+	Name      string    `json:"Name"`
+	Namespace string    `json:"Namespace"`
+	Status    string    `json:"Status"`
+	Created   time.Time `json:"Created"`
+}
+
+// pausedStatuses are the statuses of virtual clusters that are scaled down: paused by
+// `vcluster pause`, or put to sleep by the sleep mode of newer versions.
+var pausedStatuses = []string{"Paused", "Sleeping"}
+
+// paused reports whether the virtual cluster is scaled down, and must be resumed before use.
+func (e *listEntry) paused() bool {
+	for _, status := range pausedStatuses {
+		if strings.EqualFold(e.Status, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// vcluster runs the vcluster CLI for the operation against the host cluster and returns its stdout.
+func (p *Provider) vcluster(op string, args ...string) ([]byte, error) {
+	if p.HostContext != "" {
+		args = append(args, "--context", p.HostContext)
+	}
+	cmd := p.Timeouts.Command(op, "vcluster", args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", p.HostKubeConfig))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running vcluster %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// listClusters lists the virtual clusters on the host cluster. The JSON output is used when the
+// CLI supports it; older CLIs, which do not, print a table, which is parsed instead.
+func (p *Provider) listClusters() ([]listEntry, error) {
+	output, jsonErr := p.vcluster(cluster.OpList, "list", "--output", "json")
+	if jsonErr == nil {
+		var clusters []listEntry
+		err := json.Unmarshal(output, &clusters)
+		if err == nil {
+			return clusters, nil
+		}
+		jsonErr = fmt.Errorf("parsing the JSON output of vcluster list: %w\n%s", err, output)
+	}
+
+	output, err := p.vcluster(cluster.OpList, "list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vclusters: %w (with --output json: %v)", err, jsonErr)
+	}
+	clusters, err := parseListTable(string(output))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vclusters: %w\n%s (with --output json: %v)", err, output, jsonErr)
+	}
+	slog.Debug("Parsed the table output of vcluster list", "reason", jsonErr)
+	return clusters, nil
+}
+
+// find returns the virtual cluster of that name, or nil if there is none.
+func (p *Provider) find(name string) (*listEntry, error) {
+	clusters, err := p.listClusters()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusters {
+		if clusters[i].Name == name {
+			return &clusters[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// resume resumes the paused virtual cluster.
+func (p *Provider) resume(name string) error {
+	slog.Info("Resuming paused vcluster", "name", name)
+	_, err := p.vcluster(cluster.OpCreate, "resume", name, "--namespace", p.namespace(name))
+	return err
+}
+
+// tableCreatedLayouts are the formats of the CREATED column of the table output.
+var tableCreatedLayouts = []string{"2006-01-02 15:04:05 -0700 MST", time.RFC3339}
+
+// parseListTable parses the table printed by `vcluster list` without --output json. Columns are
+// separated by "|" in newer versions, and aligned with spaces under their header in older ones.
+// Only the NAME column is required.
+func parseListTable(output string) ([]listEntry, error) {
+	lines := strings.Split(output, "\n")
+	header := -1
+	for i, line := range lines {
+		if fields := strings.Fields(strings.ReplaceAll(line, "|", " ")); len(fields) > 0 && fields[0] == "NAME" {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		if strings.TrimSpace(output) == "" || strings.Contains(output, "No entries found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no NAME column in the vcluster list output")
+	}
+
+	columns, split := tableColumns(lines[header])
+	var clusters []listEntry
+	for _, line := range lines[header+1:] {
+		trimmed := strings.TrimSpace(line)
+		// Skip blank lines and the separator under the header.
+		if trimmed == "" || strings.Trim(trimmed, "-+| ") == "" {
+			continue
+		}
+		cells := split(line)
+		cell := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(cells) {
+				return ""
+			}
+			return strings.TrimSpace(cells[i])
+		}
+		entry := listEntry{Name: cell("NAME"), Namespace: cell("NAMESPACE"), Status: cell("STATUS")}
+		if entry.Name == "" {
+			continue
+		}
+		for _, layout := range tableCreatedLayouts {
+			if created, err := time.Parse(layout, cell("CREATED")); err == nil {
+				entry.Created = created
+				break
+			}
+		}
+		clusters = append(clusters, entry)
+	}
+	return clusters, nil
+}
+
+// tableColumns returns the index of each column of the header line, and how to split a row into cells.
+func tableColumns(header string) (map[string]int, func(string) []string) {
+	columns := make(map[string]int)
+	if strings.Contains(header, "|") {
+		for i, name := range strings.Split(header, "|") {
+			columns[strings.TrimSpace(name)] = i
+		}
+		return columns, func(row string) []string { return strings.Split(row, "|") }
+	}
+
+	// Cells start where their header starts, and end where the next one does.
+	var starts []int
+	inWord := false
+	for i, r := range header {
+		if r != ' ' && !inWord {
+			starts = append(starts, i)
+		}
+		inWord = r != ' '
+	}
+	for i, start := range starts {
+		columns[strings.Fields(header[start:])[0]] = i
+	}
+	return columns, func(row string) []string {
+		cells := make([]string, len(starts))
+		for i, start := range starts {
+			if start >= len(row) {
+				break
+			}
+			end := len(row)
+			if i+1 < len(starts) && starts[i+1] < end {
+				end = starts[i+1]
+			}
+			cells[i] = row[start:end]
+		}
+		return cells
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcluster
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVcluster is a vcluster CLI that prints the file $VCLUSTER_JSON for `list --output json`,
+// and $VCLUSTER_TABLE for `list`. It fails like an old CLI for --output json if there is no file.
+const fakeVcluster = `#!/bin/sh
+case "$*" in
+*--output*)
+	[ -f "$VCLUSTER_JSON" ] || { echo "unknown flag: --output" >&2; exit 1; }
+	cat "$VCLUSTER_JSON" ;;
+*)
+	[ -f "$VCLUSTER_TABLE" ] || { echo "cannot list" >&2; exit 1; }
+	cat "$VCLUSTER_TABLE" ;;
+esac
+`
+
+func TestListClusters(t *testing.T) {
+	created := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		json       string // output of `vcluster list --output json`, or "" if unsupported
+		table      string // output of `vcluster list`, or "" if it fails
+		want       []listEntry
+		wantPaused []bool
+		wantErr    []string // substrings of the error, if listing fails
+	}{
+		{
+			name: "JSON output",
+			json: `[
+  {"Name": "task-a", "Namespace": "vcluster-task-a", "Status": "Running", "Created": "2025-05-01T10:00:00Z", "Version": "0.24.1", "Connected": false},
+  {"Name": "task-b", "Namespace": "vcluster-task-b", "Status": "Paused", "Created": "2025-05-01T10:00:00Z"},
+  {"Name": "task-c", "Namespace": "vcluster-task-c", "Status": "Sleeping", "Created": "2025-05-01T10:00:00Z"}
+]`,
+			want: []listEntry{
+				{Name: "task-a", Namespace: "vcluster-task-a", Status: "Running", Created: created},
+				{Name: "task-b", Namespace: "vcluster-task-b", Status: "Paused", Created: created},
+				{Name: "task-c", Namespace: "vcluster-task-c", Status: "Sleeping", Created: created},
+			},
+			wantPaused: []bool{false, true, true},
+		},
+		{
+			name: "empty JSON output",
+			json: "[]\n",
+			want: []listEntry{},
+		},
+		{
+			name: "table with | separators",
+			table: `
+       NAME     |    NAMESPACE    | STATUS  | VERSION | CONNECTED |            CREATED            | AGE
+  --------------+-----------------+---------+---------+-----------+-------------------------------+------
+    task-a      | vcluster-task-a | Running | 0.19.5  |           | 2025-05-01 10:00:00 +0000 UTC | 1h
+    task-b      | vcluster-task-b | Paused  | 0.19.5  |           | 2025-05-01 10:00:00 +0000 UTC | 1h
+`,
+			want: []listEntry{
+				{Name: "task-a", Namespace: "vcluster-task-a", Status: "Running", Created: created},
+				{Name: "task-b", Namespace: "vcluster-task-b", Status: "Paused", Created: created},
+			},
+			wantPaused: []bool{false, true},
+		},
+		{
+			name: "table aligned with spaces",
+			table: ` NAME     NAMESPACE         STATUS    CONNECTED   CREATED                         AGE
+ task-a   vcluster-task-a   Running               2025-05-01 10:00:00 +0000 UTC   1h
+ task-b   vcluster-task-b   Paused    True        2025-05-01 10:00:00 +0000 UTC   1h
+`,
+			want: []listEntry{
+				{Name: "task-a", Namespace: "vcluster-task-a", Status: "Running", Created: created},
+				{Name: "task-b", Namespace: "vcluster-task-b", Status: "Paused", Created: created},
+			},
+			wantPaused: []bool{false, true},
+		},
+		{
+			name:  "table without entries",
+			table: "\n No entries found\n\n",
+		},
+		{
+			name:    "garbage",
+			json:    "vcluster is not configured\n",
+			table:   "something unexpected\n",
+			wantErr: []string{"no NAME column", "something unexpected", "parsing the JSON output", "vcluster is not configured"},
+		},
+		{
+			name:    "failing CLI",
+			wantErr: []string{"failed to list vclusters", "cannot list", "unknown flag: --output"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "vcluster"), []byte(fakeVcluster), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			for env, output := range map[string]string{"VCLUSTER_JSON": tt.json, "VCLUSTER_TABLE": tt.table} {
+				path := filepath.Join(dir, env)
+				t.Setenv(env, path)
+				if output == "" {
+					continue
+				}
+				if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p := &Provider{}
+			got, err := p.listClusters()
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("listClusters() = %v, want an error", got)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("listClusters() error = %q, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("listClusters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listClusters() = %+v, want %+v", got, tt.want)
+			}
+			for i, want := range tt.wantPaused {
+				if got := got[i].paused(); got != want {
+					t.Errorf("cluster %s paused() = %v, want %v", tt.want[i].Name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -15,146 +15,612 @@
 package vcluster
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/gke-labs/k8s-ai-bench/pkg/cluster"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultReadyTimeout is how long GetKubeconfig waits for the virtual cluster API to become ready.
+const DefaultReadyTimeout = 2 * time.Minute
+
+// ClusterOptions configure the virtual clusters that are created.
+// They can be set for the whole run, and overridden per task.
+type ClusterOptions struct {
+	// Distro is the kubernetes distribution of the virtual cluster control plane (k8s or k3s).
+	Distro string `json:"distro,omitempty"`
+	// KubernetesVersion is the image tag of the distro (e.g. v1.30.2 for k8s, v1.30.2-k3s1 for k3s).
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// ValuesFile is the path to a vcluster values file that is deep-merged over the default values.
+	ValuesFile string `json:"valuesFile,omitempty"`
+}
+
+type Options struct {
+	// HostContext is the kubeconfig context of the host cluster.
+	HostContext string
+	// HostKubeConfig is the kubeconfig file for the host cluster.
+	HostKubeConfig string
+	// ReadyTimeout bounds how long GetKubeconfig waits for the virtual cluster API to answer.
+	// Defaults to DefaultReadyTimeout.
+	ReadyTimeout time.Duration
+	// Timeouts bound the vcluster and kubectl commands, by operation.
+	Timeouts cluster.Timeouts
+
+	// ExposureMode is how the virtual cluster API server is reached; defaults to ExposureModeProxy.
+	ExposureMode ExposureMode
+	// IngressExternalIP is the external IP of the host's nginx ingress controller, used with ExposureModeIngress.
+	// The virtual cluster is reached at <name>.<IngressExternalIP>.nip.io.
+	IngressExternalIP string
+
+	// NamespacePrefix is prepended to the cluster name to form the host namespace; defaults to DefaultNamespacePrefix.
+	NamespacePrefix string
+	// Labels and Annotations are applied to the host namespace (and ingress/service) of every virtual cluster.
+	// ManagedLabel is always added, and is checked before a namespace is deleted.
+	Labels      map[string]string
+	Annotations map[string]string
+
+	ClusterOptions
+}
+
+const (
+	// DefaultNamespacePrefix is the default prefix of the host namespace of each virtual cluster.
+	DefaultNamespacePrefix = "vcluster-"
+
+	// ManagedLabel marks host namespaces created by k8s-ai-bench, so they can be garbage-collected safely.
+	ManagedLabel = "k8s-ai-bench/managed"
+	// RunIDLabel records the benchmark run that created the virtual cluster.
+	RunIDLabel = "k8s-ai-bench/run-id"
+)
+
+// ExposureMode is how the API server of the virtual cluster is made reachable from the benchmark.
+type ExposureMode string
+
+const (
+	// ExposureModeProxy connects through a local background proxy started by `vcluster connect`.
+	ExposureModeProxy ExposureMode = "proxy"
+	// ExposureModeIngress connects through an nginx ingress (with ssl-passthrough) on the host cluster.
+	ExposureModeIngress ExposureMode = "ingress"
+	// ExposureModeLoadBalancer connects through a LoadBalancer service for the virtual cluster.
+	ExposureModeLoadBalancer ExposureMode = "loadbalancer"
 )
 
 type Provider struct {
 	HostContext    string
 	HostKubeConfig string
 	ValuesPath     string
+	ReadyTimeout   time.Duration
+	Timeouts       cluster.Timeouts
+
+	ExposureMode      ExposureMode
+	IngressExternalIP string
+
+	NamespacePrefix string
+	Labels          map[string]string
+	Annotations     map[string]string
+
+	clusterOptions ClusterOptions
+}
+
+// defaultValues are the vcluster values we always apply, unless overridden by a user values file.
+func defaultValues() map[string]any {
+	return map[string]any{
+		"sync": map[string]any{
+			"toHost": map[string]any{
+				"persistentVolumeClaims": map[string]any{"enabled": true},
+				"persistentVolumes":      map[string]any{"enabled": true},
+				"storageClasses":         map[string]any{"enabled": true},
+			},
+		},
+	}
 }
 
-func New(hostContext, hostKubeConfig string) (cluster.Provider, func(), error) {
-	// Create a temporary file for vcluster values
-	valuesContent := `sync:
-  toHost:
-    persistentVolumeClaims:
-      enabled: true
-    persistentVolumes:
-      enabled: true
-    storageClasses:
-      enabled: true
-`
-	tmpFile, err := os.CreateTemp("", "vcluster-values-*.yaml")
-	fmt.Printf("create a temp vcluster values file: %s\n", tmpFile.Name())
+func New(opts Options) (cluster.Provider, func(), error) {
+	exposureMode := opts.ExposureMode
+	switch exposureMode {
+	case "":
+		exposureMode = ExposureModeProxy
+	case ExposureModeProxy, ExposureModeLoadBalancer:
+	case ExposureModeIngress:
+		if opts.IngressExternalIP == "" {
+			return nil, func() {}, fmt.Errorf("an ingress external IP is required with exposure mode %q", ExposureModeIngress)
+		}
+	default:
+		return nil, func() {}, fmt.Errorf("unknown vcluster exposure mode %q, valid options are %q, %q or %q", exposureMode, ExposureModeProxy, ExposureModeIngress, ExposureModeLoadBalancer)
+	}
+
+	valuesPath, err := createValuesFile(opts.ClusterOptions)
 	if err != nil {
-		fmt.Printf("failed to create temp values file: %v\n", err)
 		return nil, func() {}, err
 	}
 
-	if _, err := tmpFile.Write([]byte(valuesContent)); err != nil {
-		fmt.Printf("failed to write to temp values file: %v\n", err)
-		os.Remove(tmpFile.Name())
+	readyTimeout := opts.ReadyTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = DefaultReadyTimeout
+	}
+
+	p := &Provider{
+		HostContext:    opts.HostContext,
+		HostKubeConfig: opts.HostKubeConfig,
+		ValuesPath:     valuesPath,
+		ReadyTimeout:   readyTimeout,
+		Timeouts:       opts.Timeouts,
+		clusterOptions: opts.ClusterOptions,
+
+		ExposureMode:      exposureMode,
+		IngressExternalIP: opts.IngressExternalIP,
+
+		NamespacePrefix: opts.NamespacePrefix,
+		Labels:          map[string]string{ManagedLabel: "true"},
+		Annotations:     opts.Annotations,
+	}
+	if p.NamespacePrefix == "" {
+		p.NamespacePrefix = DefaultNamespacePrefix
+	}
+	for k, v := range opts.Labels {
+		p.Labels[k] = v
+	}
+
+	cleanup := func() {
+		os.Remove(valuesPath)
+	}
+
+	return p, cleanup, nil
+}
+
+// WithClusterOptions returns a copy of the provider where the non-empty fields of overrides
+// replace the provider's cluster options. The returned cleanup function removes the copy's values file.
+func (p *Provider) WithClusterOptions(overrides ClusterOptions) (cluster.Provider, func(), error) {
+	opts := p.clusterOptions
+	if overrides.Distro != "" {
+		opts.Distro = overrides.Distro
+	}
+	if overrides.KubernetesVersion != "" {
+		opts.KubernetesVersion = overrides.KubernetesVersion
+	}
+	if overrides.ValuesFile != "" {
+		opts.ValuesFile = overrides.ValuesFile
+	}
+
+	valuesPath, err := createValuesFile(opts)
+	if err != nil {
 		return nil, func() {}, err
 	}
+
+	clone := *p
+	clone.ValuesPath = valuesPath
+	clone.clusterOptions = opts
+	return &clone, func() { os.Remove(valuesPath) }, nil
+}
+
+// createValuesFile renders the vcluster values for opts into a temp file and returns its path.
+func createValuesFile(opts ClusterOptions) (string, error) {
+	values := defaultValues()
+
+	if opts.ValuesFile != "" {
+		data, err := os.ReadFile(opts.ValuesFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vcluster values file %q: %w", opts.ValuesFile, err)
+		}
+		userValues := make(map[string]any)
+		if err := yaml.Unmarshal(data, &userValues); err != nil {
+			return "", fmt.Errorf("failed to parse vcluster values file %q: %w", opts.ValuesFile, err)
+		}
+		values = mergeValues(values, userValues)
+	}
+
+	if opts.Distro != "" || opts.KubernetesVersion != "" {
+		distro := opts.Distro
+		if distro == "" {
+			distro = "k8s"
+		}
+		if distro != "k8s" && distro != "k3s" {
+			return "", fmt.Errorf("unsupported vcluster distro %q, valid options are 'k8s' or 'k3s'", distro)
+		}
+		distroValues := map[string]any{"enabled": true}
+		if opts.KubernetesVersion != "" {
+			distroValues["image"] = map[string]any{"tag": opts.KubernetesVersion}
+		}
+		values = mergeValues(values, map[string]any{
+			"controlPlane": map[string]any{
+				"distro": map[string]any{distro: distroValues},
+			},
+		})
+	}
+
+	valuesContent, err := yaml.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal vcluster values: %w", err)
+	}
+
+	valuesPath, err := writeTempFile("vcluster-values-*.yaml", valuesContent)
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("Created temporary vcluster values file", "path", valuesPath)
+	return valuesPath, nil
+}
+
+// writeTempFile writes data to a new temp file and returns its path.
+func writeTempFile(pattern string, data []byte) (string, error) {
+	tmpFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write to temp file %q: %w", tmpFile.Name(), err)
+	}
 	if err := tmpFile.Close(); err != nil {
-		fmt.Printf("failed to close temp values file: %v\n", err)
 		os.Remove(tmpFile.Name())
-		return nil, func() {}, err
+		return "", fmt.Errorf("failed to close temp file %q: %w", tmpFile.Name(), err)
 	}
+	return tmpFile.Name(), nil
+}
 
-	p := &Provider{
-		HostContext:    hostContext,
-		HostKubeConfig: hostKubeConfig,
-		ValuesPath:     tmpFile.Name(),
+// exposureValues returns the per-cluster values needed to expose the API server for the exposure mode, if any.
+func (p *Provider) exposureValues(name string) map[string]any {
+	switch p.ExposureMode {
+	case ExposureModeIngress:
+		host := p.ingressHost(name)
+		return map[string]any{
+			"controlPlane": map[string]any{
+				"ingress": map[string]any{
+					"enabled":     true,
+					"host":        host,
+					"labels":      p.Labels,
+					"annotations": p.ingressAnnotations(),
+					"spec": map[string]any{
+						"ingressClassName": "nginx",
+					},
+				},
+				"proxy": map[string]any{
+					"extraSANs": []any{host},
+				},
+			},
+		}
+	case ExposureModeLoadBalancer:
+		return map[string]any{
+			"controlPlane": map[string]any{
+				"service": map[string]any{
+					"labels":      p.Labels,
+					"annotations": p.Annotations,
+					"spec": map[string]any{
+						"type": "LoadBalancer",
+					},
+				},
+			},
+		}
 	}
+	return nil
+}
 
-	cleanup := func() {
-		os.Remove(tmpFile.Name())
+// ingressAnnotations returns the ingress annotations: the ssl-passthrough annotations we rely on plus the user annotations.
+// They are set explicitly because setting any ingress annotations replaces the chart defaults.
+func (p *Provider) ingressAnnotations() map[string]string {
+	annotations := map[string]string{
+		"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS",
+		"nginx.ingress.kubernetes.io/ssl-passthrough":  "true",
+		"nginx.ingress.kubernetes.io/ssl-redirect":     "true",
+	}
+	for k, v := range p.Annotations {
+		annotations[k] = v
 	}
+	return annotations
+}
 
-	return p, cleanup, nil
+// namespace is the host namespace of the virtual cluster.
+func (p *Provider) namespace(name string) string {
+	return p.NamespacePrefix + name
 }
 
-func (p *Provider) Exists(name string) (bool, error) {
-	args := []string{"list", "--output", "json"}
-	if p.HostContext != "" {
-		args = append(args, "--context", p.HostContext)
+// prepareNamespace creates (or updates) the labelled and annotated host namespace for the virtual cluster.
+func (p *Provider) prepareNamespace(name string) error {
+	metadata := map[string]any{
+		"name":   p.namespace(name),
+		"labels": p.Labels,
+	}
+	if len(p.Annotations) > 0 {
+		metadata["annotations"] = p.Annotations
+	}
+	manifest, err := yaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal namespace manifest: %w", err)
 	}
+	manifestPath, err := writeTempFile("vcluster-namespace-*.yaml", manifest)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(manifestPath)
+
+	if _, err := p.hostKubectl(context.Background(), cluster.OpCreate, "apply", "-f", manifestPath); err != nil {
+		return fmt.Errorf("failed to create namespace %q: %w", p.namespace(name), err)
+	}
+	return nil
+}
 
-	cmd := exec.Command("vcluster", args...)
+// ingressHost is the hostname under which the virtual cluster is reachable in ExposureModeIngress.
+func (p *Provider) ingressHost(name string) string {
+	return fmt.Sprintf("%s.%s.nip.io", name, p.IngressExternalIP)
+}
+
+// hostKubectl runs kubectl for the operation against the host cluster and returns its stdout.
+func (p *Provider) hostKubectl(ctx context.Context, op string, args ...string) ([]byte, error) {
+	if p.HostContext != "" {
+		args = append([]string{"--context", p.HostContext}, args...)
+	}
+	cmd := p.Timeouts.CommandContext(ctx, op, "kubectl", args...)
 	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", p.HostKubeConfig))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
 	if err != nil {
-		return false, fmt.Errorf("failed to run 'vcluster list': %w", err)
+		return nil, fmt.Errorf("running kubectl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// waitForLoadBalancerIP waits until the LoadBalancer service of the virtual cluster has an external address.
+func (p *Provider) waitForLoadBalancerIP(ctx context.Context, name string) (string, error) {
+	namespace := p.namespace(name)
+	var lastErr error
+	for {
+		output, err := p.hostKubectl(ctx, cluster.OpGetKubeconfig, "get", "service", name, "-n", namespace,
+			"-o", "jsonpath={.status.loadBalancer.ingress[0].ip}{.status.loadBalancer.ingress[0].hostname}")
+		if err == nil {
+			if address := strings.TrimSpace(string(output)); address != "" {
+				return address, nil
+			}
+			lastErr = fmt.Errorf("service %s/%s has no external address yet", namespace, name)
+		} else {
+			lastErr = err
+		}
+
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("timed out waiting for load balancer address: %w", lastErr)
+		case <-timer.C:
+		}
 	}
+}
+
+// mergeValues deep-merges src into dst; nested maps are merged recursively, other values in src replace those in dst.
+func mergeValues(dst, src map[string]any) map[string]any {
+	for k, srcValue := range src {
+		srcMap, srcIsMap := srcValue.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			dst[k] = mergeValues(dstMap, srcMap)
+		} else {
+			dst[k] = srcValue
+		}
+	}
+	return dst
+}
 
-	var clusters []struct {
-		Name string `json:"Name"`
+// Exists reports whether the virtual cluster exists and is not paused; a paused cluster cannot
+// be connected to until Create resumes it.
+func (p *Provider) Exists(name string) (bool, error) {
+	entry, err := p.find(name)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return !entry.paused(), nil
+}
+
+func (p *Provider) List() ([]string, error) {
+	clusters, err := p.listClusters()
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(output, &clusters); err != nil {
-		// Fallback to text parsing if JSON fails (older vcluster versions might behave differently)
-		return false, fmt.Errorf("failed to parse vcluster list json: %w", err)
+	var names []string
+	for _, c := range clusters {
+		names = append(names, c.Name)
 	}
+	return names, nil
+}
 
+func (p *Provider) CreationTime(name string) (time.Time, error) {
+	clusters, err := p.listClusters()
+	if err != nil {
+		return time.Time{}, err
+	}
 	for _, c := range clusters {
 		if c.Name == name {
-			return true, nil
+			return c.Created, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("vcluster %q not found", name)
+}
+
+// ListRunClusters returns the names of the virtual clusters whose host namespace was labelled with runID.
+func (p *Provider) ListRunClusters(runID string) ([]string, error) {
+	output, err := p.hostKubectl(context.Background(), cluster.OpList, "get", "namespaces",
+		"-l", fmt.Sprintf("%s=true,%s=%s", ManagedLabel, RunIDLabel, runID),
+		"-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces for run %q: %w", runID, err)
+	}
+	var names []string
+	for _, namespace := range strings.Fields(string(output)) {
+		if name, ok := strings.CutPrefix(namespace, p.NamespacePrefix); ok {
+			names = append(names, name)
 		}
 	}
-	return false, nil
+	return names, nil
 }
 
+// Create creates the virtual cluster. A paused virtual cluster of the same name (e.g. left over
+// from an interrupted run) is resumed, or deleted and created again if it cannot be resumed.
 func (p *Provider) Create(name string) error {
+	entry, err := p.find(name)
+	if err != nil {
+		return err
+	}
+	if entry != nil && entry.paused() {
+		err := p.resume(name)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("failed to resume paused vcluster, creating it again", "name", name, "error", err)
+		if err := p.Delete(name); err != nil {
+			return fmt.Errorf("failed to delete paused vcluster %q: %w", name, err)
+		}
+	}
+
+	if err := p.prepareNamespace(name); err != nil {
+		return err
+	}
+
+	args := []string{"create", name, "--namespace", p.namespace(name), "--connect=false", "--values", p.ValuesPath}
+	if exposureValues := p.exposureValues(name); exposureValues != nil {
+		data, err := yaml.Marshal(exposureValues)
+		if err != nil {
+			return fmt.Errorf("failed to marshal vcluster exposure values: %w", err)
+		}
+		exposurePath, err := writeTempFile("vcluster-exposure-values-*.yaml", data)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(exposurePath)
+		args = append(args, "--values", exposurePath)
+	}
+	if p.HostContext != "" {
+		args = append(args, "--context", p.HostContext)
+	}
+
 	var createErr error
 	for retry := range 3 {
 		if retry > 0 {
-			fmt.Printf("Retrying vcluster creation, attempt %d\n", retry+1)
+			slog.Info("Retrying vcluster creation", "name", name, "attempt", retry+1)
 			time.Sleep(5 * time.Second)
 		}
 
-		args := []string{"create", name, "--connect=false", "--values", p.ValuesPath}
-		if p.HostContext != "" {
-			args = append(args, "--context", p.HostContext)
-		}
-
-		createCmd := exec.Command("vcluster", args...)
+		createCmd := p.Timeouts.Command(cluster.OpCreate, "vcluster", args...)
 		createCmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", p.HostKubeConfig))
-		fmt.Printf("Creating vcluster %q\n", name)
+		slog.Info("Creating vcluster", "name", name)
 		createCmd.Stdout = os.Stdout
 		createCmd.Stderr = os.Stderr
 		createErr = createCmd.Run()
 		if createErr == nil {
 			return nil
 		}
-		fmt.Printf("failed to create vcluster, retrying...: %v\n", createErr)
+		slog.Warn("failed to create vcluster, retrying", "name", name, "error", createErr)
 	}
 	return fmt.Errorf("failed to create vcluster after multiple retries: %w", createErr)
 }
 
 func (p *Provider) Delete(name string) error {
-	args := []string{"delete", name}
+	// Refuse to delete namespaces we did not create; the host cluster may be shared with other users of vcluster.
+	namespace := p.namespace(name)
+	output, err := p.hostKubectl(context.Background(), cluster.OpDelete, "get", "namespace", namespace, "-o", "json")
+	if err != nil {
+		return fmt.Errorf("failed to get namespace of vcluster %q: %w", name, err)
+	}
+	var ns struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(output, &ns); err != nil {
+		return fmt.Errorf("failed to parse namespace %q: %w", namespace, err)
+	}
+	if ns.Metadata.Labels[ManagedLabel] != "true" {
+		return fmt.Errorf("refusing to delete vcluster %q: namespace %q does not have the %s=true label", name, namespace, ManagedLabel)
+	}
+
+	args := []string{"delete", name, "--namespace", namespace, "--delete-namespace"}
 	if p.HostContext != "" {
 		args = append(args, "--context", p.HostContext)
 	}
 
-	deleteCmd := exec.Command("vcluster", args...)
+	deleteCmd := p.Timeouts.Command(cluster.OpDelete, "vcluster", args...)
 	deleteCmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", p.HostKubeConfig))
-	fmt.Printf("Deleting vcluster %q\n", name)
+	slog.Info("Deleting vcluster", "name", name)
 	deleteCmd.Stdout = os.Stdout
 	deleteCmd.Stderr = os.Stderr
 	return deleteCmd.Run()
 }
 
 func (p *Provider) GetKubeconfig(name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ReadyTimeout)
+	defer cancel()
+
 	// vcluster connect <name> --print
-	args := []string{"connect", name, "--print"}
+	args := []string{"connect", name, "--namespace", p.namespace(name), "--print"}
 	if p.HostContext != "" {
 		args = append(args, "--context", p.HostContext)
 	}
+	switch p.ExposureMode {
+	case ExposureModeIngress:
+		args = append(args, "--server", "https://"+p.ingressHost(name))
+	case ExposureModeLoadBalancer:
+		address, err := p.waitForLoadBalancerIP(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("vcluster %q: %w", name, err)
+		}
+		args = append(args, "--server", "https://"+address)
+	}
 
-	cmd := exec.Command("vcluster", args...)
+	cmd := p.Timeouts.CommandContext(ctx, cluster.OpGetKubeconfig, "vcluster", args...)
 	cmd.Env = append(os.Environ(), fmt.Sprintf("KUBECONFIG=%s", p.HostKubeConfig))
 	config, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run 'vcluster connect': %w", err)
+	}
+
+	// In proxy mode the kubeconfig points at a local background proxy, which takes a while to start;
+	// the ingress and load balancer routes also take a moment to become reachable.
+	if err := waitForReady(ctx, config); err != nil {
+		return nil, fmt.Errorf("vcluster %q did not become ready within %v: %w", name, p.ReadyTimeout, err)
+	}
+
+	return config, nil
+}
+
+// waitForReady polls the /readyz endpoint of the cluster described by kubeconfig,
+// with exponential backoff, until it answers or ctx is done.
+func waitForReady(ctx context.Context, kubeconfig []byte) error {
+	kubeconfigPath, err := writeTempFile("vcluster-kubeconfig-*.yaml", kubeconfig)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(kubeconfigPath)
 
-	// Wait 60 secs for the local background proxy on docker to be running.
-	exec.Command("sleep", "60").Run()
+	backoff := time.Second
+	const maxBackoff = 10 * time.Second
+	var lastErr error
+	for {
+		cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", kubeconfigPath, "get", "--raw", "/readyz", "--request-timeout", "5s")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		lastErr = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
 
-	return config, err
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("last error: %w", lastErr)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
 }
@@ -0,0 +1,190 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package judge grades agent transcripts against a rubric using an LLM.
+package judge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Config selects the judge model.
+type Config struct {
+	// Provider is the LLM provider: "gemini" (uses GEMINI_API_KEY) or
+	// "openai" (uses OPENAI_API_KEY, and OPENAI_ENDPOINT if set).
+	Provider string
+	Model    string
+}
+
+// Verdict is the structured grade returned by the judge.
+type Verdict struct {
+	Pass      bool    `json:"pass"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+const promptTemplate = `You are grading the transcript of an AI agent that was given a Kubernetes task.
+
+Grade the transcript according to this rubric:
+
+%s
+
+Respond only with a JSON object of the form {"pass": <true|false>, "score": <number between 0 and 1>, "rationale": "<short explanation>"}.
+
+Transcript:
+
+%s
+`
+
+// Grade asks the judge model to grade transcript against rubric.
+// It returns the verdict and the raw text of the judge's response.
+func Grade(ctx context.Context, config Config, rubric, transcript string) (*Verdict, string, error) {
+	prompt := fmt.Sprintf(promptTemplate, rubric, transcript)
+
+	var response string
+	var err error
+	switch config.Provider {
+	case "gemini":
+		response, err = generateGemini(ctx, config.Model, prompt)
+	case "openai":
+		response, err = generateOpenAI(ctx, config.Model, prompt)
+	default:
+		return nil, "", fmt.Errorf("unsupported judge provider %q (supported: gemini, openai)", config.Provider)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Models sometimes wrap JSON in a markdown code block.
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+
+	var verdict Verdict
+	if err := json.Unmarshal([]byte(text), &verdict); err != nil {
+		return nil, response, fmt.Errorf("parsing judge verdict: %w", err)
+	}
+	if verdict.Score < 0 || verdict.Score > 1 {
+		return nil, response, fmt.Errorf("judge score %v is not between 0 and 1", verdict.Score)
+	}
+	return &verdict, response, nil
+}
+
+func generateGemini(ctx context.Context, model, prompt string) (string, error) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY must be set to use the gemini judge")
+	}
+	request := map[string]any{
+		"contents": []any{
+			map[string]any{
+				"role":  "user",
+				"parts": []any{map[string]any{"text": prompt}},
+			},
+		},
+		"generationConfig": map[string]any{
+			"responseMimeType": "application/json",
+		},
+	}
+	endpoint := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", url.PathEscape(model))
+	var response struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if err := postJSON(ctx, endpoint, map[string]string{"x-goog-api-key": apiKey}, request, &response); err != nil {
+		return "", err
+	}
+	if len(response.Candidates) == 0 || len(response.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("gemini returned no candidates")
+	}
+	return response.Candidates[0].Content.Parts[0].Text, nil
+}
+
+func generateOpenAI(ctx context.Context, model, prompt string) (string, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return "", fmt.Errorf("OPENAI_API_KEY must be set to use the openai judge")
+	}
+	endpoint := os.Getenv("OPENAI_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://api.openai.com/v1"
+	}
+	request := map[string]any{
+		"model": model,
+		"messages": []any{
+			map[string]any{"role": "user", "content": prompt},
+		},
+		"response_format": map[string]any{"type": "json_object"},
+	}
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + apiKey}
+	if err := postJSON(ctx, strings.TrimSuffix(endpoint, "/")+"/chat/completions", headers, request, &response); err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("openai returned no choices")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+func postJSON(ctx context.Context, endpoint string, headers map[string]string, request, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling judge model: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading judge response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("judge model returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("parsing judge response: %w", err)
+	}
+	return nil
+}
@@ -14,13 +14,82 @@
 
 package model
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
 
 type TaskResult struct {
 	Task      string    `json:"name"`
 	LLMConfig LLMConfig `json:"llmConfig"`
 	Result    string    `json:"result"`
 
+	// Agent is the ID of the agent that ran the task, when the run compared several agents.
+	Agent string `json:"agent,omitempty"`
+
+	// Difficulty and Category are copied from the task, for aggregating results.
+	Difficulty string `json:"difficulty,omitempty"`
+	Category   string `json:"category,omitempty"`
+
+	// RunID identifies the evaluation run that produced this result.
+	RunID string `json:"runID,omitempty"`
+
+	// Suite is the suite that was run, if the task was selected with --suite.
+	Suite string `json:"suite,omitempty"`
+
+	// RerunOf identifies the previous run (by run ID or output directory) this result reruns, with --rerun-failed.
+	RerunOf string `json:"rerunOf,omitempty"`
+
+	// Resumed is set when the result was loaded from a previous run with --resume, instead of being executed.
+	Resumed bool `json:"-"`
+
+	// Run is the 1-based index of this sample, when each task was run several times (--runs).
+	Run int `json:"run,omitempty"`
+	// Worker is the index of the worker that evaluated the task (from 0, as in the run's log),
+	// to correlate flaky results with it; unset for results that were not evaluated by a worker.
+	Worker *int `json:"worker,omitempty"`
+
+	// Score is the credit awarded for the task, between 0 and 1.
+	// Passing tasks score 1; failing tasks score 0 unless a verifier awarded partial credit.
+	Score float64 `json:"score"`
+
+	// Duration is how long the task took to evaluate, including retries.
+	Duration string `json:"duration,omitempty"`
+
+	// OutputSource is where the agent output checked by the task's expectations came from:
+	// the agent's trace, or its console output if the trace was unavailable.
+	OutputSource string `json:"outputSource,omitempty"`
+
+	// LogTruncated is set if the task log reached its size limit, so later output is missing from it.
+	LogTruncated bool `json:"logTruncated,omitempty"`
+
+	// Timing records when the task ran and how long each of its phases took.
+	Timing *TaskTiming `json:"timing,omitempty"`
+
+	// Usage is the LLM usage of the agent, read from its trace; unset if unknown.
+	Usage *Usage `json:"usage,omitempty"`
+	// CostCapExceeded is set if the estimated cost of the task was above the --task-cost-cap,
+	// which hints at a runaway agent; it does not change the result.
+	CostCapExceeded bool `json:"costCapExceeded,omitempty"`
+
+	// Cluster describes the cluster the task ran against.
+	Cluster *ClusterInfo `json:"cluster,omitempty"`
+
+	// ClusterDiff counts the objects the agent created, modified and deleted, by kind, with
+	// --capture-diff; the changes are in cluster-diff.yaml.
+	ClusterDiff []ResourceDiff `json:"clusterDiff,omitempty"`
+
+	// AgentIdentity is the restricted identity the agent worked with, if the task sets rbac.
+	AgentIdentity *AgentIdentity `json:"agentIdentity,omitempty"`
+
+	// AgentExit describes how the agent process ended, if it did not exit successfully.
+	AgentExit *AgentExit `json:"agentExit,omitempty"`
+
+	// Metrics count the tool calls and LLM turns of the agent, read from its trace; unset if unknown.
+	Metrics *Metrics `json:"metrics,omitempty"`
+
 	// Failure contains a list of test failures, if there were unmet expectations.
 	// These do not indicate an infrastructure failure, rather they are the details of a test failure.
 	Failures []Failure `json:"failures,omitempty"`
@@ -28,10 +97,154 @@ type TaskResult struct {
 	// Error contains the error message, if there was an unexpected error during the execution of the test.
 	// This normally indicates an infrastructure failure, rather than a test failure.
 	Error string `json:"error"`
+	// Panicked is set if the error is a panic of the benchmark itself while evaluating the task.
+	Panicked bool `json:"panicked,omitempty"`
+
+	// Verifiers records the outcome of each verifier script, in the order they ran.
+	Verifiers []VerifierResult `json:"verifiers,omitempty"`
+
+	// Judge is the verdict of the LLM judge, if the task has a judge rubric.
+	Judge *JudgeResult `json:"judge,omitempty"`
+
+	// VerifyAttempts is the number of times the verifiers and checks were run.
+	VerifyAttempts int `json:"verifyAttempts,omitempty"`
+	// VerifyDuration is how long verification took, including retries.
+	VerifyDuration string `json:"verifyDuration,omitempty"`
+	// TimedOut is set if the agent hit the task timeout. The cluster state is still verified
+	// (unless the task sets verifyAfterTimeout: false), so a result of success means the agent
+	// had solved the task, and fail that it had not.
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	// KeptCluster is the isolated cluster that was kept for debugging after the task failed.
+	KeptCluster *KeptCluster `json:"keptCluster,omitempty"`
+	// CleanupErrors are the failures of the task's cleanup, e.g. a cluster that could not be deleted.
+	// They do not change the result, but may have left resources behind.
+	CleanupErrors []string `json:"cleanupErrors,omitempty"`
+
+	// SkipReason explains why the task was skipped, if Result is "skipped".
+	SkipReason string `json:"skipReason,omitempty"`
+	// Disabled is set on the skipped results of disabled tasks, which were not run (see --include-disabled).
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Attempts records the outcome of each attempt, if the task was retried.
+	Attempts []AttemptResult `json:"attempts,omitempty"`
+
+	// TransientRetries is how many times the task was run again because the agent failed
+	// with a transient error, like a rate limit of the LLM provider.
+	TransientRetries int `json:"transientRetries,omitempty"`
+	// TransientError is the --transient-patterns pattern that the last transient failure matched.
+	TransientError string `json:"transientError,omitempty"`
+}
+
+// AttemptResult is the outcome of a single attempt at a task.
+type AttemptResult struct {
+	Result   string    `json:"result"`
+	Score    float64   `json:"score"`
+	Failures []Failure `json:"failures,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// VerifierResult is the outcome of a single verifier script.
+type VerifierResult struct {
+	Name   string  `json:"name"`
+	Result string  `json:"result"`
+	Score  float64 `json:"score"`
+	// Stdout and Stderr are the end of the output of a verifier that did not pass; the full
+	// output of every verifier run is in verifier.log in the task output directory.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// JudgeResult is the verdict of the LLM judge on the agent transcript.
+type JudgeResult struct {
+	Pass      bool    `json:"pass"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+const (
+	// OutputSourceTrace means expectations were checked against the tool output and messages in the agent's trace.
+	OutputSourceTrace = "trace"
+	// OutputSourceConsole means expectations were checked against the console output after the last "Running:" line.
+	OutputSourceConsole = "console"
+)
+
+// Usage is the LLM token usage of a task.
+type Usage struct {
+	LLMCalls         int `json:"llmCalls"`
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	// Cost is the estimated cost in USD, if the price of the model is known.
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+// Tokens returns the number of prompt and completion tokens.
+func (u *Usage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Metrics measure how efficiently the agent worked on a task.
+type Metrics struct {
+	// ToolCalls is the number of tool invocations, and ToolCallsByName their count per tool.
+	ToolCalls       int            `json:"toolCalls"`
+	ToolCallsByName map[string]int `json:"toolCallsByName,omitempty"`
+	// KubectlMutations and KubectlReads count the kubectl commands in tool calls, classified by verb.
+	KubectlMutations int `json:"kubectlMutations"`
+	KubectlReads     int `json:"kubectlReads"`
+	// LLMTurns is the number of requests to the LLM.
+	LLMTurns int `json:"llmTurns"`
+	// ToolErrors is set if any tool call returned an error.
+	ToolErrors bool `json:"toolErrors,omitempty"`
+}
+
+// AgentExit describes how an agent process that did not exit successfully ended.
+type AgentExit struct {
+	// ExitCode is the exit code of the process, or -1 if it was terminated by a signal.
+	ExitCode int `json:"exitCode"`
+	// Signal is the signal that terminated the process, if any.
+	Signal string `json:"signal,omitempty"`
+	// Killed is set if the process was killed by the benchmark, because the task timed out
+	// or the run was interrupted, rather than ending by itself.
+	Killed bool `json:"killed,omitempty"`
+	// Reason is the context error that caused the benchmark to kill the process, if it did.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ClusterInfo describes the cluster that results were produced with.
+type ClusterInfo struct {
+	// Provider is the cluster provider (kind, vcluster, ...).
+	Provider string `json:"provider"`
+	// Name is the name of the shared or per-task cluster, if it is managed by the provider.
+	// With several per-task clusters, it is the name of the first.
+	Name string `json:"name,omitempty"`
+	// Clusters are the names of the per-task clusters, for a task with several clusters.
+	Clusters []string `json:"clusters,omitempty"`
+	// Isolation is the isolation mode of the task, if any.
+	Isolation string `json:"isolation,omitempty"`
+	// ServerVersion is the Kubernetes server version, if it could be queried.
+	ServerVersion string `json:"serverVersion,omitempty"`
+}
+
+// TaskTiming records when a task ran, and the durations of its phases.
+// With retries, StartTime is when the first attempt started, and the phases are those of the final attempt.
+type TaskTiming struct {
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// Setup, Agent, Verify and Cleanup are the durations of the phases that ran.
+	// Verify includes output expectations, checks, verifiers and the judge;
+	// Cleanup includes collecting diagnostics and keeping the cluster of a failed task.
+	Setup   string `json:"setup,omitempty"`
+	Agent   string `json:"agent,omitempty"`
+	Verify  string `json:"verify,omitempty"`
+	Cleanup string `json:"cleanup,omitempty"`
 }
 
 type Failure struct {
 	Message string `json:"message"`
+
+	// Step is the 1-based index of the script step the failure applies to, if it is step-scoped.
+	Step int `json:"step,omitempty"`
 }
 
 type LLMConfig struct {
@@ -47,7 +260,75 @@ type LLMConfig struct {
 
 	McpClient bool `json:"mcpClient"`
 
-	// TODO: Maybe different styles of invocation, or different temperatures etc?
+	// IdleMarker is a regular expression the agent prints when it is waiting for input;
+	// script steps after the first are only sent once it appears after the previous step.
+	IdleMarker string `json:"idleMarker,omitempty"`
+
+	// Env is the environment of the agent for this config, on top of the task environment.
+	// Values may reference ${VAR} from the environment of k8s-ai-bench, or be file:<path>
+	// to read a secret from a file. Only the names are serialized, never the values.
+	Env EnvVars `json:"env,omitempty"`
+
+	// Temperature and MaxTokens are the generation parameters passed to the agent, if set.
+	// Configs that differ only in these need different IDs, e.g. gemini-2.5-pro-t0.
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+
+	// ExtraArgs are passed to the agent after the standard flags.
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+}
+
+// EnvVars are environment variables whose values may be secrets. They are read from
+// a map of names to values, but written as the sorted list of names only.
+type EnvVars map[string]string
+
+// MarshalJSON writes the names of the variables, without their values.
+func (e EnvVars) MarshalJSON() ([]byte, error) {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return json.Marshal(names)
+}
+
+// UnmarshalJSON reads a map of names to values, or a list of names written by MarshalJSON
+// (whose values are then empty). Numbers and booleans (like YAML's unquoted yes) are
+// converted to strings.
+func (e *EnvVars) UnmarshalJSON(data []byte) error {
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err == nil {
+		*e = make(EnvVars, len(values))
+		for name, value := range values {
+			switch value := value.(type) {
+			case string:
+				(*e)[name] = value
+			case float64, bool:
+				(*e)[name] = fmt.Sprint(value)
+			default:
+				return fmt.Errorf("env %s must be a string", name)
+			}
+		}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("env must be a map of names to values: %w", err)
+	}
+	*e = make(EnvVars, len(names))
+	for _, name := range names {
+		(*e)[name] = ""
+	}
+	return nil
+}
+
+// EffectiveScore returns the score of the result, treating passing results
+// written before scores were recorded as full credit.
+func (r *TaskResult) EffectiveScore() float64 {
+	if r.Score == 0 && r.Result == "success" {
+		return 1
+	}
+	return r.Score
 }
 
 // AddFailure is a helper for adding a formatted failure message; it also marks the test as failed
@@ -58,3 +339,126 @@ func (r *TaskResult) AddFailure(msg string, args ...any) {
 	r.Result = "fail"
 	r.Failures = append(r.Failures, failure)
 }
+
+// ResourceDiff counts the objects of a kind that changed while the agent ran.
+type ResourceDiff struct {
+	Kind     string `json:"kind"`
+	Created  int    `json:"created,omitempty"`
+	Modified int    `json:"modified,omitempty"`
+	Deleted  int    `json:"deleted,omitempty"`
+}
+
+// AgentIdentity is the ServiceAccount the agent of a task with rbac worked as.
+type AgentIdentity struct {
+	// ServiceAccount is the user name of the ServiceAccount, e.g. system:serviceaccount:default:k8s-ai-bench-agent-0a1b2c.
+	ServiceAccount string `json:"serviceAccount"`
+	// Roles are the roles bound to it, e.g. "ClusterRole view in shop".
+	Roles []string `json:"roles,omitempty"`
+}
+
+// KeptCluster is an isolated cluster kept after its task failed (--keep-cluster-on-failure).
+// It is also written to kept-cluster.yaml in the task output directory, for the cleanup subcommand.
+type KeptCluster struct {
+	Name       string `json:"name"`
+	Provider   string `json:"provider"`
+	KubeConfig string `json:"kubeconfig"`
+	RunID      string `json:"runID,omitempty"`
+	// Clusters are all the named clusters of a task with several, which are kept together.
+	// Name and KubeConfig are those of the first.
+	Clusters []KeptCluster `json:"clusters,omitempty"`
+}
+
+// RunMetadata describes an evaluation run, and is written to run-metadata.yaml in the output directory.
+type RunMetadata struct {
+	RunID string `json:"runID"`
+
+	// Shuffle and Seed record how the task order was shuffled, so the order can be reproduced.
+	Shuffle bool   `json:"shuffle,omitempty"`
+	Seed    uint64 `json:"seed,omitempty"`
+	// TaskOrder is the order in which tasks were dispatched (subject to dependencies).
+	TaskOrder []string `json:"taskOrder"`
+
+	// RerunOf identifies the previous run whose failures were rerun, with --rerun-failed.
+	RerunOf string `json:"rerunOf,omitempty"`
+
+	// StartTime is when the run started, and EndTime when it completed (unset while running).
+	StartTime time.Time  `json:"startTime"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	// Error is set if the run ended early because of an error.
+	Error string `json:"error,omitempty"`
+
+	// Config is the effective configuration of the run, with credentials and kubeconfig paths redacted.
+	Config map[string]any `json:"config,omitempty"`
+	// Hostname is the machine the run ran on.
+	Hostname string `json:"hostname,omitempty"`
+	// AgentVersion is the output of the agent binary's --version flag, if it supports it.
+	AgentVersion string `json:"agentVersion,omitempty"`
+	// TasksGitSHA is the git commit of the tasks directory, if it is a git checkout.
+	TasksGitSHA string `json:"tasksGitSHA,omitempty"`
+	// Cluster describes the shared cluster of the run.
+	Cluster *ClusterInfo `json:"cluster,omitempty"`
+	// TraceID is the OpenTelemetry trace ID of the run, when spans are exported with --otel-endpoint.
+	TraceID string `json:"traceID,omitempty"`
+	// LLMConfigs are the LLM configs the run evaluated, as resolved from the flags or --llm-configs.
+	LLMConfigs []LLMConfig `json:"llmConfigs,omitempty"`
+
+	// ResultCounts is the number of results of each kind, set when the run completes.
+	ResultCounts map[string]int `json:"resultCounts,omitempty"`
+	// TotalCost is the estimated cost in USD of the tasks with a known cost, and TotalTokens the
+	// tokens used by the tasks with known usage, set when the run completes.
+	TotalCost   *float64 `json:"totalCost,omitempty"`
+	TotalTokens int      `json:"totalTokens,omitempty"`
+}
+
+// RunResultsSchemaVersion is the version of the RunResults schema, incremented on incompatible changes.
+const RunResultsSchemaVersion = 1
+
+// RunResults aggregates the results of an evaluation run, and is written to results.json in the output directory.
+type RunResults struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	RunID         string    `json:"runID"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+
+	// Error is set if the run ended early because of an error.
+	Error string `json:"error,omitempty"`
+
+	// Config is the configuration of the run, with values that may hold credentials redacted.
+	Config map[string]any `json:"config,omitempty"`
+
+	// TaskCount is the number of distinct tasks, and ResultCounts the number of results of each kind.
+	TaskCount    int            `json:"taskCount"`
+	ResultCounts map[string]int `json:"resultCounts"`
+
+	LLMConfigs []LLMConfigSummary `json:"llmConfigs"`
+	Results    []TaskResult       `json:"results"`
+}
+
+// LLMConfigSummary aggregates the results of an LLM config (with an agent, when the run compared several).
+// Total counts the skipped results too, PassRate and MeanScore leave them out.
+type LLMConfigSummary struct {
+	// ID is the LLM config ID, or <agent>/<llm config ID>; see ConfigID.
+	ID        string  `json:"id"`
+	Agent     string  `json:"agent,omitempty"`
+	Total     int     `json:"total"`
+	Success   int     `json:"success"`
+	Fail      int     `json:"fail"`
+	Error     int     `json:"error"`
+	Skipped   int     `json:"skipped"`
+	PassRate  float64 `json:"passRate"`
+	MeanScore float64 `json:"meanScore"`
+}
+
+// ConfigID identifies what the task ran with: the ID of the LLM config, prefixed with
+// the agent's ID when the run compared several agents.
+func (r TaskResult) ConfigID() string {
+	return ConfigID(r.Agent, r.LLMConfig.ID)
+}
+
+// ConfigID returns <agentID>/<llmID>, or llmID if agentID is empty.
+func ConfigID(agentID, llmID string) string {
+	if agentID == "" {
+		return llmID
+	}
+	return agentID + "/" + llmID
+}
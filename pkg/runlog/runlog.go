@@ -0,0 +1,196 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runlog formats the messages of the benchmark itself (not the output of the tasks)
+// as slog records: leveled, with structured fields like the worker and task, written as text
+// for people or as JSON for machines. It is installed as the default slog logger, which the
+// benchmark and the cluster providers log to.
+package runlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats of the log, selected with --log-format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// EventKey is the key of the attribute naming what a message reports, e.g. EventStarted.
+// In text output, it picks the color of the message rather than being printed.
+const EventKey = "event"
+
+// Events with their own color in text output.
+const (
+	EventStarted   = "started"
+	EventCompleted = "completed"
+	EventKept      = "kept"
+)
+
+// Options configure the logger.
+type Options struct {
+	// Format is FormatText (the default) or FormatJSON.
+	Format string
+	// Level is the lowest level that is logged.
+	Level slog.Level
+	// Color colors text output, which should only be set when it goes to a terminal.
+	Color bool
+}
+
+// New returns a logger writing to w.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	switch opts.Format {
+	case "", FormatText:
+		return slog.New(&textHandler{out: &lockedWriter{w: w}, level: opts.Level, color: opts.Color}), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: opts.Level})), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be %q or %q", opts.Format, FormatText, FormatJSON)
+	}
+}
+
+// ParseLevel parses a level name: error, warn, info or debug.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, must be error, warn, info or debug", name)
+	}
+	return level, nil
+}
+
+// lockedWriter serializes the writes of the handlers derived from a text handler.
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.w.Write(p)
+}
+
+// textHandler writes a line for each record: the level (unless info), the message, then the
+// attributes as key=value, like "Warning: cleanup failed task=create-pod error=...".
+type textHandler struct {
+	out   *lockedWriter
+	level slog.Level
+	color bool
+	// attrs are the formatted attributes added with WithAttrs, and group the prefix of their keys.
+	attrs string
+	group string
+}
+
+// ANSI colors of text output.
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorCyan   = "36"
+	colorGray   = "90"
+)
+
+var levelPrefixes = map[slog.Level]string{
+	slog.LevelDebug: "Debug: ",
+	slog.LevelWarn:  "Warning: ",
+	slog.LevelError: "Error: ",
+}
+
+var levelColors = map[slog.Level]string{
+	slog.LevelDebug: colorGray,
+	slog.LevelWarn:  colorYellow,
+	slog.LevelError: colorRed,
+}
+
+var eventColors = map[string]string{
+	EventStarted:   colorCyan,
+	EventCompleted: colorGreen,
+	EventKept:      colorYellow,
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	b.WriteString(levelPrefixes[record.Level])
+	b.WriteString(record.Message)
+	color := levelColors[record.Level]
+	attrs := h.attrs
+	record.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == EventKey {
+			if c, ok := eventColors[attr.Value.String()]; ok && color == "" {
+				color = c
+			}
+			return true
+		}
+		attrs += formatAttr(h.group, attr)
+		return true
+	})
+	line := b.String()
+	if h.color && color != "" {
+		line = "\033[" + color + "m" + line + "\033[0m"
+	}
+	_, err := io.WriteString(h.out, line+attrs+"\n")
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	for _, attr := range attrs {
+		clone.attrs += formatAttr(h.group, attr)
+	}
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.group += name + "."
+	return &clone
+}
+
+// formatAttr formats the attribute as " key=value", quoting values with spaces.
+func formatAttr(group string, attr slog.Attr) string {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return ""
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		var s string
+		for _, a := range attr.Value.Group() {
+			s += formatAttr(group+attr.Key+".", a)
+		}
+		return s
+	}
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindDuration:
+		value = attr.Value.Duration().Round(time.Millisecond).String()
+	default:
+		value = attr.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	return " " + group + attr.Key + "=" + value
+}
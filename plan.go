@@ -0,0 +1,183 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// evaluationPlan is the matrix of evaluations a run would perform, printed by --dry-run.
+type evaluationPlan struct {
+	RunID           string `json:"runID"`
+	ClusterProvider string `json:"clusterProvider"`
+	Concurrency     int    `json:"concurrency"`
+	Runs            int    `json:"runs"`
+
+	Entries []planEntry `json:"entries"`
+
+	// Evaluations is the number of task evaluations, counting each run.
+	Evaluations int `json:"evaluations"`
+	// EstimatedDuration is an upper bound of the wall-clock time of the run: every evaluation
+	// takes its task's timeout, and the tasks are spread over the workers.
+	EstimatedDuration string `json:"estimatedDuration"`
+}
+
+// planEntry is a task evaluated with an agent and LLM config.
+type planEntry struct {
+	Task     string `json:"task"`
+	ConfigID string `json:"configID"`
+	Timeout  string `json:"timeout"`
+	// Skipped is why the combination would not run, e.g. its provider limit is 0.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// taskTimeout returns the timeout of the task, or the default if it declares none (or an invalid one).
+func taskTimeout(task Task) time.Duration {
+	if task.Timeout != "" {
+		if d, err := time.ParseDuration(task.Timeout); err == nil {
+			return d
+		}
+	}
+	return defaultTaskTimeout
+}
+
+// dryRun selects the cluster provider and loads the tasks as a run would, then prints the
+// evaluations the run would perform, without creating clusters, running the agent or writing
+// to the output directory. With config.PlanFile, the plan is also written there.
+func dryRun(config EvalConfig) error {
+	_, cleanupProvider, err := newClusterProvider(config)
+	if err != nil {
+		return err
+	}
+	defer cleanupProvider()
+
+	tasks, _, err := loadTasks(config)
+	if err != nil {
+		return fmt.Errorf("failed to load tasks: %w", err)
+	}
+	var rerun *rerunSelection
+	if config.RerunFailed != "" {
+		rerun, err = loadRerunSelection(config.RerunFailed, tasks, runConfigIDs(config))
+		if err != nil {
+			return err
+		}
+		rerun.filter(tasks)
+	}
+
+	plan := planEvaluation(config, tasks, rerun)
+	printPlan(plan)
+	if config.PlanFile != "" {
+		if err := writeToYAMLFile(config.PlanFile, plan); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote plan to %s\n", config.PlanFile)
+	}
+	return nil
+}
+
+// planEvaluation lists the evaluations of the tasks, in the order they are dispatched, and
+// estimates the duration of the run.
+func planEvaluation(config EvalConfig, tasks map[string]Task, rerun *rerunSelection) evaluationPlan {
+	concurrency := max(config.Concurrency, 1)
+	runs := max(config.Runs, 1)
+	plan := evaluationPlan{
+		RunID:           config.RunID,
+		ClusterProvider: config.ClusterProvider,
+		Concurrency:     concurrency,
+		Runs:            runs,
+		Entries:         []planEntry{},
+	}
+	limiters := newProviderLimiters(config.ProviderLimits)
+
+	// The LLM configs of a task on the shared cluster run one after another, so they are
+	// estimated as a single job; those of a task with its own cluster run concurrently.
+	// Tasks on the shared cluster that declare no resources run alone, after one another.
+	var jobDurations []time.Duration
+	var aloneDuration time.Duration
+	for _, taskID := range taskOrder(tasks, config.Shuffle, config.Seed) {
+		task := tasks[taskID]
+		timeout := taskTimeout(task)
+		shared := runsOnSharedCluster(config, task)
+		var jobDuration time.Duration
+		for _, agent := range config.Agents {
+			for _, llmConfig := range config.LLMConfigs {
+				configID := model.ConfigID(agent.ID, llmConfig.ID)
+				if !rerun.selects(taskID, configID) {
+					continue
+				}
+				entry := planEntry{Task: taskID, ConfigID: configID, Timeout: timeout.String()}
+				if limiter := limiters.forConfig(llmConfig); limiter.blocked() {
+					entry.Skipped = fmt.Sprintf("provider limit of %s is 0", limiter.name)
+				} else {
+					plan.Evaluations += runs
+					if shared {
+						jobDuration += time.Duration(runs) * timeout
+					} else {
+						jobDurations = append(jobDurations, time.Duration(runs)*timeout)
+					}
+				}
+				plan.Entries = append(plan.Entries, entry)
+			}
+		}
+		if shared && !task.declaresResources() {
+			aloneDuration += jobDuration
+		} else if jobDuration > 0 {
+			jobDurations = append(jobDurations, jobDuration)
+		}
+	}
+	plan.EstimatedDuration = (aloneDuration + estimateDuration(jobDurations, concurrency)).String()
+	return plan
+}
+
+// estimateDuration returns when the last of the jobs would end if each worker takes the next
+// job as soon as it is free, longest jobs first.
+func estimateDuration(jobs []time.Duration, workers int) time.Duration {
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i] > jobs[j] })
+	busyUntil := make([]time.Duration, workers)
+	var end time.Duration
+	for _, job := range jobs {
+		next := 0
+		for i := range busyUntil {
+			if busyUntil[i] < busyUntil[next] {
+				next = i
+			}
+		}
+		busyUntil[next] += job
+		end = max(end, busyUntil[next])
+	}
+	return end
+}
+
+// printPlan prints the evaluations of the plan as a table, followed by its totals.
+func printPlan(plan evaluationPlan) {
+	fmt.Printf("Dry run: planned evaluations of run %s (cluster provider %s)\n", plan.RunID, plan.ClusterProvider)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tCONFIG\tRUNS\tTIMEOUT")
+	for _, entry := range plan.Entries {
+		runs := fmt.Sprint(plan.Runs)
+		if entry.Skipped != "" {
+			runs = "skipped: " + entry.Skipped
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Task, entry.ConfigID, runs, entry.Timeout)
+	}
+	w.Flush()
+	fmt.Printf("%d evaluations with concurrency %d, estimated to take at most %s\n", plan.Evaluations, plan.Concurrency, plan.EstimatedDuration)
+}
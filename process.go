@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// processGroupGracePeriod is how long the processes of a stopped command have to exit after
+// SIGTERM, before they are killed with SIGKILL. It is a variable for tests.
+var processGroupGracePeriod = 5 * time.Second
+
+// runInProcessGroup runs the command, which must be created with exec.CommandContext, in a
+// process group of its own. When its context is done, the whole group is stopped rather than
+// only the command. Once the command exits, so are the processes it left running in the
+// background (e.g. kubectl port-forward), which would otherwise outlive the task.
+func runInProcessGroup(cmd *exec.Cmd) error {
+	// The output is copied from pipes of our own, so that waiting for the command does not
+	// also wait for the background processes that inherited them.
+	var copies sync.WaitGroup
+	var pipeWriters []*os.File
+	pipe := func(w io.Writer) (io.Writer, error) {
+		if w == nil {
+			return nil, nil
+		}
+		r, pw, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		pipeWriters = append(pipeWriters, pw)
+		copies.Add(1)
+		go func() {
+			defer copies.Done()
+			defer r.Close()
+			io.Copy(w, r)
+		}()
+		return pw, nil
+	}
+	closePipes := func() {
+		for _, pw := range pipeWriters {
+			pw.Close()
+		}
+	}
+	stdout, err := pipe(cmd.Stdout)
+	if err != nil {
+		closePipes()
+		copies.Wait()
+		return err
+	}
+	stderr, err := pipe(cmd.Stderr)
+	if err != nil {
+		closePipes()
+		copies.Wait()
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		stopProcessGroup(cmd.Process.Pid)
+		return nil
+	}
+	err = cmd.Start()
+	// The command has its own copies of the pipes; ours are closed so the copies end with it.
+	closePipes()
+	if err != nil {
+		copies.Wait()
+		return err
+	}
+	err = cmd.Wait()
+	stopProcessGroup(cmd.Process.Pid)
+	copies.Wait()
+	return err
+}
+
+// stopProcessGroup sends SIGTERM to the processes of the group, and SIGKILL to those still
+// running after the grace period. It returns once the group is empty or was sent SIGKILL.
+func stopProcessGroup(pgid int) {
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		// No process is left in the group.
+		return
+	}
+	deadline := time.Now().Add(processGroupGracePeriod)
+	for time.Now().Before(deadline) {
+		if err := syscall.Kill(-pgid, 0); err != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processRunning reports whether the process is running, treating zombies as exited.
+func processRunning(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses.
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// waitForExit returns whether the process exits within the timeout.
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
+// readPID waits for the script to write the PID of its background child to the file.
+func readPID(t *testing.T, pidFile string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatalf("parsing PID %q: %v", data, err)
+			}
+			return pid
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("the script did not write the PID of its child to %s", pidFile)
+	return 0
+}
+
+func TestRunInProcessGroup(t *testing.T) {
+	processGroupGracePeriod = 500 * time.Millisecond
+	t.Cleanup(func() { processGroupGracePeriod = 5 * time.Second })
+
+	tests := []struct {
+		name string
+		// script backgrounds a sleep and writes its PID to $PID_FILE.
+		script string
+		cancel bool
+		// wantKill is set if the script ignores SIGTERM, so it is only stopped by SIGKILL.
+		wantKill   bool
+		wantOutput string
+	}{
+		{
+			name:       "exits",
+			script:     `sleep 300 & echo $! > "$PID_FILE"; echo done`,
+			wantOutput: "done\n",
+		},
+		{
+			name: "cancelled",
+			// The script reaps its child on SIGTERM, so the group is empty once both exited.
+			script:     `sleep 300 & child=$!; echo $child > "$PID_FILE"; trap 'wait $child; exit 143' TERM; echo started; wait`,
+			cancel:     true,
+			wantOutput: "started\n",
+		},
+		{
+			name:       "cancelled, ignoring SIGTERM",
+			script:     `trap '' TERM; sleep 300 & echo $! > "$PID_FILE"; echo started; while true; do sleep 1; done`,
+			cancel:     true,
+			wantKill:   true,
+			wantOutput: "started\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "pid")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cmd := exec.CommandContext(ctx, "sh", "-c", tt.script)
+			cmd.Env = append(os.Environ(), "PID_FILE="+pidFile)
+			var output bytes.Buffer
+			cmd.Stdout = &output
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- runInProcessGroup(cmd) }()
+			child := readPID(t, pidFile)
+			t.Cleanup(func() {
+				if p, err := os.FindProcess(child); err == nil {
+					p.Kill()
+				}
+			})
+			var cancelled time.Time
+			if tt.cancel {
+				cancelled = time.Now()
+				cancel()
+			}
+
+			var err error
+			select {
+			case err = <-errCh:
+			case <-time.After(10 * time.Second):
+				t.Fatal("runInProcessGroup did not return")
+			}
+			stopped := time.Since(cancelled)
+			if tt.cancel {
+				var exitErr *exec.ExitError
+				if !errors.As(err, &exitErr) {
+					t.Errorf("runInProcessGroup() = %v, want an exit error", err)
+				}
+				if tt.wantKill && stopped < processGroupGracePeriod {
+					t.Errorf("stopped after %v, want SIGKILL after the grace period of %v", stopped, processGroupGracePeriod)
+				}
+				if !tt.wantKill && stopped >= processGroupGracePeriod {
+					t.Errorf("stopped after %v, want SIGTERM to stop it within the grace period of %v", stopped, processGroupGracePeriod)
+				}
+			} else if err != nil {
+				t.Errorf("runInProcessGroup() = %v, want no error", err)
+			}
+			if !waitForExit(child, 5*time.Second) {
+				t.Errorf("background child %d is still running", child)
+			}
+			if got := output.String(); got != tt.wantOutput {
+				t.Errorf("output = %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+}
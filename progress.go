@@ -0,0 +1,199 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// maxProgressInFlight bounds the number of in-flight tasks listed in a progress report.
+const maxProgressInFlight = 5
+
+// progressReporter reports the progress of the run: on a terminal as a status line on stderr that
+// is updated on each completion and every interval, otherwise as a plain line on stdout every interval.
+type progressReporter struct {
+	total       int
+	concurrency int
+	interval    time.Duration
+	terminal    bool
+	out         io.Writer
+
+	mutex     sync.Mutex
+	completed int
+	counts    map[string]int
+	// inFlight maps the task combinations being evaluated to when they started.
+	inFlight map[string]time.Time
+	// waiting counts the task combinations waiting on each provider limit.
+	waiting map[string]int
+	// executed and executedTime count the evaluated combinations and their durations, for the ETA.
+	executed     int
+	executedTime time.Duration
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// newProgressReporter returns a reporter of the progress of total task combinations,
+// or nil if the interval is 0.
+func newProgressReporter(total, concurrency int, interval time.Duration) *progressReporter {
+	if interval <= 0 {
+		return nil
+	}
+	p := &progressReporter{
+		total:       total,
+		concurrency: max(concurrency, 1),
+		interval:    interval,
+		terminal:    isTerminal(os.Stderr) && isTerminal(os.Stdout),
+		out:         os.Stdout,
+		counts:      make(map[string]int),
+		inFlight:    make(map[string]time.Time),
+		waiting:     make(map[string]int),
+		stopCh:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if p.terminal {
+		p.out = os.Stderr
+	}
+	go p.run()
+	return p
+}
+
+func (p *progressReporter) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			if p.terminal {
+				// Clear the status line.
+				fmt.Fprint(p.out, "\r\033[K")
+			}
+			return
+		case <-ticker.C:
+			p.report()
+		}
+	}
+}
+
+// stop stops reporting. A nil reporter does nothing.
+func (p *progressReporter) stop() {
+	if p == nil {
+		return
+	}
+	close(p.stopCh)
+	<-p.done
+}
+
+// started records that the evaluation of a task combination started. A nil reporter does nothing.
+func (p *progressReporter) started(key string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.inFlight[key] = time.Now()
+}
+
+// waitingOn records that a task combination started (delta 1) or stopped (delta -1) waiting
+// on the limit of a provider. A nil reporter does nothing.
+func (p *progressReporter) waitingOn(provider string, delta int) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.waiting[provider] += delta
+	if p.waiting[provider] <= 0 {
+		delete(p.waiting, provider)
+	}
+}
+
+// finished records the result of a task combination, which was evaluated if it was started.
+// A nil reporter does nothing.
+func (p *progressReporter) finished(key string, result model.TaskResult) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	if start, ok := p.inFlight[key]; ok {
+		p.executed++
+		p.executedTime += time.Since(start)
+		delete(p.inFlight, key)
+	}
+	p.completed++
+	p.counts[result.Result]++
+	p.mutex.Unlock()
+
+	if p.terminal {
+		p.report()
+	}
+}
+
+// report prints the progress.
+func (p *progressReporter) report() {
+	p.mutex.Lock()
+	line := p.statusLocked()
+	p.mutex.Unlock()
+	if p.terminal {
+		fmt.Fprintf(p.out, "\r\033[K%s", line)
+	} else {
+		slog.Info("Progress: " + line)
+	}
+}
+
+func (p *progressReporter) statusLocked() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d/%d done (%d success, %d fail, %d error, %d skipped)",
+		p.completed, p.total, p.counts["success"], p.counts["fail"], p.counts["error"], p.counts["skipped"])
+
+	// The remaining combinations take the mean duration so far, spread over the workers.
+	if p.executed > 0 && p.completed < p.total {
+		mean := p.executedTime / time.Duration(p.executed)
+		eta := mean * time.Duration(p.total-p.completed) / time.Duration(p.concurrency)
+		fmt.Fprintf(&b, ", ETA %s", eta.Round(time.Second))
+	}
+
+	if len(p.inFlight) > 0 {
+		keys := make([]string, 0, len(p.inFlight))
+		for key := range p.inFlight {
+			keys = append(keys, key)
+		}
+		// The longest running first.
+		sort.Slice(keys, func(i, j int) bool { return p.inFlight[keys[i]].Before(p.inFlight[keys[j]]) })
+		var running []string
+		for _, key := range keys[:min(len(keys), maxProgressInFlight)] {
+			running = append(running, fmt.Sprintf("%s %s", key, time.Since(p.inFlight[key]).Round(time.Second)))
+		}
+		if len(keys) > maxProgressInFlight {
+			running = append(running, fmt.Sprintf("+%d more", len(keys)-maxProgressInFlight))
+		}
+		fmt.Fprintf(&b, "; running: %s", strings.Join(running, ", "))
+	}
+
+	for _, provider := range sortedKeys(p.waiting) {
+		fmt.Fprintf(&b, "; %d tasks waiting on provider %s", p.waiting[provider], provider)
+	}
+	return b.String()
+}
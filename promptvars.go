@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// setupVarsEnvFile and setupVarsYAMLFile are the files in the task output directory that the
+	// setup script can write variables for the prompts to: KEY=VALUE lines, or a YAML map.
+	setupVarsEnvFile  = "vars.env"
+	setupVarsYAMLFile = "vars.yaml"
+)
+
+// runtimePromptVars are the variables of prompts that are only known when the task runs.
+// Matrix expansion leaves them in place, to be rendered when the prompt is sent.
+var runtimePromptVars = []string{"TaskID", "TaskDir", "TaskOutputDir", "KubeConfig", "Namespace"}
+
+// promptVars returns the variables prompts are rendered with: the matrix values of the task,
+// the variables written by the setup script, and the runtime variables, which take precedence.
+// Namespace is the first of the task's namespaces, and is only set if the task declares some.
+func (x *TaskExecution) promptVars() (map[string]string, error) {
+	vars := make(map[string]string)
+	for k, v := range x.task.vars {
+		vars[k] = v
+	}
+	setupVars, err := readSetupVars(x.taskOutputDir)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range setupVars {
+		vars[k] = v
+	}
+	vars["TaskID"] = x.taskID
+	vars["TaskDir"] = x.taskDir
+	vars["TaskOutputDir"] = x.taskOutputDir
+	vars["KubeConfig"] = x.kubeConfig
+	if len(x.task.Namespaces) > 0 {
+		vars["Namespace"] = x.task.Namespaces[0]
+	}
+	return vars, nil
+}
+
+// readSetupVars reads the variables the setup script wrote to the task output directory, if any.
+func readSetupVars(taskOutputDir string) (map[string]string, error) {
+	if taskOutputDir == "" {
+		return nil, nil
+	}
+	vars := make(map[string]string)
+
+	envPath := filepath.Join(taskOutputDir, setupVarsEnvFile)
+	data, err := os.ReadFile(envPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", envPath, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: must be KEY=VALUE", envPath, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[key] = value
+	}
+
+	yamlPath := filepath.Join(taskOutputDir, setupVarsYAMLFile)
+	data, err = os.ReadFile(yamlPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", yamlPath, err)
+	}
+	var yamlVars map[string]any
+	if err := yaml.Unmarshal(data, &yamlVars); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", yamlPath, err)
+	}
+	for k, v := range yamlVars {
+		vars[k] = fmt.Sprint(v)
+	}
+	return vars, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// RBAC restricts the agent of a task to the permissions of a ServiceAccount, instead of the
+// admin kubeconfig that the setup, verifiers and checks use.
+type RBAC struct {
+	// ReadOnlyNamespaces are namespaces the agent can read, with the view ClusterRole.
+	ReadOnlyNamespaces []string `json:"readOnlyNamespaces,omitempty"`
+	// Roles are existing Roles and ClusterRoles bound to the agent. A ClusterRole with a
+	// namespace is bound in that namespace only.
+	Roles []RoleRef `json:"roles,omitempty"`
+	// Manifests are files or directories (relative to the task directory) of Roles and ClusterRoles,
+	// applied after the setup script and bound to the agent.
+	Manifests []string `json:"manifests,omitempty"`
+}
+
+// RoleRef is a Role or ClusterRole bound to the agent.
+type RoleRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// String returns a human-readable description of the binding, e.g. ClusterRole view in shop.
+func (r RoleRef) String() string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s in %s", r.Kind, r.Name, r.Namespace)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// Validate checks that the roles can be bound.
+func (r *RBAC) Validate() error {
+	var errs []error
+	for i, role := range r.Roles {
+		if role.Name == "" {
+			errs = append(errs, fmt.Errorf("role %d must specify a name", i))
+		}
+		switch role.Kind {
+		case "Role":
+			if role.Namespace == "" {
+				errs = append(errs, fmt.Errorf("role %s of kind Role must specify a namespace", role.Name))
+			}
+		case "ClusterRole":
+		default:
+			errs = append(errs, fmt.Errorf("invalid kind %q of role %d, must be Role or ClusterRole", role.Kind, i))
+		}
+	}
+	if len(r.ReadOnlyNamespaces) == 0 && len(r.Roles) == 0 && len(r.Manifests) == 0 {
+		errs = append(errs, fmt.Errorf("must grant the agent some permissions"))
+	}
+	return errors.Join(errs...)
+}
+
+// rbacNamespace is the namespace of the agent's ServiceAccount.
+const rbacNamespace = "default"
+
+// rbacTokenDuration is the requested lifetime of the agent's token; the API server may shorten it.
+const rbacTokenDuration = "24h"
+
+// setupRBAC creates the ServiceAccount of the agent and binds its roles, and writes the kubeconfig
+// of the agent with a token of the ServiceAccount. Unless the task has its own cluster, everything
+// is deleted when the task is cleaned up.
+func (x *TaskExecution) setupRBAC(ctx context.Context) error {
+	rbac := x.task.RBAC
+	name, err := agentResourceName()
+	if err != nil {
+		return err
+	}
+
+	var manifests []string
+	for _, manifest := range rbac.Manifests {
+		manifestPath, err := resolveTaskPath(x.tasksDir, x.taskDir, manifest)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifestPath)
+	}
+	objects := []map[string]any{{
+		"apiVersion": "v1",
+		"kind":       "ServiceAccount",
+		"metadata":   map[string]any{"name": name, "namespace": rbacNamespace},
+	}}
+	if x.task.Isolation != IsolationModeCluster {
+		x.cleanupFunctions = append(x.cleanupFunctions, func() error {
+			return x.deleteRBAC(manifests, objects)
+		})
+	}
+
+	roles := append([]RoleRef(nil), rbac.Roles...)
+	for _, namespace := range rbac.ReadOnlyNamespaces {
+		roles = append(roles, RoleRef{Kind: "ClusterRole", Name: "view", Namespace: namespace})
+	}
+	for i, manifestPath := range manifests {
+		output, err := runKubectl(ctx, x.kubeConfig, "apply", "-f", manifestPath, "--output", "json")
+		if err != nil {
+			return fmt.Errorf("applying rbac manifest %s: %w", rbac.Manifests[i], err)
+		}
+		manifestRoles, err := appliedRoles(output)
+		if err != nil {
+			return fmt.Errorf("reading roles of rbac manifest %s: %w", rbac.Manifests[i], err)
+		}
+		roles = append(roles, manifestRoles...)
+	}
+
+	subject := map[string]any{"kind": "ServiceAccount", "name": name, "namespace": rbacNamespace}
+	identity := &model.AgentIdentity{ServiceAccount: fmt.Sprintf("system:serviceaccount:%s:%s", rbacNamespace, name)}
+	for i, role := range roles {
+		binding := map[string]any{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRoleBinding",
+			"metadata":   map[string]any{"name": fmt.Sprintf("%s-%d", name, i)},
+			"roleRef":    map[string]any{"apiGroup": "rbac.authorization.k8s.io", "kind": role.Kind, "name": role.Name},
+			"subjects":   []any{subject},
+		}
+		if role.Namespace != "" {
+			binding["kind"] = "RoleBinding"
+			binding["metadata"].(map[string]any)["namespace"] = role.Namespace
+		}
+		objects = append(objects, binding)
+		identity.Roles = append(identity.Roles, role.String())
+	}
+	if err := x.applyObjects(ctx, objects); err != nil {
+		return fmt.Errorf("creating the agent's ServiceAccount and bindings: %w", err)
+	}
+
+	token, err := runKubectl(ctx, x.kubeConfig, "create", "token", name, "--namespace", rbacNamespace, "--duration", rbacTokenDuration)
+	if err != nil {
+		return fmt.Errorf("creating a token for the agent: %w", err)
+	}
+	namespace := ""
+	if len(rbac.ReadOnlyNamespaces) > 0 {
+		namespace = rbac.ReadOnlyNamespaces[0]
+	}
+	kubeconfig, err := x.restrictedKubeConfig(ctx, name, strings.TrimSpace(string(token)), namespace)
+	if err != nil {
+		return err
+	}
+	x.agentKubeConfig = kubeconfig
+	x.cleanupFunctions = append(x.cleanupFunctions, func() error {
+		if err := os.Remove(kubeconfig); err != nil {
+			slog.Warn("failed to remove the agent kubeconfig", "task", x.taskID, "path", kubeconfig, "error", err)
+		}
+		return nil
+	})
+	x.result.AgentIdentity = identity
+	return nil
+}
+
+// appliedRoles returns the Roles and ClusterRoles in the output of kubectl apply --output json,
+// which is either an object or a List.
+func appliedRoles(output []byte) ([]RoleRef, error) {
+	type object struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Items []json.RawMessage `json:"items"`
+	}
+	var applied object
+	if err := json.Unmarshal(output, &applied); err != nil {
+		return nil, err
+	}
+	objects := []object{applied}
+	if applied.Kind == "List" {
+		objects = nil
+		for _, item := range applied.Items {
+			var o object
+			if err := json.Unmarshal(item, &o); err != nil {
+				return nil, err
+			}
+			objects = append(objects, o)
+		}
+	}
+	var roles []RoleRef
+	for _, o := range objects {
+		switch o.Kind {
+		case "Role":
+			roles = append(roles, RoleRef{Kind: o.Kind, Name: o.Metadata.Name, Namespace: o.Metadata.Namespace})
+		case "ClusterRole":
+			roles = append(roles, RoleRef{Kind: o.Kind, Name: o.Metadata.Name})
+		}
+	}
+	return roles, nil
+}
+
+// applyObjects applies the objects with kubectl, against the admin kubeconfig.
+func (x *TaskExecution) applyObjects(ctx context.Context, objects []map[string]any) error {
+	data, err := yaml.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": objects})
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", x.kubeConfig, "apply", "-f", "-")
+	cmd.Stdin = strings.NewReader(string(data))
+	if output, err := x.runCommand(cmd); err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// deleteRBAC deletes the ServiceAccount of the agent, its bindings and the rbac manifests.
+func (x *TaskExecution) deleteRBAC(manifests []string, objects []map[string]any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), manifestCleanupTimeout)
+	defer cancel()
+	var errs []string
+	data, err := yaml.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": objects})
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "kubectl", "--kubeconfig", x.kubeConfig, "delete", "-f", "-", "--ignore-not-found")
+	cmd.Stdin = strings.NewReader(string(data))
+	if output, err := x.runCommand(cmd); err != nil {
+		errs = append(errs, fmt.Sprintf("%v: %s", err, strings.TrimSpace(output)))
+	}
+	for i := len(manifests) - 1; i >= 0; i-- {
+		if err := x.kubectl(ctx, "delete", "-f", manifests[i], "--ignore-not-found"); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("deleting the agent's rbac: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// restrictedKubeConfig writes a kubeconfig for the cluster of the task that authenticates with the
+// token, to a temporary file, and returns its path.
+func (x *TaskExecution) restrictedKubeConfig(ctx context.Context, user, token, namespace string) (string, error) {
+	output, err := runKubectl(ctx, x.kubeConfig, "config", "view", "--minify", "--raw", "--flatten", "--output", "json")
+	if err != nil {
+		return "", fmt.Errorf("reading the cluster of the kubeconfig: %w", err)
+	}
+	var admin struct {
+		Clusters []struct {
+			Name    string         `json:"name"`
+			Cluster map[string]any `json:"cluster"`
+		} `json:"clusters"`
+	}
+	if err := json.Unmarshal(output, &admin); err != nil {
+		return "", fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	if len(admin.Clusters) == 0 {
+		return "", fmt.Errorf("kubeconfig %s has no current cluster", x.kubeConfig)
+	}
+	kubeContext := map[string]any{"cluster": admin.Clusters[0].Name, "user": user}
+	if namespace != "" {
+		kubeContext["namespace"] = namespace
+	}
+	data, err := yaml.Marshal(map[string]any{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"clusters":        []any{map[string]any{"name": admin.Clusters[0].Name, "cluster": admin.Clusters[0].Cluster}},
+		"users":           []any{map[string]any{"name": user, "user": map[string]any{"token": token}}},
+		"contexts":        []any{map[string]any{"name": user, "context": kubeContext}},
+		"current-context": user,
+	})
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "kubeconfig-agent-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("creating the agent kubeconfig: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing the agent kubeconfig: %w", err)
+	}
+	return f.Name(), nil
+}
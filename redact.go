@@ -0,0 +1,208 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// defaultRedactEnvPatterns select the environment variables whose values are redacted from logs.
+var defaultRedactEnvPatterns = []string{"*_API_KEY", "*_TOKEN", "AWS_SECRET*"}
+
+const redacted = "[REDACTED]"
+
+// minRedactedValueLength avoids redacting short values, which would mask unrelated output.
+const minRedactedValueLength = 6
+
+// maxRedactHoldback bounds how much output is held back waiting for a possible secret to complete.
+const maxRedactHoldback = 64 * 1024
+
+// secretPatterns match common token formats, whatever variable they come from.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+}
+
+// secretPatternLeads are the fixed beginnings of secretPatterns: output ending with a prefix of
+// one of them is held back, in case the rest of the secret follows in the next write.
+var secretPatternLeads = []string{"bearer ", "aiza", "sk-", "akia", "asia"}
+
+// redactor masks secrets: the values of selected environment variables and common token formats.
+type redactor struct {
+	values []string
+}
+
+// newRedactor returns a redactor for the values of the environment variables matching any of the glob patterns.
+func newRedactor(envPatterns []string) *redactor {
+	r := &redactor{}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if len(value) < minRedactedValueLength {
+			continue
+		}
+		for _, pattern := range envPatterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				r.values = append(r.values, value)
+				break
+			}
+		}
+	}
+	r.sort()
+	return r
+}
+
+// add masks more secret values, like those that are not in the environment of k8s-ai-bench.
+func (r *redactor) add(values ...string) {
+	for _, value := range values {
+		if len(value) >= minRedactedValueLength {
+			r.values = append(r.values, value)
+		}
+	}
+	r.sort()
+}
+
+// sort orders longer values first, so values containing others are fully masked.
+func (r *redactor) sort() {
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// redact masks the secrets in s.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, value := range r.values {
+		s = strings.ReplaceAll(s, value, redacted)
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// redactResult masks the secrets in the error and failure messages of the result.
+func (r *redactor) redactResult(result *model.TaskResult) {
+	if r == nil {
+		return
+	}
+	result.Error = r.redact(result.Error)
+	for i := range result.Failures {
+		result.Failures[i].Message = r.redact(result.Failures[i].Message)
+	}
+	for i := range result.CleanupErrors {
+		result.CleanupErrors[i] = r.redact(result.CleanupErrors[i])
+	}
+	for i := range result.Verifiers {
+		result.Verifiers[i].Stdout = r.redact(result.Verifiers[i].Stdout)
+		result.Verifiers[i].Stderr = r.redact(result.Verifiers[i].Stderr)
+	}
+}
+
+// holdback returns the offset in s from which output must be held back,
+// because a secret may start there and continue in the next write.
+func (r *redactor) holdback(s string) int {
+	cut := len(s)
+	for _, pattern := range secretPatterns {
+		for _, match := range pattern.FindAllStringIndex(s, -1) {
+			if match[1] == len(s) {
+				cut = min(cut, match[0])
+			}
+		}
+	}
+	lower := strings.ToLower(s)
+	holdPrefix := func(secret string, s string) {
+		for k := min(len(secret)-1, len(s)); k > 0; k-- {
+			if strings.HasSuffix(s, secret[:k]) {
+				cut = min(cut, len(s)-k)
+				return
+			}
+		}
+	}
+	if r != nil {
+		for _, value := range r.values {
+			holdPrefix(value, s)
+		}
+	}
+	for _, lead := range secretPatternLeads {
+		holdPrefix(lead, lower)
+	}
+	return max(cut, len(s)-maxRedactHoldback)
+}
+
+// redactFlushDelay is how long output is held back when no more output follows, so that output
+// that only looks like the beginning of a secret (e.g. a prompt) is not held back indefinitely.
+const redactFlushDelay = 100 * time.Millisecond
+
+// redactingWriter masks secrets in the output written to it, including secrets split
+// across writes: output that may be the beginning of a secret is held back until the next
+// write, Flush, or redactFlushDelay without further output.
+type redactingWriter struct {
+	mutex    sync.Mutex
+	w        io.Writer
+	redactor *redactor
+	pending  string
+	timer    *time.Timer
+}
+
+func newRedactingWriter(w io.Writer, r *redactor) *redactingWriter {
+	return &redactingWriter{w: w, redactor: r}
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pending += string(p)
+	cut := w.redactor.holdback(w.pending)
+	out := w.redactor.redact(w.pending[:cut])
+	w.pending = w.pending[cut:]
+	if w.pending != "" {
+		if w.timer == nil {
+			w.timer = time.AfterFunc(redactFlushDelay, func() { w.Flush() })
+		} else {
+			w.timer.Reset(redactFlushDelay)
+		}
+	}
+	if out == "" {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any output held back, once no more output will follow.
+func (w *redactingWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.pending == "" {
+		return nil
+	}
+	out := w.redactor.redact(w.pending)
+	w.pending = ""
+	_, err := io.WriteString(w.w, out)
+	return err
+}
@@ -0,0 +1,405 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+const uncategorized = "uncategorized"
+
+// difficultyOf returns the difficulty of the result, or "uncategorized" if it is missing or unknown.
+func difficultyOf(result model.TaskResult) string {
+	switch difficulty := strings.ToLower(strings.TrimSpace(result.Difficulty)); difficulty {
+	case "easy", "medium", "hard":
+		return difficulty
+	default:
+		return uncategorized
+	}
+}
+
+// categoryOf returns the category of the result, or "uncategorized" if it is missing.
+func categoryOf(result model.TaskResult) string {
+	if category := strings.TrimSpace(result.Category); category != "" {
+		return category
+	}
+	return uncategorized
+}
+
+// writeBreakdownTable writes a markdown table of the pass rate and mean score
+// of each LLM config, broken down by the group returned by groupOf. Skipped results are left out.
+func writeBreakdownTable(buffer *strings.Builder, results []model.TaskResult, groupName string, groupOf func(model.TaskResult) string) {
+	type key struct {
+		llmConfig string
+		group     string
+	}
+	grouped := make(map[key][]model.TaskResult)
+	for _, result := range evaluatedResults(results) {
+		k := key{llmConfig: result.ConfigID(), group: groupOf(result)}
+		grouped[k] = append(grouped[k], result)
+	}
+	keys := make([]key, 0, len(grouped))
+	for k := range grouped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].llmConfig != keys[j].llmConfig {
+			return keys[i].llmConfig < keys[j].llmConfig
+		}
+		return keys[i].group < keys[j].group
+	})
+
+	buffer.WriteString(fmt.Sprintf("| LLM Config | %s | Passed | Total | Pass Rate | Mean Score |\n", groupName))
+	buffer.WriteString("|------------|------|--------|-------|-----------|------------|\n")
+	for _, k := range keys {
+		group := grouped[k]
+		passed := 0
+		for _, result := range group {
+			if result.Result == "success" {
+				passed++
+			}
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %d%% | %.2f |\n",
+			k.llmConfig, k.group, passed, len(group), calculatePercentage(passed, len(group)), meanScore(group)))
+	}
+	buffer.WriteString("\n")
+}
+
+// passAtK estimates the probability that at least one of k samples passes, given that
+// passed of n samples passed, using the unbiased estimator 1 - C(n-passed, k) / C(n, k).
+// If there are fewer than k samples, k is reduced to n.
+func passAtK(n, passed, k int) float64 {
+	if n == 0 {
+		return 0
+	}
+	k = min(k, n)
+	if n-passed < k {
+		return 1
+	}
+	// C(n-passed, k) / C(n, k) = prod_{i=n-passed+1}^{n} (1 - k/i)
+	p := 1.0
+	for i := n - passed + 1; i <= n; i++ {
+		p *= 1 - float64(k)/float64(i)
+	}
+	return 1 - p
+}
+
+// samples counts the samples of a task with an LLM config, excluding errors, which are
+// infrastructure problems rather than failures of the model.
+type samples struct {
+	total  int
+	passed int
+	errors int
+}
+
+func (s *samples) add(result model.TaskResult) {
+	switch result.Result {
+	case "success":
+		s.passed++
+	case "error":
+		s.errors++
+		return
+	}
+	s.total++
+}
+
+// writePassAtKTables writes markdown tables of the pass rate, pass@1 and pass@k of each
+// task with each LLM config, and of each LLM config averaged over its tasks.
+// Results with an error, and skipped results, are excluded from the samples.
+func writePassAtKTables(buffer *strings.Builder, results []model.TaskResult, k int) {
+	type key struct {
+		llmConfig string
+		task      string
+	}
+	grouped := make(map[key]*samples)
+	for _, result := range evaluatedResults(results) {
+		kk := key{llmConfig: result.ConfigID(), task: result.Task}
+		if grouped[kk] == nil {
+			grouped[kk] = &samples{}
+		}
+		grouped[kk].add(result)
+	}
+	keys := make([]key, 0, len(grouped))
+	for kk := range grouped {
+		keys = append(keys, kk)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].llmConfig != keys[j].llmConfig {
+			return keys[i].llmConfig < keys[j].llmConfig
+		}
+		return keys[i].task < keys[j].task
+	})
+
+	type summary struct {
+		samples
+		tasks      int
+		sumPassAt1 float64
+		sumPassAtK float64
+	}
+	byLLMConfig := make(map[string]*summary)
+	var llmConfigs []string
+
+	buffer.WriteString(fmt.Sprintf("| LLM Config | Task | Samples | Errors | Pass Rate | pass@1 | pass@%d |\n", k))
+	buffer.WriteString("|------------|------|---------|--------|-----------|--------|--------|\n")
+	for _, kk := range keys {
+		s := grouped[kk]
+		passAt1 := passAtK(s.total, s.passed, 1)
+		passAtN := passAtK(s.total, s.passed, k)
+		buffer.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %d%% | %.2f | %.2f |\n",
+			kk.llmConfig, kk.task, s.total, s.errors, calculatePercentage(s.passed, s.total), passAt1, passAtN))
+
+		sum := byLLMConfig[kk.llmConfig]
+		if sum == nil {
+			sum = &summary{}
+			byLLMConfig[kk.llmConfig] = sum
+			llmConfigs = append(llmConfigs, kk.llmConfig)
+		}
+		sum.total += s.total
+		sum.passed += s.passed
+		sum.errors += s.errors
+		if s.total > 0 {
+			sum.tasks++
+			sum.sumPassAt1 += passAt1
+			sum.sumPassAtK += passAtN
+		}
+	}
+	buffer.WriteString("\n")
+
+	buffer.WriteString(fmt.Sprintf("| LLM Config | Tasks | Samples | Errors | Pass Rate | pass@1 | pass@%d |\n", k))
+	buffer.WriteString("|------------|-------|---------|--------|-----------|--------|--------|\n")
+	for _, llmConfig := range llmConfigs {
+		sum := byLLMConfig[llmConfig]
+		var passAt1, passAtN float64
+		if sum.tasks > 0 {
+			passAt1 = sum.sumPassAt1 / float64(sum.tasks)
+			passAtN = sum.sumPassAtK / float64(sum.tasks)
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d%% | %.2f | %.2f |\n",
+			llmConfig, sum.tasks, sum.total, sum.errors, calculatePercentage(sum.passed, sum.total), passAt1, passAtN))
+	}
+	buffer.WriteString("\n")
+}
+
+// writeLLMConfigSummaryTable writes a markdown table of the result counts, pass rate
+// and durations of each LLM config, sorted by LLM config ID.
+func writeLLMConfigSummaryTable(buffer *strings.Builder, results []model.TaskResult) {
+	durations := make(map[string][]time.Duration)
+	for _, result := range results {
+		if d, err := time.ParseDuration(result.Duration); err == nil {
+			durations[result.ConfigID()] = append(durations[result.ConfigID()], d)
+		}
+	}
+
+	buffer.WriteString("| LLM Config | Success | Fail | Error | Skipped | Pass Rate | Total Duration | Mean Duration |\n")
+	buffer.WriteString("|------------|---------|------|-------|---------|-----------|----------------|---------------|\n")
+	for _, summary := range summarizeLLMConfigs(results) {
+		var total, mean time.Duration
+		for _, d := range durations[summary.ID] {
+			total += d
+		}
+		if n := len(durations[summary.ID]); n > 0 {
+			mean = total / time.Duration(n)
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d%% | %s | %s |\n",
+			summary.ID, summary.Success, summary.Fail, summary.Error, summary.Skipped,
+			calculatePercentage(summary.Success, summary.Total-summary.Skipped), total.Round(time.Second), mean.Round(time.Second)))
+	}
+	buffer.WriteString("\n")
+}
+
+// writeFailedTasks lists the tasks that failed or errored under each LLM config,
+// with LLM configs and tasks sorted by ID.
+func writeFailedTasks(buffer *strings.Builder, results []model.TaskResult) {
+	failed := make(map[string][]model.TaskResult)
+	for _, result := range results {
+		if result.Result == "fail" || result.Result == "error" {
+			failed[result.ConfigID()] = append(failed[result.ConfigID()], result)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	llmConfigs := make([]string, 0, len(failed))
+	for llmConfig := range failed {
+		llmConfigs = append(llmConfigs, llmConfig)
+	}
+	sort.Strings(llmConfigs)
+
+	buffer.WriteString("Failed tasks:\n")
+	for _, llmConfig := range llmConfigs {
+		group := failed[llmConfig]
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Task != group[j].Task {
+				return group[i].Task < group[j].Task
+			}
+			return group[i].Run < group[j].Run
+		})
+		buffer.WriteString(fmt.Sprintf("\n  %s (%d):\n", llmConfig, len(group)))
+		for _, result := range group {
+			line := fmt.Sprintf("    %s: %s", result.Task, result.Result)
+			if result.Run > 0 {
+				line = fmt.Sprintf("    %s (run %d): %s", result.Task, result.Run, result.Result)
+			}
+			buffer.WriteString(line + "\n")
+		}
+	}
+	buffer.WriteString("\n")
+}
+
+// writePhaseTimingTable writes a markdown table of the total time each LLM config spent in
+// each phase of its tasks, sorted by LLM config ID.
+func writePhaseTimingTable(buffer *strings.Builder, results []model.TaskResult) {
+	type phases struct {
+		setup, agent, verify, cleanup time.Duration
+	}
+	byLLMConfig := make(map[string]*phases)
+	var llmConfigs []string
+	add := func(total *time.Duration, duration string) {
+		if d, err := time.ParseDuration(duration); err == nil {
+			*total += d
+		}
+	}
+	for _, result := range results {
+		if result.Timing == nil {
+			continue
+		}
+		p := byLLMConfig[result.ConfigID()]
+		if p == nil {
+			p = &phases{}
+			byLLMConfig[result.ConfigID()] = p
+			llmConfigs = append(llmConfigs, result.ConfigID())
+		}
+		add(&p.setup, result.Timing.Setup)
+		add(&p.agent, result.Timing.Agent)
+		add(&p.verify, result.Timing.Verify)
+		add(&p.cleanup, result.Timing.Cleanup)
+	}
+	sort.Strings(llmConfigs)
+
+	buffer.WriteString("| LLM Config | Setup | Agent | Verify | Cleanup |\n")
+	buffer.WriteString("|------------|-------|-------|--------|---------|\n")
+	for _, llmConfig := range llmConfigs {
+		p := byLLMConfig[llmConfig]
+		buffer.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", llmConfig,
+			p.setup.Round(time.Second), p.agent.Round(time.Second), p.verify.Round(time.Second), p.cleanup.Round(time.Second)))
+	}
+	buffer.WriteString("\n")
+}
+
+// writeUsageTable writes a markdown table of the LLM usage and estimated cost of each LLM config,
+// sorted by LLM config ID. Usage and cost are "unknown" for LLM configs whose traces reported none.
+func writeUsageTable(buffer *strings.Builder, results []model.TaskResult) {
+	type totals struct {
+		tasks, withUsage, withCost            int
+		calls, promptTokens, completionTokens int
+		cost                                  float64
+	}
+	byLLMConfig := make(map[string]*totals)
+	var llmConfigs []string
+	for _, result := range results {
+		if result.Result == "skipped" {
+			continue
+		}
+		t := byLLMConfig[result.ConfigID()]
+		if t == nil {
+			t = &totals{}
+			byLLMConfig[result.ConfigID()] = t
+			llmConfigs = append(llmConfigs, result.ConfigID())
+		}
+		t.tasks++
+		if result.Usage == nil {
+			continue
+		}
+		t.withUsage++
+		t.calls += result.Usage.LLMCalls
+		t.promptTokens += result.Usage.PromptTokens
+		t.completionTokens += result.Usage.CompletionTokens
+		if result.Usage.Cost != nil {
+			t.withCost++
+			t.cost += *result.Usage.Cost
+		}
+	}
+	sort.Strings(llmConfigs)
+
+	buffer.WriteString("| LLM Config | Tasks With Usage | LLM Calls | Prompt Tokens | Completion Tokens | Total Cost | Cost per Task |\n")
+	buffer.WriteString("|------------|------------------|-----------|---------------|-------------------|------------|---------------|\n")
+	for _, llmConfig := range llmConfigs {
+		t := byLLMConfig[llmConfig]
+		if t.withUsage == 0 {
+			buffer.WriteString(fmt.Sprintf("| %s | 0/%d | unknown | unknown | unknown | unknown | unknown |\n", llmConfig, t.tasks))
+			continue
+		}
+		totalCost, costPerTask := "unknown", "unknown"
+		if t.withCost > 0 {
+			totalCost = fmt.Sprintf("$%.4f", t.cost)
+			costPerTask = fmt.Sprintf("$%.4f", t.cost/float64(t.withCost))
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %d/%d | %d | %d | %d | %s | %s |\n",
+			llmConfig, t.withUsage, t.tasks, t.calls, t.promptTokens, t.completionTokens, totalCost, costPerTask))
+	}
+	buffer.WriteString("\n")
+}
+
+// writeMetricsTable writes a markdown table of the median tool calls, kubectl mutations and reads,
+// and LLM turns of each LLM config, and the share of tasks with a tool error, sorted by LLM config ID.
+func writeMetricsTable(buffer *strings.Builder, results []model.TaskResult) {
+	byLLMConfig := make(map[string][]*model.Metrics)
+	for _, result := range results {
+		if result.Metrics != nil {
+			byLLMConfig[result.ConfigID()] = append(byLLMConfig[result.ConfigID()], result.Metrics)
+		}
+	}
+	llmConfigs := make([]string, 0, len(byLLMConfig))
+	for llmConfig := range byLLMConfig {
+		llmConfigs = append(llmConfigs, llmConfig)
+	}
+	sort.Strings(llmConfigs)
+
+	buffer.WriteString("| LLM Config | Tasks | Tool Calls | Kubectl Mutations | Kubectl Reads | LLM Turns | Tool Errors |\n")
+	buffer.WriteString("|------------|-------|------------|-------------------|---------------|-----------|-------------|\n")
+	for _, llmConfig := range llmConfigs {
+		metrics := byLLMConfig[llmConfig]
+		median := func(value func(*model.Metrics) int) float64 {
+			values := make([]int, 0, len(metrics))
+			for _, m := range metrics {
+				values = append(values, value(m))
+			}
+			sort.Ints(values)
+			n := len(values)
+			if n%2 == 1 {
+				return float64(values[n/2])
+			}
+			return float64(values[n/2-1]+values[n/2]) / 2
+		}
+		toolErrors := 0
+		for _, m := range metrics {
+			if m.ToolErrors {
+				toolErrors++
+			}
+		}
+		buffer.WriteString(fmt.Sprintf("| %s | %d | %g | %g | %g | %g | %d%% |\n", llmConfig, len(metrics),
+			median(func(m *model.Metrics) int { return m.ToolCalls }),
+			median(func(m *model.Metrics) int { return m.KubectlMutations }),
+			median(func(m *model.Metrics) int { return m.KubectlReads }),
+			median(func(m *model.Metrics) int { return m.LLMTurns }),
+			calculatePercentage(toolErrors, len(metrics))))
+	}
+	buffer.WriteString("\n")
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// rerunSelection is the set of (task ID, LLM config ID) pairs selected by --rerun-failed.
+type rerunSelection struct {
+	// pairs maps task IDs to the IDs of the LLM configs to run them with.
+	pairs map[string]map[string]bool
+	// rerunOf identifies the previous run: its run ID, or its output directory if unknown.
+	rerunOf string
+}
+
+// selects reports whether the task should be run with the LLM config.
+// A nil selection selects everything.
+func (r *rerunSelection) selects(taskID, llmID string) bool {
+	return r == nil || r.pairs[taskID][llmID]
+}
+
+// loadRerunSelection reads the results of a previous run from dir, and selects the
+// (task, LLM config) pairs whose result was fail or error. LLM configs are identified by
+// their model.ConfigID, so runs comparing agents rerun each agent's failures.
+// Pairs whose task or LLM config is not part of this run are reported and skipped.
+func loadRerunSelection(dir string, tasks map[string]Task, configIDs []string) (*rerunSelection, error) {
+	results, err := collectResults(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading previous results from %s: %w", dir, err)
+	}
+
+	selection := &rerunSelection{
+		pairs:   make(map[string]map[string]bool),
+		rerunOf: dir,
+	}
+	var metadata model.RunMetadata
+	if data, err := os.ReadFile(filepath.Join(dir, runMetadataFile)); err == nil {
+		if err := yaml.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("parsing run metadata of %s: %w", dir, err)
+		}
+		if metadata.RunID != "" {
+			selection.rerunOf = metadata.RunID
+		}
+	}
+
+	llmIDs := make(map[string]bool, len(configIDs))
+	for _, id := range configIDs {
+		llmIDs[id] = true
+	}
+
+	missing := make(map[string]bool)
+	for _, result := range results {
+		if result.Result != "fail" && result.Result != "error" {
+			continue
+		}
+		pair := fmt.Sprintf("%s with %s", result.Task, result.ConfigID())
+		if _, ok := tasks[result.Task]; !ok {
+			missing[pair+": task not found"] = true
+			continue
+		}
+		if !llmIDs[result.ConfigID()] {
+			missing[pair+": LLM config not selected"] = true
+			continue
+		}
+		if selection.pairs[result.Task] == nil {
+			selection.pairs[result.Task] = make(map[string]bool)
+		}
+		selection.pairs[result.Task][result.ConfigID()] = true
+	}
+
+	var skipped []string
+	for pair := range missing {
+		skipped = append(skipped, pair)
+	}
+	sort.Strings(skipped)
+	for _, pair := range skipped {
+		slog.Warn("not rerunning pair", "pair", pair)
+	}
+
+	count := 0
+	for _, llmIDs := range selection.pairs {
+		count += len(llmIDs)
+	}
+	slog.Info("Rerunning failed task/LLM config pairs", "count", count, "from", selection.rerunOf)
+	return selection, nil
+}
+
+// filter removes the tasks that are not selected with any LLM config.
+func (r *rerunSelection) filter(tasks map[string]Task) {
+	for taskID := range tasks {
+		if len(r.pairs[taskID]) == 0 {
+			delete(tasks, taskID)
+		}
+	}
+}
+
+// loadCompletedResult returns the result in results.yaml in the task output directory, if it
+// was written by a previous run that completed the task, or nil if the task must be run again.
+func loadCompletedResult(taskOutputDir string) *model.TaskResult {
+	data, err := os.ReadFile(filepath.Join(taskOutputDir, "results.yaml"))
+	if err != nil {
+		return nil
+	}
+	var result model.TaskResult
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	switch result.Result {
+	case "success", "fail", "error", "skipped":
+		return &result
+	default:
+		return nil
+	}
+}
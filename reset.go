@@ -0,0 +1,113 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// defaultResetAllowlist are the namespaces that are never deleted when resetting a shared cluster.
+var defaultResetAllowlist = []string{"default", "kube-system", "kube-public", "kube-node-lease", "local-path-storage"}
+
+// resetResourceTypes are the cluster-scoped resource types we snapshot and reset, in deletion order.
+// Webhooks go first, as they can block the deletion of other objects.
+var resetResourceTypes = []string{
+	"validatingwebhookconfigurations",
+	"mutatingwebhookconfigurations",
+	"namespaces",
+	"customresourcedefinitions",
+	"clusterrolebindings",
+	"clusterroles",
+}
+
+// clusterSnapshot records the objects (as kubectl "type/name" references) that existed at the start of the run.
+type clusterSnapshot struct {
+	objects map[string]bool
+}
+
+// takeClusterSnapshot records the cluster-scoped objects of the reset resource types.
+func takeClusterSnapshot(ctx context.Context, kubeconfig string) (*clusterSnapshot, error) {
+	snapshot := &clusterSnapshot{objects: make(map[string]bool)}
+	for _, resourceType := range resetResourceTypes {
+		names, err := listObjectNames(ctx, kubeconfig, resourceType)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			snapshot.objects[name] = true
+		}
+	}
+	return snapshot, nil
+}
+
+// listObjectNames returns the objects of a resource type as "type/name" references.
+func listObjectNames(ctx context.Context, kubeconfig string, resourceType string) ([]string, error) {
+	output, err := runKubectl(ctx, kubeconfig, "get", resourceType, "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", resourceType, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// resetClusterState deletes the objects created since the baseline snapshot, except namespaces in the allowlist
+// and system cluster roles. It returns the deleted objects, and errors for objects that could not be deleted.
+func resetClusterState(ctx context.Context, kubeconfig string, baseline *clusterSnapshot, allowlist []string) ([]string, []error) {
+	allowed := make(map[string]bool)
+	for _, namespace := range allowlist {
+		allowed["namespace/"+namespace] = true
+	}
+
+	var deleted []string
+	var errs []error
+	for _, resourceType := range resetResourceTypes {
+		names, err := listObjectNames(ctx, kubeconfig, resourceType)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, name := range names {
+			if baseline.objects[name] || allowed[name] {
+				continue
+			}
+			// Cluster roles and bindings prefixed with system: are managed by kubernetes itself.
+			if _, objectName, _ := strings.Cut(name, "/"); strings.HasPrefix(objectName, "system:") {
+				continue
+			}
+			if _, err := runKubectl(ctx, kubeconfig, "delete", name, "--ignore-not-found", "--timeout=2m"); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			deleted = append(deleted, name)
+		}
+	}
+	return deleted, errs
+}
+
+// resetSharedCluster resets the shared cluster to the baseline snapshot, and reports what happened.
+func resetSharedCluster(ctx context.Context, config EvalConfig, baseline *clusterSnapshot) {
+	start := time.Now()
+	deleted, errs := resetClusterState(ctx, config.KubeConfig, baseline, config.ResetAllowlist)
+	slog.Info("Reset shared cluster", "duration", time.Since(start), "deleted", len(deleted))
+	for _, name := range deleted {
+		slog.Debug("Deleted object of the shared cluster", "object", name)
+	}
+	for _, err := range errs {
+		slog.Warn("could not reset the shared cluster", "error", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// runMetadataFile is written to the output directory when a run starts, and updated when it completes.
+const runMetadataFile = "run-metadata.yaml"
+
+// metadataCommandTimeout bounds the commands run to describe the environment of a run.
+const metadataCommandTimeout = 30 * time.Second
+
+// describeEnvironment fills in the parts of the run metadata that describe where and with
+// what the run ran. Anything that cannot be determined is left empty.
+func describeEnvironment(ctx context.Context, config EvalConfig, metadata *model.RunMetadata) error {
+	configMap, err := redactedConfig(config)
+	if err != nil {
+		return err
+	}
+	metadata.Config = configMap
+	metadata.Hostname, _ = os.Hostname()
+
+	ctx, cancel := context.WithTimeout(ctx, metadataCommandTimeout)
+	defer cancel()
+
+	if config.AgentImage != "" {
+		metadata.AgentVersion = containerImageVersion(ctx, config.AgentContainer.Runtime, config.AgentImage)
+	} else if config.AgentRunner != AgentRunnerHTTP {
+		// With several agents, the version of each is recorded as "<id>: <version>".
+		var versions []string
+		for _, agent := range config.Agents {
+			output, err := exec.CommandContext(ctx, agent.Bin, "--version").Output()
+			if err != nil {
+				continue
+			}
+			version := strings.TrimSpace(string(output))
+			if agent.ID != "" {
+				version = agent.ID + ": " + version
+			}
+			versions = append(versions, version)
+		}
+		metadata.AgentVersion = strings.Join(versions, "; ")
+	}
+	if output, err := exec.CommandContext(ctx, "git", "-C", config.TasksDir, "rev-parse", "HEAD").Output(); err == nil {
+		metadata.TasksGitSHA = strings.TrimSpace(string(output))
+	}
+	metadata.Cluster = &model.ClusterInfo{
+		Provider:      config.ClusterProvider,
+		Name:          config.clusterName,
+		ServerVersion: serverVersion(ctx, config.KubeConfig),
+	}
+	return nil
+}
+
+// serverVersion returns the Kubernetes server version of the cluster, or "" if it cannot be queried.
+func serverVersion(ctx context.Context, kubeconfig string) string {
+	ctx, cancel := context.WithTimeout(ctx, metadataCommandTimeout)
+	defer cancel()
+	output, err := runKubectl(ctx, kubeconfig, "version", "--output", "json")
+	if err != nil {
+		return ""
+	}
+	var version struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if json.Unmarshal(output, &version) != nil {
+		return ""
+	}
+	return version.ServerVersion.GitVersion
+}
+
+// writeRunMetadata writes the run metadata to the output directory.
+func writeRunMetadata(config EvalConfig, metadata *model.RunMetadata) error {
+	if err := writeToYAMLFile(filepath.Join(config.OutputDir, runMetadataFile), metadata); err != nil {
+		return fmt.Errorf("writing run metadata: %w", err)
+	}
+	return nil
+}
+
+// completeRunMetadata records the end of the run in the metadata and writes it again.
+func completeRunMetadata(config EvalConfig, metadata *model.RunMetadata, results []model.TaskResult, runErr error) error {
+	endTime := time.Now()
+	metadata.EndTime = &endTime
+	if runErr != nil {
+		metadata.Error = runErr.Error()
+	}
+	metadata.ResultCounts = make(map[string]int)
+	metadata.TotalCost, metadata.TotalTokens = nil, 0
+	var cost float64
+	for _, result := range results {
+		metadata.ResultCounts[result.Result]++
+		if result.Usage == nil {
+			continue
+		}
+		metadata.TotalTokens += result.Usage.Tokens()
+		if result.Usage.Cost != nil {
+			cost += *result.Usage.Cost
+			metadata.TotalCost = &cost
+		}
+	}
+	return writeRunMetadata(config, metadata)
+}
+
+// latestLink is the symlink in the output root to the output directory of the last successful run.
+const latestLink = "latest"
+
+// updateLatestLink points the latest symlink in the output root at this run's output directory.
+func updateLatestLink(config EvalConfig) error {
+	link := filepath.Join(config.outputRoot, latestLink)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symlink", link)
+	}
+	// The link is replaced atomically, so it always points at a complete run.
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(config.OutputDir), tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, link)
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+// runResultsFile is the aggregated results file in the output directory.
+const runResultsFile = "results.json"
+
+// writeReports writes results.json and the reports requested in the config.
+// runErr is the error the run ended with, if any.
+func writeReports(config EvalConfig, startTime time.Time, results []model.TaskResult, runErr error) error {
+	var errs []error
+	if config.OutputDir != "" {
+		if err := writeRunResults(config, startTime, results, runErr); err != nil {
+			errs = append(errs, fmt.Errorf("writing aggregated results: %w", err))
+		}
+	}
+	if config.ReportHTML != "" {
+		if err := writeHTMLReport(config, results); err != nil {
+			errs = append(errs, fmt.Errorf("writing HTML report: %w", err))
+		}
+	}
+	if config.ReportJUnit != "" {
+		if err := writeJUnitReport(config, results); err != nil {
+			errs = append(errs, fmt.Errorf("writing JUnit report: %w", err))
+		}
+	}
+	if config.ReportCSV != "" {
+		if err := writeCSVReport(config, results); err != nil {
+			errs = append(errs, fmt.Errorf("writing CSV report: %w", err))
+		}
+	}
+	if config.ReportMarkdown != "" {
+		if err := writeMarkdownReport(config, startTime, results); err != nil {
+			errs = append(errs, fmt.Errorf("writing Markdown report: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeRunResults writes the aggregated results of the run to results.json in the output directory.
+// runErr is the error the run ended with, if any.
+func writeRunResults(config EvalConfig, startTime time.Time, results []model.TaskResult, runErr error) error {
+	runResults := model.RunResults{
+		SchemaVersion: model.RunResultsSchemaVersion,
+		RunID:         config.RunID,
+		StartTime:     startTime,
+		EndTime:       time.Now(),
+		ResultCounts:  make(map[string]int),
+		LLMConfigs:    summarizeLLMConfigs(results),
+		Results:       results,
+	}
+	if runErr != nil {
+		runResults.Error = runErr.Error()
+	}
+
+	configMap, err := redactedConfig(config)
+	if err != nil {
+		return err
+	}
+	runResults.Config = configMap
+
+	tasks := make(map[string]bool)
+	for _, result := range results {
+		tasks[result.Task] = true
+		runResults.ResultCounts[result.Result]++
+	}
+	runResults.TaskCount = len(tasks)
+
+	data, err := json.MarshalIndent(runResults, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling results: %w", err)
+	}
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	p := filepath.Join(config.OutputDir, runResultsFile)
+	if err := os.WriteFile(p+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", p, err)
+	}
+	if err := os.Rename(p+".tmp", p); err != nil {
+		return fmt.Errorf("writing to file %q: %w", p, err)
+	}
+	return nil
+}
+
+// summarizeLLMConfigs aggregates the results of each LLM config (per agent, when the run
+// compared several), sorted by ID.
+func summarizeLLMConfigs(results []model.TaskResult) []model.LLMConfigSummary {
+	byID := make(map[string][]model.TaskResult)
+	for _, result := range results {
+		byID[result.ConfigID()] = append(byID[result.ConfigID()], result)
+	}
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	summaries := make([]model.LLMConfigSummary, 0, len(ids))
+	for _, id := range ids {
+		summary := model.LLMConfigSummary{ID: id, Agent: byID[id][0].Agent, Total: len(byID[id])}
+		for _, result := range byID[id] {
+			switch result.Result {
+			case "success":
+				summary.Success++
+			case "fail":
+				summary.Fail++
+			case "error":
+				summary.Error++
+			case "skipped":
+				summary.Skipped++
+			}
+		}
+		if evaluated := evaluatedResults(byID[id]); len(evaluated) > 0 {
+			summary.PassRate = float64(summary.Success) / float64(len(evaluated))
+			summary.MeanScore = meanScore(evaluated)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// sensitiveConfigKey matches config fields whose values may hold credentials, or point at them.
+// Token counts (like MaxTotalTokens) are not credentials.
+var sensitiveConfigKey = regexp.MustCompile(`(?i)(token([^s]|$)|secret|password|credential|apikey|api_key|auth|webhook|kubeconfig|^env$|^headers?$)`)
+
+// redactedConfig converts the config to a map for results.json, redacting the values of sensitive fields.
+func redactedConfig(config EvalConfig) (map[string]any, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling config: %w", err)
+	}
+	var configMap map[string]any
+	if err := json.Unmarshal(data, &configMap); err != nil {
+		return nil, fmt.Errorf("unmarshaling config: %w", err)
+	}
+	redact(configMap)
+	return configMap, nil
+}
+
+func redact(value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			if sensitiveConfigKey.MatchString(k) && v != nil && v != "" {
+				value[k] = "REDACTED"
+				continue
+			}
+			redact(v)
+		}
+	case []any:
+		for _, v := range value {
+			redact(v)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// ScriptRef is a script in the task directory, run with optional arguments and environment.
+// In task.yaml it can be written either as the script path, or as an object:
+//
+//	verifier: {script: verify.sh, args: ["shop", "web"], env: {EXPECTED_REPLICAS: "3"}}
+type ScriptRef struct {
+	Script string            `json:"script"`
+	Args   []string          `json:"args,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// UnmarshalJSON accepts either a plain string (the script path) or a ScriptRef object.
+func (s *ScriptRef) UnmarshalJSON(data []byte) error {
+	var script string
+	if err := json.Unmarshal(data, &script); err == nil {
+		*s = ScriptRef{Script: script}
+		return nil
+	}
+	// Use a type without the UnmarshalJSON method to avoid recursion.
+	type scriptRef ScriptRef
+	var ref scriptRef
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ref); err != nil {
+		return fmt.Errorf("script must be a path or an object with script, args and env: %w", err)
+	}
+	*s = ScriptRef(ref)
+	return nil
+}
+
+// expand expands ${TASK_ID}, ${TASK_DIR}, ${TASK_OUTPUT_DIR}, ${KUBECONFIG} and the
+// ${KUBECONFIG_<NAME>} of the task's clusters in s, other variables are taken from the environment.
+func (x *TaskExecution) expand(s string) string {
+	return os.Expand(s, func(name string) string {
+		switch name {
+		case "TASK_ID":
+			return x.taskID
+		case "TASK_DIR":
+			return x.taskDir
+		case "TASK_OUTPUT_DIR":
+			return x.taskOutputDir
+		case "KUBECONFIG":
+			return x.kubeConfig
+		}
+		for _, c := range x.clusters {
+			if c.name != "" && name == clusterKubeConfigVar(c.name) {
+				return c.kubeConfig
+			}
+		}
+		return os.Getenv(name)
+	})
+}
+
+// taskEnv returns the environment for commands run for the task: the current environment,
+// KUBECONFIG set to the task's kubeconfig, and the task's env.
+func (x *TaskExecution) taskEnv() []string {
+	return append(os.Environ(), x.taskVars()...)
+}
+
+// taskVars returns the variables the task sets: KUBECONFIG, the KUBECONFIG_<NAME> of the task's
+// clusters and the task's env.
+func (x *TaskExecution) taskVars() []string {
+	env := []string{fmt.Sprintf("KUBECONFIG=%s", x.kubeConfig)}
+	for _, c := range x.clusters {
+		if c.name != "" {
+			env = append(env, fmt.Sprintf("%s=%s", clusterKubeConfigVar(c.name), c.kubeConfig))
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(x.task.Env)) {
+		env = append(env, fmt.Sprintf("%s=%s", k, x.expand(x.task.Env[k])))
+	}
+	return env
+}
+
+// scriptCommand builds the command for a task script, with the task environment and the script's env.
+// Variables are expanded in the args and env values (see expand).
+func (x *TaskExecution) scriptCommand(ctx context.Context, script ScriptRef) (*exec.Cmd, error) {
+	scriptPath, err := resolveTaskPath(x.tasksDir, x.taskDir, script.Script)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, arg := range script.Args {
+		args = append(args, x.expand(arg))
+	}
+	cmd := exec.CommandContext(ctx, scriptPath, args...)
+	cmd.Env = x.taskEnv()
+	if x.taskOutputDir != "" {
+		// Scripts can write files to the task output directory, e.g. the setup script's vars.env.
+		// The path is absolute, as scripts run in the task directory.
+		outputDir, err := filepath.Abs(x.taskOutputDir)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, "TASK_OUTPUT_DIR="+outputDir)
+	}
+	for k, v := range script.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, x.expand(v)))
+	}
+	return cmd, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// agentStalledError is the cause of stopping an agent that produced no output for the stall timeout.
+type agentStalledError struct {
+	silence time.Duration
+}
+
+func (e *agentStalledError) Error() string {
+	return fmt.Sprintf("agent stalled: no output for %v", e.silence.Round(time.Second))
+}
+
+// stallWatchdog tracks the agent's activity, and stops an agent that has been silent for too long.
+// The agent is not expected to produce output while it is paused, e.g. during command steps.
+type stallWatchdog struct {
+	timeout time.Duration
+
+	mutex  sync.Mutex
+	last   time.Time
+	paused int
+}
+
+func newStallWatchdog(timeout time.Duration) *stallWatchdog {
+	return &stallWatchdog{timeout: timeout, last: time.Now()}
+}
+
+// touch records activity of the agent. A nil watchdog does nothing.
+func (w *stallWatchdog) touch() {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.last = time.Now()
+}
+
+// pause suspends the watchdog until the returned function is called. A nil watchdog does nothing.
+func (w *stallWatchdog) pause() func() {
+	if w == nil {
+		return func() {}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.paused++
+	return func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		w.paused--
+		w.last = time.Now()
+	}
+}
+
+// writer returns a writer that records activity on each write to out.
+func (w *stallWatchdog) writer(out io.Writer) io.Writer {
+	return &activityWriter{out: out, watchdog: w}
+}
+
+type activityWriter struct {
+	out      io.Writer
+	watchdog *stallWatchdog
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.watchdog.touch()
+	return a.out.Write(p)
+}
+
+// watch cancels the agent's context with an agentStalledError once the agent has been silent
+// for the timeout, until ctx is done.
+func (w *stallWatchdog) watch(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(max(min(w.timeout/10, time.Second), time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.mutex.Lock()
+		silence := time.Since(w.last)
+		stalled := w.paused == 0 && silence >= w.timeout
+		w.mutex.Unlock()
+		if stalled {
+			cancel(&agentStalledError{silence: silence})
+			return
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// defaultStepWaitTimeout bounds how long a script step waits for its waitFor pattern.
+const defaultStepWaitTimeout = 5 * time.Minute
+
+// errOutputClosed is returned when waiting for a pattern in output that has ended.
+var errOutputClosed = errors.New("agent output ended")
+
+// sendSteps sends the prompts of the task's script steps to the agent, then closes prompts.
+// A step with a waitFor pattern (or the LLM config's idle marker, or the --step-ready-pattern default)
+// is only sent once the pattern appears in the agent output produced since the previous step was sent;
+// other steps wait for --step-idle-time of silence, if set.
+// Command steps are run by the framework, in order with the prompts.
+// It returns the offsets in the agent output at which each sent step's output starts.
+// Failures are recorded on the task result.
+// Sending stops when agentDone is closed, because the agent's output has ended.
+func (x *TaskExecution) sendSteps(ctx context.Context, prompts chan<- string, agentDone <-chan struct{}, output *lockedBuffer) []int {
+	defer close(prompts)
+
+	var stepStarts []int
+	from := 0
+	prompted := false
+	// Prompts are rendered with variables known once the setup ran (see promptVars).
+	var vars map[string]string
+	for i, step := range x.task.Script {
+		if step.Command != "" {
+			// Commands run in order with the prompts, so they wait for any pattern first.
+			if !x.waitForStep(ctx, i, step, output, from, prompted) {
+				return stepStarts
+			}
+			// The agent waits for input while the command runs, which is not a stall.
+			resume := x.watchdog.pause()
+			ok := x.runCommandStep(ctx, i, step)
+			resume()
+			if !ok {
+				return stepStarts
+			}
+			// The agent was not sent anything, so the next step keeps waiting from the previous prompt.
+			stepStarts = append(stepStarts, output.Len())
+			continue
+		}
+
+		prompt, err := step.ResolvePrompt(x.taskDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving prompt: %v\n", err)
+			x.result.AddFailure("failed to resolve prompt: %v", err)
+			return stepStarts
+		}
+		if vars == nil {
+			vars, err = x.promptVars()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading prompt variables: %v\n", err)
+				x.result.AddFailure("failed to read prompt variables: %v", err)
+				return stepStarts
+			}
+		}
+		// An unknown variable fails the task, with its name in the error.
+		prompt, err = renderTemplate(fmt.Sprintf("%s step %d", x.taskID, i+1), prompt, vars)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering prompt: %v\n", err)
+			x.result.AddFailure("failed to render prompt: %v", err)
+			return stepStarts
+		}
+
+		if !x.waitForStep(ctx, i, step, output, from, prompted) {
+			return stepStarts
+		}
+
+		// The prompt is received once the agent runner is ready to pass it on,
+		// so the step's output starts after that (approximately, without a waitFor pattern).
+		select {
+		case prompts <- prompt:
+			x.watchdog.touch()
+		case <-agentDone:
+			return stepStarts
+		case <-ctx.Done():
+			return stepStarts
+		}
+		prompted = true
+		from = output.Len()
+		stepStarts = append(stepStarts, from)
+		x.logStep("Step %d: sent prompt at %s\n", i+1, time.Now().Format(time.RFC3339Nano))
+		// The prompt as sent is logged, so the run can be reproduced.
+		if x.log != nil {
+			fmt.Fprintf(x.log, "--- prompt of step %d ---\n%s\n--- end of prompt ---\n", i+1, prompt)
+		}
+	}
+	return stepStarts
+}
+
+// waitForStep waits until the step can be sent: for the step's waitFor pattern in the agent output
+// from offset from, or until the agent is idle, as shown by the LLM config's idle marker or by
+// --step-idle-time of silence (after some output, once a prompt was sent).
+// It returns false if the step cannot be sent. A missing waitFor pattern is recorded as a failure
+// on the task result; an agent that was never idle is recorded in x.idleErr.
+func (x *TaskExecution) waitForStep(ctx context.Context, i int, step ScriptStep, output *lockedBuffer, from int, prompted bool) bool {
+	pattern := step.WaitFor
+	idle := false
+	if pattern == "" && prompted && x.llmConfig.IdleMarker != "" {
+		pattern = x.llmConfig.IdleMarker
+		idle = true
+	}
+	if pattern == "" {
+		pattern = x.stepReadyPattern
+	}
+	if pattern == "" && x.stepIdleTime == 0 {
+		return true
+	}
+	// Patterns and timeouts are validated when the tasks are loaded.
+	timeout := defaultStepWaitTimeout
+	if step.WaitTimeout != "" {
+		timeout, _ = time.ParseDuration(step.WaitTimeout)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	if pattern != "" {
+		err = output.waitFor(waitCtx, regexp.MustCompile(pattern), from)
+	} else {
+		idle = true
+		err = output.waitForSilence(waitCtx, x.stepIdleTime, from, prompted)
+	}
+	if err != nil {
+		timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+		if timedOut {
+			err = fmt.Errorf("timed out after %v", timeout)
+		}
+		switch {
+		case idle && timedOut:
+			x.idleErr = fmt.Errorf("step %d: agent was not idle within %v, the step was not sent", i+1, timeout)
+			x.logStep("Step %d: agent not idle at %s\n", i+1, time.Now().Format(time.RFC3339Nano))
+		case idle:
+			x.result.AddFailure("step %d: waiting for the agent to be idle: %v", i+1, err)
+			x.result.Failures[len(x.result.Failures)-1].Step = i + 1
+		default:
+			x.result.AddFailure("step %d: waiting for %q in agent output: %v", i+1, pattern, err)
+			x.result.Failures[len(x.result.Failures)-1].Step = i + 1
+		}
+		return false
+	}
+	if pattern != "" {
+		x.logStep("Step %d: observed %q at %s\n", i+1, pattern, time.Now().Format(time.RFC3339Nano))
+	} else {
+		x.logStep("Step %d: agent idle for %v at %s\n", i+1, x.stepIdleTime, time.Now().Format(time.RFC3339Nano))
+	}
+	return true
+}
+
+// runCommandStep runs the command of a script step, with its output copied to the console and task log.
+// It returns false if the command failed and the step's onError is fail, recording the failure on the task result.
+func (x *TaskExecution) runCommandStep(ctx context.Context, i int, step ScriptStep) bool {
+	x.logStep("Step %d: running command at %s\n", i+1, time.Now().Format(time.RFC3339Nano))
+	cmd := exec.CommandContext(ctx, "sh", "-c", step.Command)
+	cmd.Dir = x.taskDir
+	cmd.Env = x.taskEnv()
+	_, err := x.runCommand(cmd)
+	if err == nil {
+		x.logStep("Step %d: command finished at %s\n", i+1, time.Now().Format(time.RFC3339Nano))
+		return true
+	}
+	if step.OnError == CommandStepOnErrorContinue {
+		x.logStep("Step %d: ignoring failed command: %v\n", i+1, err)
+		return true
+	}
+	x.result.AddFailure("step %d: command failed: %v", i+1, err)
+	x.result.Failures[len(x.result.Failures)-1].Step = i + 1
+	return false
+}
+
+// logStep writes a message about the script steps to the console and the task log.
+func (x *TaskExecution) logStep(format string, args ...any) {
+	x.progressf(format, args...)
+	if x.log != nil {
+		fmt.Fprintf(x.log, format, args...)
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use,
+// and can be waited on for a pattern to be written.
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	closed bool
+	// lastWrite is the time of the last write, or when the buffer was created.
+	lastWrite time.Time
+	// changed is signalled after each write, and when the buffer is closed.
+	changed chan struct{}
+}
+
+func newLockedBuffer() *lockedBuffer {
+	return &lockedBuffer{changed: make(chan struct{}, 1), lastWrite: time.Now()}
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	n, err := b.buffer.Write(p)
+	b.lastWrite = time.Now()
+	b.notify()
+	return n, err
+}
+
+// close marks the end of the output, so waitFor stops waiting.
+func (b *lockedBuffer) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	b.notify()
+}
+
+func (b *lockedBuffer) notify() {
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Len()
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// waitFor waits until re matches the buffer contents from offset from.
+func (b *lockedBuffer) waitFor(ctx context.Context, re *regexp.Regexp, from int) error {
+	for {
+		b.mutex.Lock()
+		matched := re.Match(b.buffer.Bytes()[from:])
+		closed := b.closed
+		b.mutex.Unlock()
+		if matched {
+			return nil
+		}
+		if closed {
+			return errOutputClosed
+		}
+		select {
+		case <-b.changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitForSilence waits until nothing has been written to the buffer for d.
+// With needOutput, something must also have been written from offset from.
+func (b *lockedBuffer) waitForSilence(ctx context.Context, d time.Duration, from int, needOutput bool) error {
+	for {
+		b.mutex.Lock()
+		written := b.buffer.Len() > from
+		silent := time.Since(b.lastWrite)
+		closed := b.closed
+		b.mutex.Unlock()
+		if closed {
+			return errOutputClosed
+		}
+		wait := d
+		if written || !needOutput {
+			if silent >= d {
+				return nil
+			}
+			wait = d - silent
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-b.changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// suitesFile is the name of the file in the tasks directory that defines suites.
+const suitesFile = "suites.yaml"
+
+// Suite is a named set of tasks, defined in suites.yaml in the tasks directory:
+//
+//	smoke:
+//	  tasks: [fix-crashloop-pod, "scale-*"]
+//	networking:
+//	  tasks: ["*-network-policy"]
+//	  suites: [smoke]
+type Suite struct {
+	// Tasks are task IDs or glob patterns matching task IDs.
+	Tasks []string `json:"tasks,omitempty"`
+	// Suites are other suites whose tasks are included in this suite.
+	Suites []string `json:"suites,omitempty"`
+}
+
+// loadSuites reads the suites defined in the tasks directories. At least one of them must
+// define suites, and suite names must be unique across them.
+func loadSuites(tasksDirs []string) (map[string]Suite, error) {
+	suites := make(map[string]Suite)
+	defined := make(map[string]string)
+	var missing error
+	for _, tasksDir := range tasksDirs {
+		suitesPath := filepath.Join(tasksDir, suitesFile)
+		data, err := os.ReadFile(suitesPath)
+		if errors.Is(err, os.ErrNotExist) && len(tasksDirs) > 1 {
+			missing = err
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading suites file: %w", err)
+		}
+		var dirSuites map[string]Suite
+		if err := yaml.Unmarshal(data, &dirSuites); err != nil {
+			return nil, fmt.Errorf("parsing suites file %s: %w", suitesPath, err)
+		}
+		for name, suite := range dirSuites {
+			if other, ok := defined[name]; ok {
+				return nil, fmt.Errorf("suite %q is defined in both %s and %s", name, other, suitesPath)
+			}
+			defined[name] = suitesPath
+			suites[name] = suite
+		}
+	}
+	if len(defined) == 0 && missing != nil {
+		return nil, fmt.Errorf("reading suites file: %w", missing)
+	}
+	return suites, nil
+}
+
+// resolveSuite returns the task patterns of the named suite, including those of nested suites.
+// Literal task IDs must exist in taskIDs.
+func resolveSuite(suites map[string]Suite, name string, taskIDs map[string]bool) ([]string, error) {
+	var patterns []string
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var visit func(name string, stack []string) error
+	visit = func(name string, stack []string) error {
+		stack = append(stack, name)
+		if visiting[name] {
+			return fmt.Errorf("suite cycle detected: %s", strings.Join(stack, " -> "))
+		}
+		if visited[name] {
+			return nil
+		}
+		suite, ok := suites[name]
+		if !ok {
+			return fmt.Errorf("suite %q not found in %s", name, suitesFile)
+		}
+		visiting[name] = true
+		for _, pattern := range suite.Tasks {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("suite %q: invalid task pattern %q: %w", name, pattern, err)
+			}
+			isGlob := strings.ContainsAny(pattern, "*?[")
+			if !isGlob && !taskIDs[pattern] {
+				return fmt.Errorf("suite %q references task %q, which does not exist", name, pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		for _, nested := range suite.Suites {
+			if err := visit(nested, stack); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		return nil
+	}
+
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// matchesAnyPattern reports whether taskID matches any of the glob patterns.
+func matchesAnyPattern(taskID string, patterns []string) bool {
+	for _, pattern := range patterns {
+		// Patterns were validated when the suite was resolved.
+		if ok, _ := path.Match(pattern, taskID); ok {
+			return true
+		}
+	}
+	return false
+}
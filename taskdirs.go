@@ -0,0 +1,87 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// taskFile is the file that makes a directory a task.
+const taskFile = "task.yaml"
+
+// taskDir is a directory containing a task file, found in a tasks directory.
+type taskDir struct {
+	// root is the tasks directory it was found in.
+	root string
+	// id is its path relative to root with forward slashes (e.g. networking/dns-failure),
+	// which is the ID of its task, or the prefix of the IDs of its matrix instances.
+	id string
+}
+
+// path returns the directory of the task file.
+func (d taskDir) path() string {
+	return filepath.Join(d.root, filepath.FromSlash(d.id))
+}
+
+// findTaskDirs returns the directories containing a task file in the tasks directories, at any
+// depth, sorted by ID. The subdirectories of a task, the fixtures directory of each tasks directory
+// and hidden directories are not searched. IDs must be unique across the tasks directories.
+func findTaskDirs(roots []string) ([]taskDir, error) {
+	var dirs []taskDir
+	found := make(map[string]string)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() || path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			id := filepath.ToSlash(rel)
+			if id == fixturesDir || strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, taskFile)); err != nil {
+				return nil
+			}
+			if other, ok := found[id]; ok {
+				return fmt.Errorf("task %s is in both %s and %s", id, other, root)
+			}
+			found[id] = root
+			dirs = append(dirs, taskDir{root: root, id: id})
+			// Directories of a task hold its files, not other tasks.
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return nil, fmt.Errorf("finding tasks in %s: %w", root, err)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].id < dirs[j].id })
+	return dirs, nil
+}
+
+// tasksDirs returns the tasks directories of the run: TasksDir, then TasksDirs.
+func (c EvalConfig) tasksDirs() []string {
+	return append([]string{c.TasksDir}, c.TasksDirs...)
+}
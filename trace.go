@@ -0,0 +1,302 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+	"sigs.k8s.io/yaml"
+)
+
+// traceEvent is an event in the trace written by the agent with --trace-path:
+// a stream of YAML documents, each with a timestamp, an action and a payload.
+type traceEvent struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Payload   any    `json:"payload,omitempty"`
+}
+
+// traceDocumentSeparator matches the separators between the YAML documents of a trace.
+var traceDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// readTrace reads the events of the trace at path.
+func readTrace(path string) ([]traceEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var events []traceEvent
+	for i, document := range traceDocumentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+		var event traceEvent
+		if err := yaml.Unmarshal([]byte(document), &event); err != nil {
+			return nil, fmt.Errorf("parsing trace document %d: %w", i+1, err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Token count fields, as reported in the usage metadata of the supported LLM providers.
+var (
+	promptTokenKeys     = []string{"promptTokenCount", "prompt_tokens", "promptTokens", "input_tokens", "inputTokens"}
+	completionTokenKeys = []string{"candidatesTokenCount", "completion_tokens", "completionTokens", "output_tokens", "outputTokens"}
+)
+
+// traceUsage sums the token usage reported by the LLM calls in the trace.
+// Each object with token counts in an event payload is counted as an LLM call.
+// It returns nil if the trace reports no usage.
+func traceUsage(events []traceEvent) *model.Usage {
+	usage := &model.Usage{}
+	for _, event := range events {
+		findUsage(event.Payload, usage)
+	}
+	if usage.LLMCalls == 0 {
+		return nil
+	}
+	return usage
+}
+
+func findUsage(value any, usage *model.Usage) {
+	switch value := value.(type) {
+	case map[string]any:
+		prompt, hasPrompt := intField(value, promptTokenKeys)
+		completion, hasCompletion := intField(value, completionTokenKeys)
+		if hasPrompt || hasCompletion {
+			usage.LLMCalls++
+			usage.PromptTokens += prompt
+			usage.CompletionTokens += completion
+			return
+		}
+		for _, v := range value {
+			findUsage(v, usage)
+		}
+	case []any:
+		for _, v := range value {
+			findUsage(v, usage)
+		}
+	}
+}
+
+// intField returns the value of the first of keys that is a number in m.
+func intField(m map[string]any, keys []string) (int, bool) {
+	for _, key := range keys {
+		if n, ok := m[key].(float64); ok {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// analyzeTrace reads the token usage and tool call metrics from the agent's trace into the result,
+// and estimates the cost of the usage. A missing or malformed trace leaves them unknown,
+// it does not fail the task.
+func (x *TaskExecution) analyzeTrace(tracePath string) {
+	events, err := readTrace(tracePath)
+	if err != nil {
+		slog.Warn("usage and metrics of task are unknown", "task", x.taskID, "error", err)
+		return
+	}
+	x.trace = events
+	x.result.Metrics = traceMetrics(events)
+	usage := traceUsage(events)
+	if usage == nil {
+		return
+	}
+	price, ok := x.modelPrices[x.llmConfig.ModelID]
+	if !ok {
+		price, ok = x.modelPrices[x.llmConfig.ID]
+	}
+	if ok {
+		cost := (float64(usage.PromptTokens)*price.InputPerMillion + float64(usage.CompletionTokens)*price.OutputPerMillion) / 1e6
+		usage.Cost = &cost
+	}
+	x.result.Usage = usage
+}
+
+// ModelPrice is the price of a model in USD per million tokens, used to estimate the cost of tasks.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+}
+
+// loadModelPrices reads a price table from a YAML file, mapping model (or LLM config) IDs to prices.
+func loadModelPrices(path string) (map[string]ModelPrice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var prices map[string]ModelPrice
+	if err := yaml.UnmarshalStrict(data, &prices); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// isToolRequest and isToolResponse match the actions of the trace events of tool calls.
+var (
+	isToolRequest  = regexp.MustCompile(`(?i)tool[-_.]?(request|call)`)
+	isToolResponse = regexp.MustCompile(`(?i)tool[-_.]?(response|result)`)
+	isLLMRequest   = regexp.MustCompile(`(?i)llm[-_.]?(request|chat)`)
+)
+
+// isFinalMessage matches the actions of trace events carrying messages from the model.
+var isFinalMessage = regexp.MustCompile(`(?i)(llm|model|assistant|agent)[-_.]?(response|message)`)
+
+// traceOutputs returns the output of the last tool call in the trace, and the last message from
+// the model after it (or the tool output if there is none). It returns false if there are no tool calls.
+func traceOutputs(events []traceEvent) (lastToolOutput, finalMessage string, ok bool) {
+	for _, event := range events {
+		payload, _ := event.Payload.(map[string]any)
+		switch {
+		case isToolResponse.MatchString(event.Action):
+			lastToolOutput = payloadText(payload, "response", "result", "output")
+			finalMessage = ""
+			ok = true
+		case isFinalMessage.MatchString(event.Action):
+			if text := payloadText(payload, "text", "content", "message"); text != "" {
+				finalMessage = text
+			}
+		}
+	}
+	if finalMessage == "" {
+		finalMessage = lastToolOutput
+	}
+	return lastToolOutput, finalMessage, ok
+}
+
+// payloadText returns the first of keys in the payload as text: strings as-is, and
+// structured values (e.g. a tool result with stdout and stderr) as their stdout or YAML.
+func payloadText(payload map[string]any, keys ...string) string {
+	for _, key := range keys {
+		switch value := payload[key].(type) {
+		case nil:
+			continue
+		case string:
+			return value
+		case map[string]any:
+			if stdout, ok := value["stdout"].(string); ok {
+				return stdout
+			}
+			data, _ := yaml.Marshal(value)
+			return string(data)
+		default:
+			data, _ := yaml.Marshal(value)
+			return string(data)
+		}
+	}
+	return ""
+}
+
+// kubectlMutatingVerbs and kubectlReadVerbs classify kubectl commands by their verb.
+// Verbs in neither set are not counted as mutations or reads.
+var (
+	kubectlMutatingVerbs = map[string]bool{
+		"apply": true, "create": true, "delete": true, "patch": true, "replace": true, "edit": true,
+		"scale": true, "set": true, "label": true, "annotate": true, "expose": true, "run": true,
+		"cordon": true, "uncordon": true, "drain": true, "taint": true, "autoscale": true, "rollout": true,
+	}
+	kubectlReadVerbs = map[string]bool{
+		"get": true, "describe": true, "logs": true, "top": true, "explain": true, "events": true,
+		"api-resources": true, "api-versions": true, "version": true, "cluster-info": true, "auth": true,
+		"diff": true, "wait": true,
+	}
+	// kubectlReadRolloutCommands are the rollout subcommands that do not change anything.
+	kubectlReadRolloutCommands = map[string]bool{"status": true, "history": true}
+	// kubectlFlagsWithValues are the global flags whose value is a separate argument.
+	kubectlFlagsWithValues = map[string]bool{
+		"-n": true, "--namespace": true, "--context": true, "--kubeconfig": true, "--cluster": true, "--user": true, "-s": true, "--server": true,
+	}
+)
+
+// traceMetrics counts the tool calls and LLM turns in the trace.
+func traceMetrics(events []traceEvent) *model.Metrics {
+	metrics := &model.Metrics{ToolCallsByName: make(map[string]int)}
+	for _, event := range events {
+		payload, _ := event.Payload.(map[string]any)
+		switch {
+		case isToolRequest.MatchString(event.Action):
+			name, _ := payload["name"].(string)
+			if name == "" {
+				name = "unknown"
+			}
+			metrics.ToolCalls++
+			metrics.ToolCallsByName[name]++
+			if arguments, ok := payload["arguments"].(map[string]any); ok {
+				if command, ok := arguments["command"].(string); ok {
+					mutations, reads := classifyKubectlCommands(command)
+					metrics.KubectlMutations += mutations
+					metrics.KubectlReads += reads
+				}
+			}
+		case isToolResponse.MatchString(event.Action):
+			if e, ok := payload["error"]; ok && e != nil && e != "" {
+				metrics.ToolErrors = true
+			}
+		case isLLMRequest.MatchString(event.Action):
+			metrics.LLMTurns++
+		}
+	}
+	// Traces without LLM request events count a turn per LLM call that reported usage.
+	if metrics.LLMTurns == 0 {
+		if usage := traceUsage(events); usage != nil {
+			metrics.LLMTurns = usage.LLMCalls
+		}
+	}
+	return metrics
+}
+
+// classifyKubectlCommands counts the mutating and read-only kubectl invocations in a shell command.
+func classifyKubectlCommands(command string) (mutations, reads int) {
+	fields := strings.FieldsFunc(command, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == ';' || r == '|' || r == '&' || r == '(' || r == ')'
+	})
+	for i, field := range fields {
+		if field != "kubectl" && !strings.HasSuffix(field, "/kubectl") {
+			continue
+		}
+		var args []string
+		for j := i + 1; j < len(fields) && fields[j] != "kubectl"; j++ {
+			if kubectlFlagsWithValues[fields[j]] {
+				j++
+				continue
+			}
+			if strings.HasPrefix(fields[j], "-") {
+				continue
+			}
+			args = append(args, fields[j])
+		}
+		if len(args) == 0 {
+			continue
+		}
+		verb := args[0]
+		switch {
+		case verb == "rollout" && len(args) > 1 && kubectlReadRolloutCommands[args[1]]:
+			reads++
+		case kubectlMutatingVerbs[verb]:
+			mutations++
+		case kubectlReadVerbs[verb]:
+			reads++
+		}
+	}
+	return mutations, reads
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// defaultTransientPatterns match agent failures caused by the LLM provider or the network,
+// rather than by the agent: rate limits, exhausted quotas, unavailable services and reset connections.
+var defaultTransientPatterns = []string{
+	`(?i)\b(error|status|code)[: ]+(429|50[234])\b`,
+	`Too Many Requests`,
+	`RESOURCE_EXHAUSTED`,
+	`Service Unavailable`,
+	`(?i)rate limit exceeded`,
+	`connection reset by peer`,
+}
+
+// maxTransientScanBytes bounds how much of the end of the agent's stderr and trace is scanned.
+const maxTransientScanBytes = 64 * 1024
+
+// maxTransientBackoff caps the wait between transient retries.
+const maxTransientBackoff = 5 * time.Minute
+
+// compileTransientPatterns compiles the --transient-patterns.
+func compileTransientPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --transient-patterns %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// classifyTransient returns the first of the patterns that matches the error of the agent,
+// the end of its stderr, or the end of its trace file, or "" if the failure is not transient.
+func classifyTransient(patterns []*regexp.Regexp, agentErr error, stderr string, tracePath string) string {
+	texts := []string{agentErr.Error(), stderr}
+	if trace := readTail(tracePath, maxTransientScanBytes); trace != "" {
+		texts = append(texts, trace)
+	}
+	for _, re := range patterns {
+		for _, text := range texts {
+			if re.MatchString(text) {
+				return re.String()
+			}
+		}
+	}
+	return ""
+}
+
+// readTail returns up to the last n bytes of the file, or "" if it cannot be read.
+func readTail(path string, n int64) string {
+	if path == "" {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	if info.Size() > n {
+		if _, err := f.Seek(-n, io.SeekEnd); err != nil {
+			return ""
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, n))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// transientBackoff returns the wait before transient retry n (from 1): the base, doubled for each retry.
+func transientBackoff(base time.Duration, n int) time.Duration {
+	backoff := base
+	for i := 1; i < n && backoff < maxTransientBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxTransientBackoff)
+}
@@ -0,0 +1,317 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// uploadManifestFile lists the uploaded objects; it is written to the output directory and uploaded last.
+	uploadManifestFile = "upload-manifest.json"
+	// uploadAttempts is how many times a file is uploaded before giving up on transient errors.
+	uploadAttempts = 4
+	// uploadTimeout bounds the upload of each file.
+	uploadTimeout = 10 * time.Minute
+)
+
+// objectUploader uploads local files to an object store.
+type objectUploader interface {
+	// upload streams the file at localPath to the object, returning a *transientUploadError
+	// if the upload may succeed when retried.
+	upload(ctx context.Context, localPath, object string) error
+	// url returns the URL of the object, e.g. gs://bucket/object.
+	url(object string) string
+}
+
+// transientUploadError is an upload error that may not recur, like a network error or a 5xx response.
+type transientUploadError struct {
+	err error
+}
+
+func (e *transientUploadError) Error() string { return e.err.Error() }
+func (e *transientUploadError) Unwrap() error { return e.err }
+
+// uploadDestination is a parsed --upload-to URL: gs://bucket/prefix or s3://bucket/prefix.
+type uploadDestination struct {
+	scheme string
+	bucket string
+	prefix string
+}
+
+func parseUploadDestination(dest string) (uploadDestination, error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return uploadDestination{}, err
+	}
+	if u.Scheme != "gs" && u.Scheme != "s3" {
+		return uploadDestination{}, fmt.Errorf("%q must be a gs:// or s3:// URL", dest)
+	}
+	if u.Host == "" {
+		return uploadDestination{}, fmt.Errorf("%q has no bucket", dest)
+	}
+	return uploadDestination{scheme: u.Scheme, bucket: u.Host, prefix: strings.Trim(u.Path, "/")}, nil
+}
+
+// object returns the name of the object for a path relative to the uploaded directory.
+func (d uploadDestination) object(rel string) string {
+	return path.Join(d.prefix, filepath.ToSlash(rel))
+}
+
+func newObjectUploader(ctx context.Context, dest uploadDestination) (objectUploader, error) {
+	switch dest.scheme {
+	case "gs":
+		return newGCSUploader(ctx, dest.bucket)
+	default:
+		return &s3Uploader{bucket: dest.bucket}, nil
+	}
+}
+
+// gcsUploader uploads to Google Cloud Storage with the JSON API, authenticated with
+// Application Default Credentials (through gcloud, which implements the ADC chain).
+type gcsUploader struct {
+	bucket string
+	token  string
+}
+
+func newGCSUploader(ctx context.Context, bucket string) (*gcsUploader, error) {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "gcloud", "auth", "application-default", "print-access-token")
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("getting application default credentials: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		token = strings.TrimSpace(string(output))
+	}
+	return &gcsUploader{bucket: bucket, token: token}, nil
+}
+
+func (u *gcsUploader) url(object string) string {
+	return "gs://" + u.bucket + "/" + object
+}
+
+func (u *gcsUploader) upload(ctx context.Context, localPath, object string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		url.PathEscape(u.bucket), url.QueryEscape(object))
+	// The file is streamed as the request body, rather than read into memory.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Authorization", "Bearer "+u.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &transientUploadError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return &transientUploadError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// s3Uploader uploads to Amazon S3 with the aws CLI, which uses the standard AWS credential chain
+// and streams the file.
+type s3Uploader struct {
+	bucket string
+}
+
+func (u *s3Uploader) url(object string) string {
+	return "s3://" + u.bucket + "/" + object
+}
+
+func (u *s3Uploader) upload(ctx context.Context, localPath, object string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "aws", "s3", "cp", "--only-show-errors", localPath, u.url(object))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("aws s3 cp: %w: %s", err, strings.TrimSpace(stderr.String()))
+		// The CLI does not tell transient errors apart, unless it could not be run at all.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &transientUploadError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadManifest records the outcome of uploading the output directory.
+type uploadManifest struct {
+	Destination string           `json:"destination"`
+	Uploaded    []uploadedObject `json:"uploaded"`
+	Failed      []failedUpload   `json:"failed,omitempty"`
+}
+
+type uploadedObject struct {
+	// Path is relative to the output directory.
+	Path   string `json:"path"`
+	Object string `json:"object"`
+	Size   int64  `json:"size"`
+}
+
+type failedUpload struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// uploadOutputs uploads the files in config.OutputDir to <config.UploadTo>/<run ID>/, keeping
+// their relative paths, config.UploadConcurrency at a time. Transient errors are retried.
+// The manifest of uploaded objects is then written to the output directory and uploaded.
+// It returns an error if any file could not be uploaded.
+func uploadOutputs(ctx context.Context, config EvalConfig) error {
+	dest, err := parseUploadDestination(config.UploadTo)
+	if err != nil {
+		return fmt.Errorf("invalid --upload-to: %w", err)
+	}
+	dest.prefix = path.Join(dest.prefix, config.RunID)
+	uploader, err := newObjectUploader(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		rel  string
+		size int64
+	}
+	var files []file
+	err = filepath.WalkDir(config.OutputDir, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(config.OutputDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == uploadManifestFile {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{rel: rel, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing %s: %w", config.OutputDir, err)
+	}
+
+	slog.Info("Uploading outputs", "files", len(files), "from", config.OutputDir, "to", uploader.url(dest.prefix))
+	manifest := uploadManifest{Destination: uploader.url(dest.prefix), Uploaded: []uploadedObject{}}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(config.UploadConcurrency, 1))
+	for _, f := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			object := dest.object(f.rel)
+			err := uploadWithRetries(ctx, uploader, filepath.Join(config.OutputDir, f.rel), object)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				manifest.Failed = append(manifest.Failed, failedUpload{Path: filepath.ToSlash(f.rel), Error: err.Error()})
+				return
+			}
+			manifest.Uploaded = append(manifest.Uploaded, uploadedObject{Path: filepath.ToSlash(f.rel), Object: uploader.url(object), Size: f.size})
+		}()
+	}
+	wg.Wait()
+	sort.Slice(manifest.Uploaded, func(i, j int) bool { return manifest.Uploaded[i].Path < manifest.Uploaded[j].Path })
+	sort.Slice(manifest.Failed, func(i, j int) bool { return manifest.Failed[i].Path < manifest.Failed[j].Path })
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling upload manifest: %w", err)
+	}
+	manifestPath := filepath.Join(config.OutputDir, uploadManifestFile)
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("writing to file %q: %w", manifestPath, err)
+	}
+	var errs []error
+	if err := uploadWithRetries(ctx, uploader, manifestPath, dest.object(uploadManifestFile)); err != nil {
+		errs = append(errs, fmt.Errorf("uploading %s: %w", uploadManifestFile, err))
+	}
+	if len(manifest.Failed) > 0 {
+		errs = append(errs, fmt.Errorf("failed to upload %d of %d files (see %s), first: %s: %s",
+			len(manifest.Failed), len(files), manifestPath, manifest.Failed[0].Path, manifest.Failed[0].Error))
+	}
+	if len(errs) == 0 {
+		slog.Info("Uploaded outputs", "files", len(files), "to", manifest.Destination)
+	}
+	return errors.Join(errs...)
+}
+
+// uploadWithRetries uploads the file, retrying transient errors with exponential backoff.
+func uploadWithRetries(ctx context.Context, uploader objectUploader, localPath, object string) error {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		uploadCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+		err := uploader.upload(uploadCtx, localPath, object)
+		cancel()
+		var transient *transientUploadError
+		if err == nil || !errors.As(err, &transient) || attempt == uploadAttempts {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
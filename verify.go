@@ -0,0 +1,263 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gke-labs/k8s-ai-bench/pkg/model"
+)
+
+const defaultVerifyRetryInterval = 5 * time.Second
+
+// verifyAfterTimeoutLimit bounds the verification of a task whose agent hit the task timeout,
+// including verifyRetry attempts.
+const verifyAfterTimeoutLimit = 2 * time.Minute
+
+// partialCreditExitCode is the verifier exit code for partial credit.
+// The score is read from a SCORE=<0..1> line on the verifier's stdout.
+const partialCreditExitCode = 3
+
+// VerifyRetry configures re-running verification until it passes or the timeout elapses.
+type VerifyRetry struct {
+	// Interval between attempts, defaults to 5s.
+	Interval string `json:"interval,omitempty"`
+	// Timeout is how long to keep retrying, bounded by the task timeout.
+	Timeout string `json:"timeout"`
+}
+
+// durations parses the interval and timeout.
+func (r *VerifyRetry) durations() (time.Duration, time.Duration, error) {
+	interval := defaultVerifyRetryInterval
+	if r.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(r.Interval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing verifyRetry interval: %w", err)
+		}
+	}
+	timeout, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing verifyRetry timeout: %w", err)
+	}
+	return interval, timeout, nil
+}
+
+// verification is the outcome of running the checks and verifiers once.
+type verification struct {
+	checkFailures     []model.Failure
+	verifierFailures  []model.Failure
+	verifiers         []model.VerifierResult
+	verifierSucceeded bool
+	// verifierScore is the mean verifier score (the best score with the "any" policy).
+	verifierScore float64
+}
+
+func (v *verification) passed(hasVerifiers bool) bool {
+	return len(v.checkFailures) == 0 && (!hasVerifiers || v.verifierSucceeded)
+}
+
+// verify runs the checks and verifiers, retrying according to the task's verifyRetry setting.
+// It returns the outcome of the last attempt and the number of attempts.
+func (x *TaskExecution) verify(ctx context.Context) (*verification, int) {
+	var interval, timeout time.Duration
+	if x.task.VerifyRetry != nil {
+		// Validated when the task was loaded.
+		interval, timeout, _ = x.task.VerifyRetry.durations()
+	}
+	hasVerifiers := len(x.task.verifierScripts()) > 0
+	deadline := time.Now().Add(timeout)
+
+	for attempt := 1; ; attempt++ {
+		v := x.verifyOnce(ctx)
+		if v.passed(hasVerifiers) || time.Now().Add(interval).After(deadline) {
+			return v, attempt
+		}
+		x.progressf("\nVerification failed for task %s, retrying in %v (attempt %d)\n", x.taskID, interval, attempt)
+		select {
+		case <-ctx.Done():
+			return v, attempt
+		case <-time.After(interval):
+		}
+	}
+}
+
+// maxVerifierOutputLines is how many lines of the end of the stdout and stderr of a verifier
+// that did not pass are kept in its result.
+const maxVerifierOutputLines = 50
+
+// verifierLogFile is the file in the task output directory that receives the full output of the verifiers.
+const verifierLogFile = "verifier.log"
+
+// openVerifierLog opens verifier.log for appending, with secrets masked. Without a task output
+// directory, or if it cannot be opened, the output is discarded. The returned function closes it.
+func (x *TaskExecution) openVerifierLog() (io.Writer, func()) {
+	if x.taskOutputDir == "" {
+		return io.Discard, func() {}
+	}
+	f, err := os.OpenFile(filepath.Join(x.taskOutputDir, verifierLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Warn("failed to open the verifier log", "task", x.taskID, "error", err)
+		return io.Discard, func() {}
+	}
+	w := newRedactingWriter(f, x.redactor)
+	return w, func() {
+		w.Flush()
+		f.Close()
+	}
+}
+
+// verifiesAfterTimeout reports whether the task is verified when its agent times out.
+func (t *Task) verifiesAfterTimeout() bool {
+	return t.VerifyAfterTimeout == nil || *t.VerifyAfterTimeout
+}
+
+// verifyAfterTimeout runs the checks and verifiers of a task whose agent timed out, under ctx,
+// which must not be the expired task context. It reports whether they passed, and records
+// them in the result like verification after a finished agent does.
+func (x *TaskExecution) verifyAfterTimeout(ctx context.Context) bool {
+	hasVerifiers := len(x.task.verifierScripts()) > 0
+	if !hasVerifiers && len(x.task.Checks) == 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyAfterTimeoutLimit)
+	defer cancel()
+	x.progressf("\nAgent timed out, verifying the cluster state of task %s\n", x.taskID)
+	start := time.Now()
+	v, attempts := x.verify(ctx)
+	x.result.VerifyAttempts = attempts
+	x.result.VerifyDuration = time.Since(start).Round(time.Millisecond).String()
+	x.result.Verifiers = v.verifiers
+	// As after a finished agent, partial credit only comes from failed verifiers; a passing task scores 1.
+	if hasVerifiers && !v.verifierSucceeded {
+		x.result.Score = v.verifierScore
+	}
+	if v.passed(hasVerifiers) {
+		return true
+	}
+	x.result.Failures = append(x.result.Failures, v.verifierFailures...)
+	x.result.Failures = append(x.result.Failures, v.checkFailures...)
+	return false
+}
+
+// verifyOnce runs the checks and verifiers once.
+func (x *TaskExecution) verifyOnce(ctx context.Context) *verification {
+	v := &verification{}
+
+	if len(x.task.Checks) > 0 {
+		v.checkFailures = evaluateChecks(ctx, x.task.Checks, x.kubeConfig)
+		if len(v.checkFailures) == 0 {
+			x.progressf("\nAll cluster checks passed\n")
+		}
+	}
+
+	verifiers := x.task.verifierScripts()
+	if len(verifiers) == 0 {
+		return v
+	}
+	verifierLog, closeVerifierLog := x.openVerifierLog()
+	defer closeVerifierLog()
+	totalScore := 0.0
+	for _, verifier := range verifiers {
+		var stdout, stderr bytes.Buffer
+		cmd, err := x.scriptCommand(ctx, verifier)
+		if err != nil {
+			v.verifiers = append(v.verifiers, model.VerifierResult{Name: verifier.Script, Result: "fail"})
+			v.verifierFailures = append(v.verifierFailures, model.Failure{Message: fmt.Sprintf("verifier %s: %v", verifier.Script, err)})
+			continue
+		}
+		fmt.Fprintf(verifierLog, "=== %s: running verifier %s\n", time.Now().Format(time.RFC3339), verifier.Script)
+		cmd.Stdout = io.MultiWriter(&stdout, verifierLog)
+		cmd.Stderr = io.MultiWriter(&stderr, verifierLog)
+		x.progressf("\nRunning verifier %s for task %s\n", verifier.Script, x.taskID)
+
+		output, err := x.runCommand(cmd)
+		if err == nil {
+			v.verifiers = append(v.verifiers, model.VerifierResult{Name: verifier.Script, Result: "success", Score: 1})
+			totalScore++
+			v.verifierScore = max(v.verifierScore, 1)
+			continue
+		}
+
+		fmt.Fprintf(verifierLog, "=== verifier %s failed: %v\n", verifier.Script, err)
+		verifierResult := model.VerifierResult{Name: verifier.Script, Result: "fail"}
+		verifierResult.Stdout, _ = getLastNLines(stdout.String(), maxVerifierOutputLines)
+		verifierResult.Stderr, _ = getLastNLines(stderr.String(), maxVerifierOutputLines)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == partialCreditExitCode {
+			score, scoreErr := parseScore(stdout.String())
+			if scoreErr != nil {
+				err = fmt.Errorf("%w (partial credit: %v)", err, scoreErr)
+			} else {
+				verifierResult.Result = "partial"
+				verifierResult.Score = score
+			}
+		}
+		v.verifiers = append(v.verifiers, verifierResult)
+		totalScore += verifierResult.Score
+		v.verifierScore = max(v.verifierScore, verifierResult.Score)
+
+		const maxLogLines = 20
+		outputTail, truncated := getLastNLines(output, maxLogLines)
+		failureMessage := fmt.Sprintf("verifier %s failed: %v\n---OUTPUT---\n%s", verifier.Script, err, outputTail)
+		if truncated {
+			failureMessage += fmt.Sprintf("\n... (output truncated, full log at %s)", x.taskOutputDir)
+		}
+		v.verifierFailures = append(v.verifierFailures, model.Failure{Message: failureMessage})
+	}
+
+	passed := len(verifiers) - len(v.verifierFailures)
+	if x.task.VerifierPolicy == VerifierPolicyAny {
+		v.verifierSucceeded = passed > 0
+	} else {
+		v.verifierSucceeded = passed == len(verifiers)
+		v.verifierScore = totalScore / float64(len(verifiers))
+	}
+	return v
+}
+
+// parseScore returns the score from the last SCORE=<value> line of output.
+func parseScore(output string) (float64, error) {
+	var value string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if s, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "SCORE="); ok {
+			value = s
+		}
+	}
+	if value == "" {
+		return 0, fmt.Errorf("no SCORE= line in output")
+	}
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing score %q: %w", value, err)
+	}
+	if score < 0 || score > 1 {
+		return 0, fmt.Errorf("score %v is not between 0 and 1", score)
+	}
+	return score, nil
+}